@@ -0,0 +1,93 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/audit"
+	"github.com/spf13/cobra"
+)
+
+// newAuditCommand builds the `audit` command, for working with the audit log.
+func newAuditCommand(opt *Options) *cobra.Command {
+	auditCmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Work with the audit log of agent actions",
+	}
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify [path]",
+		Short: "Check that the audit log has not been modified",
+		Long:  "verify checks the hash chain of the audit log, reporting the first entry that was modified, removed or reordered. It defaults to the log configured with --audit-log (or the auditLog setting).",
+		Args:  cobra.MaximumNArgs(1),
+		// A failed verification is reported by the caller, and is not a usage error
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := opt.AuditLogPath
+			if len(args) == 1 {
+				path = args[0]
+			}
+			if path == "" {
+				return fmt.Errorf("no audit log given, and none is configured")
+			}
+
+			result, err := audit.VerifyFile(path)
+			if err != nil {
+				if result != nil {
+					return fmt.Errorf("audit log %q is not valid after %d entries: %w", path, result.Entries, err)
+				}
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "audit log %q is valid: %d entries, last hash %s\n", path, result.Entries, result.LastHash)
+			return nil
+		},
+	}
+	auditCmd.AddCommand(verifyCmd)
+
+	return auditCmd
+}
+
+// openAuditLog opens the audit log configured in opt, with its sinks, or returns nil if auditing is not enabled.
+func openAuditLog(opt Options) (*audit.Log, error) {
+	if opt.AuditLogPath == "" {
+		if opt.AuditSyslog || opt.AuditWebhook != "" {
+			return nil, fmt.Errorf("--audit-syslog and --audit-webhook require --audit-log")
+		}
+		return nil, nil
+	}
+
+	var sinks []audit.Sink
+	if opt.AuditSyslog {
+		sink, err := audit.NewSyslogSink("kubectl-ai")
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if opt.AuditWebhook != "" {
+		sinks = append(sinks, audit.NewWebhookSink(opt.AuditWebhook))
+	}
+
+	log, err := audit.Open(opt.AuditLogPath, sinks...)
+	if err != nil {
+		for _, sink := range sinks {
+			sink.Close()
+		}
+		return nil, err
+	}
+	return log, nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// FileContent is a text file passed to Chat.Send, such as a manifest the user attached to their query.
+// Providers send it as text, labelled with the file name.
+type FileContent struct {
+	Name string `json:"name,omitempty"`
+	Text string `json:"text"`
+}
+
+// AsText returns the text sent to the LLM for the file.
+func (c FileContent) AsText() string {
+	return fmt.Sprintf("Contents of attached file %q:\n```\n%s\n```\n", c.Name, strings.TrimSuffix(c.Text, "\n"))
+}
+
+// ImageContent is an image passed to Chat.Send, such as a screenshot the user attached to their query.
+// Not all models accept images; providers return an *UnsupportedContentError when they know the image cannot be sent.
+type ImageContent struct {
+	Name     string `json:"name,omitempty"`
+	MIMEType string `json:"mimeType"`
+	Data     []byte `json:"data"`
+}
+
+// DataURL returns the image encoded as a data: URL, as accepted by OpenAI-compatible APIs.
+func (c ImageContent) DataURL() string {
+	return "data:" + c.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(c.Data)
+}
+
+// UnsupportedContentError is returned by Send when the provider or model cannot accept a type of content.
+type UnsupportedContentError struct {
+	Provider    string
+	Model       string
+	ContentType string
+	// Err is the underlying error from the provider, if the provider rejected the content.
+	Err error
+}
+
+func (e *UnsupportedContentError) Error() string {
+	s := fmt.Sprintf("%s provider", e.Provider)
+	if e.Model != "" {
+		s += fmt.Sprintf(" (model %q)", e.Model)
+	}
+	s += fmt.Sprintf(" does not support %s content", e.ContentType)
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *UnsupportedContentError) Unwrap() error {
+	return e.Err
+}
+
+// containsImages returns true if any of the contents is an image.
+func containsImages(contents []any) bool {
+	for _, content := range contents {
+		if _, ok := content.(ImageContent); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isClientError returns true for 4xx HTTP status codes, which is how most APIs reject content a model cannot take.
+func isClientError(statusCode int) bool {
+	return statusCode >= 400 && statusCode < 500
+}
+
+// maxAttachmentSize limits the size of files we will attach, to avoid accidentally sending huge files to the LLM.
+const maxAttachmentSize = 20 * 1024 * 1024
+
+// LoadAttachment reads a file to be sent to the LLM, returning an ImageContent for images and a FileContent for text.
+func LoadAttachment(path string) (any, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading attachment: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("attachment %q is a directory", path)
+	}
+	if info.Size() > maxAttachmentSize {
+		return nil, fmt.Errorf("attachment %q is too large (%d bytes, the limit is %d)", path, info.Size(), maxAttachmentSize)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading attachment: %w", err)
+	}
+	name := filepath.Base(path)
+
+	mimeType := http.DetectContentType(b)
+	if strings.HasPrefix(mimeType, "image/") {
+		return ImageContent{Name: name, MIMEType: mimeType, Data: b}, nil
+	}
+	if !utf8.Valid(b) {
+		return nil, fmt.Errorf("attachment %q is neither text nor a supported image (detected %s)", path, mimeType)
+	}
+	return FileContent{Name: name, Text: string(b)}, nil
+}
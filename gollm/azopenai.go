@@ -17,13 +17,16 @@ package gollm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 )
 
@@ -37,6 +40,9 @@ func azureOpenAIFactory(ctx context.Context, u *url.URL) (Client, error) {
 
 type AzureOpenAIClient struct {
 	client *azopenai.Client
+
+	// generationConfig holds the generation settings, or nil to use the API defaults.
+	generationConfig *GenerationConfig
 }
 
 var _ Client = &AzureOpenAIClient{}
@@ -82,11 +88,17 @@ func (c *AzureOpenAIClient) Close() error {
 }
 
 func (c *AzureOpenAIClient) GenerateCompletion(ctx context.Context, request *CompletionRequest) (CompletionResponse, error) {
+	params := toAzureGenerationParams(c.generationConfig)
 	req := azopenai.ChatCompletionsOptions{
 		Messages: []azopenai.ChatRequestMessageClassification{
 			&azopenai.ChatRequestUserMessage{Content: azopenai.NewChatRequestUserMessageContent(request.Prompt)},
 		},
 		DeploymentName: &request.Model,
+		Temperature:    params.Temperature,
+		TopP:           params.TopP,
+		MaxTokens:      params.MaxTokens,
+		Stop:           params.Stop,
+		Seed:           params.Seed,
 	}
 
 	resp, err := c.client.GetChatCompletions(ctx, req, nil)
@@ -109,10 +121,40 @@ func (c *AzureOpenAIClient) SetResponseSchema(schema *Schema) error {
 	return nil
 }
 
+// SetGenerationConfig sets the generation settings.
+// Azure OpenAI has no equivalent of topK or a thinking budget, so those are ignored.
+func (c *AzureOpenAIClient) SetGenerationConfig(config *GenerationConfig) error {
+	c.generationConfig = config
+	return config.checkSupported("azopenai", GenerationSettingTopK, GenerationSettingThinkingBudget, GenerationSettingIncludeThoughts)
+}
+
+// azureGenerationParams holds the generation settings in the form used by both streaming and non-streaming requests.
+type azureGenerationParams struct {
+	Temperature *float32
+	TopP        *float32
+	MaxTokens   *int32
+	Stop        []string
+	Seed        *int64
+}
+
+func toAzureGenerationParams(config *GenerationConfig) azureGenerationParams {
+	if config == nil {
+		return azureGenerationParams{}
+	}
+	return azureGenerationParams{
+		Temperature: config.Temperature,
+		TopP:        config.TopP,
+		MaxTokens:   config.MaxOutputTokens,
+		Stop:        config.StopSequences,
+		Seed:        config.Seed,
+	}
+}
+
 func (c *AzureOpenAIClient) StartChat(systemPrompt string, model string) Chat {
 	return &AzureOpenAIChat{
 		client: c.client,
 		model:  model,
+		params: toAzureGenerationParams(c.generationConfig),
 		history: []azopenai.ChatRequestMessageClassification{
 			&azopenai.ChatRequestSystemMessage{Content: azopenai.NewChatRequestSystemMessageContent(systemPrompt)},
 		},
@@ -136,9 +178,83 @@ type AzureOpenAIChat struct {
 	model   string
 	history []azopenai.ChatRequestMessageClassification
 	tools   []azopenai.ChatCompletionsToolDefinitionClassification
+	params  azureGenerationParams
 }
 
 func (c *AzureOpenAIChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
+	historyLen := len(c.history)
+	if err := c.addContents(contents); err != nil {
+		c.history = c.history[:historyLen]
+		return nil, err
+	}
+
+	resp, err := c.client.GetChatCompletions(ctx, azopenai.ChatCompletionsOptions{
+		DeploymentName: &c.model,
+		Messages:       c.history,
+		Tools:          c.tools,
+		Temperature:    c.params.Temperature,
+		TopP:           c.params.TopP,
+		MaxTokens:      c.params.MaxTokens,
+		Stop:           c.params.Stop,
+		Seed:           c.params.Seed,
+	}, nil)
+	if err != nil {
+		return nil, c.requestFailed(historyLen, contents, err)
+	}
+	if len(resp.Choices) == 0 || resp.Choices[0].Message == nil {
+		c.history = c.history[:historyLen]
+		return nil, fmt.Errorf("no response from Azure OpenAI: %v", resp)
+	}
+	message := resp.Choices[0].Message
+	c.history = append(c.history, newAzureAssistantMessage(ptrValue(message.Content), message.ToolCalls))
+
+	return &AzureOpenAIChatResponse{azureOpenAIResponse: resp}, nil
+}
+
+// newAzureAssistantMessage builds the history entry for a response of the model.
+func newAzureAssistantMessage(content string, toolCalls []azopenai.ChatCompletionsToolCallClassification) *azopenai.ChatRequestAssistantMessage {
+	return &azopenai.ChatRequestAssistantMessage{
+		Content:   azopenai.NewChatRequestAssistantMessageContent(content),
+		ToolCalls: toolCalls,
+	}
+}
+
+// requestFailed drops the request from the history, so that a retry does not send it twice, and returns the error.
+// A client error for a request containing images is reported as an UnsupportedContentError, as most likely the
+// model does not accept images, and the chat can continue without them.
+func (c *AzureOpenAIChat) requestFailed(historyLen int, contents []any, err error) error {
+	c.history = c.history[:historyLen]
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) || !isClientError(respErr.StatusCode) || !containsImages(contents) {
+		return err
+	}
+	return &UnsupportedContentError{Provider: "azopenai", Model: c.model, ContentType: "image", Err: err}
+}
+
+func (c *AzureOpenAIChat) IsRetryableError(err error) bool {
+	return c.ClassifyError(err).Retryable
+}
+
+// ClassifyError classifies errors from Azure OpenAI, honoring the Retry-After headers it sends.
+func (c *AzureOpenAIChat) ClassifyError(err error) ErrorClassification {
+	if err == nil {
+		return ErrorClassification{}
+	}
+
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		classification := ClassifyHTTPStatus(respErr.StatusCode)
+		if respErr.RawResponse != nil {
+			classification.RetryAfter = ParseRetryAfterHeader(respErr.RawResponse.Header)
+		}
+		return classification
+	}
+
+	return DefaultClassifyError(err)
+}
+
+// addContents appends the user contents to the chat history.
+func (c *AzureOpenAIChat) addContents(contents []any) error {
 	for _, content := range contents {
 		switch v := content.(type) {
 		case string:
@@ -146,43 +262,183 @@ func (c *AzureOpenAIChat) Send(ctx context.Context, contents ...any) (ChatRespon
 				Content: azopenai.NewChatRequestUserMessageContent(v),
 			}
 			c.history = append(c.history, &message)
-		case FunctionCallResult:
+		case FileContent:
 			message := azopenai.ChatRequestUserMessage{
-				Content: azopenai.NewChatRequestUserMessageContent(fmt.Sprintf("Function call result: %s", v.Result)),
+				Content: azopenai.NewChatRequestUserMessageContent(v.AsText()),
 			}
 			c.history = append(c.history, &message)
+		case ImageContent:
+			message := azopenai.ChatRequestUserMessage{
+				Content: azopenai.NewChatRequestUserMessageContent([]azopenai.ChatCompletionRequestMessageContentPartClassification{
+					&azopenai.ChatCompletionRequestMessageContentPartImage{
+						ImageURL: &azopenai.ChatCompletionRequestMessageContentPartImageURL{URL: ptrTo(v.DataURL())},
+					},
+				}),
+			}
+			c.history = append(c.history, &message)
+		case FunctionCallResult:
+			if v.ID == "" {
+				message := azopenai.ChatRequestUserMessage{
+					Content: azopenai.NewChatRequestUserMessageContent(fmt.Sprintf("Function call result: %s", v.Result)),
+				}
+				c.history = append(c.history, &message)
+				continue
+			}
+			// The result answers a tool call in the history, which the API requires to be matched by its ID
+			result, err := json.Marshal(v.Result)
+			if err != nil {
+				return fmt.Errorf("marshalling result of function call %q: %w", v.Name, err)
+			}
+			c.history = append(c.history, &azopenai.ChatRequestToolMessage{
+				Content:    azopenai.NewChatRequestToolMessageContent(string(result)),
+				ToolCallID: ptrTo(v.ID),
+			})
 		default:
-			return nil, fmt.Errorf("unsupported content type: %T", v)
+			return fmt.Errorf("unsupported content type: %T", v)
 		}
 	}
+	return nil
+}
 
-	resp, err := c.client.GetChatCompletions(ctx, azopenai.ChatCompletionsOptions{
+func (c *AzureOpenAIChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
+	historyLen := len(c.history)
+	if err := c.addContents(contents); err != nil {
+		c.history = c.history[:historyLen]
+		return nil, err
+	}
+
+	// We read the events ourselves, as the SDK drops the index of each tool call delta
+	var httpResponse *http.Response
+	resp, err := c.client.GetChatCompletionsStream(policy.WithCaptureResponse(ctx, &httpResponse), azopenai.ChatCompletionsStreamOptions{
 		DeploymentName: &c.model,
 		Messages:       c.history,
 		Tools:          c.tools,
+		Temperature:    c.params.Temperature,
+		TopP:           c.params.TopP,
+		MaxTokens:      c.params.MaxTokens,
+		Stop:           c.params.Stop,
+		Seed:           c.params.Seed,
 	}, nil)
 	if err != nil {
-		return nil, err
+		return nil, c.requestFailed(historyLen, contents, err)
 	}
-	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from Azure OpenAI: %v", resp)
+	if httpResponse == nil {
+		resp.ChatCompletionsStream.Close()
+		c.history = c.history[:historyLen]
+		return nil, fmt.Errorf("no response from Azure OpenAI")
 	}
 
-	return &AzureOpenAIChatResponse{azureOpenAIResponse: resp}, nil
+	return func(yield func(ChatResponse, error) bool) {
+		defer resp.ChatCompletionsStream.Close()
+
+		var content strings.Builder
+		// Tool calls arrive as deltas, keyed by index: the first delta of each call carries its ID and name,
+		// later deltas carry fragments of the JSON arguments.
+		var toolCalls []*azopenai.ChatCompletionsFunctionToolCall
+
+		onEvent := func(data []byte) error {
+			var chunk azopenai.ChatCompletions
+			if err := json.Unmarshal(data, &chunk); err != nil {
+				return fmt.Errorf("unmarshalling streaming chunk: %w", err)
+			}
+			var indexes azureStreamingIndexes
+			if err := json.Unmarshal(data, &indexes); err != nil {
+				return fmt.Errorf("unmarshalling streaming chunk: %w", err)
+			}
+
+			for i, choice := range chunk.Choices {
+				if (choice.Index != nil && *choice.Index != 0) || choice.Delta == nil {
+					continue
+				}
+				for j, delta := range choice.Delta.ToolCalls {
+					functionDelta, ok := delta.(*azopenai.ChatCompletionsFunctionToolCall)
+					if !ok || functionDelta.Function == nil {
+						continue
+					}
+					index := indexes.toolCallIndex(i, j)
+					for len(toolCalls) <= index {
+						toolCalls = append(toolCalls, &azopenai.ChatCompletionsFunctionToolCall{
+							Type:     ptrTo("function"),
+							Function: &azopenai.FunctionCall{Name: ptrTo(""), Arguments: ptrTo("")},
+						})
+					}
+					toolCall := toolCalls[index]
+					if functionDelta.ID != nil && *functionDelta.ID != "" {
+						toolCall.ID = functionDelta.ID
+					}
+					if functionDelta.Function.Name != nil {
+						*toolCall.Function.Name += *functionDelta.Function.Name
+					}
+					if functionDelta.Function.Arguments != nil {
+						*toolCall.Function.Arguments += *functionDelta.Function.Arguments
+					}
+				}
+
+				if choice.Delta.Content != nil && *choice.Delta.Content != "" {
+					content.WriteString(*choice.Delta.Content)
+					if !yield(newAzureOpenAIStreamingResponse(chunk, &azopenai.ChatResponseMessage{Content: choice.Delta.Content}), nil) {
+						return errStopStreaming
+					}
+				}
+			}
+			return nil
+		}
+
+		err := readServerSentEvents(httpResponse.Body, onEvent)
+		if err != nil && !errors.Is(err, errStopStreaming) {
+			// The request is dropped from the history, so that a retry does not send it twice
+			c.history = c.history[:historyLen]
+			yield(nil, err)
+			return
+		}
+
+		var assembled []azopenai.ChatCompletionsToolCallClassification
+		for _, toolCall := range toolCalls {
+			assembled = append(assembled, toolCall)
+		}
+		if content.Len() != 0 || len(assembled) != 0 {
+			c.history = append(c.history, newAzureAssistantMessage(content.String(), assembled))
+		}
+		if err != nil {
+			return
+		}
+
+		// We only know the function calls are complete once the stream ends, so we send them last.
+		if len(assembled) != 0 {
+			yield(newAzureOpenAIStreamingResponse(azopenai.ChatCompletions{}, &azopenai.ChatResponseMessage{ToolCalls: assembled}), nil)
+		}
+	}, nil
 }
 
-func (c *AzureOpenAIChat) IsRetryableError(err error) bool {
-	// TODO: Implement this
-	return false
+// azureStreamingIndexes holds the index of each tool call delta in a streaming chunk, which the SDK does not parse;
+// deltas with the same index are fragments of the same call.
+type azureStreamingIndexes struct {
+	Choices []struct {
+		Delta *struct {
+			ToolCalls []struct {
+				Index *int `json:"index"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
 }
 
-func (c *AzureOpenAIChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
-	// TODO: Implement streaming
-	response, err := c.Send(ctx, contents...)
-	if err != nil {
-		return nil, err
+// toolCallIndex returns the index of the jth tool call delta of the ith choice, or j if the chunk does not say.
+func (s *azureStreamingIndexes) toolCallIndex(i, j int) int {
+	if i >= len(s.Choices) || s.Choices[i].Delta == nil || j >= len(s.Choices[i].Delta.ToolCalls) {
+		return j
+	}
+	if index := s.Choices[i].Delta.ToolCalls[j].Index; index != nil && *index >= 0 {
+		return *index
+	}
+	return j
+}
+
+// newAzureOpenAIStreamingResponse wraps the (assembled) message from a streaming chunk as a ChatResponse.
+func newAzureOpenAIStreamingResponse(chunk azopenai.ChatCompletions, message *azopenai.ChatResponseMessage) *AzureOpenAIChatResponse {
+	chunk.Choices = []azopenai.ChatChoice{{Index: ptrTo(int32(0)), Message: message}}
+	return &AzureOpenAIChatResponse{
+		azureOpenAIResponse: azopenai.GetChatCompletionsResponse{ChatCompletions: chunk},
 	}
-	return singletonChatResponseIterator(response), nil
 }
 
 type AzureOpenAIChatResponse struct {
@@ -244,19 +500,22 @@ func (r *AzureOpenAICandidate) String() string {
 
 func (r *AzureOpenAICandidate) Parts() []Part {
 	var parts []Part
-
-	if r.candidate.Message != nil {
-		parts = append(parts, &AzureOpenAIPart{
-			text: r.candidate.Message.Content,
-		})
+	if r.candidate.Message == nil {
+		return parts
 	}
 
+	parts = append(parts, &AzureOpenAIPart{
+		text: r.candidate.Message.Content,
+	})
+
 	for _, tool := range r.candidate.Message.ToolCalls {
-		if tool == nil {
+		functionCall, ok := tool.(*azopenai.ChatCompletionsFunctionToolCall)
+		if !ok || functionCall == nil {
 			continue
 		}
 		parts = append(parts, &AzureOpenAIPart{
-			functionCall: tool.(*azopenai.ChatCompletionsFunctionToolCall).Function,
+			functionCallID: functionCall.ID,
+			functionCall:   functionCall.Function,
 		})
 	}
 
@@ -264,8 +523,9 @@ func (r *AzureOpenAICandidate) Parts() []Part {
 }
 
 type AzureOpenAIPart struct {
-	text         *string
-	functionCall *azopenai.FunctionCall
+	text           *string
+	functionCallID *string
+	functionCall   *azopenai.FunctionCall
 }
 
 func (p *AzureOpenAIPart) AsText() (string, bool) {
@@ -275,6 +535,11 @@ func (p *AzureOpenAIPart) AsText() (string, bool) {
 	return "", false
 }
 
+// AsThinking always returns false; Azure OpenAI does not return the model's reasoning.
+func (p *AzureOpenAIPart) AsThinking() (string, bool) {
+	return "", false
+}
+
 func (p *AzureOpenAIPart) AsFunctionCalls() ([]FunctionCall, bool) {
 	if p.functionCall != nil {
 		argumentsObj := map[string]any{}
@@ -284,6 +549,7 @@ func (p *AzureOpenAIPart) AsFunctionCalls() ([]FunctionCall, bool) {
 		}
 		functionCalls := []FunctionCall{
 			{
+				ID:        ptrValue(p.functionCallID),
 				Name:      *p.functionCall.Name,
 				Arguments: argumentsObj,
 			},
@@ -303,19 +569,9 @@ func (c *AzureOpenAIChat) SetFunctionDefinitions(functionDefinitions []*Function
 }
 
 func fnDefToAzureOpenAITool(fnDef *FunctionDefinition) *azopenai.ChatCompletionsFunctionToolDefinitionFunction {
-	properties := make(map[string]any)
-	for paramName, param := range fnDef.Parameters.Properties {
-		properties[paramName] = map[string]any{
-			"type":        string(param.Type),
-			"description": param.Description,
-		}
-	}
-	parameters := map[string]any{
-		"type":       "object",
-		"properties": properties,
-	}
-	if len(fnDef.Parameters.Required) > 0 {
-		parameters["required"] = fnDef.Parameters.Required
+	parameters := fnDef.Parameters.ToJSONSchema()
+	if parameters == nil {
+		parameters = map[string]any{"type": "object", "properties": map[string]any{}}
 	}
 	jsonBytes, _ := json.Marshal(parameters)
 
@@ -0,0 +1,173 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// azureMessage is a message of a chat completions request, as sent to the server.
+type azureMessage struct {
+	Role       string `json:"role"`
+	Content    string `json:"content"`
+	ToolCallID string `json:"tool_call_id"`
+	ToolCalls  []struct {
+		ID       string `json:"id"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls"`
+}
+
+// newAzureOpenAITestServer returns a client for a server that answers chat completion requests with the responses
+// in order (JSON, or server-sent events for streaming requests), and the messages of the requests it received.
+func newAzureOpenAITestServer(t *testing.T, responses ...string) (*AzureOpenAIClient, *[][]azureMessage) {
+	t.Helper()
+
+	var requests [][]azureMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Messages []azureMessage `json:"messages"`
+			Stream   bool           `json:"stream"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		requests = append(requests, req.Messages)
+		if len(requests) > len(responses) {
+			http.Error(w, "no more responses", http.StatusInternalServerError)
+			return
+		}
+		if req.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		fmt.Fprint(w, responses[len(requests)-1])
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := azopenai.NewClientWithKeyCredential(server.URL, azcore.NewKeyCredential("key"), &azopenai.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			InsecureAllowCredentialWithHTTP: true,
+			Retry:                           policy.RetryOptions{MaxRetries: -1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &AzureOpenAIClient{client: client}, &requests
+}
+
+// azureRoles returns the roles of the messages.
+func azureRoles(messages []azureMessage) string {
+	var roles []string
+	for _, message := range messages {
+		roles = append(roles, message.Role)
+	}
+	return strings.Join(roles, ",")
+}
+
+func TestAzureOpenAIChatSendStreaming(t *testing.T) {
+	client, requests := newAzureOpenAITestServer(t,
+		sseBody(splitToolCallEvents...),
+		sseBody(`{"choices":[{"index":0,"delta":{"content":"There are "}}]}`, `{"choices":[{"index":0,"delta":{"content":"no pods."}}]}`),
+	)
+	chat := client.StartChat("system", "model")
+
+	stream, err := chat.SendStreaming(context.Background(), "what is running?")
+	if err != nil {
+		t.Fatalf("SendStreaming failed: %v", err)
+	}
+	text, calls, err := readStreamAndCalls(stream)
+	if err != nil {
+		t.Fatalf("reading stream: %v", err)
+	}
+	if text != "Let me check." {
+		t.Errorf("got text %q, want %q", text, "Let me check.")
+	}
+	if !reflect.DeepEqual(calls, wantSplitToolCalls) {
+		t.Errorf("got function calls %+v, want %+v", calls, wantSplitToolCalls)
+	}
+
+	stream, err = chat.SendStreaming(context.Background(),
+		FunctionCallResult{ID: "call-1", Name: "kubectl", Result: map[string]any{"stdout": "No resources found"}},
+		FunctionCallResult{ID: "call-2", Name: "bash", Result: map[string]any{"stdout": ""}},
+	)
+	if err != nil {
+		t.Fatalf("SendStreaming failed: %v", err)
+	}
+	if text, _, err := readStreamAndCalls(stream); err != nil || text != "There are no pods." {
+		t.Fatalf("got (%q, %v) from the second stream, want the text", text, err)
+	}
+
+	// The second request carries the assembled response, and the results answer its calls
+	messages := (*requests)[1]
+	if got, want := azureRoles(messages), "system,user,assistant,tool,tool"; got != want {
+		t.Fatalf("got roles %q in the second request, want %q", got, want)
+	}
+	assistant := messages[2]
+	if assistant.Content != "Let me check." || len(assistant.ToolCalls) != 2 {
+		t.Fatalf("got assistant message %+v, want the text and both tool calls", assistant)
+	}
+	if call := assistant.ToolCalls[1]; call.ID != "call-2" || call.Function.Name != "bash" || call.Function.Arguments != `{"command":"ls"}` {
+		t.Errorf("got second tool call %+v, want bash with its arguments reassembled", call)
+	}
+	if messages[3].ToolCallID != "call-1" || messages[4].ToolCallID != "call-2" {
+		t.Errorf("got tool messages for %q and %q, want call-1 and call-2", messages[3].ToolCallID, messages[4].ToolCallID)
+	}
+
+	// The final response is kept in the history for the next request
+	if got := len(chat.(*AzureOpenAIChat).history); got != 6 {
+		t.Errorf("got %d messages in the history, want 6", got)
+	}
+}
+
+func TestAzureOpenAIChatSendKeepsResponseInHistory(t *testing.T) {
+	client, requests := newAzureOpenAITestServer(t,
+		`{"choices":[{"index":0,"message":{"role":"assistant","content":"Hello."}}]}`,
+		`{"choices":[{"index":0,"message":{"role":"assistant","content":"Fine."}}]}`,
+	)
+	chat := client.StartChat("system", "model")
+
+	for _, query := range []string{"hi", "how are you?"} {
+		if _, err := chat.Send(context.Background(), query); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	messages := (*requests)[1]
+	if got, want := azureRoles(messages), "system,user,assistant,user"; got != want {
+		t.Fatalf("got roles %q in the second request, want %q", got, want)
+	}
+	if messages[2].Content != "Hello." {
+		t.Errorf("got assistant message %q, want the first response", messages[2].Content)
+	}
+}
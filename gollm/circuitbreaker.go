@@ -0,0 +1,93 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// CircuitBreaker pauses calls to an LLM after repeated quota (429) errors.
+// Once Threshold consecutive quota errors are seen, the breaker "opens" and
+// callers block in Wait until the cooldown has passed.
+type CircuitBreaker struct {
+	// Threshold is the number of consecutive quota errors that open the breaker.
+	Threshold int
+	// Cooldown is how long the breaker stays open, unless the provider asked for a longer delay.
+	Cooldown time.Duration
+
+	mutex               sync.Mutex
+	consecutiveFailures int
+	openUntil           time.Time
+}
+
+// NewCircuitBreaker builds a CircuitBreaker with the given threshold and cooldown.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+	}
+}
+
+// Wait blocks until the breaker is closed, or the context is cancelled.
+func (b *CircuitBreaker) Wait(ctx context.Context) error {
+	b.mutex.Lock()
+	openUntil := b.openUntil
+	b.mutex.Unlock()
+
+	waitTime := time.Until(openUntil)
+	if waitTime <= 0 {
+		return nil
+	}
+
+	klog.FromContext(ctx).Info("circuit breaker is open, pausing LLM calls", "waitTime", waitTime)
+	select {
+	case <-time.After(waitTime):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RecordSuccess resets the count of consecutive quota errors.
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.consecutiveFailures = 0
+}
+
+// RecordQuotaError records a quota error, and returns true if the breaker is now open.
+// retryAfter is the delay suggested by the provider, if any.
+func (b *CircuitBreaker) RecordQuotaError(retryAfter time.Duration) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.consecutiveFailures++
+	if b.consecutiveFailures < b.Threshold {
+		return false
+	}
+
+	cooldown := b.Cooldown
+	if retryAfter > cooldown {
+		cooldown = retryAfter
+	}
+	b.openUntil = time.Now().Add(cooldown)
+	b.consecutiveFailures = 0
+	return true
+}
@@ -18,11 +18,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"iter"
 	"math/rand/v2"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -95,6 +97,9 @@ type APIError struct {
 	StatusCode int
 	Message    string
 	Err        error
+
+	// RetryAfter is the delay suggested by the server before retrying, if any.
+	RetryAfter time.Duration
 }
 
 func (e *APIError) Error() string {
@@ -108,38 +113,115 @@ func (e *APIError) Unwrap() error {
 	return e.Err
 }
 
+// ErrorClassification describes how an error from an LLM should be handled.
+type ErrorClassification struct {
+	// Retryable is true if the operation may succeed if retried.
+	Retryable bool
+	// RetryAfter is the delay the provider asked us to wait before retrying, if any.
+	RetryAfter time.Duration
+	// IsQuota is true if the error indicates we are rate-limited or out of quota.
+	IsQuota bool
+	// IsAuth is true if the error indicates missing or invalid credentials.
+	IsAuth bool
+}
+
+// ErrorClassifier is implemented by Chats that can classify errors in more detail than IsRetryableError,
+// for example to relay the backoff delay suggested by the provider.
+type ErrorClassifier interface {
+	ClassifyError(err error) ErrorClassification
+}
+
 // IsRetryableFunc defines the signature for functions that check if an error is retryable.
-// TODO (droot): Adjust the signature to allow underlying client to relay the backoff
-// delay etc. for example, Gemini's error codes contain retryDelay information.
 type IsRetryableFunc func(error) bool
 
+// ClassifyErrorFunc defines the signature for functions that classify an error.
+type ClassifyErrorFunc func(error) ErrorClassification
+
+// ClassifierFromIsRetryable adapts an IsRetryableFunc to a ClassifyErrorFunc.
+func ClassifierFromIsRetryable(isRetryable IsRetryableFunc) ClassifyErrorFunc {
+	return func(err error) ErrorClassification {
+		return ErrorClassification{Retryable: isRetryable(err)}
+	}
+}
+
 // DefaultIsRetryableError provides a default implementation based on common HTTP codes and network errors.
 func DefaultIsRetryableError(err error) bool {
+	return DefaultClassifyError(err).Retryable
+}
+
+// DefaultClassifyError provides a default classification based on common HTTP codes and network errors.
+func DefaultClassifyError(err error) ErrorClassification {
 	if err == nil {
-		return false
+		return ErrorClassification{}
+	}
+
+	var replayed *ReplayedError
+	if errors.As(err, &replayed) {
+		return replayed.Classification
 	}
 
 	var apiErr *APIError
 	if errors.As(err, &apiErr) {
-		switch apiErr.StatusCode {
-		case http.StatusConflict, http.StatusTooManyRequests,
-			http.StatusInternalServerError, http.StatusBadGateway,
-			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
-			return true
-		default:
-			return false
-		}
+		classification := ClassifyHTTPStatus(apiErr.StatusCode)
+		classification.RetryAfter = apiErr.RetryAfter
+		return classification
 	}
 
 	var netErr net.Error
 	if errors.As(err, &netErr) && netErr.Timeout() {
-		return true
+		return ErrorClassification{Retryable: true}
 	}
 
 	// Add other error checks specific to LLM clients if needed
 	// e.g., if errors.Is(err, specificLLMRateLimitError) { return true }
 
-	return false
+	return ErrorClassification{}
+}
+
+// ClassifyHTTPStatus classifies an error based on the HTTP status code alone.
+func ClassifyHTTPStatus(statusCode int) ErrorClassification {
+	switch statusCode {
+	case http.StatusTooManyRequests:
+		return ErrorClassification{Retryable: true, IsQuota: true}
+	case http.StatusConflict,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ErrorClassification{Retryable: true}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrorClassification{IsAuth: true}
+	default:
+		return ErrorClassification{}
+	}
+}
+
+// ParseRetryAfterHeader parses the Retry-After (or retry-after-ms) header of an HTTP response.
+// It returns zero if the header is not present or cannot be parsed.
+func ParseRetryAfterHeader(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+	// retry-after-ms is a non-standard header sent by OpenAI and Azure.
+	if v := header.Get("retry-after-ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	v := header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
 }
 
 // RetryConfig holds the configuration for the retry mechanism (same as before)
@@ -149,6 +231,11 @@ type RetryConfig struct {
 	MaxBackoff     time.Duration
 	BackoffFactor  float64
 	Jitter         bool
+
+	// CircuitBreaker, if set, pauses all calls after repeated quota errors,
+	// instead of spending retry attempts on them.
+	// It can be shared between chats so that they back off together.
+	CircuitBreaker *CircuitBreaker
 }
 
 // DefaultRetryConfig provides sensible defaults (same as before)
@@ -162,10 +249,11 @@ var DefaultRetryConfig = RetryConfig{
 
 // Retry executes the provided operation with retries, returning the result and error.
 // It's now generic to handle any return type T.
+// If the error classification includes a RetryAfter delay, we wait at least that long before the next attempt.
 func Retry[T any](
 	ctx context.Context,
 	config RetryConfig,
-	isRetryable IsRetryableFunc,
+	classify ClassifyErrorFunc,
 	operation func(ctx context.Context) (T, error),
 ) (T, error) {
 	var lastErr error
@@ -174,13 +262,27 @@ func Retry[T any](
 	log := klog.FromContext(ctx)
 
 	backoff := config.InitialBackoff
+	breaker := config.CircuitBreaker
+
+	// pauses counts the attempts that tripped the circuit breaker;
+	// these are not counted against MaxAttempts, but we bound them so we don't wait forever.
+	pauses := 0
 
 	for attempt := 1; attempt <= config.MaxAttempts; attempt++ {
+		if breaker != nil {
+			if err := breaker.Wait(ctx); err != nil {
+				return zero, err
+			}
+		}
+
 		// log.Printf("Executing operation, attempt %d of %d", attempt, config.MaxAttempts) // Optional verbose log
 		result, err := operation(ctx)
 
 		if err == nil {
 			// Success
+			if breaker != nil {
+				breaker.RecordSuccess()
+			}
 			return result, nil
 		}
 		lastErr = err // Store the last error encountered
@@ -194,12 +296,23 @@ func Retry[T any](
 			// Context not cancelled, proceed with error checking
 		}
 
-		if !isRetryable(lastErr) {
-			log.Info("Attempt failed with non-retryable error", "attempt", attempt, "error", lastErr)
+		classification := classify(lastErr)
+		if !classification.Retryable {
+			log.Info("Attempt failed with non-retryable error", "attempt", attempt, "error", lastErr, "auth", classification.IsAuth)
 			return zero, lastErr // Return the non-retryable error immediately
 		}
 
-		log.Info("Attempt failed with retryable error", "attempt", attempt, "error", lastErr)
+		log.Info("Attempt failed with retryable error", "attempt", attempt, "error", lastErr, "retryAfter", classification.RetryAfter, "quota", classification.IsQuota)
+
+		if classification.IsQuota && breaker != nil {
+			if breaker.RecordQuotaError(classification.RetryAfter) && pauses < config.MaxAttempts {
+				// The breaker is now open; the next iteration will wait for it,
+				// and this attempt does not count.
+				pauses++
+				attempt--
+				continue
+			}
+		}
 
 		if attempt == config.MaxAttempts {
 			// Max attempts reached
@@ -211,6 +324,10 @@ func Retry[T any](
 		if config.Jitter {
 			waitTime += time.Duration(rand.Float64() * float64(backoff) / 2)
 		}
+		// Honor the delay suggested by the provider
+		if classification.RetryAfter > waitTime {
+			waitTime = classification.RetryAfter
+		}
 
 		log.Info("Waiting before next attempt", "waitTime", waitTime, "attempt", attempt+1, "maxAttempts", config.MaxAttempts)
 
@@ -237,9 +354,8 @@ func Retry[T any](
 
 // retryChat is a generic decorator that adds retry logic to any Chat implementation.
 type retryChat[C Chat] struct {
-	underlying  Chat // The actual client implementation being wrapped
-	config      RetryConfig
-	isRetryable IsRetryableFunc
+	underlying Chat // The actual client implementation being wrapped
+	config     RetryConfig
 }
 
 // NewRetryChat creates a new Chat that wraps the given underlying client
@@ -264,13 +380,47 @@ func (rc *retryChat[C]) Send(ctx context.Context, contents ...any) (ChatResponse
 	}
 
 	// Execute with retry
-	return Retry[ChatResponse](ctx, rc.config, rc.underlying.IsRetryableError, operation)
+	return Retry[ChatResponse](ctx, rc.config, rc.ClassifyError, operation)
 }
 
-// Embed implements the Client interface for the retryClient decorator.
+// SendStreaming implements the Chat interface for the retryChat decorator.
+// Errors starting the stream, or from its first step, are retried (and count towards the circuit breaker);
+// once a response has been passed to the caller, errors are returned as they are, as the caller has already
+// seen part of the response.
 func (rc *retryChat[C]) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
-	// TODO: Retry logic
-	return rc.underlying.SendStreaming(ctx, contents...)
+	operation := func(ctx context.Context) (ChatResponseIterator, error) {
+		stream, err := rc.underlying.SendStreaming(ctx, contents...)
+		if err != nil {
+			return nil, err
+		}
+
+		// Read the first response, so that an error from the first step can be retried
+		next, stop := iter.Pull2(iter.Seq2[ChatResponse, error](stream))
+		first, err, ok := next()
+		if !ok {
+			stop()
+			return func(yield func(ChatResponse, error) bool) {}, nil
+		}
+		if err != nil {
+			stop()
+			return nil, err
+		}
+
+		return func(yield func(ChatResponse, error) bool) {
+			defer stop()
+			if !yield(first, nil) {
+				return
+			}
+			for {
+				response, err, ok := next()
+				if !ok || !yield(response, err) {
+					return
+				}
+			}
+		}, nil
+	}
+
+	return Retry[ChatResponseIterator](ctx, rc.config, rc.ClassifyError, operation)
 }
 
 func (rc *retryChat[C]) SetFunctionDefinitions(functionDefinitions []*FunctionDefinition) error {
@@ -280,3 +430,11 @@ func (rc *retryChat[C]) SetFunctionDefinitions(functionDefinitions []*FunctionDe
 func (rc *retryChat[C]) IsRetryableError(err error) bool {
 	return rc.underlying.IsRetryableError(err)
 }
+
+// ClassifyError uses the underlying ErrorClassifier if it has one, falling back to IsRetryableError.
+func (rc *retryChat[C]) ClassifyError(err error) ErrorClassification {
+	if classifier, ok := rc.underlying.(ErrorClassifier); ok {
+		return classifier.ClassifyError(err)
+	}
+	return ErrorClassification{Retryable: rc.underlying.IsRetryableError(err)}
+}
@@ -0,0 +1,295 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRetryConfig retries quickly, so that tests do not wait on backoff.
+func testRetryConfig(breaker *CircuitBreaker) RetryConfig {
+	return RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		BackoffFactor:  1,
+		CircuitBreaker: breaker,
+	}
+}
+
+// readStream returns the text of the responses in the stream, and the first error it returned.
+func readStream(stream ChatResponseIterator) (string, error) {
+	var sb strings.Builder
+	for response, err := range stream {
+		if err != nil {
+			return sb.String(), err
+		}
+		for _, candidate := range response.Candidates() {
+			for _, part := range candidate.Parts() {
+				if text, ok := part.AsText(); ok {
+					sb.WriteString(text)
+				}
+			}
+		}
+	}
+	return sb.String(), nil
+}
+
+// readStreamAndCalls returns the text and function calls of the responses in the stream,
+// and the first error it returned.
+func readStreamAndCalls(stream ChatResponseIterator) (string, []FunctionCall, error) {
+	var sb strings.Builder
+	var calls []FunctionCall
+	for response, err := range stream {
+		if err != nil {
+			return sb.String(), calls, err
+		}
+		for _, candidate := range response.Candidates() {
+			for _, part := range candidate.Parts() {
+				if text, ok := part.AsText(); ok {
+					sb.WriteString(text)
+				}
+				if functionCalls, ok := part.AsFunctionCalls(); ok {
+					calls = append(calls, functionCalls...)
+				}
+			}
+		}
+	}
+	return sb.String(), calls, nil
+}
+
+// sseBody formats the events as a server-sent event stream, ending with [DONE].
+func sseBody(events ...string) string {
+	var sb strings.Builder
+	for _, event := range events {
+		fmt.Fprintf(&sb, "data: %s\n\n", event)
+	}
+	sb.WriteString("data: [DONE]\n\n")
+	return sb.String()
+}
+
+// splitToolCallEvents are streaming chunks in the OpenAI format with text, followed by two tool calls whose
+// deltas are interleaved; only the first delta of each call has its ID and name.
+var splitToolCallEvents = []string{
+	`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Let me "}}]}`,
+	`{"choices":[{"index":0,"delta":{"content":"check."}}]}`,
+	`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call-1","type":"function","function":{"name":"kubectl","arguments":""}}]}}]}`,
+	`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call-2","type":"function","function":{"name":"bash","arguments":"{\"comm"}}]}}]}`,
+	`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"command\":\"kubectl get pods\"}"}}]}}]}`,
+	`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"and\":\"ls\"}"}}]}}]}`,
+	`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+}
+
+// wantSplitToolCalls are the calls assembled from splitToolCallEvents.
+var wantSplitToolCalls = []FunctionCall{
+	{ID: "call-1", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get pods"}},
+	{ID: "call-2", Name: "bash", Arguments: map[string]any{"command": "ls"}},
+}
+
+func TestRetryChatSendStreaming(t *testing.T) {
+	unavailable := &FakeError{StatusCode: 503, Message: "unavailable"}
+
+	tests := []struct {
+		name  string
+		turns []*FakeTurn
+		// wantText is the text read from the stream, and wantErr the status code of the error it returned, if any.
+		wantText string
+		wantErr  int
+		// wantRemaining is the number of turns of the script that were not used.
+		wantRemaining int
+	}{
+		{
+			name:     "error starting the stream is retried",
+			turns:    []*FakeTurn{{Error: unavailable}, {Chunks: []string{"a", "b"}}},
+			wantText: "ab",
+		},
+		{
+			name:     "error from the first step is retried",
+			turns:    []*FakeTurn{{StreamError: unavailable}, {Chunks: []string{"a", "b"}}},
+			wantText: "ab",
+		},
+		{
+			name:          "error after the first response is not retried",
+			turns:         []*FakeTurn{{Chunks: []string{"a"}, StreamError: unavailable}, {Chunks: []string{"b"}}},
+			wantText:      "a",
+			wantErr:       503,
+			wantRemaining: 1,
+		},
+		{
+			name:          "non-retryable error is not retried",
+			turns:         []*FakeTurn{{Error: &FakeError{StatusCode: 400, Message: "bad request"}}, {Text: "a"}},
+			wantErr:       400,
+			wantRemaining: 1,
+		},
+		{
+			name:    "gives up after MaxAttempts",
+			turns:   []*FakeTurn{{Error: unavailable}, {StreamError: unavailable}, {Error: unavailable}, {Text: "a"}},
+			wantErr: 503,
+			// The fourth turn is not used
+			wantRemaining: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := NewFakeClient(&FakeScript{Turns: test.turns})
+			chat := NewRetryChat(client.StartChat("", ""), testRetryConfig(nil))
+
+			text, err := func() (string, error) {
+				stream, err := chat.SendStreaming(ctx, "hello")
+				if err != nil {
+					return "", err
+				}
+				return readStream(stream)
+			}()
+
+			if text != test.wantText {
+				t.Errorf("got text %q, want %q", text, test.wantText)
+			}
+			var apiErr *APIError
+			switch {
+			case test.wantErr == 0 && err != nil:
+				t.Errorf("got error %v, want none", err)
+			case test.wantErr != 0 && (!errors.As(err, &apiErr) || apiErr.StatusCode != test.wantErr):
+				t.Errorf("got error %v, want status code %d", err, test.wantErr)
+			}
+			if got := client.Remaining(); got != test.wantRemaining {
+				t.Errorf("got %d remaining turns, want %d", got, test.wantRemaining)
+			}
+		})
+	}
+}
+
+func TestRetryChatSendStreamingCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	quota := &FakeError{StatusCode: 429, Message: "quota exceeded"}
+	client := NewFakeClient(&FakeScript{Turns: []*FakeTurn{
+		{Error: quota},
+		{StreamError: quota},
+		{Chunks: []string{"ok"}},
+	}})
+	cooldown := 200 * time.Millisecond
+	breaker := NewCircuitBreaker(2, cooldown)
+	chat := NewRetryChat(client.StartChat("", ""), testRetryConfig(breaker))
+
+	start := time.Now()
+	stream, err := chat.SendStreaming(ctx, "hello")
+	if err != nil {
+		t.Fatalf("SendStreaming failed: %v", err)
+	}
+	text, err := readStream(stream)
+	if err != nil {
+		t.Fatalf("reading stream failed: %v", err)
+	}
+	if text != "ok" {
+		t.Errorf("got text %q, want %q", text, "ok")
+	}
+	// The second quota error opens the breaker, so the third attempt waits for the cooldown
+	if elapsed := time.Since(start); elapsed < cooldown {
+		t.Errorf("got response after %s, want the breaker to pause calls for %s", elapsed, cooldown)
+	}
+	if client.Remaining() != 0 {
+		t.Errorf("got %d remaining turns, want 0", client.Remaining())
+	}
+
+	// A success closes the breaker again, so the next call does not wait
+	breaker.mutex.Lock()
+	failures := breaker.consecutiveFailures
+	breaker.mutex.Unlock()
+	if failures != 0 {
+		t.Errorf("got %d consecutive failures after a success, want 0", failures)
+	}
+}
+
+// newFlakyLlamaCppServer serves llama.cpp chat completions, failing the first request with a 503.
+// It records the messages of each request.
+func newFlakyLlamaCppServer(t *testing.T) (*LlamaCppClient, *[][]llamacppChatMessage) {
+	t.Helper()
+
+	var requests [][]llamacppChatMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llamacppChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		requests = append(requests, req.Messages)
+		if len(requests) == 1 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		if req.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hello\"}}]}\n\ndata: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"hello"}}]}`)
+	}))
+	t.Cleanup(server.Close)
+
+	baseURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &LlamaCppClient{baseURL: baseURL, httpClient: server.Client()}, &requests
+}
+
+func TestRetryChatDoesNotRepeatFailedRequestsInHistory(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		t.Run(fmt.Sprintf("streaming=%t", streaming), func(t *testing.T) {
+			client, requests := newFlakyLlamaCppServer(t)
+			chat := client.StartChat("system", "model").(*LlamaCppChat)
+			retrying := NewRetryChat(chat, testRetryConfig(nil))
+
+			if streaming {
+				stream, err := retrying.SendStreaming(context.Background(), "hi")
+				if err != nil {
+					t.Fatalf("SendStreaming failed: %v", err)
+				}
+				if _, err := readStream(stream); err != nil {
+					t.Fatalf("reading stream: %v", err)
+				}
+			} else if _, err := retrying.Send(context.Background(), "hi"); err != nil {
+				t.Fatalf("Send failed: %v", err)
+			}
+
+			if len(*requests) != 2 {
+				t.Fatalf("got %d requests, want the failed one and its retry", len(*requests))
+			}
+			for i, messages := range *requests {
+				if len(messages) != 2 {
+					t.Errorf("got %d messages in request %d, want the system prompt and the query once", len(messages), i+1)
+				}
+			}
+			var roles []string
+			for _, message := range chat.history {
+				roles = append(roles, message.Role)
+			}
+			if got, want := strings.Join(roles, ","), "system,user,assistant"; got != want {
+				t.Errorf("got history roles %q, want %q", got, want)
+			}
+		})
+	}
+}
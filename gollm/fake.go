@@ -0,0 +1,313 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+func init() {
+	if err := RegisterProvider("fake", fakeFactory); err != nil {
+		klog.Fatalf("Failed to register fake provider: %v", err)
+	}
+}
+
+// fakeFactory builds a FakeClient from a URL like fake:///path/to/script.yaml
+func fakeFactory(ctx context.Context, u *url.URL) (Client, error) {
+	path := u.Host + u.Path
+	if path == "" {
+		return nil, fmt.Errorf("fake provider requires a script path, e.g. fake:///tmp/script.yaml")
+	}
+	return NewFakeClientFromFile(path)
+}
+
+// FakeScript is the script of turns played back by a FakeClient.
+//
+// An example script:
+//
+//	turns:
+//	- text: "Let me look at the pods."
+//	  functionCalls:
+//	  - name: kubectl
+//	    arguments:
+//	      command: kubectl get pods
+//	- error:
+//	    statusCode: 429
+//	    message: quota exceeded
+//	    retryAfter: 1s
+//	- chunks: ["There are "]
+//	  streamError:
+//	    statusCode: 503
+//	    message: connection reset
+//	- chunks: ["There are ", "3 pods running."]
+type FakeScript struct {
+	// Models is the list of models returned by ListModels.
+	Models []string `json:"models,omitempty"`
+	// Turns are the responses to Send / SendStreaming, in order, shared across all chats.
+	Turns []*FakeTurn `json:"turns,omitempty"`
+	// Completions are the responses to GenerateCompletion, in order.
+	Completions []*FakeTurn `json:"completions,omitempty"`
+}
+
+// FakeTurn is a single scripted response from the LLM.
+type FakeTurn struct {
+	// Text is the text of the response.
+	Text string `json:"text,omitempty"`
+	// Thinking is the reasoning returned before the response.
+	Thinking string `json:"thinking,omitempty"`
+	// Chunks splits a text response into several streaming chunks; it is used instead of Text.
+	Chunks []string `json:"chunks,omitempty"`
+	// FunctionCalls are the tool calls made in the response.
+	FunctionCalls []FunctionCall `json:"functionCalls,omitempty"`
+	// Error causes the request to fail instead of returning a response.
+	Error *FakeError `json:"error,omitempty"`
+	// StreamError is returned by the stream of a streaming request, after any chunks, rather than by
+	// SendStreaming itself. Send ignores it.
+	StreamError *FakeError `json:"streamError,omitempty"`
+	// ExpectContains, if set, must be contained in the text of the request; otherwise the turn fails.
+	ExpectContains string `json:"expectContains,omitempty"`
+}
+
+// FakeError is a scripted error, returned as an *APIError so that retry logic sees a real status code.
+type FakeError struct {
+	StatusCode int    `json:"statusCode,omitempty"`
+	Message    string `json:"message,omitempty"`
+	// RetryAfter is a duration string, such as "2s".
+	RetryAfter string `json:"retryAfter,omitempty"`
+}
+
+func (e *FakeError) toError() error {
+	apiErr := &APIError{
+		StatusCode: e.StatusCode,
+		Message:    e.Message,
+	}
+	if e.RetryAfter != "" {
+		d, err := time.ParseDuration(e.RetryAfter)
+		if err != nil {
+			return fmt.Errorf("parsing retryAfter %q in fake script: %w", e.RetryAfter, err)
+		}
+		apiErr.RetryAfter = d
+	}
+	return apiErr
+}
+
+// FakeClient is a Client that plays back a FakeScript, for testing without a real model.
+type FakeClient struct {
+	mutex  sync.Mutex
+	script *FakeScript
+
+	nextTurn       int
+	nextCompletion int
+}
+
+var _ Client = &FakeClient{}
+
+// NewFakeClientFromFile loads a FakeScript from a YAML file.
+func NewFakeClientFromFile(path string) (*FakeClient, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading fake script %q: %w", path, err)
+	}
+	script := &FakeScript{}
+	if err := yaml.Unmarshal(b, script); err != nil {
+		return nil, fmt.Errorf("parsing fake script %q: %w", path, err)
+	}
+	return NewFakeClient(script), nil
+}
+
+// NewFakeClient builds a FakeClient that plays back the given script.
+func NewFakeClient(script *FakeScript) *FakeClient {
+	return &FakeClient{script: script}
+}
+
+// Remaining returns the number of turns that have not yet been played back.
+func (c *FakeClient) Remaining() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.script.Turns) - c.nextTurn
+}
+
+func (c *FakeClient) Close() error {
+	return nil
+}
+
+func (c *FakeClient) SetResponseSchema(schema *Schema) error {
+	return nil
+}
+
+func (c *FakeClient) SetGenerationConfig(config *GenerationConfig) error {
+	return nil
+}
+
+func (c *FakeClient) ListModels(ctx context.Context) ([]string, error) {
+	return c.script.Models, nil
+}
+
+func (c *FakeClient) GenerateCompletion(ctx context.Context, req *CompletionRequest) (CompletionResponse, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.nextCompletion >= len(c.script.Completions) {
+		return nil, fmt.Errorf("fake: script has no more completions (played %d)", c.nextCompletion)
+	}
+	turn := c.script.Completions[c.nextCompletion]
+	c.nextCompletion++
+
+	if turn.ExpectContains != "" && !strings.Contains(req.Prompt, turn.ExpectContains) {
+		return nil, fmt.Errorf("fake: completion prompt does not contain %q", turn.ExpectContains)
+	}
+	if turn.Error != nil {
+		return nil, turn.Error.toError()
+	}
+	return &simpleCompletionResponse{content: turn.Text + strings.Join(turn.Chunks, "")}, nil
+}
+
+func (c *FakeClient) StartChat(systemPrompt, model string) Chat {
+	return &fakeChat{client: c}
+}
+
+// takeTurn returns the next turn of the script, checking it against the request.
+func (c *FakeClient) takeTurn(contents []any) (*FakeTurn, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.nextTurn >= len(c.script.Turns) {
+		return nil, fmt.Errorf("fake: script has no more turns (played %d)", c.nextTurn)
+	}
+	turn := c.script.Turns[c.nextTurn]
+	c.nextTurn++
+
+	if turn.ExpectContains != "" {
+		var sb strings.Builder
+		for _, content := range contents {
+			switch v := content.(type) {
+			case string:
+				sb.WriteString(v)
+			case FileContent:
+				sb.WriteString(v.AsText())
+			case FunctionCallResult:
+				fmt.Fprintf(&sb, "%v", v.Result)
+			}
+		}
+		if !strings.Contains(sb.String(), turn.ExpectContains) {
+			return nil, fmt.Errorf("fake: turn %d expected request to contain %q, got %q", c.nextTurn-1, turn.ExpectContains, sb.String())
+		}
+	}
+	return turn, nil
+}
+
+// fakeChat is a Chat that plays back turns from its FakeClient.
+type fakeChat struct {
+	client *FakeClient
+}
+
+var _ Chat = &fakeChat{}
+
+func (c *fakeChat) SetFunctionDefinitions(functionDefinitions []*FunctionDefinition) error {
+	return nil
+}
+
+func (c *fakeChat) IsRetryableError(err error) bool {
+	return c.ClassifyError(err).Retryable
+}
+
+func (c *fakeChat) ClassifyError(err error) ErrorClassification {
+	return DefaultClassifyError(err)
+}
+
+// responses builds the responses for a turn; text chunks become separate responses,
+// and any function calls are sent in the final response.
+func (t *FakeTurn) responses() []*FixtureResponse {
+	chunks := t.Chunks
+	if len(chunks) == 0 {
+		chunks = []string{t.Text}
+	}
+
+	var responses []*FixtureResponse
+	if t.Thinking != "" {
+		responses = append(responses, &FixtureResponse{
+			FixtureCandidates: []*FixtureCandidate{{FixtureParts: []*FixturePart{{Thinking: t.Thinking}}}},
+		})
+	}
+	for i, chunk := range chunks {
+		part := &FixturePart{Text: chunk}
+		if i == len(chunks)-1 {
+			part.FunctionCalls = t.FunctionCalls
+		}
+		responses = append(responses, &FixtureResponse{
+			FixtureCandidates: []*FixtureCandidate{{FixtureParts: []*FixturePart{part}}},
+		})
+	}
+	return responses
+}
+
+func (c *fakeChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
+	turn, err := c.client.takeTurn(contents)
+	if err != nil {
+		return nil, err
+	}
+	if turn.Error != nil {
+		return nil, turn.Error.toError()
+	}
+
+	// A non-streaming response contains all the chunks in a single part.
+	var parts []*FixturePart
+	if turn.Thinking != "" {
+		parts = append(parts, &FixturePart{Thinking: turn.Thinking})
+	}
+	parts = append(parts, &FixturePart{
+		Text:          turn.Text + strings.Join(turn.Chunks, ""),
+		FunctionCalls: turn.FunctionCalls,
+	})
+	return &FixtureResponse{
+		FixtureCandidates: []*FixtureCandidate{{FixtureParts: parts}},
+	}, nil
+}
+
+func (c *fakeChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
+	turn, err := c.client.takeTurn(contents)
+	if err != nil {
+		return nil, err
+	}
+	if turn.Error != nil {
+		return nil, turn.Error.toError()
+	}
+
+	var responses []*FixtureResponse
+	// A turn with only a stream error fails on the first step of the stream
+	if turn.StreamError == nil || len(turn.Chunks) != 0 || turn.Text != "" || turn.Thinking != "" || len(turn.FunctionCalls) != 0 {
+		responses = turn.responses()
+	}
+	return func(yield func(ChatResponse, error) bool) {
+		for _, response := range responses {
+			if !yield(response, nil) {
+				return
+			}
+		}
+		if turn.StreamError != nil {
+			yield(nil, turn.StreamError.toError())
+		}
+	}, nil
+}
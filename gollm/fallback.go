@@ -0,0 +1,517 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/url"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+func init() {
+	if err := RegisterProvider("fallback", fallbackFactory); err != nil {
+		klog.Fatalf("Failed to register fallback provider: %v", err)
+	}
+}
+
+// fallbackFactory builds a FallbackClient from a URL such as
+//
+//	fallback://?chain=gemini/gemini-2.5-pro,vertexai/gemini-2.0-flash&classify=gemini/gemini-2.0-flash
+//
+// Each entry in chain is "<provider-id>[/<model>]"; an entry without a model
+// uses the model passed to StartChat.
+// Any other query parameter is treated as a route for the named task (see CompletionRequest.Task).
+func fallbackFactory(ctx context.Context, u *url.URL) (Client, error) {
+	opt := FallbackClientOptions{
+		Routes: make(map[string]ModelRoute),
+	}
+
+	query := u.Query()
+	for _, chain := range query["chain"] {
+		for _, s := range strings.Split(chain, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			opt.Chain = append(opt.Chain, parseModelRoute(s))
+		}
+	}
+	for key, values := range query {
+		if key == "chain" || len(values) == 0 {
+			continue
+		}
+		opt.Routes[key] = parseModelRoute(values[len(values)-1])
+	}
+
+	return NewFallbackClient(ctx, opt)
+}
+
+// parseModelRoute parses "<provider-id>[/<model>]".
+func parseModelRoute(s string) ModelRoute {
+	providerID, model, _ := strings.Cut(s, "/")
+	return ModelRoute{ProviderID: providerID, Model: model}
+}
+
+// ModelRoute identifies a model on a specific provider.
+type ModelRoute struct {
+	// ProviderID is passed to NewClient, for example "gemini" or "openai".
+	ProviderID string `json:"provider"`
+	// Model is the model to use; if empty the model passed to StartChat is used.
+	Model string `json:"model,omitempty"`
+}
+
+func (r ModelRoute) String() string {
+	if r.Model == "" {
+		return r.ProviderID
+	}
+	return r.ProviderID + "/" + r.Model
+}
+
+// FallbackClientOptions configures a FallbackClient.
+type FallbackClientOptions struct {
+	// Chain is the ordered list of models to try; the first is the primary model.
+	Chain []ModelRoute
+
+	// Routes maps a task name (CompletionRequest.Task) to the model that should serve it,
+	// so that cheap sub-tasks such as TaskClassify can be sent to a smaller model.
+	Routes map[string]ModelRoute
+
+	// Retry is applied to each model in the chain before we fail over to the next one.
+	// If MaxAttempts is zero, DefaultRetryConfig is used.
+	Retry RetryConfig
+}
+
+// FallbackClient is a Client that fails over between an ordered chain of models.
+// A model is abandoned when it returns a non-retryable error, or when retries are exhausted;
+// the conversation history is carried over to the next model in the chain.
+type FallbackClient struct {
+	options FallbackClientOptions
+
+	mutex sync.Mutex
+	// clients holds the clients we have built, keyed by provider id.
+	// Clients are built lazily, so that credentials are only required for providers we actually use.
+	clients map[string]Client
+
+	responseSchema   *Schema
+	generationConfig *GenerationConfig
+}
+
+var _ Client = &FallbackClient{}
+
+// NewFallbackClient builds a FallbackClient for the given chain of models.
+func NewFallbackClient(ctx context.Context, opt FallbackClientOptions) (*FallbackClient, error) {
+	if len(opt.Chain) == 0 {
+		return nil, fmt.Errorf("fallback client requires at least one model in the chain")
+	}
+	for _, route := range opt.Chain {
+		if route.ProviderID == "" {
+			return nil, fmt.Errorf("fallback chain entry %q has no provider", route)
+		}
+	}
+	if opt.Retry.MaxAttempts == 0 {
+		opt.Retry = DefaultRetryConfig
+	}
+
+	c := &FallbackClient{
+		options: opt,
+		clients: make(map[string]Client),
+	}
+
+	// Build the primary client eagerly, so that configuration errors are reported early.
+	if _, err := c.clientFor(ctx, opt.Chain[0]); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// clientFor returns the (cached) client for the provider of the given route.
+func (c *FallbackClient) clientFor(ctx context.Context, route ModelRoute) (Client, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if client := c.clients[route.ProviderID]; client != nil {
+		return client, nil
+	}
+	client, err := NewClient(ctx, route.ProviderID)
+	if err != nil {
+		return nil, fmt.Errorf("creating client for %q: %w", route.ProviderID, err)
+	}
+	if c.responseSchema != nil {
+		if err := client.SetResponseSchema(c.responseSchema); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	if c.generationConfig != nil {
+		if err := client.SetGenerationConfig(c.generationConfig); err != nil {
+			var unsupported *UnsupportedGenerationSettingsError
+			if !errors.As(err, &unsupported) {
+				client.Close()
+				return nil, err
+			}
+			klog.FromContext(ctx).Info("fallback model does not support all generation settings", "model", route, "err", err)
+		}
+	}
+	c.clients[route.ProviderID] = client
+	return client, nil
+}
+
+// Close closes all the underlying clients.
+func (c *FallbackClient) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var errs []error
+	for _, client := range c.clients {
+		if err := client.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	c.clients = make(map[string]Client)
+	return errors.Join(errs...)
+}
+
+// SetResponseSchema sets the response schema on every underlying client.
+func (c *FallbackClient) SetResponseSchema(schema *Schema) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.responseSchema = schema
+	for _, client := range c.clients {
+		if err := client.SetResponseSchema(schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetGenerationConfig sets the generation settings on every underlying client, including clients built later.
+// Unsupported settings are reported for the clients that have been built so far.
+func (c *FallbackClient) SetGenerationConfig(config *GenerationConfig) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generationConfig = config
+	var errs []error
+	for _, client := range c.clients {
+		if err := client.SetGenerationConfig(config); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ListModels lists the models in the fallback chain.
+func (c *FallbackClient) ListModels(ctx context.Context) ([]string, error) {
+	var models []string
+	for _, route := range c.options.Chain {
+		models = append(models, route.String())
+	}
+	return models, nil
+}
+
+// GenerateCompletion sends the request to the model routed for req.Task, if any,
+// otherwise it walks the fallback chain.
+func (c *FallbackClient) GenerateCompletion(ctx context.Context, req *CompletionRequest) (CompletionResponse, error) {
+	log := klog.FromContext(ctx)
+
+	chain := c.options.Chain
+	if route, ok := c.options.Routes[req.Task]; ok && req.Task != "" {
+		log.V(1).Info("routing completion request", "task", req.Task, "route", route)
+		chain = append([]ModelRoute{route}, chain...)
+	}
+
+	var errs []error
+	for _, route := range chain {
+		client, err := c.clientFor(ctx, route)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		routed := *req
+		if route.Model != "" {
+			routed.Model = route.Model
+		}
+		response, err := Retry(ctx, c.options.Retry, DefaultClassifyError, func(ctx context.Context) (CompletionResponse, error) {
+			return client.GenerateCompletion(ctx, &routed)
+		})
+		if err == nil {
+			return response, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		log.Info("model failed, falling back to next model", "route", route, "error", err)
+		errs = append(errs, fmt.Errorf("%s: %w", route, err))
+	}
+	return nil, fmt.Errorf("all models in fallback chain failed: %w", errors.Join(errs...))
+}
+
+// StartChat starts a chat on the primary model; it will fail over to later models as needed.
+func (c *FallbackClient) StartChat(systemPrompt, model string) Chat {
+	return &fallbackChat{
+		client:       c,
+		systemPrompt: systemPrompt,
+		model:        model,
+	}
+}
+
+// fallbackHistoryEntry is a provider-neutral record of one message in the chat.
+// We keep this so we can rebuild the conversation on another model when we fail over.
+type fallbackHistoryEntry struct {
+	Role            string               `json:"role"`
+	Text            string               `json:"text,omitempty"`
+	FunctionCalls   []FunctionCall       `json:"functionCalls,omitempty"`
+	FunctionResults []FunctionCallResult `json:"functionResults,omitempty"`
+}
+
+// fallbackChat implements Chat on top of a FallbackClient.
+type fallbackChat struct {
+	client       *FallbackClient
+	systemPrompt string
+	model        string
+
+	functionDefinitions []*FunctionDefinition
+
+	// position is the index into the chain of the model we are currently using.
+	position int
+	// current is the chat for the current model; nil until the first message is sent.
+	current Chat
+	// needsReplay is true if current was started on a fresh model and has not yet seen the history.
+	needsReplay bool
+
+	history []fallbackHistoryEntry
+}
+
+var _ Chat = &fallbackChat{}
+
+func (c *fallbackChat) SetFunctionDefinitions(functionDefinitions []*FunctionDefinition) error {
+	c.functionDefinitions = functionDefinitions
+	if c.current != nil {
+		return c.current.SetFunctionDefinitions(functionDefinitions)
+	}
+	return nil
+}
+
+// IsRetryableError returns false; retries are performed per-model before we fail over,
+// so there is nothing left for an outer retry loop to do.
+func (c *fallbackChat) IsRetryableError(err error) bool {
+	return false
+}
+
+// startChat starts a chat on the model at the current position in the chain.
+func (c *fallbackChat) startChat(ctx context.Context) error {
+	route := c.client.options.Chain[c.position]
+	client, err := c.client.clientFor(ctx, route)
+	if err != nil {
+		return err
+	}
+	model := route.Model
+	if model == "" {
+		model = c.model
+	}
+	chat := client.StartChat(c.systemPrompt, model)
+	if len(c.functionDefinitions) != 0 {
+		if err := chat.SetFunctionDefinitions(c.functionDefinitions); err != nil {
+			return fmt.Errorf("setting function definitions for %s: %w", route, err)
+		}
+	}
+	c.current = NewRetryChat(chat, c.client.options.Retry)
+	c.needsReplay = len(c.history) != 0
+	klog.FromContext(ctx).Info("started chat", "route", route, "model", model)
+	return nil
+}
+
+// failover moves to the next model in the chain, returning false if there are none left.
+func (c *fallbackChat) failover(ctx context.Context, cause error) bool {
+	log := klog.FromContext(ctx)
+
+	for c.position+1 < len(c.client.options.Chain) {
+		c.position++
+		log.Info("model failed, falling back to next model", "error", cause, "next", c.client.options.Chain[c.position])
+		if err := c.startChat(ctx); err != nil {
+			log.Info("unable to start chat on fallback model", "error", err)
+			cause = err
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// buildContents returns the contents to send to the current chat.
+// If the current chat was just started on a fallback model, we replay the history as a
+// transcript; function results are converted to text, because the new model never issued those calls.
+func (c *fallbackChat) buildContents(contents []any) []any {
+	if !c.needsReplay {
+		return contents
+	}
+
+	var transcript strings.Builder
+	transcript.WriteString("The conversation so far was handled by another model; here is the transcript, continue from where it left off.\n\n")
+	for _, entry := range c.history {
+		if entry.Text != "" {
+			fmt.Fprintf(&transcript, "%s: %s\n", entry.Role, entry.Text)
+		}
+		for _, call := range entry.FunctionCalls {
+			args, _ := json.Marshal(call.Arguments)
+			fmt.Fprintf(&transcript, "%s called function %q with arguments %s\n", entry.Role, call.Name, args)
+		}
+		for _, result := range entry.FunctionResults {
+			fmt.Fprintf(&transcript, "result of function %q: %s\n", result.Name, functionResultText(result))
+		}
+	}
+
+	out := []any{transcript.String()}
+	for _, content := range contents {
+		if result, ok := content.(FunctionCallResult); ok {
+			out = append(out, fmt.Sprintf("Result of function %q: %s", result.Name, functionResultText(result)))
+			continue
+		}
+		out = append(out, content)
+	}
+	return out
+}
+
+func functionResultText(result FunctionCallResult) string {
+	b, err := json.Marshal(result.Result)
+	if err != nil {
+		return fmt.Sprintf("%v", result.Result)
+	}
+	return string(b)
+}
+
+// recordUser adds the user contents to our provider-neutral history.
+func (c *fallbackChat) recordUser(contents []any) {
+	entry := fallbackHistoryEntry{Role: "user"}
+	for _, content := range contents {
+		switch v := content.(type) {
+		case string:
+			entry.Text += v
+		case FileContent:
+			entry.Text += v.AsText()
+		case ImageContent:
+			// Images are not replayed on failover, but we note that there was one
+			entry.Text += fmt.Sprintf("[attached image %q]\n", v.Name)
+		case FunctionCallResult:
+			entry.FunctionResults = append(entry.FunctionResults, v)
+		}
+	}
+	c.history = append(c.history, entry)
+}
+
+// recordResponse adds the (first candidate of the) response to our provider-neutral history.
+func (c *fallbackChat) recordResponse(entry *fallbackHistoryEntry, response ChatResponse) {
+	if response == nil || len(response.Candidates()) == 0 {
+		return
+	}
+	for _, part := range response.Candidates()[0].Parts() {
+		if text, ok := part.AsText(); ok {
+			entry.Text += text
+		}
+		if calls, ok := part.AsFunctionCalls(); ok {
+			entry.FunctionCalls = append(entry.FunctionCalls, calls...)
+		}
+	}
+}
+
+func (c *fallbackChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
+	if c.current == nil {
+		if err := c.startChat(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		response, err := c.current.Send(ctx, c.buildContents(contents)...)
+		if err == nil {
+			c.needsReplay = false
+			c.recordUser(contents)
+			entry := fallbackHistoryEntry{Role: "model"}
+			c.recordResponse(&entry, response)
+			c.history = append(c.history, entry)
+			return response, nil
+		}
+		if ctx.Err() != nil || !c.failover(ctx, err) {
+			return nil, err
+		}
+	}
+}
+
+func (c *fallbackChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
+	if c.current == nil {
+		if err := c.startChat(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		stream, err := c.current.SendStreaming(ctx, c.buildContents(contents)...)
+		if err != nil {
+			if ctx.Err() != nil || !c.failover(ctx, err) {
+				return nil, err
+			}
+			continue
+		}
+
+		// Peek at the first chunk, so we can still fail over if the stream fails immediately.
+		next, stop := iter.Pull2(iter.Seq2[ChatResponse, error](stream))
+		first, err, ok := next()
+		if ok && err != nil {
+			stop()
+			if ctx.Err() != nil || !c.failover(ctx, err) {
+				return nil, err
+			}
+			continue
+		}
+
+		c.needsReplay = false
+		c.recordUser(contents)
+
+		return func(yield func(ChatResponse, error) bool) {
+			defer stop()
+
+			entry := fallbackHistoryEntry{Role: "model"}
+			defer func() {
+				c.history = append(c.history, entry)
+			}()
+
+			if !ok {
+				return
+			}
+			c.recordResponse(&entry, first)
+			if !yield(first, nil) {
+				return
+			}
+			for {
+				response, err, ok := next()
+				if !ok {
+					return
+				}
+				c.recordResponse(&entry, response)
+				if !yield(response, err) {
+					return
+				}
+			}
+		}, nil
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// newTestFallbackClient builds a FallbackClient over fake clients, keyed by provider id, in chain order.
+func newTestFallbackClient(routes map[string]ModelRoute, chain []string, fakes map[string]*FakeClient) *FallbackClient {
+	c := &FallbackClient{
+		options: FallbackClientOptions{
+			Routes: routes,
+			Retry:  testRetryConfig(nil),
+		},
+		clients: make(map[string]Client),
+	}
+	for _, providerID := range chain {
+		c.options.Chain = append(c.options.Chain, ModelRoute{ProviderID: providerID})
+	}
+	for providerID, fake := range fakes {
+		c.clients[providerID] = fake
+	}
+	return c
+}
+
+func TestFallbackChatFailsOverInOrder(t *testing.T) {
+	ctx := context.Background()
+	unavailable := &FakeError{StatusCode: 503, Message: "unavailable"}
+
+	// a answers the first message, then keeps failing with a retryable error until retries are exhausted;
+	// b fails with a non-retryable error; c answers, and is sent the history.
+	a := NewFakeClient(&FakeScript{Turns: []*FakeTurn{
+		{Chunks: []string{"there are ", "3 pods"}},
+		{Error: unavailable},
+		{StreamError: unavailable},
+		{Error: unavailable},
+	}})
+	b := NewFakeClient(&FakeScript{Turns: []*FakeTurn{
+		{Error: &FakeError{StatusCode: 400, Message: "model not supported"}},
+	}})
+	c := NewFakeClient(&FakeScript{Turns: []*FakeTurn{
+		{Text: "the web pod is crashing", ExpectContains: "model: there are 3 pods"},
+	}})
+	client := newTestFallbackClient(nil, []string{"a", "b", "c"}, map[string]*FakeClient{"a": a, "b": b, "c": c})
+	chat := client.StartChat("", "model")
+
+	for i, want := range []string{"there are 3 pods", "the web pod is crashing"} {
+		stream, err := chat.SendStreaming(ctx, fmt.Sprintf("message %d", i+1))
+		if err != nil {
+			t.Fatalf("message %d: SendStreaming failed: %v", i+1, err)
+		}
+		text, err := readStream(stream)
+		if err != nil {
+			t.Fatalf("message %d: reading stream failed: %v", i+1, err)
+		}
+		if text != want {
+			t.Errorf("message %d: got %q, want %q", i+1, text, want)
+		}
+	}
+
+	for name, fake := range map[string]*FakeClient{"a": a, "b": b, "c": c} {
+		if fake.Remaining() != 0 {
+			t.Errorf("model %s has %d turns remaining, want all turns used", name, fake.Remaining())
+		}
+	}
+}
+
+func TestFallbackChatAllModelsFail(t *testing.T) {
+	ctx := context.Background()
+	badRequest := &FakeError{StatusCode: 400, Message: "bad request"}
+	a := NewFakeClient(&FakeScript{Turns: []*FakeTurn{{Error: badRequest}}})
+	b := NewFakeClient(&FakeScript{Turns: []*FakeTurn{{StreamError: badRequest}}})
+	client := newTestFallbackClient(nil, []string{"a", "b"}, map[string]*FakeClient{"a": a, "b": b})
+
+	_, err := client.StartChat("", "model").SendStreaming(ctx, "hello")
+	if err == nil || !strings.Contains(err.Error(), "bad request") {
+		t.Errorf("got error %v, want the last model's error", err)
+	}
+	if a.Remaining() != 0 || b.Remaining() != 0 {
+		t.Errorf("got %d and %d turns remaining, want both models tried", a.Remaining(), b.Remaining())
+	}
+}
+
+func TestFallbackGenerateCompletionRoutesTasks(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		task string
+		// small and primary are the completions of the routed model and the primary model
+		small   []*FakeTurn
+		primary []*FakeTurn
+		want    string
+	}{
+		{
+			name:  "routed task uses the routed model",
+			task:  TaskClassify,
+			small: []*FakeTurn{{Text: "small"}},
+			want:  "small",
+		},
+		{
+			name:    "other tasks use the chain",
+			task:    "other",
+			primary: []*FakeTurn{{Text: "primary"}},
+			want:    "primary",
+		},
+		{
+			name:    "routed task falls back to the chain",
+			task:    TaskClassify,
+			small:   []*FakeTurn{{Error: &FakeError{StatusCode: 404, Message: "no such model"}}},
+			primary: []*FakeTurn{{Text: "primary"}},
+			want:    "primary",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			small := NewFakeClient(&FakeScript{Completions: test.small})
+			primary := NewFakeClient(&FakeScript{Completions: test.primary})
+			routes := map[string]ModelRoute{TaskClassify: {ProviderID: "small"}}
+			client := newTestFallbackClient(routes, []string{"primary"}, map[string]*FakeClient{"small": small, "primary": primary})
+
+			response, err := client.GenerateCompletion(ctx, &CompletionRequest{Prompt: "hello", Task: test.task})
+			if err != nil {
+				t.Fatalf("GenerateCompletion failed: %v", err)
+			}
+			if got := response.Response(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -21,12 +21,12 @@ import (
 	"errors"
 	"fmt"
 	"iter"
-	"net"
-	"net/http"
+	"math"
 	"net/url"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"google.golang.org/genai"
 
@@ -181,6 +181,9 @@ type GoogleAIClient struct {
 
 	// responseSchema will constrain the output to match the given schema
 	responseSchema *genai.Schema
+
+	// generationConfig holds the generation settings, or nil to use our defaults.
+	generationConfig *GenerationConfig
 }
 
 var _ Client = &GoogleAIClient{}
@@ -218,17 +221,61 @@ func (c *GoogleAIClient) SetResponseSchema(responseSchema *Schema) error {
 	return nil
 }
 
+// SetGenerationConfig sets the generation settings. Gemini supports all of them, but its seed is a 32-bit integer,
+// so a seed outside that range is ignored rather than wrapped around.
+func (c *GoogleAIClient) SetGenerationConfig(config *GenerationConfig) error {
+	if config != nil && config.Seed != nil && (*config.Seed < math.MinInt32 || *config.Seed > math.MaxInt32) {
+		withoutSeed := *config
+		withoutSeed.Seed = nil
+		c.generationConfig = &withoutSeed
+		return &UnsupportedGenerationSettingsError{Provider: "gemini", Settings: []string{GenerationSettingSeed}}
+	}
+	c.generationConfig = config
+	return nil
+}
+
+// applyGenerationConfig copies the generation settings onto a gemini request config.
+func (c *GoogleAIClient) applyGenerationConfig(genConfig *genai.GenerateContentConfig) {
+	config := c.generationConfig
+	if config == nil {
+		return
+	}
+	if config.Temperature != nil {
+		genConfig.Temperature = config.Temperature
+	}
+	if config.TopP != nil {
+		genConfig.TopP = config.TopP
+	}
+	if config.TopK != nil {
+		genConfig.TopK = ptrTo(float32(*config.TopK))
+	}
+	if config.MaxOutputTokens != nil {
+		genConfig.MaxOutputTokens = *config.MaxOutputTokens
+	}
+	if len(config.StopSequences) != 0 {
+		genConfig.StopSequences = config.StopSequences
+	}
+	if config.Seed != nil {
+		genConfig.Seed = ptrTo(int32(*config.Seed))
+	}
+	if config.ThinkingBudget != nil || config.IncludeThoughts != nil {
+		genConfig.ThinkingConfig = &genai.ThinkingConfig{ThinkingBudget: config.ThinkingBudget}
+		if config.IncludeThoughts != nil {
+			genConfig.ThinkingConfig.IncludeThoughts = *config.IncludeThoughts
+		}
+	}
+}
+
 func (c *GoogleAIClient) GenerateCompletion(ctx context.Context, request *CompletionRequest) (CompletionResponse, error) {
 	log := klog.FromContext(ctx)
 
-	var config *genai.GenerateContentConfig
+	config := &genai.GenerateContentConfig{}
 
 	if c.responseSchema != nil {
-		config = &genai.GenerateContentConfig{
-			ResponseSchema:   c.responseSchema,
-			ResponseMIMEType: "application/json",
-		}
+		config.ResponseSchema = c.responseSchema
+		config.ResponseMIMEType = "application/json"
 	}
+	c.applyGenerationConfig(config)
 
 	content := []*genai.Content{
 		{Role: "user", Parts: []*genai.Part{{Text: request.Prompt}}},
@@ -283,6 +330,7 @@ func (c *GoogleAIClient) StartChat(systemPrompt string, model string) Chat {
 		chat.genConfig.ResponseSchema = c.responseSchema
 		chat.genConfig.ResponseMIMEType = "application/json"
 	}
+	c.applyGenerationConfig(chat.genConfig)
 	return chat
 }
 
@@ -323,6 +371,18 @@ func toGeminiSchema(schema *Schema) (*genai.Schema, error) {
 	ret := &genai.Schema{
 		Description: schema.Description,
 		Required:    schema.Required,
+		Enum:        schema.Enum,
+		Format:      schema.Format,
+	}
+	if schema.Nullable {
+		ret.Nullable = ptrTo(true)
+	}
+	// The Gemini API (unlike Vertex AI) rejects schemas with a default, so we describe it instead.
+	if schema.Default != nil {
+		if ret.Description != "" {
+			ret.Description += " "
+		}
+		ret.Description += fmt.Sprintf("(Defaults to %v.)", schema.Default)
 	}
 
 	switch schema.Type {
@@ -334,6 +394,8 @@ func toGeminiSchema(schema *Schema) (*genai.Schema, error) {
 		ret.Type = genai.TypeBoolean
 	case TypeInteger:
 		ret.Type = genai.TypeInteger
+	case TypeNumber:
+		ret.Type = genai.TypeNumber
 	case TypeArray:
 		ret.Type = genai.TypeArray
 	default:
@@ -356,6 +418,14 @@ func toGeminiSchema(schema *Schema) (*genai.Schema, error) {
 		}
 		ret.Items = geminiValue
 	}
+	// genai.Schema has no additionalProperties; the best we can do for map-like objects is describe them.
+	if schema.AdditionalProperties != nil && schema.Properties == nil {
+		valueType := schema.AdditionalProperties.Type
+		if ret.Description != "" {
+			ret.Description += " "
+		}
+		ret.Description += fmt.Sprintf("(A map of string keys to %s values.)", valueType)
+	}
 	return ret, nil
 }
 
@@ -366,6 +436,10 @@ func (c *GeminiChat) partsToGemini(contents ...any) ([]*genai.Part, error) {
 		switch v := content.(type) {
 		case string:
 			parts = append(parts, genai.NewPartFromText(v))
+		case FileContent:
+			parts = append(parts, genai.NewPartFromText(v.AsText()))
+		case ImageContent:
+			parts = append(parts, genai.NewPartFromBytes(v.Data, v.MIMEType))
 		case FunctionCallResult:
 			parts = append(parts, &genai.Part{
 				FunctionResponse: &genai.FunctionResponse{
@@ -397,12 +471,16 @@ func (c *GeminiChat) Send(ctx context.Context, contents ...any) (ChatResponse, e
 		Parts: parts,
 	}
 
+	// The request is dropped from the history if it fails, so that a retry does not send it twice
+	historyLen := len(c.history)
 	c.history = append(c.history, genaiContent)
 	result, err := c.client.Models.GenerateContent(ctx, c.model, c.history, c.genConfig)
 	if err != nil {
+		c.history = c.history[:historyLen]
 		return nil, fmt.Errorf("failed to generate content: %w", err)
 	}
 	if result == nil || len(result.Candidates) == 0 {
+		c.history = c.history[:historyLen]
 		return nil, fmt.Errorf("no response from Gemini")
 	}
 	c.history = append(c.history, result.Candidates[0].Content)
@@ -425,6 +503,7 @@ func (c *GeminiChat) SendStreaming(ctx context.Context, contents ...any) (ChatRe
 		Parts: parts,
 	}
 
+	historyLen := len(c.history)
 	c.history = append(c.history, genaiContent)
 	stream := c.client.Models.GenerateContentStream(ctx, c.model, c.history, c.genConfig)
 
@@ -437,6 +516,13 @@ func (c *GeminiChat) SendStreaming(ctx context.Context, contents ...any) (ChatRe
 				return
 			}
 
+			if err != nil {
+				// The request is dropped from the history, so that a retry does not send it twice
+				c.history = c.history[:historyLen]
+				yield(nil, err)
+				return
+			}
+
 			var response *GeminiChatResponse
 			if geminiResponse != nil {
 				response = &GeminiChatResponse{geminiResponse: geminiResponse}
@@ -538,7 +624,15 @@ type GeminiPart struct {
 
 // AsText returns the text of the part.
 func (p *GeminiPart) AsText() (string, bool) {
-	if p.part.Text != "" {
+	if p.part.Text != "" && !p.part.Thought {
+		return p.part.Text, true
+	}
+	return "", false
+}
+
+// AsThinking returns the text of a thought part (only returned when IncludeThoughts is set).
+func (p *GeminiPart) AsThinking() (string, bool) {
+	if p.part.Text != "" && p.part.Thought {
 		return p.part.Text, true
 	}
 	return "", false
@@ -586,29 +680,44 @@ func (r *GeminiCompletionResponse) String() string {
 }
 
 func (c *GeminiChat) IsRetryableError(err error) bool {
+	return c.ClassifyError(err).Retryable
+}
+
+// ClassifyError classifies errors from the Gemini API, including the retryDelay the server suggests.
+func (c *GeminiChat) ClassifyError(err error) ErrorClassification {
 	if err == nil {
-		return false
+		return ErrorClassification{}
 	}
 
 	var apiErr genai.APIError
 	if errors.As(err, &apiErr) {
-		switch apiErr.Code {
-		case http.StatusConflict, http.StatusTooManyRequests,
-			http.StatusInternalServerError, http.StatusBadGateway,
-			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
-			return true
-		default:
-			return false
+		classification := ClassifyHTTPStatus(apiErr.Code)
+		if apiErr.Status == "RESOURCE_EXHAUSTED" {
+			classification.Retryable = true
+			classification.IsQuota = true
 		}
+		classification.RetryAfter = geminiRetryDelay(apiErr.Details)
+		return classification
 	}
 
-	var netErr net.Error
-	if errors.As(err, &netErr) && netErr.Timeout() {
-		return true
-	}
-
-	// Add other error checks specific to LLM clients if needed
-	// e.g., if errors.Is(err, specificLLMRateLimitError) { return true }
+	return DefaultClassifyError(err)
+}
 
-	return false
+// geminiRetryDelay extracts the retryDelay from a google.rpc.RetryInfo error detail, if present.
+// For example: {"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "39s"}
+func geminiRetryDelay(details []map[string]any) time.Duration {
+	for _, detail := range details {
+		if detail["@type"] != "type.googleapis.com/google.rpc.RetryInfo" {
+			continue
+		}
+		s, ok := detail["retryDelay"].(string)
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(s)
+		if err == nil && d > 0 {
+			return d
+		}
+	}
+	return 0
 }
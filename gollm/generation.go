@@ -0,0 +1,100 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GenerationConfig holds provider-neutral settings that control how the LLM generates responses.
+// Fields that are not set are left at the provider (or model) default.
+type GenerationConfig struct {
+	Temperature     *float32 `json:"temperature,omitempty"`
+	TopP            *float32 `json:"topP,omitempty"`
+	TopK            *int32   `json:"topK,omitempty"`
+	MaxOutputTokens *int32   `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+	Seed            *int64   `json:"seed,omitempty"`
+	// ThinkingBudget is the number of tokens the model may spend reasoning before answering.
+	ThinkingBudget *int32 `json:"thinkingBudget,omitempty"`
+	// IncludeThoughts asks the provider to return the model's reasoning, as Parts for which AsThinking is true.
+	// Some providers (ollama, llama.cpp) return reasoning whenever the model produces it.
+	IncludeThoughts *bool `json:"includeThoughts,omitempty"`
+}
+
+// Names of the settings in GenerationConfig, as used in UnsupportedGenerationSettingsError.
+const (
+	GenerationSettingTemperature     = "temperature"
+	GenerationSettingTopP            = "topP"
+	GenerationSettingTopK            = "topK"
+	GenerationSettingMaxOutputTokens = "maxOutputTokens"
+	GenerationSettingStopSequences   = "stopSequences"
+	GenerationSettingSeed            = "seed"
+	GenerationSettingThinkingBudget  = "thinkingBudget"
+	GenerationSettingIncludeThoughts = "includeThoughts"
+)
+
+// isSet returns true if the named setting has a value.
+func (c *GenerationConfig) isSet(setting string) bool {
+	if c == nil {
+		return false
+	}
+	switch setting {
+	case GenerationSettingTemperature:
+		return c.Temperature != nil
+	case GenerationSettingTopP:
+		return c.TopP != nil
+	case GenerationSettingTopK:
+		return c.TopK != nil
+	case GenerationSettingMaxOutputTokens:
+		return c.MaxOutputTokens != nil
+	case GenerationSettingStopSequences:
+		return len(c.StopSequences) != 0
+	case GenerationSettingSeed:
+		return c.Seed != nil
+	case GenerationSettingThinkingBudget:
+		return c.ThinkingBudget != nil
+	case GenerationSettingIncludeThoughts:
+		return c.IncludeThoughts != nil && *c.IncludeThoughts
+	}
+	return false
+}
+
+// checkSupported returns an UnsupportedGenerationSettingsError if any of the unsupported settings are set,
+// or nil if the provider can honor the whole config.
+func (c *GenerationConfig) checkSupported(provider string, unsupported ...string) error {
+	var ignored []string
+	for _, setting := range unsupported {
+		if c.isSet(setting) {
+			ignored = append(ignored, setting)
+		}
+	}
+	if len(ignored) == 0 {
+		return nil
+	}
+	return &UnsupportedGenerationSettingsError{Provider: provider, Settings: ignored}
+}
+
+// UnsupportedGenerationSettingsError is returned by SetGenerationConfig when the provider cannot honor some settings.
+// The supported settings are still applied, so callers will typically report this as a warning.
+type UnsupportedGenerationSettingsError struct {
+	Provider string
+	Settings []string
+}
+
+func (e *UnsupportedGenerationSettingsError) Error() string {
+	return fmt.Sprintf("%s provider ignores generation settings: %s", e.Provider, strings.Join(e.Settings, ", "))
+}
@@ -0,0 +1,200 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+// ignoredSettings returns the settings reported by an UnsupportedGenerationSettingsError, or nil if err is nil.
+func ignoredSettings(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var unsupported *UnsupportedGenerationSettingsError
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("got error %v, want an UnsupportedGenerationSettingsError", err)
+	}
+	return unsupported.Settings
+}
+
+func TestCheckSupported(t *testing.T) {
+	all := []string{
+		GenerationSettingTemperature, GenerationSettingTopP, GenerationSettingTopK, GenerationSettingMaxOutputTokens,
+		GenerationSettingStopSequences, GenerationSettingSeed, GenerationSettingThinkingBudget, GenerationSettingIncludeThoughts,
+	}
+	tests := []struct {
+		name   string
+		config *GenerationConfig
+		want   []string
+	}{
+		{name: "nil config", config: nil},
+		{name: "nothing set", config: &GenerationConfig{}},
+		// Not asking for reasoning is what every provider does anyway
+		{name: "includeThoughts false", config: &GenerationConfig{IncludeThoughts: ptrTo(false)}},
+		{name: "no stop sequences", config: &GenerationConfig{StopSequences: []string{}}},
+		{
+			name: "everything set",
+			config: &GenerationConfig{
+				Temperature: ptrTo[float32](0.2), TopP: ptrTo[float32](0.9), TopK: ptrTo[int32](40), MaxOutputTokens: ptrTo[int32](100),
+				StopSequences: []string{"END"}, Seed: ptrTo[int64](7), ThinkingBudget: ptrTo[int32](1024), IncludeThoughts: ptrTo(true),
+			},
+			want: all,
+		},
+		{
+			name:   "in the order given",
+			config: &GenerationConfig{Seed: ptrTo[int64](7), TopK: ptrTo[int32](40), ThinkingBudget: ptrTo[int32](0)},
+			want:   []string{GenerationSettingTopK, GenerationSettingSeed, GenerationSettingThinkingBudget},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.config.checkSupported("test", all...)
+			if got := ignoredSettings(t, err); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got ignored settings %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	err := (&GenerationConfig{TopK: ptrTo[int32](40), Seed: ptrTo[int64](7)}).checkSupported("test", all...)
+	if want := "test provider ignores generation settings: topK, seed"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestGeminiSetGenerationConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		seed        int64
+		wantSeed    *int32
+		wantIgnored []string
+	}{
+		{name: "in range", seed: 42, wantSeed: ptrTo[int32](42)},
+		{name: "largest", seed: math.MaxInt32, wantSeed: ptrTo[int32](math.MaxInt32)},
+		{name: "smallest", seed: math.MinInt32, wantSeed: ptrTo[int32](math.MinInt32)},
+		// Converting these would wrap around to a different seed
+		{name: "too large", seed: math.MaxInt32 + 1, wantIgnored: []string{GenerationSettingSeed}},
+		{name: "too small", seed: math.MinInt32 - 1, wantIgnored: []string{GenerationSettingSeed}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &GenerationConfig{Temperature: ptrTo[float32](0.2), TopK: ptrTo[int32](40), Seed: ptrTo(test.seed)}
+			c := &GoogleAIClient{}
+			err := c.SetGenerationConfig(config)
+			if got := ignoredSettings(t, err); !reflect.DeepEqual(got, test.wantIgnored) {
+				t.Errorf("got ignored settings %q, want %q", got, test.wantIgnored)
+			}
+			if config.Seed == nil || *config.Seed != test.seed {
+				t.Errorf("got the caller's config changed to seed %v", config.Seed)
+			}
+
+			genConfig := &genai.GenerateContentConfig{}
+			c.applyGenerationConfig(genConfig)
+			if !reflect.DeepEqual(genConfig.Seed, test.wantSeed) {
+				t.Errorf("got seed %v, want %v", ptrValue(genConfig.Seed), ptrValue(test.wantSeed))
+			}
+			// The other settings are still applied
+			if genConfig.Temperature == nil || *genConfig.Temperature != 0.2 || genConfig.TopK == nil || *genConfig.TopK != 40 {
+				t.Errorf("got temperature %v and topK %v, want 0.2 and 40", ptrValue(genConfig.Temperature), ptrValue(genConfig.TopK))
+			}
+		})
+	}
+
+	c := &GoogleAIClient{}
+	config := &GenerationConfig{TopK: ptrTo[int32](40), ThinkingBudget: ptrTo[int32](1024), IncludeThoughts: ptrTo(true)}
+	if err := c.SetGenerationConfig(config); err != nil {
+		t.Errorf("SetGenerationConfig failed: %v", err)
+	}
+	if err := c.SetGenerationConfig(nil); err != nil {
+		t.Errorf("SetGenerationConfig(nil) failed: %v", err)
+	}
+}
+
+func TestOpenAISetGenerationConfig(t *testing.T) {
+	config := &GenerationConfig{
+		Temperature: ptrTo[float32](0.5), TopK: ptrTo[int32](40), MaxOutputTokens: ptrTo[int32](100),
+		StopSequences: []string{"END"}, Seed: ptrTo[int64](math.MaxInt32 + 1), ThinkingBudget: ptrTo[int32](1024),
+	}
+	want := []string{GenerationSettingTopK, GenerationSettingThinkingBudget}
+
+	c := &OpenAIClient{}
+	if got := ignoredSettings(t, c.SetGenerationConfig(config)); !reflect.DeepEqual(got, want) {
+		t.Errorf("openai: got ignored settings %q, want %q", got, want)
+	}
+	chatReq := openai.ChatCompletionNewParams{}
+	applyOpenAIGenerationConfig(c.generationConfig, &chatReq)
+	// OpenAI seeds are 64-bit
+	if chatReq.Temperature.Value != 0.5 || chatReq.MaxCompletionTokens.Value != 100 || chatReq.Seed.Value != math.MaxInt32+1 ||
+		!reflect.DeepEqual(chatReq.Stop.OfChatCompletionNewsStopArray, []string{"END"}) {
+		t.Errorf("got request temperature %v, max tokens %v, seed %v and stop %q", chatReq.Temperature.Value,
+			chatReq.MaxCompletionTokens.Value, chatReq.Seed.Value, chatReq.Stop.OfChatCompletionNewsStopArray)
+	}
+
+	azureClient := &AzureOpenAIClient{}
+	if got := ignoredSettings(t, azureClient.SetGenerationConfig(config)); !reflect.DeepEqual(got, want) {
+		t.Errorf("azopenai: got ignored settings %q, want %q", got, want)
+	}
+	params := toAzureGenerationParams(azureClient.generationConfig)
+	wantParams := azureGenerationParams{Temperature: config.Temperature, MaxTokens: config.MaxOutputTokens, Stop: config.StopSequences, Seed: config.Seed}
+	if !reflect.DeepEqual(params, wantParams) {
+		t.Errorf("got params %+v, want %+v", params, wantParams)
+	}
+}
+
+func TestOllamaSetGenerationConfig(t *testing.T) {
+	c := &OllamaClient{}
+	config := &GenerationConfig{
+		Temperature: ptrTo[float32](0.5), TopK: ptrTo[int32](40), MaxOutputTokens: ptrTo[int32](100),
+		StopSequences: []string{"END"}, Seed: ptrTo[int64](7), ThinkingBudget: ptrTo[int32](1024),
+	}
+	if got, want := ignoredSettings(t, c.SetGenerationConfig(config)), []string{GenerationSettingThinkingBudget}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got ignored settings %q, want %q", got, want)
+	}
+	wantOptions := map[string]any{
+		"temperature": float32(0.5), "top_k": int32(40), "num_predict": int32(100), "stop": []string{"END"}, "seed": int64(7),
+	}
+	if !reflect.DeepEqual(c.options, wantOptions) {
+		t.Errorf("got options %v, want %v", c.options, wantOptions)
+	}
+
+	// Clearing the config clears the options
+	if err := c.SetGenerationConfig(nil); err != nil {
+		t.Errorf("SetGenerationConfig(nil) failed: %v", err)
+	}
+	if c.options != nil {
+		t.Errorf("got options %v after clearing the config, want none", c.options)
+	}
+}
+
+func TestLlamaCppSetGenerationConfig(t *testing.T) {
+	c := &LlamaCppClient{}
+	config := &GenerationConfig{
+		TopK: ptrTo[int32](40), Seed: ptrTo[int64](7), ThinkingBudget: ptrTo[int32](1024), IncludeThoughts: ptrTo(true),
+	}
+	if got, want := ignoredSettings(t, c.SetGenerationConfig(config)), []string{GenerationSettingThinkingBudget}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got ignored settings %q, want %q", got, want)
+	}
+	req := c.StartChat("", "model").(*LlamaCppChat).newChatRequest()
+	if req.TopK != config.TopK || req.Seed != config.Seed {
+		t.Errorf("got request topK %v and seed %v, want 40 and 7", ptrValue(req.TopK), ptrValue(req.Seed))
+	}
+}
@@ -12,6 +12,7 @@ require (
 	github.com/openai/openai-go v0.1.0-beta.10 // Reverted to latest beta
 	google.golang.org/genai v1.0.0
 	k8s.io/klog/v2 v2.130.1
+	sigs.k8s.io/yaml v1.4.0
 )
 
 require (
@@ -36,6 +36,10 @@ type Client interface {
 	// Calling with nil will clear the current schema.
 	SetResponseSchema(schema *Schema) error
 
+	// SetGenerationConfig configures generation settings (temperature, max tokens etc) for subsequent requests.
+	// If the provider cannot honor some of the settings, it applies the rest and returns an UnsupportedGenerationSettingsError.
+	SetGenerationConfig(config *GenerationConfig) error
+
 	// ListModels lists the models available in the LLM.
 	ListModels(ctx context.Context) ([]string, error)
 }
@@ -63,8 +67,16 @@ type Chat interface {
 type CompletionRequest struct {
 	Model  string `json:"model,omitempty"`
 	Prompt string `json:"prompt,omitempty"`
+
+	// Task optionally names the kind of sub-task (e.g. TaskClassify),
+	// allowing routing clients to serve it with a cheaper model.
+	Task string `json:"task,omitempty"`
 }
 
+// TaskClassify is the Task of requests that only need a short verdict on some text,
+// such as whether the output of a command is what was expected.
+const TaskClassify = "classify"
+
 // CompletionResponse is a response from the GenerateCompletion method.
 type CompletionResponse interface {
 	Response() string
@@ -89,17 +101,29 @@ type FunctionDefinition struct {
 }
 
 // Schema is a schema for a function definition.
+// It is a subset of JSON Schema; see ToJSONSchema for the standard representation.
 type Schema struct {
 	Type        SchemaType         `json:"type,omitempty"`
 	Properties  map[string]*Schema `json:"properties,omitempty"`
 	Items       *Schema            `json:"items,omitempty"`
 	Description string             `json:"description,omitempty"`
 	Required    []string           `json:"required,omitempty"`
+
+	// Enum restricts the value to one of the listed values.
+	Enum []string `json:"enum,omitempty"`
+	// Format is a hint about the format of a string or number, such as "date-time" or "int64".
+	Format string `json:"format,omitempty"`
+	// Default is the value assumed when the field is omitted.
+	Default any `json:"default,omitempty"`
+	// Nullable indicates that null is allowed in addition to values of Type.
+	Nullable bool `json:"nullable,omitempty"`
+	// AdditionalProperties is the schema for object properties not listed in Properties, as for maps.
+	AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
 }
 
-// ToRawSchema converts a Schema to a json.RawMessage.
+// ToRawSchema converts a Schema to a json.RawMessage, in standard JSON Schema form.
 func (s *Schema) ToRawSchema() (json.RawMessage, error) {
-	jsonSchema, err := json.Marshal(s)
+	jsonSchema, err := json.Marshal(s.ToJSONSchema())
 	if err != nil {
 		return nil, fmt.Errorf("converting tool schema to json: %w", err)
 	}
@@ -120,6 +144,7 @@ const (
 	TypeString  SchemaType = "string"
 	TypeBoolean SchemaType = "boolean"
 	TypeInteger SchemaType = "integer"
+	TypeNumber  SchemaType = "number"
 )
 
 // FunctionCallResult is the result of a function call.
@@ -165,4 +190,8 @@ type Part interface {
 	// AsFunctionCalls returns the function calls of the part.
 	// if the part is not a function call, it returns (nil, false)
 	AsFunctionCalls() ([]FunctionCall, bool)
+
+	// AsThinking returns the reasoning ("thinking") of the part, which is not part of the answer.
+	// if the part is not reasoning, it returns ("", false)
+	AsThinking() (string, bool)
 }
@@ -18,11 +18,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 
 	"k8s.io/klog/v2"
 )
@@ -39,6 +41,9 @@ type LlamaCppClient struct {
 	baseURL        *url.URL
 	httpClient     *http.Client
 	responseSchema *llamacppSchema
+
+	// generationConfig holds the generation settings, or nil to use the server defaults.
+	generationConfig *GenerationConfig
 }
 
 type LlamaCppChat struct {
@@ -77,6 +82,14 @@ func (c *LlamaCppClient) GenerateCompletion(ctx context.Context, request *Comple
 		Prompt:     request.Prompt,
 		JSONSchema: c.responseSchema,
 	}
+	if config := c.generationConfig; config != nil {
+		llamacppRequest.Temperature = config.Temperature
+		llamacppRequest.TopP = config.TopP
+		llamacppRequest.TopK = config.TopK
+		llamacppRequest.NPredict = config.MaxOutputTokens
+		llamacppRequest.Stop = config.StopSequences
+		llamacppRequest.Seed = config.Seed
+	}
 
 	llamacppResponse, err := c.doCompletion(ctx, llamacppRequest)
 	if err != nil {
@@ -92,38 +105,70 @@ func (c *LlamaCppClient) GenerateCompletion(ctx context.Context, request *Comple
 }
 
 func (c *LlamaCppClient) doRequest(ctx context.Context, httpMethod, relativePath string, req any, response any) error {
+	httpResponse, err := c.startRequest(ctx, httpMethod, relativePath, req)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	b, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %w", err)
+	}
+
+	if err := json.Unmarshal(b, response); err != nil {
+		return fmt.Errorf("unmarshalling json response: %w", err)
+	}
+
+	return nil
+}
+
+// startRequest sends the request, returning the response if the server replied with a 200 status.
+// The caller must close the response body.
+func (c *LlamaCppClient) startRequest(ctx context.Context, httpMethod, relativePath string, req any) (*http.Response, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("building json body: %w", err)
+		return nil, fmt.Errorf("building json body: %w", err)
 	}
 	u := c.baseURL.JoinPath(relativePath)
 	klog.V(2).Infof("sending %s request to %v: %v", httpMethod, u.String(), string(body))
 	httpRequest, err := http.NewRequestWithContext(ctx, httpMethod, u.String(), bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("building http request: %w", err)
+		return nil, fmt.Errorf("building http request: %w", err)
 	}
 	httpRequest.Header.Set("Content-Type", "application/json")
 
 	httpResponse, err := c.httpClient.Do(httpRequest)
 	if err != nil {
-		return fmt.Errorf("performing http request: %w", err)
-	}
-	defer httpResponse.Body.Close()
-
-	b, err := io.ReadAll(httpResponse.Body)
-	if err != nil {
-		return fmt.Errorf("reading response body: %w", err)
+		return nil, fmt.Errorf("performing http request: %w", err)
 	}
 
 	if httpResponse.StatusCode != 200 {
-		return fmt.Errorf("unexpected http status: %q with response %q", httpResponse.Status, string(b))
+		defer httpResponse.Body.Close()
+		b, err := io.ReadAll(httpResponse.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading response body: %w", err)
+		}
+		return nil, &APIError{
+			StatusCode: httpResponse.StatusCode,
+			Message:    string(b),
+			Err:        fmt.Errorf("unexpected http status: %q", httpResponse.Status),
+			RetryAfter: ParseRetryAfterHeader(httpResponse.Header),
+		}
 	}
 
-	if err := json.Unmarshal(b, response); err != nil {
-		return fmt.Errorf("unmarshalling json response: %w", err)
+	return httpResponse, nil
+}
+
+// doStreamingRequest sends the request and calls onEvent for each server-sent event, until the stream ends.
+func (c *LlamaCppClient) doStreamingRequest(ctx context.Context, httpMethod, relativePath string, req any, onEvent func(data []byte) error) error {
+	httpResponse, err := c.startRequest(ctx, httpMethod, relativePath, req)
+	if err != nil {
+		return err
 	}
+	defer httpResponse.Body.Close()
 
-	return nil
+	return readServerSentEvents(httpResponse.Body, onEvent)
 }
 
 func (c *LlamaCppClient) doCompletion(ctx context.Context, req *llamacppCompletionRequest) (*llamacppCompletionResponse, error) {
@@ -152,6 +197,30 @@ func (c *LlamaCppClient) SetResponseSchema(responseSchema *Schema) error {
 	return nil
 }
 
+// SetGenerationConfig sets the generation settings; llama.cpp has no thinking budget, so that is ignored.
+func (c *LlamaCppClient) SetGenerationConfig(config *GenerationConfig) error {
+	c.generationConfig = config
+	return config.checkSupported("llamacpp", GenerationSettingThinkingBudget)
+}
+
+// newChatRequest builds a chat request for the current history, with the configured generation settings.
+func (c *LlamaCppChat) newChatRequest() *llamacppChatRequest {
+	req := &llamacppChatRequest{
+		Model:    c.model,
+		Messages: c.history,
+		Tools:    c.tools,
+	}
+	if config := c.client.generationConfig; config != nil {
+		req.Temperature = config.Temperature
+		req.TopP = config.TopP
+		req.TopK = config.TopK
+		req.MaxTokens = config.MaxOutputTokens
+		req.Stop = config.StopSequences
+		req.Seed = config.Seed
+	}
+	return req
+}
+
 func (c *LlamaCppClient) StartChat(systemPrompt, model string) Chat {
 	return &LlamaCppChat{
 		client: c,
@@ -179,42 +248,19 @@ func (r *LlamaCppCompletionResponse) UsageMetadata() any {
 
 func (c *LlamaCppChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
 	log := klog.FromContext(ctx)
-	for _, content := range contents {
-		switch v := content.(type) {
-		case string:
-			message := llamacppChatMessage{
-				Role:    "user",
-				Content: ptrTo(v),
-			}
-			c.history = append(c.history, message)
-		case FunctionCallResult:
-			resultJSON, err := json.Marshal(v.Result)
-			if err != nil {
-				return nil, fmt.Errorf("marshalling function call result: %w", err)
-			}
-
-			message := llamacppChatMessage{
-				Role: "tool",
-				// TODO: Do we need ToolCallID?  ToolCallID: toolCallId,
-				Content: ptrTo(string(resultJSON)),
-			}
-			c.history = append(c.history, message)
-		default:
-			return nil, fmt.Errorf("unsupported content type: %T", v)
-		}
+	historyLen := len(c.history)
+	if err := c.addContents(contents); err != nil {
+		return nil, err
 	}
 
-	req := &llamacppChatRequest{
-		Model:    c.model,
-		Messages: c.history,
-		// Stream:   ptrTo(false),
-		Tools: c.tools,
-	}
+	req := c.newChatRequest()
 
 	var llmacppResponse *LlamaCppChatResponse
 
 	resp, err := c.client.doChat(ctx, req)
 	if err != nil {
+		// The request is dropped from the history, so that a retry does not send it twice
+		c.history = c.history[:historyLen]
 		return nil, err
 	}
 
@@ -226,26 +272,23 @@ func (c *LlamaCppChat) Send(ctx context.Context, contents ...any) (ChatResponse,
 		candidate := &LlamaCppCandidate{}
 
 		if choice.Message != nil && choice.Message.Content != nil {
-			parts := &LlamaCppPart{
-				text: *choice.Message.Content,
+			// The reasoning is returned separately if the server runs with --reasoning-format,
+			// otherwise reasoning models return it inline in <think> tags.
+			thinking, text := splitThinking(*choice.Message.Content)
+			if choice.Message.ReasoningContent != nil {
+				thinking = *choice.Message.ReasoningContent
+			}
+			if thinking != "" {
+				candidate.parts = append(candidate.parts, &LlamaCppPart{thinking: thinking})
+			}
+			if text != "" {
+				candidate.parts = append(candidate.parts, &LlamaCppPart{text: text})
 			}
-			candidate.parts = append(candidate.parts, parts)
 		}
 		if choice.Message != nil && len(choice.Message.ToolCalls) != 0 {
-			var functionCalls []FunctionCall
-			for _, toolCall := range choice.Message.ToolCalls {
-				functionCall := FunctionCall{
-					Name: toolCall.Function.Name,
-				}
-
-				if toolCall.Function.Arguments != "" {
-					arguments := make(map[string]any)
-					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &arguments); err != nil {
-						return nil, fmt.Errorf("parsing function call arguments: %w", err)
-					}
-					functionCall.Arguments = arguments
-				}
-				functionCalls = append(functionCalls, functionCall)
+			functionCalls, err := llamacppToFunctionCalls(choice.Message.ToolCalls)
+			if err != nil {
+				return nil, err
 			}
 
 			parts := &LlamaCppPart{
@@ -271,24 +314,211 @@ func (c *LlamaCppChat) Send(ctx context.Context, contents ...any) (ChatResponse,
 	return llmacppResponse, nil
 }
 
+// newLlamaCppStreamingResponse builds the response for a streaming chunk.
+func newLlamaCppStreamingResponse(chunk *llamacppChatResponse, thinking string, text string) *LlamaCppChatResponse {
+	candidate := &LlamaCppCandidate{}
+	if thinking != "" {
+		candidate.parts = append(candidate.parts, &LlamaCppPart{thinking: thinking})
+	}
+	if text != "" {
+		candidate.parts = append(candidate.parts, &LlamaCppPart{text: text})
+	}
+	return &LlamaCppChatResponse{
+		LlamaCppResponse: *chunk,
+		candidates:       []*LlamaCppCandidate{candidate},
+	}
+}
+
+// addContents appends the user contents to the chat history.
+func (c *LlamaCppChat) addContents(contents []any) error {
+	// Convert all the contents before touching the history, so that a rejected message leaves no partial turn behind.
+	var messages []llamacppChatMessage
+	for _, content := range contents {
+		switch v := content.(type) {
+		case string:
+			message := llamacppChatMessage{
+				Role:    "user",
+				Content: ptrTo(v),
+			}
+			messages = append(messages, message)
+		case FileContent:
+			message := llamacppChatMessage{
+				Role:    "user",
+				Content: ptrTo(v.AsText()),
+			}
+			messages = append(messages, message)
+		case ImageContent:
+			return &UnsupportedContentError{Provider: "llamacpp", Model: c.model, ContentType: "image"}
+		case FunctionCallResult:
+			resultJSON, err := json.Marshal(v.Result)
+			if err != nil {
+				return fmt.Errorf("marshalling function call result: %w", err)
+			}
+
+			message := llamacppChatMessage{
+				Role:       "tool",
+				ToolCallID: v.ID,
+				Content:    ptrTo(string(resultJSON)),
+			}
+			messages = append(messages, message)
+		default:
+			return fmt.Errorf("unsupported content type: %T", v)
+		}
+	}
+	c.history = append(c.history, messages...)
+	return nil
+}
+
 func (c *LlamaCppChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
-	// TODO: Implement streaming
-	response, err := c.Send(ctx, contents...)
-	if err != nil {
+	log := klog.FromContext(ctx)
+	historyLen := len(c.history)
+	if err := c.addContents(contents); err != nil {
 		return nil, err
 	}
-	return singletonChatResponseIterator(response), nil
+
+	req := c.newChatRequest()
+	req.Stream = true
+
+	return func(yield func(ChatResponse, error) bool) {
+		var content strings.Builder
+		// Reasoning models stream their reasoning inline in <think> tags, unless the server separates it
+		splitter := &thinkTagSplitter{}
+		// Tool calls are streamed as deltas, keyed by index; the name and id arrive first, then fragments of the arguments.
+		var toolCalls []llamacppToolCall
+
+		onEvent := func(data []byte) error {
+			chunk := &llamacppChatResponse{}
+			if err := json.Unmarshal(data, chunk); err != nil {
+				return fmt.Errorf("unmarshalling streaming chunk: %w", err)
+			}
+			log.V(2).Info("received streaming chunk from llama.cpp", "chunk", chunk)
+
+			for _, choice := range chunk.Choices {
+				if choice.Index != 0 || choice.Delta == nil {
+					continue
+				}
+				for _, delta := range choice.Delta.ToolCalls {
+					for len(toolCalls) <= delta.Index {
+						toolCalls = append(toolCalls, llamacppToolCall{Type: "function"})
+					}
+					toolCall := &toolCalls[delta.Index]
+					if delta.ID != "" {
+						toolCall.ID = delta.ID
+					}
+					if delta.Function.Name != "" {
+						toolCall.Function.Name += delta.Function.Name
+					}
+					toolCall.Function.Arguments += delta.Function.Arguments
+				}
+				thinking := ""
+				if choice.Delta.ReasoningContent != nil {
+					thinking = *choice.Delta.ReasoningContent
+				}
+				if choice.Delta.Content != nil && *choice.Delta.Content != "" {
+					content.WriteString(*choice.Delta.Content)
+					moreThinking, text := splitter.split(*choice.Delta.Content)
+					thinking += moreThinking
+					if text != "" || thinking != "" {
+						if !yield(newLlamaCppStreamingResponse(chunk, thinking, text), nil) {
+							return errStopStreaming
+						}
+					}
+				} else if thinking != "" {
+					if !yield(newLlamaCppStreamingResponse(chunk, thinking, ""), nil) {
+						return errStopStreaming
+					}
+				}
+			}
+			return nil
+		}
+
+		err := c.client.doStreamingRequest(ctx, "POST", "v1/chat/completions", req, onEvent)
+		if err != nil && !errors.Is(err, errStopStreaming) {
+			// The request is dropped from the history, so that a retry does not send it twice
+			c.history = c.history[:historyLen]
+			yield(nil, err)
+			return
+		}
+
+		msg := llamacppChatMessage{
+			Role:      "assistant",
+			ToolCalls: toolCalls,
+		}
+		if content.Len() != 0 {
+			msg.Content = ptrTo(content.String())
+		}
+		if msg.Content != nil || len(msg.ToolCalls) != 0 {
+			c.history = append(c.history, msg)
+		}
+
+		if err != nil {
+			return
+		}
+
+		if thinking, text := splitter.flush(); thinking != "" || text != "" {
+			if !yield(newLlamaCppStreamingResponse(&llamacppChatResponse{}, thinking, text), nil) {
+				return
+			}
+		}
+
+		// We only know the function calls are complete once the stream ends, so we send them last.
+		if len(toolCalls) != 0 {
+			functionCalls, err := llamacppToFunctionCalls(toolCalls)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			response := &LlamaCppChatResponse{
+				candidates: []*LlamaCppCandidate{{parts: []*LlamaCppPart{{functionCalls: functionCalls}}}},
+			}
+			yield(response, nil)
+		}
+	}, nil
+}
+
+// llamacppToFunctionCalls converts tool calls returned by llama.cpp, parsing the JSON arguments.
+func llamacppToFunctionCalls(toolCalls []llamacppToolCall) ([]FunctionCall, error) {
+	var functionCalls []FunctionCall
+	for _, toolCall := range toolCalls {
+		functionCall := FunctionCall{
+			ID:   toolCall.ID,
+			Name: toolCall.Function.Name,
+		}
+
+		if toolCall.Function.Arguments != "" {
+			arguments := make(map[string]any)
+			if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &arguments); err != nil {
+				return nil, fmt.Errorf("parsing function call arguments: %w", err)
+			}
+			functionCall.Arguments = arguments
+		}
+		functionCalls = append(functionCalls, functionCall)
+	}
+	return functionCalls, nil
 }
 
 func (c *LlamaCppChat) IsRetryableError(err error) bool {
-	// TODO(droot): Implement this
-	return false
+	return c.ClassifyError(err).Retryable
+}
+
+// ClassifyError classifies errors from the llama.cpp server; see doRequest for how APIError is populated.
+func (c *LlamaCppChat) ClassifyError(err error) ErrorClassification {
+	return DefaultClassifyError(err)
 }
 
 func ptrTo[T any](t T) *T {
 	return &t
 }
 
+// ptrValue returns the value p points to, or the zero value if p is nil.
+func ptrValue[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
+
 type LlamaCppChatResponse struct {
 	candidates       []*LlamaCppCandidate
 	LlamaCppResponse llamacppChatResponse
@@ -335,7 +565,11 @@ type LlamaCppCandidate struct {
 }
 
 func (r *LlamaCppCandidate) String() string {
-	return r.parts[0].text
+	var sb strings.Builder
+	for _, part := range r.parts {
+		sb.WriteString(part.text)
+	}
+	return sb.String()
 }
 
 func (r *LlamaCppCandidate) Parts() []Part {
@@ -348,9 +582,14 @@ func (r *LlamaCppCandidate) Parts() []Part {
 
 type LlamaCppPart struct {
 	text          string
+	thinking      string
 	functionCalls []FunctionCall
 }
 
+func (p *LlamaCppPart) AsThinking() (string, bool) {
+	return p.thinking, p.thinking != ""
+}
+
 func (p *LlamaCppPart) AsText() (string, bool) {
 	if len(p.text) > 0 {
 		return p.text, true
@@ -398,10 +637,17 @@ func toLlamacppSchema(in *Schema) *llamacppSchema {
 	}
 
 	out := &llamacppSchema{
-		Type:        string(in.Type),
-		Items:       toLlamacppSchema(in.Items),
-		Description: in.Description,
-		Required:    in.Required,
+		Type:                 string(in.Type),
+		Items:                toLlamacppSchema(in.Items),
+		Description:          in.Description,
+		Required:             in.Required,
+		Enum:                 in.Enum,
+		Format:               in.Format,
+		Default:              in.Default,
+		AdditionalProperties: toLlamacppSchema(in.AdditionalProperties),
+	}
+	if in.Nullable {
+		out.Type = []string{string(in.Type), "null"}
 	}
 
 	if in.Properties != nil {
@@ -420,6 +666,13 @@ type llamacppCompletionRequest struct {
 	Prompt string `json:"prompt,omitempty"`
 
 	JSONSchema *llamacppSchema `json:"json_schema,omitempty"`
+
+	Temperature *float32 `json:"temperature,omitempty"`
+	TopP        *float32 `json:"top_p,omitempty"`
+	TopK        *int32   `json:"top_k,omitempty"`
+	NPredict    *int32   `json:"n_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Seed        *int64   `json:"seed,omitempty"`
 }
 
 type llamacppCompletionResponse struct {
@@ -470,6 +723,14 @@ type llamacppChatRequest struct {
 	Model    string                `json:"model,omitempty"`
 	Messages []llamacppChatMessage `json:"messages,omitempty"`
 	Tools    []llamacppTool        `json:"tools,omitempty"`
+	Stream   bool                  `json:"stream,omitempty"`
+
+	Temperature *float32 `json:"temperature,omitempty"`
+	TopP        *float32 `json:"top_p,omitempty"`
+	TopK        *int32   `json:"top_k,omitempty"`
+	MaxTokens   *int32   `json:"max_tokens,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Seed        *int64   `json:"seed,omitempty"`
 }
 
 type llamacppChatResponse struct {
@@ -487,6 +748,8 @@ type llamacppChoice struct {
 	FinishReason string               `json:"finish_reason,omitempty"`
 	Index        int32                `json:"index,omitempty"`
 	Message      *llamacppChatMessage `json:"message,omitempty"`
+	// Delta is populated instead of Message when streaming.
+	Delta *llamacppChatMessage `json:"delta,omitempty"`
 }
 
 type llamacppUsage struct {
@@ -496,13 +759,18 @@ type llamacppUsage struct {
 }
 
 type llamacppChatMessage struct {
-	Role       string             `json:"role,omitempty"`
-	Content    *string            `json:"content,omitempty"`
-	ToolCalls  []llamacppToolCall `json:"tool_calls,omitempty"`
-	ToolCallID string             `json:"tool_call_id,omitempty"`
+	Role    string  `json:"role,omitempty"`
+	Content *string `json:"content,omitempty"`
+	// ReasoningContent is returned (but never sent) when the server separates the reasoning of reasoning models.
+	ReasoningContent *string            `json:"reasoning_content,omitempty"`
+	ToolCalls        []llamacppToolCall `json:"tool_calls,omitempty"`
+	ToolCallID       string             `json:"tool_call_id,omitempty"`
 }
 
 type llamacppToolCall struct {
+	// Index identifies the tool call that a streaming delta belongs to.
+	Index    int                  `json:"index,omitempty"`
+	ID       string               `json:"id,omitempty"`
 	Type     string               `json:"type,omitempty"`
 	Function llamacppFunctionCall `json:"function,omitempty"`
 }
@@ -525,10 +793,14 @@ type llamacppFunction struct {
 }
 
 type llamacppSchema struct {
-	Type        string                    `json:"type,omitempty"`
-	Required    []string                  `json:"required,omitempty"`
-	Items       *llamacppSchema           `json:"items,omitempty"`
-	Properties  map[string]llamacppSchema `json:"properties,omitempty"`
-	Description string                    `json:"description,omitempty"`
-	Enum        []string                  `json:"enum,omitempty"`
+	// Type is a string, or a list of strings for nullable types.
+	Type                 any                       `json:"type,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Items                *llamacppSchema           `json:"items,omitempty"`
+	Properties           map[string]llamacppSchema `json:"properties,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Default              any                       `json:"default,omitempty"`
+	AdditionalProperties *llamacppSchema           `json:"additionalProperties,omitempty"`
 }
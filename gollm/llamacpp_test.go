@@ -0,0 +1,123 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestLlamaCppChatRejectsImagesWithoutChangingHistory(t *testing.T) {
+	chat := &LlamaCppChat{model: "model", history: []llamacppChatMessage{{Role: "system", Content: ptrTo("system")}}}
+
+	// The request fails before it is sent, so no server is needed
+	_, err := chat.Send(context.Background(), "what is in this image?", ImageContent{Data: []byte("png"), MIMEType: "image/png"})
+	var unsupported *UnsupportedContentError
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("got error %v, want an *UnsupportedContentError", err)
+	}
+	if len(chat.history) != 1 {
+		t.Errorf("got %d messages in history, want only the system prompt", len(chat.history))
+	}
+}
+
+// newLlamaCppTestServer returns a client for a server that answers chat requests with the streaming responses
+// in order, and the messages of the requests it received.
+func newLlamaCppTestServer(t *testing.T, responses ...string) (*LlamaCppClient, *[][]llamacppChatMessage) {
+	t.Helper()
+
+	var requests [][]llamacppChatMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llamacppChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		requests = append(requests, req.Messages)
+		if len(requests) > len(responses) {
+			http.Error(w, "no more responses", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, responses[len(requests)-1])
+	}))
+	t.Cleanup(server.Close)
+
+	baseURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &LlamaCppClient{baseURL: baseURL, httpClient: server.Client()}, &requests
+}
+
+func TestLlamaCppChatSendStreaming(t *testing.T) {
+	client, requests := newLlamaCppTestServer(t,
+		sseBody(splitToolCallEvents...),
+		sseBody(`{"choices":[{"index":0,"delta":{"content":"There are no pods."}}]}`),
+	)
+	chat := client.StartChat("system", "model")
+
+	stream, err := chat.SendStreaming(context.Background(), "what is running?")
+	if err != nil {
+		t.Fatalf("SendStreaming failed: %v", err)
+	}
+	text, calls, err := readStreamAndCalls(stream)
+	if err != nil {
+		t.Fatalf("reading stream: %v", err)
+	}
+	if text != "Let me check." {
+		t.Errorf("got text %q, want %q", text, "Let me check.")
+	}
+	if !reflect.DeepEqual(calls, wantSplitToolCalls) {
+		t.Errorf("got function calls %+v, want %+v", calls, wantSplitToolCalls)
+	}
+
+	stream, err = chat.SendStreaming(context.Background(),
+		FunctionCallResult{ID: "call-1", Name: "kubectl", Result: map[string]any{"stdout": "No resources found"}},
+		FunctionCallResult{ID: "call-2", Name: "bash", Result: map[string]any{"stdout": ""}},
+	)
+	if err != nil {
+		t.Fatalf("SendStreaming failed: %v", err)
+	}
+	if text, _, err := readStreamAndCalls(stream); err != nil || text != "There are no pods." {
+		t.Fatalf("got (%q, %v) from the second stream, want the text", text, err)
+	}
+
+	// The second request carries the assembled response, and the results answer its calls
+	messages := (*requests)[1]
+	var roles []string
+	for _, message := range messages {
+		roles = append(roles, message.Role)
+	}
+	if got, want := fmt.Sprint(roles), "[system user assistant tool tool]"; got != want {
+		t.Fatalf("got roles %s in the second request, want %s", got, want)
+	}
+	assistant := messages[2]
+	if assistant.Content == nil || *assistant.Content != "Let me check." || len(assistant.ToolCalls) != 2 {
+		t.Fatalf("got assistant message %+v, want the text and both tool calls", assistant)
+	}
+	if call := assistant.ToolCalls[1]; call.ID != "call-2" || call.Function.Name != "bash" || call.Function.Arguments != `{"command":"ls"}` {
+		t.Errorf("got second tool call %+v, want bash with its arguments reassembled", call)
+	}
+	if messages[3].ToolCallID != "call-1" || messages[4].ToolCallID != "call-2" {
+		t.Errorf("got tool messages for %q and %q, want call-1 and call-2", messages[3].ToolCallID, messages[4].ToolCallID)
+	}
+}
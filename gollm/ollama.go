@@ -17,8 +17,10 @@ package gollm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
+	"strings"
 
 	"github.com/ollama/ollama/api"
 	"k8s.io/klog/v2"
@@ -38,6 +40,9 @@ const (
 
 type OllamaClient struct {
 	client *api.Client
+
+	// options holds the generation settings, in ollama's format.
+	options map[string]any
 }
 
 type OllamaChat struct {
@@ -45,6 +50,10 @@ type OllamaChat struct {
 	model   string
 	history []api.Message
 	tools   []api.Tool
+	options map[string]any
+
+	// supportsImages caches whether the model accepts images, once we have checked
+	supportsImages *bool
 }
 
 var _ Client = &OllamaClient{}
@@ -66,9 +75,10 @@ func (c *OllamaClient) Close() error {
 
 func (c *OllamaClient) GenerateCompletion(ctx context.Context, request *CompletionRequest) (CompletionResponse, error) {
 	req := &api.GenerateRequest{
-		Model:  request.Model,
-		Prompt: request.Prompt,
-		Stream: ptrTo(false),
+		Model:   request.Model,
+		Prompt:  request.Prompt,
+		Stream:  ptrTo(false),
+		Options: c.options,
 	}
 
 	var ollamaResponse *OllamaCompletionResponse
@@ -104,10 +114,44 @@ func (c *OllamaClient) SetResponseSchema(schema *Schema) error {
 	return nil
 }
 
+// SetGenerationConfig sets the generation settings; ollama has no thinking budget, so that is ignored.
+func (c *OllamaClient) SetGenerationConfig(config *GenerationConfig) error {
+	c.options = nil
+	if config == nil {
+		return nil
+	}
+
+	// See https://github.com/ollama/ollama/blob/main/docs/modelfile.md#valid-parameters-and-values
+	options := make(map[string]any)
+	if config.Temperature != nil {
+		options["temperature"] = *config.Temperature
+	}
+	if config.TopP != nil {
+		options["top_p"] = *config.TopP
+	}
+	if config.TopK != nil {
+		options["top_k"] = *config.TopK
+	}
+	if config.MaxOutputTokens != nil {
+		options["num_predict"] = *config.MaxOutputTokens
+	}
+	if len(config.StopSequences) != 0 {
+		options["stop"] = config.StopSequences
+	}
+	if config.Seed != nil {
+		options["seed"] = *config.Seed
+	}
+	if len(options) != 0 {
+		c.options = options
+	}
+	return config.checkSupported("ollama", GenerationSettingThinkingBudget)
+}
+
 func (c *OllamaClient) StartChat(systemPrompt, model string) Chat {
 	return &OllamaChat{
-		client: c.client,
-		model:  model,
+		client:  c.client,
+		model:   model,
+		options: c.options,
 		history: []api.Message{
 			{
 				Role:    "system",
@@ -131,56 +175,39 @@ func (r *OllamaCompletionResponse) UsageMetadata() any {
 
 func (c *OllamaChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
 	log := klog.FromContext(ctx)
-	for _, content := range contents {
-		switch v := content.(type) {
-		case string:
-			message := api.Message{
-				Role:    "user",
-				Content: v,
-			}
-			c.history = append(c.history, message)
-		case FunctionCallResult:
-			message := api.Message{
-				Role:    "user",
-				Content: fmt.Sprintf("Function call result: %s", v.Result),
-			}
-			c.history = append(c.history, message)
-		default:
-			return nil, fmt.Errorf("unsupported content type: %T", v)
-		}
+	if err := c.checkImagesSupported(ctx, contents); err != nil {
+		return nil, err
+	}
+	historyLen := len(c.history)
+	if err := c.addContents(contents); err != nil {
+		c.history = c.history[:historyLen]
+		return nil, err
 	}
 
 	req := &api.ChatRequest{
 		Model:    c.model,
 		Messages: c.history,
 		// set streaming to false
-		Stream: new(bool),
-		Tools:  c.tools,
+		Stream:  new(bool),
+		Tools:   c.tools,
+		Options: c.options,
 	}
 
 	var ollamaResponse *OllamaChatResponse
 
 	respFunc := func(resp api.ChatResponse) error {
 		log.Info("recieved response from ollama", "resp", resp)
-		ollamaResponse = &OllamaChatResponse{
-			ollamaResponse: resp,
-			candidates: []*OllamaCandidate{
-				{
-					parts: []OllamaPart{
-						{
-							text:      resp.Message.Content,
-							toolCalls: resp.Message.ToolCalls,
-						},
-					},
-				},
-			},
-		}
+		// Reasoning models such as deepseek-r1 return their reasoning inline, in <think> tags
+		thinking, text := splitThinking(resp.Message.Content)
+		ollamaResponse = newOllamaChatResponse(resp, thinking, text, resp.Message.ToolCalls)
 		c.history = append(c.history, resp.Message)
 		return nil
 	}
 
 	err := c.client.Chat(ctx, req, respFunc)
 	if err != nil {
+		// The request is dropped from the history, so that a retry does not send it twice
+		c.history = c.history[:historyLen]
 		return nil, err
 	}
 
@@ -188,18 +215,169 @@ func (c *OllamaChat) Send(ctx context.Context, contents ...any) (ChatResponse, e
 	return ollamaResponse, nil
 }
 
+// checkImagesSupported returns an UnsupportedContentError if the contents include images and the model has no vision support.
+// Ollama silently ignores images for models that cannot use them, so we check the model details up front.
+func (c *OllamaChat) checkImagesSupported(ctx context.Context, contents []any) error {
+	if !containsImages(contents) {
+		return nil
+	}
+
+	if c.supportsImages == nil {
+		show, err := c.client.Show(ctx, &api.ShowRequest{Model: c.model})
+		if err != nil {
+			return fmt.Errorf("checking whether model %q supports images: %w", c.model, err)
+		}
+		supported := len(show.ProjectorInfo) != 0
+		for key := range show.ModelInfo {
+			if strings.Contains(key, ".vision.") {
+				supported = true
+			}
+		}
+		c.supportsImages = &supported
+	}
+
+	if !*c.supportsImages {
+		return &UnsupportedContentError{Provider: "ollama", Model: c.model, ContentType: "image"}
+	}
+	return nil
+}
+
+// addContents appends the user contents to the chat history.
+func (c *OllamaChat) addContents(contents []any) error {
+	for _, content := range contents {
+		switch v := content.(type) {
+		case string:
+			message := api.Message{
+				Role:    "user",
+				Content: v,
+			}
+			c.history = append(c.history, message)
+		case FileContent:
+			message := api.Message{
+				Role:    "user",
+				Content: v.AsText(),
+			}
+			c.history = append(c.history, message)
+		case ImageContent:
+			message := api.Message{
+				Role:   "user",
+				Images: []api.ImageData{v.Data},
+			}
+			c.history = append(c.history, message)
+		case FunctionCallResult:
+			message := api.Message{
+				Role:    "user",
+				Content: fmt.Sprintf("Function call result: %s", v.Result),
+			}
+			c.history = append(c.history, message)
+		default:
+			return fmt.Errorf("unsupported content type: %T", v)
+		}
+	}
+	return nil
+}
+
+func newOllamaChatResponse(resp api.ChatResponse, thinking string, text string, toolCalls []api.ToolCall) *OllamaChatResponse {
+	var parts []OllamaPart
+	if thinking != "" {
+		parts = append(parts, OllamaPart{thinking: thinking})
+	}
+	parts = append(parts, OllamaPart{
+		text:      text,
+		toolCalls: toolCalls,
+	})
+	return &OllamaChatResponse{
+		ollamaResponse: resp,
+		candidates: []*OllamaCandidate{
+			{
+				parts: parts,
+			},
+		},
+	}
+}
+
 func (c *OllamaChat) IsRetryableError(err error) bool {
-	// TODO(droot): Implement this
-	return false
+	return c.ClassifyError(err).Retryable
 }
 
+// ClassifyError classifies errors from the ollama server.
+// Ollama does not send a retry delay, so RetryAfter is never populated.
+func (c *OllamaChat) ClassifyError(err error) ErrorClassification {
+	if err == nil {
+		return ErrorClassification{}
+	}
+
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		return ClassifyHTTPStatus(statusErr.StatusCode)
+	}
+
+	return DefaultClassifyError(err)
+}
+
+// errStopStreaming is returned from the ollama callback when the consumer stops iterating.
+var errStopStreaming = errors.New("streaming stopped by caller")
+
 func (c *OllamaChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
-	// TODO: Implement streaming
-	response, err := c.Send(ctx, contents...)
-	if err != nil {
+	log := klog.FromContext(ctx)
+	if err := c.checkImagesSupported(ctx, contents); err != nil {
 		return nil, err
 	}
-	return singletonChatResponseIterator(response), nil
+	historyLen := len(c.history)
+	if err := c.addContents(contents); err != nil {
+		c.history = c.history[:historyLen]
+		return nil, err
+	}
+
+	req := &api.ChatRequest{
+		Model:    c.model,
+		Messages: c.history,
+		Stream:   ptrTo(true),
+		Tools:    c.tools,
+		Options:  c.options,
+	}
+
+	return func(yield func(ChatResponse, error) bool) {
+		// Ollama sends the text incrementally, but each tool call arrives whole in a single chunk,
+		// so we only need to concatenate to rebuild the message for the history.
+		assembled := api.Message{Role: "assistant"}
+		// Reasoning models such as deepseek-r1 stream their reasoning inline, in <think> tags
+		splitter := &thinkTagSplitter{}
+		var last api.ChatResponse
+
+		respFunc := func(resp api.ChatResponse) error {
+			log.V(2).Info("received streaming chunk from ollama", "resp", resp)
+			last = resp
+			assembled.Content += resp.Message.Content
+			assembled.ToolCalls = append(assembled.ToolCalls, resp.Message.ToolCalls...)
+
+			thinking, text := splitter.split(resp.Message.Content)
+			if thinking == "" && text == "" && len(resp.Message.ToolCalls) == 0 {
+				return nil
+			}
+			if !yield(newOllamaChatResponse(resp, thinking, text, resp.Message.ToolCalls), nil) {
+				return errStopStreaming
+			}
+			return nil
+		}
+
+		err := c.client.Chat(ctx, req, respFunc)
+		if err != nil && !errors.Is(err, errStopStreaming) {
+			// The request is dropped from the history, so that a retry does not send it twice
+			c.history = c.history[:historyLen]
+			yield(nil, err)
+			return
+		}
+		if assembled.Content != "" || len(assembled.ToolCalls) != 0 {
+			c.history = append(c.history, assembled)
+		}
+		if err != nil {
+			return
+		}
+		if thinking, text := splitter.flush(); thinking != "" || text != "" {
+			yield(newOllamaChatResponse(last, thinking, text, nil), nil)
+		}
+	}, nil
 }
 
 type OllamaChatResponse struct {
@@ -245,6 +423,7 @@ func (r *OllamaCandidate) Parts() []Part {
 	for _, part := range r.parts {
 		parts = append(parts, &OllamaPart{
 			text:      part.text,
+			thinking:  part.thinking,
 			toolCalls: part.toolCalls,
 		})
 	}
@@ -253,9 +432,14 @@ func (r *OllamaCandidate) Parts() []Part {
 
 type OllamaPart struct {
 	text      string
+	thinking  string
 	toolCalls []api.ToolCall
 }
 
+func (p *OllamaPart) AsThinking() (string, bool) {
+	return p.thinking, p.thinking != ""
+}
+
 func (p *OllamaPart) AsText() (string, bool) {
 	if len(p.text) > 0 {
 		return p.text, true
@@ -301,8 +485,7 @@ func fnDefToOllamaTool(fnDef *FunctionDefinition) api.Tool {
 					Enum        []string `json:"enum,omitempty"`
 				} `json:"properties"`
 			}{
-				Type:     "object",
-				Required: fnDef.Parameters.Required,
+				Type: "object",
 				Properties: map[string]struct {
 					Type        string   `json:"type"`
 					Description string   `json:"description"`
@@ -312,6 +495,11 @@ func fnDefToOllamaTool(fnDef *FunctionDefinition) api.Tool {
 		},
 	}
 
+	if fnDef.Parameters == nil {
+		return tool
+	}
+
+	tool.Function.Parameters.Required = fnDef.Parameters.Required
 	for paramName, param := range fnDef.Parameters.Properties {
 		tool.Function.Parameters.Properties[paramName] = struct {
 			Type        string   `json:"type"`
@@ -319,9 +507,36 @@ func fnDefToOllamaTool(fnDef *FunctionDefinition) api.Tool {
 			Enum        []string `json:"enum,omitempty"`
 		}{
 			Type:        string(param.Type),
-			Description: param.Description,
+			Description: ollamaParameterDescription(param),
+			Enum:        param.Enum,
 		}
 	}
 
 	return tool
 }
+
+// ollamaParameterDescription builds the description for a tool parameter.
+// Ollama's tool schema only has a type, description and enum for each parameter,
+// so we describe the other schema features instead.
+func ollamaParameterDescription(param *Schema) string {
+	var notes []string
+	if param.Format != "" {
+		notes = append(notes, fmt.Sprintf("format: %s", param.Format))
+	}
+	if param.Nullable {
+		notes = append(notes, "may be null")
+	}
+	if param.Default != nil {
+		notes = append(notes, fmt.Sprintf("default: %v", param.Default))
+	}
+	if param.Items != nil {
+		notes = append(notes, fmt.Sprintf("items are of type %s", param.Items.Type))
+	}
+	if param.AdditionalProperties != nil {
+		notes = append(notes, fmt.Sprintf("a map of string keys to %s values", param.AdditionalProperties.Type))
+	}
+	if len(notes) == 0 {
+		return param.Description
+	}
+	return fmt.Sprintf("%s (%s)", param.Description, strings.Join(notes, "; "))
+}
@@ -0,0 +1,125 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+)
+
+// newOllamaTestServer returns a client for a server that answers chat requests with the newline-delimited
+// streaming responses in order, and the messages of the requests it received.
+func newOllamaTestServer(t *testing.T, responses ...[]string) (*OllamaClient, *[][]api.Message) {
+	t.Helper()
+
+	var requests [][]api.Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		var req api.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		requests = append(requests, req.Messages)
+		if len(requests) > len(responses) {
+			http.Error(w, `{"error":"no more responses"}`, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, line := range responses[len(requests)-1] {
+			fmt.Fprintln(w, line)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	baseURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &OllamaClient{client: api.NewClient(baseURL, server.Client())}, &requests
+}
+
+func TestOllamaChatSendStreaming(t *testing.T) {
+	// Ollama streams the text in pieces, but sends each tool call whole
+	client, requests := newOllamaTestServer(t,
+		[]string{
+			`{"message":{"role":"assistant","content":"<think>The user wants "}}`,
+			`{"message":{"role":"assistant","content":"pods.</think>Let me "}}`,
+			`{"message":{"role":"assistant","content":"check."}}`,
+			`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"kubectl","arguments":{"command":"kubectl get pods"}}}]}}`,
+			`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"bash","arguments":{"command":"ls"}}}]}}`,
+			`{"message":{"role":"assistant","content":""},"done":true}`,
+		},
+		[]string{
+			`{"message":{"role":"assistant","content":"There are no pods."},"done":true}`,
+		},
+	)
+	chat := client.StartChat("system", "model")
+
+	stream, err := chat.SendStreaming(context.Background(), "what is running?")
+	if err != nil {
+		t.Fatalf("SendStreaming failed: %v", err)
+	}
+	text, calls, err := readStreamAndCalls(stream)
+	if err != nil {
+		t.Fatalf("reading stream: %v", err)
+	}
+	if text != "Let me check." {
+		t.Errorf("got text %q, want the answer without the reasoning", text)
+	}
+	wantCalls := []FunctionCall{
+		{Name: "kubectl", Arguments: map[string]any{"command": "kubectl get pods"}},
+		{Name: "bash", Arguments: map[string]any{"command": "ls"}},
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("got function calls %+v, want %+v", calls, wantCalls)
+	}
+
+	stream, err = chat.SendStreaming(context.Background(),
+		FunctionCallResult{Name: "kubectl", Result: map[string]any{"stdout": "No resources found"}},
+		FunctionCallResult{Name: "bash", Result: map[string]any{"stdout": ""}},
+	)
+	if err != nil {
+		t.Fatalf("SendStreaming failed: %v", err)
+	}
+	if text, _, err := readStreamAndCalls(stream); err != nil || text != "There are no pods." {
+		t.Fatalf("got (%q, %v) from the second stream, want the text", text, err)
+	}
+
+	// The second request carries the assembled response
+	messages := (*requests)[1]
+	var roles []string
+	for _, message := range messages {
+		roles = append(roles, message.Role)
+	}
+	if got, want := strings.Join(roles, ","), "system,user,assistant,user,user"; got != want {
+		t.Fatalf("got roles %q in the second request, want %q", got, want)
+	}
+	assistant := messages[2]
+	if assistant.Content != "<think>The user wants pods.</think>Let me check." || len(assistant.ToolCalls) != 2 {
+		t.Errorf("got assistant message %+v, want the whole text and both tool calls", assistant)
+	}
+}
@@ -43,6 +43,9 @@ func newOpenAIClientFactory(ctx context.Context, _ *url.URL) (Client, error) {
 // OpenAIClient implements the gollm.Client interface for OpenAI models.
 type OpenAIClient struct {
 	client openai.Client
+
+	// generationConfig holds the generation settings, or nil to use the API defaults.
+	generationConfig *GenerationConfig
 }
 
 // Ensure OpenAIClient implements the Client interface.
@@ -92,9 +95,10 @@ func (c *OpenAIClient) StartChat(systemPrompt, model string) Chat {
 	}
 
 	return &openAIChatSession{
-		client:  c.client, // Pass the client from OpenAIClient
-		history: history,
-		model:   model,
+		client:           c.client, // Pass the client from OpenAIClient
+		history:          history,
+		model:            model,
+		generationConfig: c.generationConfig,
 		// functionDefinitions and tools will be set later via SetFunctionDefinitions
 	}
 }
@@ -127,6 +131,7 @@ func (c *OpenAIClient) GenerateCompletion(ctx context.Context, req *CompletionRe
 			openai.UserMessage(req.Prompt),
 		},
 	}
+	applyOpenAIGenerationConfig(c.generationConfig, &chatReq)
 
 	completion, err := c.client.Chat.Completions.New(ctx, chatReq)
 	if err != nil {
@@ -152,6 +157,35 @@ func (c *OpenAIClient) SetResponseSchema(schema *Schema) error {
 	return nil
 }
 
+// SetGenerationConfig sets the generation settings.
+// The chat completions API has no equivalent of topK or a thinking budget, so those are ignored.
+func (c *OpenAIClient) SetGenerationConfig(config *GenerationConfig) error {
+	c.generationConfig = config
+	return config.checkSupported("openai", GenerationSettingTopK, GenerationSettingThinkingBudget, GenerationSettingIncludeThoughts)
+}
+
+// applyOpenAIGenerationConfig copies the supported generation settings onto a chat completion request.
+func applyOpenAIGenerationConfig(config *GenerationConfig, chatReq *openai.ChatCompletionNewParams) {
+	if config == nil {
+		return
+	}
+	if config.Temperature != nil {
+		chatReq.Temperature = openai.Float(float64(*config.Temperature))
+	}
+	if config.TopP != nil {
+		chatReq.TopP = openai.Float(float64(*config.TopP))
+	}
+	if config.MaxOutputTokens != nil {
+		chatReq.MaxCompletionTokens = openai.Int(int64(*config.MaxOutputTokens))
+	}
+	if len(config.StopSequences) != 0 {
+		chatReq.Stop = openai.ChatCompletionNewParamsStopUnion{OfChatCompletionNewsStopArray: config.StopSequences}
+	}
+	if config.Seed != nil {
+		chatReq.Seed = openai.Int(*config.Seed)
+	}
+}
+
 // ListModels is not implemented yet.
 func (c *OpenAIClient) ListModels(ctx context.Context) ([]string, error) {
 	// TODO: Implement listing OpenAI models using c.client
@@ -165,6 +199,7 @@ type openAIChatSession struct {
 	client              openai.Client
 	history             []openai.ChatCompletionMessageParamUnion
 	model               string
+	generationConfig    *GenerationConfig
 	functionDefinitions []*FunctionDefinition            // Stored in gollm format
 	tools               []openai.ChatCompletionToolParam // Stored in OpenAI format
 }
@@ -210,23 +245,34 @@ func (cs *openAIChatSession) Send(ctx context.Context, contents ...any) (ChatRes
 	klog.V(1).InfoS("openAIChatSession.Send called", "model", cs.model, "history_len", len(cs.history))
 
 	// 1. Append user message(s) to history
+	historyLen := len(cs.history)
 	for _, content := range contents {
 		switch c := content.(type) {
 		case string:
 			klog.V(2).Infof("Adding user message to history: %s", c)
 			cs.history = append(cs.history, openai.UserMessage(c))
+		case FileContent:
+			klog.V(2).Infof("Adding attached file to history: %s", c.Name)
+			cs.history = append(cs.history, openai.UserMessage(c.AsText()))
+		case ImageContent:
+			klog.V(2).Infof("Adding attached image to history: %s", c.Name)
+			cs.history = append(cs.history, openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+				openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: c.DataURL()}),
+			}))
 		case FunctionCallResult:
 			klog.V(2).Infof("Adding tool call result to history: Name=%s, ID=%s", c.Name, c.ID)
 			// Marshal the result map into a JSON string for the message content
 			resultJSON, err := json.Marshal(c.Result)
 			if err != nil {
 				klog.Errorf("Failed to marshal function call result: %v", err)
+				cs.history = cs.history[:historyLen]
 				return nil, fmt.Errorf("failed to marshal function call result %q: %w", c.Name, err)
 			}
 			cs.history = append(cs.history, openai.ToolMessage(string(resultJSON), c.ID))
 		default:
 			// TODO: Handle other content types if necessary?
 			klog.Warningf("Unhandled content type in Send: %T", content)
+			cs.history = cs.history[:historyLen]
 			return nil, fmt.Errorf("unhandled content type: %T", content)
 		}
 	}
@@ -236,6 +282,7 @@ func (cs *openAIChatSession) Send(ctx context.Context, contents ...any) (ChatRes
 		Model:    openai.ChatModel(cs.model),
 		Messages: cs.history,
 	}
+	applyOpenAIGenerationConfig(cs.generationConfig, &chatReq)
 	if len(cs.tools) > 0 {
 		chatReq.Tools = cs.tools
 		// chatReq.ToolChoice = openai.ToolChoiceAuto // Or specify if needed
@@ -245,8 +292,14 @@ func (cs *openAIChatSession) Send(ctx context.Context, contents ...any) (ChatRes
 	klog.V(1).InfoS("Sending request to OpenAI Chat API", "model", cs.model, "messages", len(chatReq.Messages), "tools", len(chatReq.Tools))
 	completion, err := cs.client.Chat.Completions.New(ctx, chatReq)
 	if err != nil {
-		// TODO: Check if error is retryable using cs.IsRetryableError
 		klog.Errorf("OpenAI ChatCompletion API error: %v", err)
+		// The request is dropped from the history, so that a retry does not send it twice
+		cs.history = cs.history[:historyLen]
+		var apiErr *openai.Error
+		if errors.As(err, &apiErr) && isClientError(apiErr.StatusCode) && containsImages(contents) {
+			// Most likely the model does not accept images, so the chat can continue without them.
+			return nil, &UnsupportedContentError{Provider: "openai", Model: cs.model, ContentType: "image", Err: err}
+		}
 		return nil, fmt.Errorf("OpenAI chat completion failed: %w", err)
 	}
 	klog.V(1).InfoS("Received response from OpenAI Chat API", "id", completion.ID, "choices", len(completion.Choices))
@@ -254,6 +307,7 @@ func (cs *openAIChatSession) Send(ctx context.Context, contents ...any) (ChatRes
 	// 4. Process the response
 	if len(completion.Choices) == 0 {
 		klog.Warning("Received response with no choices from OpenAI")
+		cs.history = cs.history[:historyLen]
 		return nil, errors.New("received empty response from OpenAI (no choices)")
 	}
 
@@ -301,10 +355,32 @@ func (cs *openAIChatSession) SendStreaming(ctx context.Context, contents ...any)
 	return iteratorFunc, nil
 }
 
-// IsRetryableError returns false for now.
+// IsRetryableError returns true for rate-limit, server and timeout errors.
 func (cs *openAIChatSession) IsRetryableError(err error) bool {
-	// TODO: Implement actual retry logic if needed
-	return false
+	return cs.ClassifyError(err).Retryable
+}
+
+// ClassifyError classifies errors from the OpenAI API, honoring the Retry-After headers it sends.
+func (cs *openAIChatSession) ClassifyError(err error) ErrorClassification {
+	if err == nil {
+		return ErrorClassification{}
+	}
+
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		classification := ClassifyHTTPStatus(apiErr.StatusCode)
+		if apiErr.Code == "insufficient_quota" {
+			// Billing quota exhaustion will not resolve itself by retrying
+			classification.Retryable = false
+			classification.IsQuota = true
+		}
+		if apiErr.Response != nil {
+			classification.RetryAfter = ParseRetryAfterHeader(apiErr.Response.Header)
+		}
+		return classification
+	}
+
+	return DefaultClassifyError(err)
 }
 
 // --- Helper structs for ChatResponse interface ---
@@ -348,6 +424,13 @@ func (c *openAICandidate) Parts() []Part {
 
 	// OpenAI message can have Content AND ToolCalls
 	var parts []Part
+	// OpenAI-compatible servers for reasoning models (e.g. DeepSeek, vLLM) return the reasoning as an extra field
+	if field, found := c.openaiChoice.Message.JSON.ExtraFields["reasoning_content"]; found && field.IsPresent() {
+		var reasoning string
+		if err := json.Unmarshal([]byte(field.Raw()), &reasoning); err == nil && reasoning != "" {
+			parts = append(parts, &openAIPart{reasoning: reasoning})
+		}
+	}
 	if c.openaiChoice.Message.Content != "" {
 		parts = append(parts, &openAIPart{content: c.openaiChoice.Message.Content})
 	}
@@ -373,6 +456,7 @@ func (c *openAICandidate) String() string {
 
 type openAIPart struct {
 	content   string
+	reasoning string
 	toolCalls []openai.ChatCompletionMessageToolCall // Correct type
 }
 
@@ -382,6 +466,10 @@ func (p *openAIPart) AsText() (string, bool) {
 	return p.content, p.content != ""
 }
 
+func (p *openAIPart) AsThinking() (string, bool) {
+	return p.reasoning, p.reasoning != ""
+}
+
 func (p *openAIPart) AsFunctionCalls() ([]FunctionCall, bool) {
 	if len(p.toolCalls) == 0 {
 		return nil, false
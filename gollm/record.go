@@ -0,0 +1,680 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+func init() {
+	if err := RegisterProvider("record", recordFactory); err != nil {
+		klog.Fatalf("Failed to register record provider: %v", err)
+	}
+	if err := RegisterProvider("replay", replayFactory); err != nil {
+		klog.Fatalf("Failed to register replay provider: %v", err)
+	}
+}
+
+// recordFactory builds a RecordClient from a URL like record:///path/to/fixture.json?provider=gemini
+func recordFactory(ctx context.Context, u *url.URL) (Client, error) {
+	path := fixturePathFromURL(u)
+	if path == "" {
+		return nil, fmt.Errorf("record provider requires a fixture path, e.g. record:///tmp/fixture.json?provider=gemini")
+	}
+	providerID := u.Query().Get("provider")
+	if providerID == "" {
+		return nil, fmt.Errorf("record provider requires the provider to record, e.g. record:///tmp/fixture.json?provider=gemini")
+	}
+
+	underlying, err := NewClient(ctx, providerID)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecordClient(underlying, path), nil
+}
+
+// replayFactory builds a ReplayClient from a URL like replay:///path/to/fixture.json
+func replayFactory(ctx context.Context, u *url.URL) (Client, error) {
+	path := fixturePathFromURL(u)
+	if path == "" {
+		return nil, fmt.Errorf("replay provider requires a fixture path, e.g. replay:///tmp/fixture.json")
+	}
+	return NewReplayClientFromFile(path)
+}
+
+// fixturePathFromURL accepts both record:///abs/path and record://relative/path
+func fixturePathFromURL(u *url.URL) string {
+	return u.Host + u.Path
+}
+
+// Fixture is a recording of the exchanges with an LLM, suitable for deterministic replay.
+type Fixture struct {
+	Chats       []*FixtureChat       `json:"chats,omitempty"`
+	Completions []*FixtureCompletion `json:"completions,omitempty"`
+}
+
+// FixtureChat is a recording of a single chat session.
+type FixtureChat struct {
+	SystemPrompt        string                `json:"systemPrompt,omitempty"`
+	Model               string                `json:"model,omitempty"`
+	FunctionDefinitions []*FunctionDefinition `json:"functionDefinitions,omitempty"`
+	Exchanges           []*FixtureExchange    `json:"exchanges,omitempty"`
+}
+
+// FixtureExchange is a single request to the LLM, and the response(s) it returned.
+type FixtureExchange struct {
+	// Streaming is true if the request was made with SendStreaming.
+	Streaming bool `json:"streaming,omitempty"`
+	// Request holds the contents that were sent.
+	Request []FixtureContent `json:"request,omitempty"`
+	// Responses holds the response, or each chunk of a streaming response.
+	Responses []*FixtureResponse `json:"responses,omitempty"`
+	// Error is set if the request itself failed.
+	Error string `json:"error,omitempty"`
+	// ErrorClassification is how Error was classified, so that it is retried as it was when recorded.
+	ErrorClassification *FixtureErrorClassification `json:"errorClassification,omitempty"`
+}
+
+// FixtureContent is one item of content sent to the LLM.
+type FixtureContent struct {
+	Text           string              `json:"text,omitempty"`
+	File           *FileContent        `json:"file,omitempty"`
+	Image          *ImageContent       `json:"image,omitempty"`
+	FunctionResult *FunctionCallResult `json:"functionResult,omitempty"`
+}
+
+// FixtureResponse is a recorded ChatResponse; it also implements ChatResponse for replay.
+type FixtureResponse struct {
+	FixtureCandidates []*FixtureCandidate `json:"candidates,omitempty"`
+	Usage             any                 `json:"usage,omitempty"`
+	// Error is set if this chunk of a streaming response was an error.
+	Error string `json:"error,omitempty"`
+	// ErrorClassification is how Error was classified.
+	ErrorClassification *FixtureErrorClassification `json:"errorClassification,omitempty"`
+}
+
+var _ ChatResponse = &FixtureResponse{}
+
+func (r *FixtureResponse) UsageMetadata() any {
+	return r.Usage
+}
+
+func (r *FixtureResponse) Candidates() []Candidate {
+	var candidates []Candidate
+	for _, candidate := range r.FixtureCandidates {
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// FixtureCandidate is a recorded Candidate.
+type FixtureCandidate struct {
+	FixtureParts []*FixturePart `json:"parts,omitempty"`
+}
+
+var _ Candidate = &FixtureCandidate{}
+
+func (c *FixtureCandidate) String() string {
+	var sb strings.Builder
+	for _, part := range c.FixtureParts {
+		sb.WriteString(part.Text)
+		for _, call := range part.FunctionCalls {
+			fmt.Fprintf(&sb, "%q(args=%v)", call.Name, call.Arguments)
+		}
+	}
+	return sb.String()
+}
+
+func (c *FixtureCandidate) Parts() []Part {
+	var parts []Part
+	for _, part := range c.FixtureParts {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// FixturePart is a recorded Part.
+type FixturePart struct {
+	Text          string         `json:"text,omitempty"`
+	Thinking      string         `json:"thinking,omitempty"`
+	FunctionCalls []FunctionCall `json:"functionCalls,omitempty"`
+}
+
+var _ Part = &FixturePart{}
+
+func (p *FixturePart) AsText() (string, bool) {
+	return p.Text, p.Text != ""
+}
+
+func (p *FixturePart) AsThinking() (string, bool) {
+	return p.Thinking, p.Thinking != ""
+}
+
+func (p *FixturePart) AsFunctionCalls() ([]FunctionCall, bool) {
+	return p.FunctionCalls, len(p.FunctionCalls) != 0
+}
+
+// FixtureCompletion is a recorded GenerateCompletion call.
+type FixtureCompletion struct {
+	Request  CompletionRequest `json:"request"`
+	Response string            `json:"response,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	// ErrorClassification is how Error was classified.
+	ErrorClassification *FixtureErrorClassification `json:"errorClassification,omitempty"`
+}
+
+// FixtureErrorClassification is a recorded ErrorClassification.
+type FixtureErrorClassification struct {
+	Retryable bool `json:"retryable,omitempty"`
+	// RetryAfter is a duration string, such as "2s".
+	RetryAfter string `json:"retryAfter,omitempty"`
+	IsQuota    bool   `json:"isQuota,omitempty"`
+	IsAuth     bool   `json:"isAuth,omitempty"`
+}
+
+func toFixtureErrorClassification(classification ErrorClassification) *FixtureErrorClassification {
+	recorded := &FixtureErrorClassification{
+		Retryable: classification.Retryable,
+		IsQuota:   classification.IsQuota,
+		IsAuth:    classification.IsAuth,
+	}
+	if classification.RetryAfter != 0 {
+		recorded.RetryAfter = classification.RetryAfter.String()
+	}
+	return recorded
+}
+
+// ReplayedError is an error replayed from a fixture. It is classified as the original error was.
+type ReplayedError struct {
+	Message        string
+	Classification ErrorClassification
+}
+
+func (e *ReplayedError) Error() string {
+	return e.Message
+}
+
+// replayedError rebuilds a recorded error. Errors recorded without a classification are not retryable.
+func replayedError(message string, recorded *FixtureErrorClassification) error {
+	err := &ReplayedError{Message: message}
+	if recorded != nil {
+		err.Classification = ErrorClassification{
+			Retryable: recorded.Retryable,
+			IsQuota:   recorded.IsQuota,
+			IsAuth:    recorded.IsAuth,
+		}
+		// The recording was written by us, so a bad duration means it was edited; we just don't wait
+		err.Classification.RetryAfter, _ = time.ParseDuration(recorded.RetryAfter)
+	}
+	return err
+}
+
+// toFixtureContents converts the contents passed to Send into their recorded form.
+func toFixtureContents(contents []any) ([]FixtureContent, error) {
+	var out []FixtureContent
+	for _, content := range contents {
+		switch v := content.(type) {
+		case string:
+			out = append(out, FixtureContent{Text: v})
+		case FileContent:
+			out = append(out, FixtureContent{File: &v})
+		case ImageContent:
+			out = append(out, FixtureContent{Image: &v})
+		case FunctionCallResult:
+			out = append(out, FixtureContent{FunctionResult: &v})
+		default:
+			return nil, fmt.Errorf("unsupported content type for recording: %T", content)
+		}
+	}
+	return out, nil
+}
+
+// toFixtureResponse converts a ChatResponse into its recorded form.
+func toFixtureResponse(response ChatResponse) *FixtureResponse {
+	out := &FixtureResponse{}
+	if response == nil {
+		return out
+	}
+	out.Usage = response.UsageMetadata()
+	for _, candidate := range response.Candidates() {
+		fixtureCandidate := &FixtureCandidate{}
+		for _, part := range candidate.Parts() {
+			fixturePart := &FixturePart{}
+			if text, ok := part.AsText(); ok {
+				fixturePart.Text = text
+			}
+			if thinking, ok := part.AsThinking(); ok {
+				fixturePart.Thinking = thinking
+			}
+			if calls, ok := part.AsFunctionCalls(); ok {
+				fixturePart.FunctionCalls = calls
+			}
+			fixtureCandidate.FixtureParts = append(fixtureCandidate.FixtureParts, fixturePart)
+		}
+		out.FixtureCandidates = append(out.FixtureCandidates, fixtureCandidate)
+	}
+	return out
+}
+
+// RecordClient wraps another Client, recording every exchange to a fixture file.
+type RecordClient struct {
+	underlying Client
+	path       string
+
+	mutex   sync.Mutex
+	fixture Fixture
+}
+
+var _ Client = &RecordClient{}
+
+// NewRecordClient builds a RecordClient that records exchanges with underlying to the file at path.
+// The fixture is rewritten after every exchange, so it is complete even if the process exits abruptly.
+func NewRecordClient(underlying Client, path string) *RecordClient {
+	return &RecordClient{
+		underlying: underlying,
+		path:       path,
+	}
+}
+
+// saveHoldingLock writes the fixture to disk; it must be called with the mutex held.
+func (c *RecordClient) saveHoldingLock() error {
+	b, err := json.MarshalIndent(&c.fixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling fixture: %w", err)
+	}
+	if err := os.WriteFile(c.path, b, 0644); err != nil {
+		return fmt.Errorf("writing fixture to %q: %w", c.path, err)
+	}
+	return nil
+}
+
+func (c *RecordClient) save() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.saveHoldingLock(); err != nil {
+		klog.Warningf("unable to save LLM recording: %v", err)
+	}
+}
+
+func (c *RecordClient) Close() error {
+	c.mutex.Lock()
+	saveErr := c.saveHoldingLock()
+	c.mutex.Unlock()
+
+	return errors.Join(saveErr, c.underlying.Close())
+}
+
+func (c *RecordClient) SetResponseSchema(schema *Schema) error {
+	return c.underlying.SetResponseSchema(schema)
+}
+
+func (c *RecordClient) SetGenerationConfig(config *GenerationConfig) error {
+	return c.underlying.SetGenerationConfig(config)
+}
+
+func (c *RecordClient) ListModels(ctx context.Context) ([]string, error) {
+	return c.underlying.ListModels(ctx)
+}
+
+func (c *RecordClient) GenerateCompletion(ctx context.Context, req *CompletionRequest) (CompletionResponse, error) {
+	response, err := c.underlying.GenerateCompletion(ctx, req)
+
+	recorded := &FixtureCompletion{Request: *req}
+	if err != nil {
+		recorded.Error = err.Error()
+		recorded.ErrorClassification = toFixtureErrorClassification(DefaultClassifyError(err))
+	} else {
+		recorded.Response = response.Response()
+	}
+
+	c.mutex.Lock()
+	c.fixture.Completions = append(c.fixture.Completions, recorded)
+	c.mutex.Unlock()
+	c.save()
+
+	return response, err
+}
+
+func (c *RecordClient) StartChat(systemPrompt, model string) Chat {
+	recorded := &FixtureChat{
+		SystemPrompt: systemPrompt,
+		Model:        model,
+	}
+
+	c.mutex.Lock()
+	c.fixture.Chats = append(c.fixture.Chats, recorded)
+	c.mutex.Unlock()
+
+	return &recordChat{
+		client:     c,
+		underlying: c.underlying.StartChat(systemPrompt, model),
+		recorded:   recorded,
+	}
+}
+
+// recordChat wraps a Chat, recording every exchange.
+type recordChat struct {
+	client     *RecordClient
+	underlying Chat
+	recorded   *FixtureChat
+}
+
+var _ Chat = &recordChat{}
+
+func (c *recordChat) SetFunctionDefinitions(functionDefinitions []*FunctionDefinition) error {
+	c.client.mutex.Lock()
+	c.recorded.FunctionDefinitions = functionDefinitions
+	c.client.mutex.Unlock()
+
+	return c.underlying.SetFunctionDefinitions(functionDefinitions)
+}
+
+func (c *recordChat) IsRetryableError(err error) bool {
+	return c.underlying.IsRetryableError(err)
+}
+
+func (c *recordChat) ClassifyError(err error) ErrorClassification {
+	if classifier, ok := c.underlying.(ErrorClassifier); ok {
+		return classifier.ClassifyError(err)
+	}
+	return ErrorClassification{Retryable: c.underlying.IsRetryableError(err)}
+}
+
+// startExchange records the request, returning the exchange so the response can be added.
+func (c *recordChat) startExchange(streaming bool, contents []any) (*FixtureExchange, error) {
+	request, err := toFixtureContents(contents)
+	if err != nil {
+		return nil, err
+	}
+	exchange := &FixtureExchange{
+		Streaming: streaming,
+		Request:   request,
+	}
+
+	c.client.mutex.Lock()
+	c.recorded.Exchanges = append(c.recorded.Exchanges, exchange)
+	c.client.mutex.Unlock()
+
+	return exchange, nil
+}
+
+func (c *recordChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
+	exchange, err := c.startExchange(false, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.underlying.Send(ctx, contents...)
+
+	c.client.mutex.Lock()
+	if err != nil {
+		exchange.Error = err.Error()
+		exchange.ErrorClassification = toFixtureErrorClassification(c.ClassifyError(err))
+	} else {
+		exchange.Responses = append(exchange.Responses, toFixtureResponse(response))
+	}
+	c.client.mutex.Unlock()
+	c.client.save()
+
+	return response, err
+}
+
+func (c *recordChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
+	exchange, err := c.startExchange(true, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := c.underlying.SendStreaming(ctx, contents...)
+	if err != nil {
+		c.client.mutex.Lock()
+		exchange.Error = err.Error()
+		exchange.ErrorClassification = toFixtureErrorClassification(c.ClassifyError(err))
+		c.client.mutex.Unlock()
+		c.client.save()
+		return nil, err
+	}
+
+	return func(yield func(ChatResponse, error) bool) {
+		defer c.client.save()
+
+		for response, err := range stream {
+			recorded := toFixtureResponse(response)
+			if err != nil {
+				recorded.Error = err.Error()
+				recorded.ErrorClassification = toFixtureErrorClassification(c.ClassifyError(err))
+			}
+			c.client.mutex.Lock()
+			exchange.Responses = append(exchange.Responses, recorded)
+			c.client.mutex.Unlock()
+
+			if !yield(response, err) {
+				return
+			}
+		}
+	}, nil
+}
+
+// ReplayClient is a Client that replays the exchanges from a Fixture.
+// Chats are replayed in the order they were started, and each Send/SendStreaming
+// returns the next recorded exchange of its chat.
+type ReplayClient struct {
+	mutex   sync.Mutex
+	fixture *Fixture
+
+	nextChat       int
+	nextCompletion int
+
+	// Strict causes replay to fail if the request does not match the recorded request.
+	Strict bool
+}
+
+var _ Client = &ReplayClient{}
+
+// NewReplayClientFromFile loads a fixture previously written by a RecordClient.
+// The returned client is in strict mode.
+func NewReplayClientFromFile(path string) (*ReplayClient, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading fixture %q: %w", path, err)
+	}
+	fixture := &Fixture{}
+	if err := json.Unmarshal(b, fixture); err != nil {
+		return nil, fmt.Errorf("parsing fixture %q: %w", path, err)
+	}
+	client := NewReplayClient(fixture)
+	client.Strict = true
+	return client, nil
+}
+
+// NewReplayClient builds a ReplayClient for the given fixture.
+func NewReplayClient(fixture *Fixture) *ReplayClient {
+	return &ReplayClient{fixture: fixture}
+}
+
+func (c *ReplayClient) Close() error {
+	return nil
+}
+
+func (c *ReplayClient) SetResponseSchema(schema *Schema) error {
+	return nil
+}
+
+func (c *ReplayClient) SetGenerationConfig(config *GenerationConfig) error {
+	return nil
+}
+
+func (c *ReplayClient) ListModels(ctx context.Context) ([]string, error) {
+	var models []string
+	for _, chat := range c.fixture.Chats {
+		if chat.Model != "" && !containsString(models, chat.Model) {
+			models = append(models, chat.Model)
+		}
+	}
+	return models, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ReplayClient) GenerateCompletion(ctx context.Context, req *CompletionRequest) (CompletionResponse, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.nextCompletion >= len(c.fixture.Completions) {
+		return nil, fmt.Errorf("replay: no more recorded completions (replayed %d)", c.nextCompletion)
+	}
+	recorded := c.fixture.Completions[c.nextCompletion]
+	c.nextCompletion++
+
+	if c.Strict && recorded.Request.Prompt != req.Prompt {
+		return nil, fmt.Errorf("replay: completion prompt %q does not match recorded prompt %q", req.Prompt, recorded.Request.Prompt)
+	}
+	if recorded.Error != "" {
+		return nil, replayedError(recorded.Error, recorded.ErrorClassification)
+	}
+	return &simpleCompletionResponse{content: recorded.Response}, nil
+}
+
+func (c *ReplayClient) StartChat(systemPrompt, model string) Chat {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	chat := &replayChat{client: c}
+	if c.nextChat < len(c.fixture.Chats) {
+		chat.recorded = c.fixture.Chats[c.nextChat]
+	}
+	c.nextChat++
+	return chat
+}
+
+// replayChat replays the exchanges of a FixtureChat.
+type replayChat struct {
+	client   *ReplayClient
+	recorded *FixtureChat
+
+	nextExchange int
+}
+
+var _ Chat = &replayChat{}
+
+func (c *replayChat) SetFunctionDefinitions(functionDefinitions []*FunctionDefinition) error {
+	return nil
+}
+
+func (c *replayChat) IsRetryableError(err error) bool {
+	return c.ClassifyError(err).Retryable
+}
+
+// ClassifyError classifies replayed errors as they were classified when recorded.
+func (c *replayChat) ClassifyError(err error) ErrorClassification {
+	return DefaultClassifyError(err)
+}
+
+// next returns the next recorded exchange, checking the request if we are in strict mode.
+func (c *replayChat) next(contents []any) (*FixtureExchange, error) {
+	if c.recorded == nil {
+		return nil, fmt.Errorf("replay: no recorded chat session")
+	}
+	if c.nextExchange >= len(c.recorded.Exchanges) {
+		return nil, fmt.Errorf("replay: no more recorded exchanges (replayed %d)", c.nextExchange)
+	}
+	exchange := c.recorded.Exchanges[c.nextExchange]
+	c.nextExchange++
+
+	if c.client.Strict {
+		request, err := toFixtureContents(contents)
+		if err != nil {
+			return nil, err
+		}
+		if !fixtureContentsEqual(request, exchange.Request) {
+			return nil, fmt.Errorf("replay: request %d does not match the recording; got %+v, recorded %+v", c.nextExchange-1, request, exchange.Request)
+		}
+	}
+	return exchange, nil
+}
+
+// fixtureContentsEqual compares requests after round-tripping through JSON,
+// so that (for example) int and float64 arguments compare equal.
+func fixtureContentsEqual(a, b []FixtureContent) bool {
+	normalize := func(v []FixtureContent) any {
+		j, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var out any
+		if err := json.Unmarshal(j, &out); err != nil {
+			return nil
+		}
+		return out
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func (c *replayChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
+	exchange, err := c.next(contents)
+	if err != nil {
+		return nil, err
+	}
+	if exchange.Error != "" {
+		return nil, replayedError(exchange.Error, exchange.ErrorClassification)
+	}
+	if len(exchange.Responses) == 0 {
+		return nil, fmt.Errorf("replay: recorded exchange has no response")
+	}
+	return exchange.Responses[0], nil
+}
+
+func (c *replayChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
+	exchange, err := c.next(contents)
+	if err != nil {
+		return nil, err
+	}
+	if exchange.Error != "" {
+		return nil, replayedError(exchange.Error, exchange.ErrorClassification)
+	}
+
+	return func(yield func(ChatResponse, error) bool) {
+		for _, response := range exchange.Responses {
+			if response.Error != "" {
+				if !yield(nil, replayedError(response.Error, response.ErrorClassification)) {
+					return
+				}
+				continue
+			}
+			if !yield(response, nil) {
+				return
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordAndReplayRetries(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "fixture.json")
+
+	// The model fails twice with retryable errors before answering
+	fake := NewFakeClient(&FakeScript{Turns: []*FakeTurn{
+		{Error: &FakeError{StatusCode: 429, Message: "quota exceeded", RetryAfter: "1ms"}},
+		{StreamError: &FakeError{StatusCode: 503, Message: "unavailable"}},
+		{Chunks: []string{"hello ", "world"}},
+	}})
+
+	send := func(client Client) (string, error) {
+		chat := NewRetryChat(client.StartChat("system", "model"), testRetryConfig(nil))
+		stream, err := chat.SendStreaming(ctx, "hi")
+		if err != nil {
+			return "", err
+		}
+		return readStream(stream)
+	}
+
+	recorder := NewRecordClient(fake, path)
+	text, err := send(recorder)
+	if err != nil {
+		t.Fatalf("recording failed: %v", err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("saving recording failed: %v", err)
+	}
+
+	replay, err := NewReplayClientFromFile(path)
+	if err != nil {
+		t.Fatalf("loading recording failed: %v", err)
+	}
+	exchanges := replay.fixture.Chats[0].Exchanges
+	if len(exchanges) != 3 {
+		t.Fatalf("got %d recorded exchanges, want 3", len(exchanges))
+	}
+	if got := exchanges[0].ErrorClassification; got == nil || !got.Retryable || !got.IsQuota || got.RetryAfter != "1ms" {
+		t.Errorf("got classification %+v for the quota error, want retryable quota error with retryAfter 1ms", got)
+	}
+
+	replayed, err := send(replay)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if replayed != text {
+		t.Errorf("replayed %q, recorded %q", replayed, text)
+	}
+
+	// Errors are classified as they were when recorded; errors recorded without a classification are not retried
+	replay = NewReplayClient(&Fixture{Chats: []*FixtureChat{{Exchanges: []*FixtureExchange{
+		{Error: "unavailable", ErrorClassification: &FixtureErrorClassification{Retryable: true}},
+		{Error: "bad request"},
+	}}}})
+	chat := replay.StartChat("", "")
+	for _, want := range []bool{true, false} {
+		_, err := chat.Send(ctx, "hi")
+		var replayedErr *ReplayedError
+		if !errors.As(err, &replayedErr) {
+			t.Fatalf("got error %v, want a *ReplayedError", err)
+		}
+		if got := chat.IsRetryableError(err); got != want {
+			t.Errorf("IsRetryableError(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
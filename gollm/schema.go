@@ -15,15 +15,18 @@
 package gollm
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"reflect"
+	"slices"
+	"sort"
 	"strings"
-
-	"k8s.io/klog/v2"
 )
 
 // BuildSchemaFor will build a schema for the given golang type.
 // Because this does not have description populated, it is more useful for the response schema than tools/functions.
-func BuildSchemaFor(t reflect.Type) *Schema {
+func BuildSchemaFor(t reflect.Type) (*Schema, error) {
 	out := &Schema{}
 
 	switch t.Kind() {
@@ -31,8 +34,18 @@ func BuildSchemaFor(t reflect.Type) *Schema {
 		out.Type = TypeString
 	case reflect.Bool:
 		out.Type = TypeBoolean
-	case reflect.Int:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		out.Type = TypeInteger
+	case reflect.Float32, reflect.Float64:
+		out.Type = TypeNumber
+	case reflect.Pointer:
+		elem, err := BuildSchemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		out = elem
+		out.Nullable = true
 	case reflect.Struct:
 		out.Type = TypeObject
 		out.Properties = make(map[string]*Schema)
@@ -41,30 +54,237 @@ func BuildSchemaFor(t reflect.Type) *Schema {
 		for i := 0; i < numFields; i++ {
 			field := t.Field(i)
 			jsonTag := field.Tag.Get("json")
-			if jsonTag == "" {
+			if jsonTag == "" || jsonTag == "-" {
 				continue
 			}
-			if strings.HasSuffix(jsonTag, ",omitempty") {
-				jsonTag = strings.TrimSuffix(jsonTag, ",omitempty")
-			} else {
-				required = append(required, jsonTag)
+			name, options, _ := strings.Cut(jsonTag, ",")
+			if !slices.Contains(strings.Split(options, ","), "omitempty") {
+				required = append(required, name)
 			}
 
-			fieldType := field.Type
-
-			fieldSchema := BuildSchemaFor(fieldType)
-			out.Properties[jsonTag] = fieldSchema
+			fieldSchema, err := BuildSchemaFor(field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", field.Name, err)
+			}
+			out.Properties[name] = fieldSchema
 		}
 
 		if len(required) != 0 {
 			out.Required = required
 		}
-	case reflect.Slice:
+	case reflect.Slice, reflect.Array:
 		out.Type = TypeArray
-		out.Items = BuildSchemaFor(t.Elem())
+		items, err := BuildSchemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		out.Items = items
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map key kind %v is not supported, only string keys are", t.Key().Kind())
+		}
+		out.Type = TypeObject
+		values, err := BuildSchemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		out.AdditionalProperties = values
 	default:
-		klog.Fatalf("unhandled kind %v", t.Kind())
+		return nil, fmt.Errorf("unhandled kind %v", t.Kind())
 	}
 
+	return out, nil
+}
+
+// ToJSONSchema converts the schema to standard JSON Schema, as accepted by OpenAI-compatible APIs.
+// Nullable is expressed by adding "null" to the type, and to the enum if there is one.
+func (s *Schema) ToJSONSchema() map[string]any {
+	if s == nil {
+		return nil
+	}
+
+	out := make(map[string]any)
+	if s.Type != "" {
+		if s.Nullable {
+			out["type"] = []string{string(s.Type), "null"}
+		} else {
+			out["type"] = string(s.Type)
+		}
+	}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+	if s.Properties != nil {
+		properties := make(map[string]any, len(s.Properties))
+		for k, v := range s.Properties {
+			properties[k] = v.ToJSONSchema()
+		}
+		out["properties"] = properties
+	}
+	if s.Items != nil {
+		out["items"] = s.Items.ToJSONSchema()
+	}
+	if len(s.Required) != 0 {
+		out["required"] = s.Required
+	}
+	if len(s.Enum) != 0 {
+		if s.Nullable {
+			// Otherwise null would be rejected by the enum, although the type allows it
+			enum := make([]any, 0, len(s.Enum)+1)
+			for _, v := range s.Enum {
+				enum = append(enum, v)
+			}
+			out["enum"] = append(enum, nil)
+		} else {
+			out["enum"] = s.Enum
+		}
+	}
+	if s.Format != "" {
+		out["format"] = s.Format
+	}
+	if s.Default != nil {
+		out["default"] = s.Default
+	}
+	if s.AdditionalProperties != nil {
+		out["additionalProperties"] = s.AdditionalProperties.ToJSONSchema()
+	}
 	return out
 }
+
+// Validate checks that value (as decoded from JSON, e.g. function call arguments) conforms to the schema.
+// All the problems found are returned, joined, each prefixed with the path to the offending value.
+func (s *Schema) Validate(value any) error {
+	var errs []error
+	s.validate("", value, &errs)
+	return errors.Join(errs...)
+}
+
+// SchemaValidationError is a single problem found by Schema.Validate.
+type SchemaValidationError struct {
+	// Path is the location of the problem, such as "containers[0].name"; it is empty for the root value.
+	Path    string
+	Message string
+}
+
+func (e *SchemaValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+func (s *Schema) validate(path string, value any, errs *[]error) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, &SchemaValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil {
+		if !s.Nullable {
+			fail("must not be null")
+		}
+		return
+	}
+
+	switch s.Type {
+	case TypeString:
+		v, ok := value.(string)
+		if !ok {
+			fail("expected a string, got %s", describeJSONType(value))
+			return
+		}
+		if len(s.Enum) != 0 && !slices.Contains(s.Enum, v) {
+			fail("%q is not one of the allowed values [%s]", v, strings.Join(s.Enum, ", "))
+		}
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			fail("expected a boolean, got %s", describeJSONType(value))
+		}
+	case TypeInteger:
+		f, ok := toFloat64(value)
+		if !ok {
+			fail("expected an integer, got %s", describeJSONType(value))
+			return
+		}
+		if f != math.Trunc(f) {
+			fail("expected an integer, got %v", value)
+		}
+	case TypeNumber:
+		if _, ok := toFloat64(value); !ok {
+			fail("expected a number, got %s", describeJSONType(value))
+		}
+	case TypeArray:
+		v, ok := value.([]any)
+		if !ok {
+			fail("expected an array, got %s", describeJSONType(value))
+			return
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+	case TypeObject:
+		v, ok := value.(map[string]any)
+		if !ok {
+			fail("expected an object, got %s", describeJSONType(value))
+			return
+		}
+		for _, name := range s.Required {
+			if _, found := v[name]; !found {
+				fail("missing required property %q", name)
+			}
+		}
+
+		// Sort the keys so the errors are deterministic
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+			if propertySchema := s.Properties[k]; propertySchema != nil {
+				propertySchema.validate(childPath, v[k], errs)
+			} else if s.AdditionalProperties != nil {
+				s.AdditionalProperties.validate(childPath, v[k], errs)
+			}
+		}
+	}
+}
+
+// toFloat64 converts the numeric types we may see in decoded arguments.
+func toFloat64(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// describeJSONType returns the JSON name for the type of a decoded value, for error messages.
+func describeJSONType(value any) string {
+	switch value.(type) {
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case float64, float32, int, int32, int64:
+		return "a number"
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", value)
+}
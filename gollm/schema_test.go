@@ -0,0 +1,232 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildSchemaFor(t *testing.T) {
+	type container struct {
+		Name  string `json:"name"`
+		Image string `json:"image,omitempty"`
+	}
+	type pod struct {
+		Name       string            `json:"name"`
+		Replicas   *int              `json:"replicas,omitempty"`
+		Containers []container       `json:"containers"`
+		Labels     map[string]string `json:"labels,omitempty"`
+		internal   string
+		Ignored    string `json:"-"`
+	}
+
+	tests := []struct {
+		name    string
+		value   any
+		want    *Schema
+		wantErr string
+	}{
+		{name: "string", value: "", want: &Schema{Type: TypeString}},
+		{name: "bool", value: false, want: &Schema{Type: TypeBoolean}},
+		{name: "int", value: int(0), want: &Schema{Type: TypeInteger}},
+		{name: "int8", value: int8(0), want: &Schema{Type: TypeInteger}},
+		{name: "int64", value: int64(0), want: &Schema{Type: TypeInteger}},
+		{name: "uint", value: uint(0), want: &Schema{Type: TypeInteger}},
+		{name: "uint32", value: uint32(0), want: &Schema{Type: TypeInteger}},
+		{name: "float32", value: float32(0), want: &Schema{Type: TypeNumber}},
+		{name: "float64", value: float64(0), want: &Schema{Type: TypeNumber}},
+		{name: "pointer", value: new(string), want: &Schema{Type: TypeString, Nullable: true}},
+		{name: "slice", value: []int{}, want: &Schema{Type: TypeArray, Items: &Schema{Type: TypeInteger}}},
+		{name: "array", value: [2]bool{}, want: &Schema{Type: TypeArray, Items: &Schema{Type: TypeBoolean}}},
+		{name: "map", value: map[string]float64{}, want: &Schema{Type: TypeObject, AdditionalProperties: &Schema{Type: TypeNumber}}},
+		{
+			name:  "struct",
+			value: pod{},
+			want: &Schema{
+				Type: TypeObject,
+				Properties: map[string]*Schema{
+					"name":     {Type: TypeString},
+					"replicas": {Type: TypeInteger, Nullable: true},
+					"containers": {Type: TypeArray, Items: &Schema{
+						Type: TypeObject,
+						Properties: map[string]*Schema{
+							"name":  {Type: TypeString},
+							"image": {Type: TypeString},
+						},
+						Required: []string{"name"},
+					}},
+					"labels": {Type: TypeObject, AdditionalProperties: &Schema{Type: TypeString}},
+				},
+				Required: []string{"name", "containers"},
+			},
+		},
+		{name: "map with int keys", value: map[int]string{}, wantErr: "map key kind int is not supported"},
+		{name: "channel", value: make(chan int), wantErr: "unhandled kind chan"},
+		{name: "func", value: func() {}, wantErr: "unhandled kind func"},
+		{name: "field of an unhandled kind", value: struct {
+			Callback func() `json:"callback"`
+		}{}, wantErr: `field "Callback": unhandled kind func`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := BuildSchemaFor(reflect.TypeOf(test.value))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want it to contain %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BuildSchemaFor failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				gotJSON, _ := json.Marshal(got)
+				wantJSON, _ := json.Marshal(test.want)
+				t.Errorf("got schema %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestToJSONSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema *Schema
+		want   string
+	}{
+		{name: "nil", schema: nil, want: `null`},
+		{name: "string", schema: &Schema{Type: TypeString, Description: "a name"}, want: `{"description":"a name","type":"string"}`},
+		{name: "nullable", schema: &Schema{Type: TypeInteger, Nullable: true}, want: `{"type":["integer","null"]}`},
+		{name: "enum", schema: &Schema{Type: TypeString, Enum: []string{"yes", "no"}}, want: `{"enum":["yes","no"],"type":"string"}`},
+		{
+			name:   "nullable enum",
+			schema: &Schema{Type: TypeString, Enum: []string{"yes", "no"}, Nullable: true},
+			want:   `{"enum":["yes","no",null],"type":["string","null"]}`,
+		},
+		{
+			name:   "format and default",
+			schema: &Schema{Type: TypeInteger, Format: "int64", Default: 5},
+			want:   `{"default":5,"format":"int64","type":"integer"}`,
+		},
+		{
+			name: "object",
+			schema: &Schema{
+				Type:       TypeObject,
+				Properties: map[string]*Schema{"names": {Type: TypeArray, Items: &Schema{Type: TypeString}}},
+				Required:   []string{"names"},
+			},
+			want: `{"properties":{"names":{"items":{"type":"string"},"type":"array"}},"required":["names"],"type":"object"}`,
+		},
+		{
+			name:   "additionalProperties",
+			schema: &Schema{Type: TypeObject, AdditionalProperties: &Schema{Type: TypeString, Nullable: true}},
+			want:   `{"additionalProperties":{"type":["string","null"]},"type":"object"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := json.Marshal(test.schema.ToJSONSchema())
+			if err != nil {
+				t.Fatalf("marshalling schema: %v", err)
+			}
+			if string(got) != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema := &Schema{
+		Type: TypeObject,
+		Properties: map[string]*Schema{
+			"name":     {Type: TypeString},
+			"replicas": {Type: TypeInteger},
+			"ratio":    {Type: TypeNumber},
+			"enabled":  {Type: TypeBoolean},
+			"policy":   {Type: TypeString, Enum: []string{"Always", "Never"}},
+			"nodeName": {Type: TypeString, Nullable: true},
+			"containers": {Type: TypeArray, Items: &Schema{
+				Type:       TypeObject,
+				Properties: map[string]*Schema{"name": {Type: TypeString}, "ports": {Type: TypeArray, Items: &Schema{Type: TypeInteger}}},
+				Required:   []string{"name"},
+			}},
+			"labels": {Type: TypeObject, AdditionalProperties: &Schema{Type: TypeString}},
+		},
+		Required: []string{"name"},
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		// wantErrs are the messages of the problems found, in order
+		wantErrs []string
+	}{
+		{name: "valid", value: `{"name": "web", "replicas": 3, "ratio": 0.5, "enabled": true, "policy": "Always", "nodeName": "node-a"}`},
+		{name: "integer written as a whole float", value: `{"name": "web", "replicas": 1.0}`},
+		{name: "integer with a fraction", value: `{"name": "web", "replicas": 1.5}`, wantErrs: []string{"replicas: expected an integer, got 1.5"}},
+		{name: "integer as a string", value: `{"name": "web", "replicas": "3"}`, wantErrs: []string{"replicas: expected an integer, got a string"}},
+		{name: "number with a fraction", value: `{"name": "web", "ratio": 1.5}`},
+		{name: "number as a boolean", value: `{"name": "web", "ratio": true}`, wantErrs: []string{"ratio: expected a number, got a boolean"}},
+		{name: "boolean as a string", value: `{"name": "web", "enabled": "true"}`, wantErrs: []string{"enabled: expected a boolean, got a string"}},
+		{name: "enum", value: `{"name": "web", "policy": "Sometimes"}`, wantErrs: []string{`policy: "Sometimes" is not one of the allowed values [Always, Never]`}},
+		{name: "nullable", value: `{"name": "web", "nodeName": null}`},
+		{name: "not nullable", value: `{"name": null}`, wantErrs: []string{"name: must not be null"}},
+		{name: "missing required property", value: `{"replicas": 3}`, wantErrs: []string{`missing required property "name"`}},
+		{name: "root of the wrong type", value: `["web"]`, wantErrs: []string{"expected an object, got an array"}},
+		{name: "additionalProperties", value: `{"name": "web", "labels": {"app": "web", "tier": 2}}`, wantErrs: []string{"labels.tier: expected a string, got a number"}},
+		{name: "unknown properties are allowed", value: `{"name": "web", "extra": [1, 2]}`},
+		{
+			name:  "nested paths",
+			value: `{"name": "web", "containers": [{"name": "app", "ports": [80, "http"]}, {"ports": []}, "sidecar"]}`,
+			wantErrs: []string{
+				"containers[0].ports[1]: expected an integer, got a string",
+				`containers[1]: missing required property "name"`,
+				"containers[2]: expected an object, got a string",
+			},
+		},
+		{
+			name:     "all problems are reported",
+			value:    `{"replicas": "3", "policy": "Sometimes"}`,
+			wantErrs: []string{`missing required property "name"`, `policy: "Sometimes" is not one of the allowed values [Always, Never]`, "replicas: expected an integer, got a string"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var value any
+			if err := json.Unmarshal([]byte(test.value), &value); err != nil {
+				t.Fatalf("parsing %s: %v", test.value, err)
+			}
+
+			var gotErrs []string
+			if err := schema.Validate(value); err != nil {
+				for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+					var validationErr *SchemaValidationError
+					if !errors.As(err, &validationErr) {
+						t.Errorf("got error %v of type %T, want a *SchemaValidationError", err, err)
+					}
+					gotErrs = append(gotErrs, err.Error())
+				}
+			}
+			if !reflect.DeepEqual(gotErrs, test.wantErrs) {
+				t.Errorf("got errors %q, want %q", gotErrs, test.wantErrs)
+			}
+		})
+	}
+}
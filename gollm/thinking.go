@@ -0,0 +1,85 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import "strings"
+
+const (
+	thinkStartTag = "<think>"
+	thinkEndTag   = "</think>"
+)
+
+// thinkTagSplitter separates the <think>...</think> reasoning emitted inline by models such as DeepSeek-R1 and Qwen3
+// from the answer text. It is stateful so that it can be fed streaming chunks, where a tag may be split across chunks.
+type thinkTagSplitter struct {
+	inThinking bool
+	// pending holds the end of the last chunk when it could be the start of a tag
+	pending string
+}
+
+// split returns the reasoning and answer text in the chunk.
+func (s *thinkTagSplitter) split(chunk string) (thinking string, text string) {
+	var thinkingOut, textOut strings.Builder
+
+	buf := s.pending + chunk
+	s.pending = ""
+	for buf != "" {
+		tag, out := thinkStartTag, &textOut
+		if s.inThinking {
+			tag, out = thinkEndTag, &thinkingOut
+		}
+
+		if i := strings.Index(buf, tag); i >= 0 {
+			out.WriteString(buf[:i])
+			buf = buf[i+len(tag):]
+			s.inThinking = !s.inThinking
+			continue
+		}
+
+		keep := partialTagSuffix(buf, tag)
+		out.WriteString(buf[:len(buf)-keep])
+		s.pending = buf[len(buf)-keep:]
+		break
+	}
+	return thinkingOut.String(), textOut.String()
+}
+
+// flush returns any text held back by split, once there are no more chunks.
+func (s *thinkTagSplitter) flush() (thinking string, text string) {
+	pending := s.pending
+	s.pending = ""
+	if s.inThinking {
+		return pending, ""
+	}
+	return "", pending
+}
+
+// splitThinking separates the <think>...</think> reasoning from the answer in a complete (non-streaming) response.
+func splitThinking(content string) (thinking string, text string) {
+	s := &thinkTagSplitter{}
+	thinking, text = s.split(content)
+	moreThinking, moreText := s.flush()
+	return strings.TrimSpace(thinking + moreThinking), strings.TrimLeft(text+moreText, "\n")
+}
+
+// partialTagSuffix returns the length of the longest suffix of s that is a prefix of tag.
+func partialTagSuffix(s, tag string) int {
+	for n := min(len(tag)-1, len(s)); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
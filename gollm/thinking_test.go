@@ -0,0 +1,126 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import "testing"
+
+// splitChunks feeds the chunks to a new thinkTagSplitter, and returns all the reasoning and text it returned.
+func splitChunks(chunks ...string) (thinking string, text string) {
+	s := &thinkTagSplitter{}
+	for _, chunk := range chunks {
+		moreThinking, moreText := s.split(chunk)
+		thinking += moreThinking
+		text += moreText
+	}
+	moreThinking, moreText := s.flush()
+	return thinking + moreThinking, text + moreText
+}
+
+func TestThinkTagSplitter(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		wantThinking string
+		wantText     string
+	}{
+		{name: "no tags", input: "There are 3 pods.", wantText: "There are 3 pods."},
+		{name: "reasoning then answer", input: "<think>The user wants pods.</think>There are 3 pods.", wantThinking: "The user wants pods.", wantText: "There are 3 pods."},
+		{name: "text before and after", input: "Sure. <think>Check pods.</think> There are 3.", wantThinking: "Check pods.", wantText: "Sure.  There are 3."},
+		{name: "several blocks", input: "<think>a</think>b<think>c</think>d", wantThinking: "ac", wantText: "bd"},
+		{name: "empty block", input: "<think></think>done", wantText: "done"},
+		{name: "unclosed tag", input: "<think>still thinking", wantThinking: "still thinking"},
+		{name: "partial start tag at the end", input: "x < y, so <thi", wantText: "x < y, so <thi"},
+		{name: "partial end tag at the end", input: "<think>a < b</thi", wantThinking: "a < b</thi"},
+		{name: "not a tag", input: "<thinking> is not a tag <t", wantText: "<thinking> is not a tag <t"},
+		{name: "end tag outside reasoning", input: "a</think>b", wantText: "a</think>b"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			thinking, text := splitChunks(test.input)
+			if thinking != test.wantThinking || text != test.wantText {
+				t.Fatalf("got (%q, %q), want (%q, %q)", thinking, text, test.wantThinking, test.wantText)
+			}
+
+			// A tag can be split across chunks at any offset
+			for i := 0; i <= len(test.input); i++ {
+				thinking, text := splitChunks(test.input[:i], test.input[i:])
+				if thinking != test.wantThinking || text != test.wantText {
+					t.Errorf("split at %d (%q, %q): got (%q, %q), want (%q, %q)", i, test.input[:i], test.input[i:], thinking, text, test.wantThinking, test.wantText)
+				}
+			}
+
+			// or arrive one byte at a time
+			var bytes []string
+			for i := range len(test.input) {
+				bytes = append(bytes, test.input[i:i+1])
+			}
+			thinking, text = splitChunks(bytes...)
+			if thinking != test.wantThinking || text != test.wantText {
+				t.Errorf("one byte at a time: got (%q, %q), want (%q, %q)", thinking, text, test.wantThinking, test.wantText)
+			}
+		})
+	}
+}
+
+func TestThinkTagSplitterHoldsBackPartialTags(t *testing.T) {
+	s := &thinkTagSplitter{}
+	if thinking, text := s.split("Sure <th"); thinking != "" || text != "Sure " {
+		t.Errorf("got (%q, %q), want the partial tag held back", thinking, text)
+	}
+	if thinking, text := s.split("ink>pods"); thinking != "pods" || text != "" {
+		t.Errorf("got (%q, %q), want the reasoning once the tag is complete", thinking, text)
+	}
+	if thinking, text := s.split("</"); thinking != "" || text != "" {
+		t.Errorf("got (%q, %q), want the partial end tag held back", thinking, text)
+	}
+	if thinking, text := s.flush(); thinking != "</" || text != "" {
+		t.Errorf("got (%q, %q) from flush, want the partial end tag as reasoning", thinking, text)
+	}
+	if thinking, text := s.flush(); thinking != "" || text != "" {
+		t.Errorf("got (%q, %q) from a second flush, want nothing", thinking, text)
+	}
+}
+
+func TestPartialTagSuffix(t *testing.T) {
+	tests := []struct {
+		s    string
+		tag  string
+		want int
+	}{
+		{s: "", tag: thinkStartTag, want: 0},
+		{s: "abc", tag: thinkStartTag, want: 0},
+		{s: "abc<", tag: thinkStartTag, want: 1},
+		{s: "abc<think", tag: thinkStartTag, want: 6},
+		// A whole tag is not a partial tag; split finds it first
+		{s: "abc<think>", tag: thinkStartTag, want: 0},
+		{s: "<thi", tag: thinkStartTag, want: 4},
+		{s: "<t<th", tag: thinkStartTag, want: 3},
+		{s: "abc</thin", tag: thinkEndTag, want: 6},
+		{s: "abc<", tag: thinkEndTag, want: 1},
+		{s: "abc<think", tag: thinkEndTag, want: 0},
+	}
+	for _, test := range tests {
+		if got := partialTagSuffix(test.s, test.tag); got != test.want {
+			t.Errorf("partialTagSuffix(%q, %q) = %d, want %d", test.s, test.tag, got, test.want)
+		}
+	}
+}
+
+func TestSplitThinking(t *testing.T) {
+	thinking, text := splitThinking("<think>\nThe user wants pods.\n</think>\n\nThere are 3 pods.\n")
+	if thinking != "The user wants pods." || text != "There are 3 pods.\n" {
+		t.Errorf("got (%q, %q), want the reasoning trimmed and the answer without leading newlines", thinking, text)
+	}
+}
@@ -315,6 +315,7 @@ func (x *TaskExecution) runAgent(ctx context.Context) error {
 		"--trace-path", tracePath,
 		"--skip-permissions",
 	}
+	args = append(args, x.llmConfig.Generation.Args()...)
 
 	stdinReader, stdinWriter := io.Pipe()
 
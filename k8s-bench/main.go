@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -171,6 +172,42 @@ func runEvals(ctx context.Context) error {
 	flag.BoolVar(&enableToolUseShim, "enable-tool-use-shim", enableToolUseShim, "Enable tool use shim")
 	flag.BoolVar(&quiet, "quiet", quiet, "Quiet mode (non-interactive mode)")
 	flag.StringVar(&config.OutputDir, "output-dir", config.OutputDir, "Directory to write results to")
+
+	var generation model.GenerationConfig
+	flag.Func("temperature", "Sampling temperature passed to the agent (default is provider-specific)", func(s string) error {
+		v, err := strconv.ParseFloat(s, 32)
+		generation.Temperature = ptrTo(float32(v))
+		return err
+	})
+	flag.Func("top-p", "Nucleus sampling probability passed to the agent", func(s string) error {
+		v, err := strconv.ParseFloat(s, 32)
+		generation.TopP = ptrTo(float32(v))
+		return err
+	})
+	flag.Func("top-k", "Top-k sampling passed to the agent", func(s string) error {
+		v, err := strconv.ParseInt(s, 10, 32)
+		generation.TopK = ptrTo(int32(v))
+		return err
+	})
+	flag.Func("stop-sequences", "Comma-separated sequences that stop generation by the LLM (can be repeated)", func(s string) error {
+		generation.StopSequences = append(generation.StopSequences, strings.Split(s, ",")...)
+		return nil
+	})
+	flag.Func("max-output-tokens", "Maximum number of tokens in each LLM response", func(s string) error {
+		v, err := strconv.ParseInt(s, 10, 32)
+		generation.MaxOutputTokens = ptrTo(int32(v))
+		return err
+	})
+	flag.Func("seed", "Random seed passed to the agent, for more reproducible runs", func(s string) error {
+		v, err := strconv.ParseInt(s, 10, 64)
+		generation.Seed = ptrTo(v)
+		return err
+	})
+	flag.Func("thinking-budget", "Number of tokens the LLM may spend on reasoning", func(s string) error {
+		v, err := strconv.ParseInt(s, 10, 32)
+		generation.ThinkingBudget = ptrTo(int32(v))
+		return err
+	})
 	flag.Parse()
 
 	if config.KubeConfig == "" {
@@ -213,6 +250,7 @@ func runEvals(ctx context.Context) error {
 				ModelID:           modelID,
 				EnableToolUseShim: enableToolUseShim,
 				Quiet:             quiet,
+				Generation:        generation,
 			})
 		}
 	}
@@ -602,3 +640,7 @@ func printJSONResults(results []model.TaskResult, resultsFilePath string) error
 
 	return nil
 }
+
+func ptrTo[T any](t T) *T {
+	return &t
+}
@@ -14,7 +14,10 @@
 
 package model
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type TaskResult struct {
 	Task      string    `json:"name"`
@@ -45,7 +48,50 @@ type LLMConfig struct {
 
 	Quiet bool `json:"quiet"`
 
-	// TODO: Maybe different styles of invocation, or different temperatures etc?
+	// Generation holds the generation settings passed to the agent; unset fields use the agent defaults.
+	Generation GenerationConfig `json:"generation,omitempty"`
+}
+
+// GenerationConfig mirrors the generation settings accepted by kubectl-ai (see gollm.GenerationConfig).
+type GenerationConfig struct {
+	Temperature     *float32 `json:"temperature,omitempty"`
+	TopP            *float32 `json:"topP,omitempty"`
+	TopK            *int32   `json:"topK,omitempty"`
+	MaxOutputTokens *int32   `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+	Seed            *int64   `json:"seed,omitempty"`
+	ThinkingBudget  *int32   `json:"thinkingBudget,omitempty"`
+	IncludeThoughts *bool    `json:"includeThoughts,omitempty"`
+}
+
+// Args returns the kubectl-ai command line flags for the settings that are set.
+func (c *GenerationConfig) Args() []string {
+	var args []string
+	if c.Temperature != nil {
+		args = append(args, fmt.Sprintf("--temperature=%v", *c.Temperature))
+	}
+	if c.TopP != nil {
+		args = append(args, fmt.Sprintf("--top-p=%v", *c.TopP))
+	}
+	if c.TopK != nil {
+		args = append(args, fmt.Sprintf("--top-k=%d", *c.TopK))
+	}
+	if c.MaxOutputTokens != nil {
+		args = append(args, fmt.Sprintf("--max-output-tokens=%d", *c.MaxOutputTokens))
+	}
+	if len(c.StopSequences) != 0 {
+		args = append(args, fmt.Sprintf("--stop-sequences=%s", strings.Join(c.StopSequences, ",")))
+	}
+	if c.Seed != nil {
+		args = append(args, fmt.Sprintf("--seed=%d", *c.Seed))
+	}
+	if c.ThinkingBudget != nil {
+		args = append(args, fmt.Sprintf("--thinking-budget=%d", *c.ThinkingBudget))
+	}
+	if c.IncludeThoughts != nil {
+		args = append(args, fmt.Sprintf("--include-thoughts=%t", *c.IncludeThoughts))
+	}
+	return args
 }
 
 // AddFailure is a helper for adding a formatted failure message; it also marks the test as failed
@@ -16,37 +16,104 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/GoogleCloudPlatform/kubectl-ai/kubectl-utils/pkg/kel"
+	"github.com/GoogleCloudPlatform/kubectl-ai/kubectl-utils/pkg/expect"
 	"github.com/GoogleCloudPlatform/kubectl-ai/kubectl-utils/pkg/kube"
-	celtypes "github.com/google/cel-go/common/types"
 	"github.com/spf13/pflag"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
 )
 
+// Exit codes
+const (
+	// exitConditionNotMet is returned when we time out before the targets satisfy the expression.
+	exitConditionNotMet = 1
+	// exitError is returned for invalid arguments, and when the objects cannot be read or the expression
+	// cannot be evaluated.
+	exitError = 2
+)
+
+// errConditionNotMet is returned by run when we time out.
+var errConditionNotMet = errors.New("timed out waiting for the condition")
+
 func main() {
-	ctx := context.Background()
-	if err := run(ctx); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx)
+	stop()
+	if errors.Is(err, errConditionNotMet) {
+		os.Exit(exitConditionNotMet)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(exitError)
 	}
 }
 
-func run(ctx context.Context) error {
-	// log := klog.FromContext(ctx)
+// Output is the JSON output, with -o json.
+type Output struct {
+	Expression string `json:"expression"`
+	// Met is true if all the targets satisfied the expression.
+	Met     bool            `json:"met"`
+	Elapsed string          `json:"elapsed"`
+	Targets []*TargetOutput `json:"targets"`
+}
+
+// TargetOutput is the final status of a target.
+type TargetOutput struct {
+	Target    string          `json:"target"`
+	Namespace string          `json:"namespace,omitempty"`
+	Selector  string          `json:"selector,omitempty"`
+	Match     string          `json:"match,omitempty"`
+	Met       bool            `json:"met"`
+	Status    string          `json:"status"`
+	Objects   []*ObjectOutput `json:"objects"`
+}
 
+// ObjectOutput is the final status of an object.
+type ObjectOutput struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Met       bool   `json:"met"`
+	// Values are the final values of the parts of the expression, e.g. {"self.status.readyReplicas": 3}.
+	// Values that could not be evaluated, e.g. because the field is not set, are null.
+	Values map[string]any `json:"values,omitempty"`
+}
+
+func run(ctx context.Context) error {
 	namespace := ""
 	kubeconfig := ""
+	selector := ""
+	match := string(expect.MatchAll)
+	timeout := 5 * time.Minute
+	output := ""
 
+	pflag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: kubectl expect [flags] TARGET... CEL-EXPRESSION\n\n")
+		fmt.Fprintf(os.Stderr, "Waits until the targets satisfy the CEL expression, where self is the object.\n")
+		fmt.Fprintf(os.Stderr, "A TARGET is Kind/name, or a Kind with --selector.\n\n")
+		fmt.Fprintf(os.Stderr, "Exits with 0 when the expression is satisfied, %d on timeout, and %d on errors.\n\n", exitConditionNotMet, exitError)
+		fmt.Fprintf(os.Stderr, "Examples:\n")
+		fmt.Fprintf(os.Stderr, "  kubectl expect StatefulSet/mysql 'self.status.readyReplicas >= 1'\n")
+		fmt.Fprintf(os.Stderr, "  kubectl expect --timeout=2m -l app=web Pod 'self.status.phase == \"Running\"'\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		pflag.PrintDefaults()
+	}
 	pflag.StringVarP(&namespace, "namespace", "n", namespace, "If present, the namespace scope for this CLI request")
 	pflag.StringVar(&kubeconfig, "kubeconfig", kubeconfig, "Path to the kubeconfig file to use for CLI requests.")
+	pflag.StringVarP(&selector, "selector", "l", selector, "Label selector for targets given as a Kind, e.g. app=web")
+	pflag.StringVar(&match, "match", match, "For targets with a label selector, whether all or any of the matching objects must satisfy the expression. With all, at least one object must match.")
+	pflag.DurationVar(&timeout, "timeout", timeout, "How long to wait for the expression to be satisfied; 0 waits forever.")
+	pflag.StringVarP(&output, "output", "o", output, "Output format: empty, or json to print the final values of the expression for each object.")
 
 	klog.InitFlags(nil)
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
@@ -55,104 +122,143 @@ func run(ctx context.Context) error {
 	args := pflag.Args()
 
 	if len(args) < 2 {
-		return fmt.Errorf("expected [target] [cel-expression]")
+		return fmt.Errorf("expected [target]... [cel-expression]")
+	}
+	if output != "" && output != "json" {
+		return fmt.Errorf("unsupported output format %q; expected json", output)
 	}
 
-	target := args[0]
-	celExpressionText := args[1]
+	celExpressionText := args[len(args)-1]
 
 	kubeClient, err := kube.NewClient(kubeconfig)
 	if err != nil {
 		return err
 	}
 
-	tokens := strings.Split(target, "/")
-	if len(tokens) != 2 {
-		return fmt.Errorf("expected target like Pod/<name>")
-	}
-
-	// Find the resource (kind) the user is asking about
-	resource, err := kubeClient.FindResource(ctx, tokens[0])
+	// Compile the CEL expression
+	condition, err := expect.NewCondition(ctx, celExpressionText)
 	if err != nil {
 		return err
 	}
 
-	// Compute namespace, defaulting to kubeconfig or default
-	if namespace == "" && resource.Namespaced {
-		namespace, err = kubeClient.DefaultNamespace()
+	// Find the resources (kinds) the user is asking about
+	var targets []*expect.Target
+	for _, arg := range args[:len(args)-1] {
+		var target *expect.Target
+		if strings.Contains(arg, "/") {
+			target, err = expect.ParseTarget(ctx, kubeClient, arg, namespace)
+		} else if selector != "" {
+			target, err = expect.ParseSelectorTarget(ctx, kubeClient, arg, selector, expect.Match(match), namespace)
+		} else {
+			err = fmt.Errorf("expected target like Pod/<name>, or a kind with --selector, got %q", arg)
+		}
 		if err != nil {
 			return err
 		}
+		targets = append(targets, target)
 	}
 
-	// Compile the CEL expression
-	env, err := kel.NewEnv()
-	if err != nil {
-		return fmt.Errorf("initalizing CEL: %w", err)
-	}
-	celExpression, err := kel.NewExpression(env, celExpressionText)
-	if err != nil {
-		return err
+	// Progress goes to stderr with -o json, so that stdout is only the JSON
+	var progressOut io.Writer = os.Stdout
+	if output == "json" {
+		progressOut = os.Stderr
 	}
 
-	// build a pretty-printer for outputing status while polling
-	printer, err := celExpression.BuildStatusPrinter(ctx)
-	if err != nil {
-		return fmt.Errorf("building status printer: %w", err)
-	}
+	// Watch the targets at the same time, until they all satisfy the expression, one fails, or we time out
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	// Get ready to get the object
-	id := types.NamespacedName{
-		Namespace: namespace,
-		Name:      tokens[1],
-	}
+	var mutex sync.Mutex
+	var firstErr error
+	results := make([]*expect.Result, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-	gv := schema.GroupVersion{
-		Group:   resource.Group,
-		Version: resource.Version,
-	}
-	gvr := gv.WithResource(resource.Name)
-	gvk := gv.WithKind(resource.Kind)
+			onProgress := func(progress expect.Progress) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				fmt.Fprintf(progressOut, "waiting for %s to satisfy %q (%s)\n", target, celExpressionText, progress.Status)
+			}
+			result, err := expect.Wait(ctx, kubeClient, target, condition, expect.Options{Timeout: timeout, OnProgress: onProgress})
 
-	client := kubeClient.ForGVR(gvr, id.Namespace)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
 
-	// Poll the object until the CEL expression returns true
-	for {
-		// We _could_ watch...
-		time.Sleep(1 * time.Second)
+	out := &Output{Expression: celExpressionText, Met: true}
+	var elapsed time.Duration
+	for i, result := range results {
+		target := targets[i]
+		elapsed = max(elapsed, result.Elapsed)
+		out.Met = out.Met && result.Met
 
-		u, err := client.Get(ctx, id.Name, metav1.GetOptions{})
-		if err != nil {
-			return fmt.Errorf("getting %s %s: %w", gvk.Kind, id.Name, err)
+		targetOut := &TargetOutput{
+			Target:    target.String(),
+			Namespace: target.Namespace,
+			Selector:  target.Selector,
+			Met:       result.Met,
+			Status:    result.Status,
+			Objects:   []*ObjectOutput{},
 		}
-
-		out, err := celExpression.Eval(ctx, u)
-		if err != nil {
-			return err
+		if target.Selector != "" {
+			targetOut.Match = string(target.Match)
 		}
-
-		done := false
-		switch out.Type() {
-		case celtypes.BoolType:
-			v := out.Value().(bool)
-			if v {
-				done = true
+		for _, object := range result.Objects {
+			objectOut := &ObjectOutput{
+				Namespace: object.Namespace,
+				Name:      object.Name,
+				Met:       object.Met,
 			}
-		default:
-			return fmt.Errorf("unhandled type for CEL expression: %v", out.Type())
-		}
-		if done {
-			break
+			for _, value := range object.Values {
+				if objectOut.Values == nil {
+					objectOut.Values = make(map[string]any)
+				}
+				objectOut.Values[value.Expression] = value.Value
+			}
+			targetOut.Objects = append(targetOut.Objects, objectOut)
 		}
+		out.Targets = append(out.Targets, targetOut)
+	}
+	out.Elapsed = elapsed.Round(time.Millisecond).String()
 
-		// Pretty print some intermediate values if we can
-		if printer != nil {
-			s := printer(ctx, u)
-			fmt.Printf("waiting for %q (%s)\n", celExpression.CELText, s)
-		} else {
-			fmt.Printf("waiting for %q\n", celExpression.CELText)
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(out); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+	} else {
+		for _, target := range out.Targets {
+			state := "satisfied"
+			if !target.Met {
+				state = "timed out"
+			}
+			if target.Status != "" {
+				fmt.Printf("%s: %s (%s)\n", target.Target, state, target.Status)
+			} else {
+				fmt.Printf("%s: %s\n", target.Target, state)
+			}
 		}
 	}
 
+	if !out.Met {
+		return errConditionNotMet
+	}
 	return nil
 }
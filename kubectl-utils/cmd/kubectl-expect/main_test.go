@@ -0,0 +1,236 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runMainEnv makes the test binary run main instead of the tests, so that we can check its exit code.
+const runMainEnv = "KUBECTL_EXPECT_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// newFakeAPIServer serves the discovery, list and watch requests of kubectl expect, for a single ConfigMap
+// named web in the default namespace. Watches send no events.
+func newFakeAPIServer(t *testing.T, data map[string]string) *httptest.Server {
+	t.Helper()
+
+	configMap := map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "web", "namespace": "default", "resourceVersion": "1"},
+		"data":       data,
+	}
+	responses := map[string]any{
+		"/api":  map[string]any{"kind": "APIVersions", "versions": []string{"v1"}},
+		"/apis": map[string]any{"kind": "APIGroupList", "apiVersion": "v1", "groups": []any{}},
+		"/api/v1": map[string]any{
+			"kind":         "APIResourceList",
+			"groupVersion": "v1",
+			"resources": []any{map[string]any{
+				"name":         "configmaps",
+				"singularName": "configmap",
+				"namespaced":   true,
+				"kind":         "ConfigMap",
+				"verbs":        []string{"get", "list", "watch"},
+				"shortNames":   []string{"cm"},
+			}},
+		},
+		"/api/v1/namespaces/default/configmaps": map[string]any{
+			"apiVersion": "v1",
+			"kind":       "ConfigMapList",
+			"metadata":   map[string]any{"resourceVersion": "1"},
+			"items":      []any{configMap},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// writeKubeconfig writes a kubeconfig for the server.
+func writeKubeconfig(t *testing.T, server string) string {
+	t.Helper()
+
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: fake
+  cluster:
+    server: %s
+contexts:
+- name: fake
+  context:
+    cluster: fake
+    user: fake
+    namespace: default
+users:
+- name: fake
+  user: {}
+current-context: fake
+`, server)
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// runExpect runs kubectl expect with the arguments, and returns its exit code and output.
+func runExpect(t *testing.T, kubeconfig string, args ...string) (int, string, string) {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], append([]string{"--kubeconfig", kubeconfig}, args...)...)
+	cmd.Env = append(os.Environ(), runMainEnv+"=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitError *exec.ExitError
+	if err != nil && !errors.As(err, &exitError) {
+		t.Fatalf("running kubectl expect: %v", err)
+	}
+	return cmd.ProcessState.ExitCode(), stdout.String(), stderr.String()
+}
+
+func TestExitCodes(t *testing.T) {
+	server := newFakeAPIServer(t, map[string]string{"ready": "false"})
+	kubeconfig := writeKubeconfig(t, server.URL)
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantOutput string
+	}{
+		{
+			name:       "condition met",
+			args:       []string{"ConfigMap/web", `self.data.ready == "false"`},
+			wantCode:   0,
+			wantOutput: "ConfigMap/web: satisfied",
+		},
+		{
+			name:       "condition met by a selector target",
+			args:       []string{"-l", "app=web", "cm", `self.data.ready == "false"`},
+			wantCode:   0,
+			wantOutput: "ConfigMap -l app=web: satisfied",
+		},
+		{
+			name:       "timed out",
+			args:       []string{"--timeout=1s", "ConfigMap/web", `self.data.ready == "true"`},
+			wantCode:   exitConditionNotMet,
+			wantOutput: "ConfigMap/web: timed out",
+		},
+		{
+			name:     "invalid expression",
+			args:     []string{"ConfigMap/web", `self.data.ready ==`},
+			wantCode: exitError,
+		},
+		{
+			name:     "unknown kind",
+			args:     []string{"Widget/web", `self.data.ready == "true"`},
+			wantCode: exitError,
+		},
+		{
+			name:     "missing expression",
+			args:     []string{"ConfigMap/web"},
+			wantCode: exitError,
+		},
+		{
+			name:     "unsupported output format",
+			args:     []string{"-o", "yaml", "ConfigMap/web", `self.data.ready == "false"`},
+			wantCode: exitError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := runExpect(t, kubeconfig, tt.args...)
+			if code != tt.wantCode {
+				t.Fatalf("got exit code %d, want %d\nstdout:\n%s\nstderr:\n%s", code, tt.wantCode, stdout, stderr)
+			}
+			if !strings.Contains(stdout, tt.wantOutput) {
+				t.Errorf("got output %q, want it to contain %q", stdout, tt.wantOutput)
+			}
+			if tt.wantCode == exitError && stderr == "" {
+				t.Errorf("got no error message on stderr")
+			}
+		})
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	server := newFakeAPIServer(t, map[string]string{"ready": "false"})
+	kubeconfig := writeKubeconfig(t, server.URL)
+
+	for _, tt := range []struct {
+		expression string
+		wantCode   int
+		wantMet    bool
+	}{
+		{expression: `self.data.ready == "false"`, wantCode: 0, wantMet: true},
+		{expression: `self.data.ready == "true"`, wantCode: exitConditionNotMet, wantMet: false},
+	} {
+		t.Run(tt.expression, func(t *testing.T) {
+			code, stdout, stderr := runExpect(t, kubeconfig, "-o", "json", "--timeout=1s", "ConfigMap/web", tt.expression)
+			if code != tt.wantCode {
+				t.Fatalf("got exit code %d, want %d\nstderr:\n%s", code, tt.wantCode, stderr)
+			}
+
+			// Progress goes to stderr, so stdout is only the JSON
+			var out Output
+			if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+				t.Fatalf("parsing output %q: %v", stdout, err)
+			}
+			if out.Met != tt.wantMet || len(out.Targets) != 1 || out.Targets[0].Met != tt.wantMet {
+				t.Fatalf("got output %+v, want met %t for the one target", out, tt.wantMet)
+			}
+			if got := out.Targets[0].Objects; len(got) != 1 || got[0].Name != "web" || got[0].Values["self.data.ready"] != "false" {
+				t.Errorf("got objects %+v, want web with self.data.ready = \"false\"", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,404 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package expect waits for kubernetes objects to satisfy a CEL expression, watching them rather than polling.
+package expect
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/kubectl-utils/pkg/kel"
+	"github.com/GoogleCloudPlatform/kubectl-ai/kubectl-utils/pkg/kube"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+	"k8s.io/klog/v2"
+)
+
+// Match is how many of the objects matching a label selector must satisfy the condition.
+type Match string
+
+const (
+	// MatchAll requires all the matching objects to satisfy the condition, and at least one object to match.
+	MatchAll Match = "all"
+	// MatchAny requires at least one of the matching objects to satisfy the condition.
+	MatchAny Match = "any"
+)
+
+// Target is the object, or the objects matching a label selector, to wait for.
+type Target struct {
+	Resource  *metav1.APIResource
+	Namespace string
+	// Name is the name of the object, for a single object.
+	Name string
+	// Selector is the label selector of the objects, if Name is not set.
+	Selector string
+	// Match is how many of the objects matching Selector must satisfy the condition; it defaults to MatchAll.
+	Match Match
+}
+
+// ParseTarget resolves a target such as "Deployment/web" or "deploy/web". The namespace defaults to the
+// kubeconfig's namespace, for namespaced resources.
+func ParseTarget(ctx context.Context, client *kube.Client, target, namespace string) (*Target, error) {
+	kind, name, ok := strings.Cut(target, "/")
+	if !ok || kind == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("expected target like Pod/<name>, got %q", target)
+	}
+	return resolveTarget(ctx, client, &Target{Name: name}, kind, namespace)
+}
+
+// ParseSelectorTarget resolves a target for the objects of a kind (e.g. "Pod" or "pods") matching a label selector,
+// such as "app=web". The namespace defaults as for ParseTarget.
+func ParseSelectorTarget(ctx context.Context, client *kube.Client, kind, selector string, match Match, namespace string) (*Target, error) {
+	if kind == "" || strings.Contains(kind, "/") {
+		return nil, fmt.Errorf("expected a kind such as Pod with a label selector, got %q", kind)
+	}
+	if _, err := labels.Parse(selector); err != nil {
+		return nil, fmt.Errorf("parsing label selector %q: %w", selector, err)
+	}
+	switch match {
+	case "":
+		match = MatchAll
+	case MatchAll, MatchAny:
+	default:
+		return nil, fmt.Errorf("expected match %q or %q, got %q", MatchAll, MatchAny, match)
+	}
+	return resolveTarget(ctx, client, &Target{Selector: selector, Match: match}, kind, namespace)
+}
+
+// resolveTarget sets the resource and namespace of the target.
+func resolveTarget(ctx context.Context, client *kube.Client, target *Target, kind, namespace string) (*Target, error) {
+	resource, err := client.FindResource(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	if !resource.Namespaced {
+		namespace = ""
+	} else if namespace == "" {
+		namespace, err = client.DefaultNamespace()
+		if err != nil {
+			return nil, err
+		}
+	}
+	target.Resource = resource
+	target.Namespace = namespace
+	return target, nil
+}
+
+// String returns the target as Kind/name, e.g. "Deployment/web", or as the kind and selector, e.g. "Pod -l app=web".
+func (t *Target) String() string {
+	if t.Name == "" {
+		return t.Resource.Kind + " -l " + t.Selector
+	}
+	return t.Resource.Kind + "/" + t.Name
+}
+
+func (t *Target) gvr() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: t.Resource.Group, Version: t.Resource.Version, Resource: t.Resource.Name}
+}
+
+// listOptions selects the objects of the target.
+func (t *Target) listOptions(options *metav1.ListOptions) {
+	if t.Name == "" {
+		options.LabelSelector = t.Selector
+	} else {
+		options.FieldSelector = fields.OneTermEqualSelector("metadata.name", t.Name).String()
+	}
+}
+
+// Condition is a CEL expression that the object must satisfy, where self is the object.
+type Condition struct {
+	Expression *kel.Expression
+	// values returns the values of the parts of the expression, or is nil if we don't know which values to return.
+	values kel.ValuesFunction
+}
+
+// NewCondition compiles a CEL expression, such as "self.status.readyReplicas == self.spec.replicas".
+func NewCondition(ctx context.Context, expression string) (*Condition, error) {
+	env, err := kel.NewEnv()
+	if err != nil {
+		return nil, fmt.Errorf("initalizing CEL: %w", err)
+	}
+	celExpression, err := kel.NewExpression(env, expression)
+	if err != nil {
+		return nil, err
+	}
+	values, err := celExpression.BuildValuesFunction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("building status printer: %w", err)
+	}
+	return &Condition{Expression: celExpression, values: values}, nil
+}
+
+// ObjectStatus is the status of one of the objects of a target.
+type ObjectStatus struct {
+	Namespace string
+	Name      string
+	// Met is true if the object satisfies the condition.
+	Met bool
+	// Status is the values of the parts of the expression (e.g. "self.status.readyReplicas=1"), or why the
+	// expression could not be evaluated.
+	Status string
+	// Values are the values of the parts of the expression, if we know which values to report.
+	Values []kel.Value
+	Object *unstructured.Unstructured
+}
+
+// Evaluate returns whether the object satisfies the condition, and its status. An expression that refers to
+// fields the object does not have (yet) is not satisfied, rather than an error.
+func (c *Condition) Evaluate(ctx context.Context, u *unstructured.Unstructured) (*ObjectStatus, error) {
+	status := &ObjectStatus{
+		Namespace: u.GetNamespace(),
+		Name:      u.GetName(),
+		Object:    u,
+	}
+	if c.values != nil {
+		status.Values = c.values(ctx, u)
+		status.Status = kel.FormatValues(status.Values)
+	}
+
+	out, err := c.Expression.Eval(ctx, u)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such key") {
+			if status.Status == "" {
+				status.Status = err.Error()
+			}
+			return status, nil
+		}
+		return nil, err
+	}
+	met, ok := out.Value().(bool)
+	if !ok {
+		return nil, fmt.Errorf("CEL expression %q must return a bool, got %v", c.Expression.CELText, out.Type())
+	}
+	status.Met = met
+	return status, nil
+}
+
+// Progress is reported when the status of the target changes, while the condition is not yet satisfied.
+type Progress struct {
+	// Elapsed is the time since we started waiting.
+	Elapsed time.Duration
+	// Status is the status of the target, as in Result.
+	Status string
+}
+
+// Options configure Wait.
+type Options struct {
+	// Timeout is how long to wait; 0 means no timeout.
+	Timeout time.Duration
+	// OnProgress, if set, is called with each new status of the target while we wait.
+	OnProgress func(Progress)
+}
+
+// Result is the outcome of Wait.
+type Result struct {
+	// Met is true if the condition was satisfied, and false if we timed out.
+	Met     bool
+	Elapsed time.Duration
+	// Status is the last status of the target: the status of the object, or e.g. "not found", for a single
+	// object; how many objects satisfy the condition, for a label selector.
+	Status string
+	// Objects are the last status of the objects of the target, sorted by namespace and name.
+	Objects []*ObjectStatus
+}
+
+// Wait watches the target until it satisfies the condition, or until the timeout. A timeout is not an error;
+// errors are returned if the objects cannot be read, the condition cannot be evaluated, or ctx is cancelled.
+func Wait(ctx context.Context, client *kube.Client, target *Target, condition *Condition, opt Options) (*Result, error) {
+	log := klog.FromContext(ctx)
+
+	start := time.Now()
+	result := &Result{}
+	// objects holds the status of each object of the target, by namespace/name
+	objects := make(map[string]*ObjectStatus)
+
+	// update evaluates the condition against the object with the key, after it was added or changed (u is set)
+	// or deleted (u is nil).
+	update := func(key string, u *unstructured.Unstructured) error {
+		if u == nil {
+			delete(objects, key)
+			return nil
+		}
+		status, err := condition.Evaluate(ctx, u)
+		if err != nil {
+			return err
+		}
+		objects[key] = status
+		return nil
+	}
+	// check returns whether the objects satisfy the condition, reporting progress if they don't.
+	check := func() bool {
+		result.Objects = result.Objects[:0]
+		for _, status := range objects {
+			result.Objects = append(result.Objects, status)
+		}
+		sort.Slice(result.Objects, func(i, j int) bool {
+			return objectKey(result.Objects[i].Namespace, result.Objects[i].Name) < objectKey(result.Objects[j].Namespace, result.Objects[j].Name)
+		})
+
+		met, status := target.summarize(result.Objects)
+		if met {
+			result.Status = status
+			return true
+		}
+		if status != result.Status {
+			result.Status = status
+			if opt.OnProgress != nil {
+				opt.OnProgress(Progress{Elapsed: time.Since(start), Status: status})
+			}
+		}
+		return false
+	}
+	// reset evaluates the condition against all the objects of the target, as listed.
+	reset := func(items []*unstructured.Unstructured) (bool, error) {
+		clear(objects)
+		for _, u := range items {
+			if err := update(objectKey(u.GetNamespace(), u.GetName()), u); err != nil {
+				return false, err
+			}
+		}
+		return check(), nil
+	}
+
+	waitCtx, cancel := watchtools.ContextWithOptionalTimeout(ctx, opt.Timeout)
+	defer cancel()
+
+	resourceClient := client.ForGVR(target.gvr(), target.Namespace)
+
+	// List the objects first, so that errors such as being forbidden to read them are reported straight away,
+	// rather than retried by the watch until we time out
+	options := metav1.ListOptions{}
+	target.listOptions(&options)
+	list, err := resourceClient.List(waitCtx, options)
+	if err != nil {
+		if waitCtx.Err() != nil && ctx.Err() == nil {
+			result.Elapsed = time.Since(start)
+			return result, nil
+		}
+		return nil, fmt.Errorf("listing %s: %w", target, err)
+	}
+	var items []*unstructured.Unstructured
+	for i := range list.Items {
+		items = append(items, &list.Items[i])
+	}
+	if met, err := reset(items); err != nil || met {
+		result.Met = met
+		result.Elapsed = time.Since(start)
+		return result, err
+	}
+
+	// The requests are not bound to waitCtx: UntilWithSync stops the watch when we time out, and a request that
+	// fails with a timeout would be logged as an error
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			target.listOptions(&options)
+			return resourceClient.List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			target.listOptions(&options)
+			return resourceClient.Watch(ctx, options)
+		},
+	}
+	// The objects may have changed since we listed them, so start again from the objects the watch starts with
+	precondition := func(store cache.Store) (bool, error) {
+		var items []*unstructured.Unstructured
+		for _, obj := range store.List() {
+			if u, ok := obj.(*unstructured.Unstructured); ok {
+				items = append(items, u)
+			}
+		}
+		return reset(items)
+	}
+	_, err = watchtools.UntilWithSync(waitCtx, lw, &unstructured.Unstructured{}, precondition, func(event watch.Event) (bool, error) {
+		switch event.Type {
+		case watch.Added, watch.Modified, watch.Deleted:
+			u, ok := event.Object.(*unstructured.Unstructured)
+			if !ok {
+				return false, fmt.Errorf("unexpected object %T in watch", event.Object)
+			}
+			key := objectKey(u.GetNamespace(), u.GetName())
+			if event.Type == watch.Deleted {
+				u = nil
+			}
+			if err := update(key, u); err != nil {
+				return false, err
+			}
+			return check(), nil
+		default:
+			log.V(2).Info("ignoring watch event", "type", event.Type)
+			return false, nil
+		}
+	})
+	result.Elapsed = time.Since(start)
+	if err != nil {
+		if waitCtx.Err() != nil && ctx.Err() == nil {
+			// Timed out
+			return result, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("watching %s: %w", target, err)
+	}
+	result.Met = true
+	return result, nil
+}
+
+// summarize returns whether the objects of the target satisfy the condition, and the status of the target.
+func (t *Target) summarize(objects []*ObjectStatus) (bool, string) {
+	if t.Name != "" {
+		if len(objects) == 0 {
+			return false, "not found"
+		}
+		return objects[0].Met, objects[0].Status
+	}
+
+	if len(objects) == 0 {
+		return false, fmt.Sprintf("no objects match %q", t.Selector)
+	}
+	var met []*ObjectStatus
+	var unmet []*ObjectStatus
+	for _, object := range objects {
+		if object.Met {
+			met = append(met, object)
+		} else {
+			unmet = append(unmet, object)
+		}
+	}
+	status := fmt.Sprintf("%d of %d objects satisfy the condition", len(met), len(objects))
+	if len(unmet) != 0 && unmet[0].Status != "" {
+		// Show why one of the objects does not, to show progress
+		status += fmt.Sprintf("; %s: %s", unmet[0].Name, unmet[0].Status)
+	}
+	if t.Match == MatchAny {
+		return len(met) != 0, status
+	}
+	return len(unmet) == 0, status
+}
+
+func objectKey(namespace, name string) string {
+	return namespace + "/" + name
+}
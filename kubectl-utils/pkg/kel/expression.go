@@ -90,8 +90,16 @@ func (a *unstructuredToCELAdapter) NativeToValue(value any) ref.Val {
 		return celtypes.Int(value)
 	case map[string]any:
 		return celtypes.NewDynamicMap(a, value)
+	case []any:
+		return celtypes.NewDynamicList(a, value)
+	case bool:
+		return celtypes.Bool(value)
+	case float64:
+		return celtypes.Double(value)
+	case nil:
+		return celtypes.NullValue
 	default:
-		klog.Fatalf("unhandled type %T", value)
-		return nil
+		// Objects decoded from JSON only hold the types above, but objects built in code may hold others
+		return celtypes.DefaultTypeAdapter.NativeToValue(value)
 	}
 }
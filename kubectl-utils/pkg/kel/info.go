@@ -28,8 +28,49 @@ import (
 
 type InfoFunction func(ctx context.Context, self *unstructured.Unstructured) string
 
+// Value is the value of part of a CEL expression, such as self.status.readyReplicas, for an object.
+type Value struct {
+	// Expression is the part of the expression.
+	Expression string
+	// Value is the value, or nil if it could not be evaluated, e.g. because the field is not set.
+	Value any
+	// Err is why the value could not be evaluated.
+	Err error
+}
+
+// String returns the value as "<expression>=<value>", with ??? for values that could not be evaluated.
+func (v Value) String() string {
+	if v.Err != nil {
+		return fmt.Sprintf("%s=%v", v.Expression, "???")
+	}
+	return fmt.Sprintf("%s=%v", v.Expression, v.Value)
+}
+
+type ValuesFunction func(ctx context.Context, self *unstructured.Unstructured) []Value
+
 // BuildStatusPrinter returns an InfoFunction that attempts to report important values from the evaluation of the CEL expression
 func (x *Expression) BuildStatusPrinter(ctx context.Context) (InfoFunction, error) {
+	valuesFunction, err := x.BuildValuesFunction(ctx)
+	if err != nil || valuesFunction == nil {
+		return nil, err
+	}
+	return func(ctx context.Context, self *unstructured.Unstructured) string {
+		return FormatValues(valuesFunction(ctx, self))
+	}, nil
+}
+
+// FormatValues formats values as "<expression>=<value>; ...".
+func FormatValues(values []Value) string {
+	var s []string
+	for _, value := range values {
+		s = append(s, value.String())
+	}
+	return strings.Join(s, "; ")
+}
+
+// BuildValuesFunction returns a ValuesFunction that attempts to report important values from the evaluation of the
+// CEL expression, or nil if we don't know which values are important.
+func (x *Expression) BuildValuesFunction(ctx context.Context) (ValuesFunction, error) {
 	log := klog.FromContext(ctx)
 
 	checkedExpr, err := cel.AstToCheckedExpr(x.AST)
@@ -57,7 +98,7 @@ func (x *Expression) BuildStatusPrinter(ctx context.Context) (InfoFunction, erro
 			return nil, nil
 		}
 		log.V(2).Info("recognized function", "function", printFunction)
-		return x.buildFunctionPrinterFor(v.CallExpr.Args)
+		return x.buildFunctionValuesFor(v.CallExpr.Args)
 
 	default:
 		klog.Warningf("unhandled expression kind %T", checkedExpr.Expr.ExprKind)
@@ -65,7 +106,7 @@ func (x *Expression) BuildStatusPrinter(ctx context.Context) (InfoFunction, erro
 	}
 }
 
-func (x *Expression) buildFunctionPrinterFor(args []*exprpb.Expr) (InfoFunction, error) {
+func (x *Expression) buildFunctionValuesFor(args []*exprpb.Expr) (ValuesFunction, error) {
 	checkedExpr, err := cel.AstToCheckedExpr(x.AST)
 	if err != nil {
 		return nil, fmt.Errorf("parsing CEL ast: %w", err)
@@ -124,24 +165,24 @@ func (x *Expression) buildFunctionPrinterFor(args []*exprpb.Expr) (InfoFunction,
 		return nil, nil
 	}
 
-	return func(ctx context.Context, self *unstructured.Unstructured) string {
+	return func(ctx context.Context, self *unstructured.Unstructured) []Value {
 		log := klog.FromContext(ctx)
 
 		inputs := x.buildInputs(self)
 
-		var values []string
+		var values []Value
 		for _, debugValue := range debugValues {
-			s := ""
+			value := Value{Expression: debugValue.Key}
 			out, details, err := debugValue.Program.Eval(inputs)
 			log.V(2).Info("evaluated CEL expression", "out", out, "details", details, "error", err)
 			if err == nil {
-				s = fmt.Sprintf("%s=%v", debugValue.Key, out.Value())
+				value.Value = out.Value()
 			} else {
-				s = fmt.Sprintf("%s=%v", debugValue.Key, "???")
+				value.Err = err
 			}
-			values = append(values, s)
+			values = append(values, value)
 		}
 
-		return strings.Join(values, "; ")
+		return values
 	}, nil
 }
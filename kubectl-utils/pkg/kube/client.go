@@ -16,6 +16,7 @@ package kube
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
@@ -62,7 +63,10 @@ func NewClient(kubeconfig string) (*Client, error) {
 
 func loadKubeconfig(kubeconfigPath string) (clientcmd.ClientConfig, error) {
 	rules := clientcmd.NewDefaultClientConfigLoadingRules()
-	if kubeconfigPath != "" {
+	if paths := filepath.SplitList(kubeconfigPath); len(paths) > 1 {
+		// A list of files, as in $KUBECONFIG
+		rules.Precedence = paths
+	} else if kubeconfigPath != "" {
 		rules.ExplicitPath = kubeconfigPath
 	}
 	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
@@ -84,6 +88,19 @@ func (c *Client) DefaultNamespace() (string, error) {
 	return namespace, nil
 }
 
+// CurrentContext returns the name of the kubeconfig context in use, and the address of its API server.
+func (c *Client) CurrentContext() (string, string, error) {
+	rawConfig, err := c.clientConfig.RawConfig()
+	if err != nil {
+		return "", "", fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	restConfig, err := c.clientConfig.ClientConfig()
+	if err != nil {
+		return "", "", fmt.Errorf("building kubernetes API configuration: %w", err)
+	}
+	return rawConfig.CurrentContext, restConfig.Host, nil
+}
+
 // ForGVR returns a dynamic client for the specified GroupVersionResource and namespace
 func (c *Client) ForGVR(gvr schema.GroupVersionResource, namespace string) dynamic.ResourceInterface {
 	var client dynamic.ResourceInterface
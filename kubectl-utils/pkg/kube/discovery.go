@@ -18,11 +18,13 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
 )
 
 func buildDiscoveryClient(restConfig *rest.Config, httpClient *http.Client) (discovery.DiscoveryInterface, error) {
@@ -34,36 +36,82 @@ func buildDiscoveryClient(restConfig *rest.Config, httpClient *http.Client) (dis
 	return client, nil
 }
 
+// FindResource finds the resource with the given name, as accepted by kubectl: the kind (e.g. "Deployment"),
+// the plural or singular resource name, or a short name (e.g. "deploy"), optionally qualified by the group
+// (e.g. "deployments.apps"). Names are matched case-insensitively.
 func (c *Client) FindResource(ctx context.Context, name string) (*metav1.APIResource, error) {
 	var matches []metav1.APIResource
 	resourceLists, err := c.DiscoveryClient.ServerPreferredResources()
 	if err != nil {
-		return nil, fmt.Errorf("doing server discovery: %w", err)
+		// Some API groups may be unavailable (e.g. an aggregated API whose server is down); we can use the others
+		if !discovery.IsGroupDiscoveryFailedError(err) || len(resourceLists) == 0 {
+			return nil, fmt.Errorf("doing server discovery: %w", err)
+		}
+		klog.FromContext(ctx).V(2).Info("ignoring partial discovery failure", "error", err)
 	}
+
+	lowerName := strings.ToLower(name)
 	for _, resourceList := range resourceLists {
 		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
 		if err != nil {
 			return nil, fmt.Errorf("parsing group version %q: %w", resourceList.GroupVersion, err)
 		}
 		for _, resource := range resourceList.APIResources {
-			if resource.Kind == name {
-				if resource.Group == "" {
-					resource.Group = gv.Group
-				}
-				if resource.Version == "" {
-					resource.Version = gv.Version
-				}
+			if strings.Contains(resource.Name, "/") {
+				// A subresource, such as pods/log
+				continue
+			}
+			if resource.Group == "" {
+				resource.Group = gv.Group
+			}
+			if resource.Version == "" {
+				resource.Version = gv.Version
+			}
+			if matchesResource(lowerName, resource) {
 				matches = append(matches, resource)
 			}
 		}
 	}
 
+	if len(matches) > 1 {
+		// As kubectl does, prefer the core group, e.g. for events
+		var core []metav1.APIResource
+		for _, match := range matches {
+			if match.Group == "" {
+				core = append(core, match)
+			}
+		}
+		if len(core) == 1 {
+			matches = core
+		}
+	}
+
 	if len(matches) == 0 {
 		return nil, fmt.Errorf("no match for resource %q", name)
 	}
 	if len(matches) > 1 {
-		return nil, fmt.Errorf("found multiple matches for resource %q", name)
+		var candidates []string
+		for _, match := range matches {
+			candidates = append(candidates, match.Name+"."+match.Group)
+		}
+		return nil, fmt.Errorf("found multiple matches for resource %q: %s", name, strings.Join(candidates, ", "))
 	}
 	resource := matches[0]
 	return &resource, nil
 }
+
+// matchesResource returns true if the lower-case name refers to the resource.
+func matchesResource(name string, resource metav1.APIResource) bool {
+	if group, ok := strings.CutPrefix(name, strings.ToLower(resource.Name)+"."); ok {
+		return group == resource.Group
+	}
+	if name == strings.ToLower(resource.Kind) || name == resource.Name || name == resource.SingularName {
+		return true
+	}
+	for _, shortName := range resource.ShortNames {
+		if name == shortName {
+			return true
+		}
+	}
+	return false
+}
@@ -26,12 +26,18 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/agent"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/clusterinfo"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/kubeconfig"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/redact"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/telemetry"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui"
 	"github.com/spf13/cobra"
@@ -66,6 +72,9 @@ func BuildRootCommand(opt *Options) (*cobra.Command, error) {
 		return nil, err
 	}
 
+	rootCmd.AddCommand(newAuditCommand(opt))
+	rootCmd.AddCommand(newTraceCommand(opt))
+
 	return rootCmd, nil
 }
 
@@ -86,8 +95,50 @@ type Options struct {
 	MaxIterations          int    `json:"maxIterations,omitempty"`
 	KubeConfigPath         string `json:"kubeConfigPath,omitempty"`
 	PromptTemplateFilePath string `json:"promptTemplateFilePath,omitempty"`
-	TracePath              string `json:"tracePath,omitempty"`
-	RemoveWorkDir          bool   `json:"removeWorkDir,omitempty"`
+	// InstructionFiles are extra files of instructions added to the system prompt, e.g. organization-wide instructions.
+	InstructionFiles []string `json:"instructionFiles,omitempty"`
+	TracePath        string   `json:"tracePath,omitempty"`
+	// TraceFormat is the format of the trace file, "yaml" or "jsonl".
+	TraceFormat string `json:"traceFormat,omitempty"`
+	// OTelEndpoint is the base URL of an OTLP/HTTP collector to export traces and metrics to.
+	// Telemetry is also exported if the standard OTEL_EXPORTER_OTLP_ENDPOINT environment variable is set.
+	OTelEndpoint string `json:"otelEndpoint,omitempty"`
+	// AuditLogPath is the append-only, hash-chained log of queries, tool calls, approvals and results.
+	AuditLogPath string `json:"auditLog,omitempty"`
+	// AuditSyslog and AuditWebhook send a copy of each audit entry to the system log, and to a webhook.
+	AuditSyslog  bool   `json:"auditSyslog,omitempty"`
+	AuditWebhook string `json:"auditWebhook,omitempty"`
+	// Redact masks secrets in tool results before they are sent to the LLM, or written to the trace and audit log.
+	Redact bool `json:"redact"`
+	// RedactPatterns are extra regular expressions to redact, given by flag; others can be set in Redaction.
+	RedactPatterns []string `json:"-"`
+	// Redaction configures the rules used to redact secrets, globally and for each tool.
+	Redaction     redact.Config `json:"redaction,omitempty"`
+	RemoveWorkDir bool          `json:"removeWorkDir,omitempty"`
+	// ReadOnly makes the tools refuse commands that may modify the cluster, and disables the bash tool.
+	ReadOnly bool `json:"readOnly,omitempty"`
+	// ImpersonateUser and ImpersonateGroups make the agent's tool calls impersonate another user, as with kubectl --as.
+	ImpersonateUser   string   `json:"impersonateUser,omitempty"`
+	ImpersonateGroups []string `json:"impersonateGroups,omitempty"`
+	// ServiceAccount ("namespace/name") makes the agent's tool calls use a token for the service account instead of the user's credentials.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// TokenFile makes the agent's tool calls use the bearer token in the file instead of the user's credentials.
+	TokenFile string `json:"tokenFile,omitempty"`
+	// Escalate retries approved calls that the agent's identity is forbidden to make with the user's own credentials.
+	Escalate bool `json:"escalate,omitempty"`
+	// PlanMode restricts the agent to read-only commands while investigating, and requires approval of a plan for any changes.
+	PlanMode bool `json:"planMode,omitempty"`
+	// DiscoverCluster gathers information about the cluster (version, namespaces, API groups etc.) for the system prompt.
+	DiscoverCluster bool `json:"discoverCluster,omitempty"`
+	// ClusterInfoTTL is how long gathered cluster information is cached.
+	// It is only set by flag or environment variable, as a time.Duration does not parse from the configuration file.
+	ClusterInfoTTL time.Duration `json:"-"`
+	// ShowThinking expands the reasoning of the model in the UI, rather than only showing a summary.
+	ShowThinking bool `json:"showThinking,omitempty"`
+	// Attachments are files (text or images) sent along with the first query.
+	Attachments []string `json:"-"`
+	// GenerationConfig holds the settings (temperature, max tokens etc) passed to the LLM.
+	GenerationConfig gollm.GenerationConfig `json:"generationConfig,omitempty"`
 }
 
 func (o *Options) InitDefaults() {
@@ -105,7 +156,13 @@ func (o *Options) InitDefaults() {
 	o.KubeConfigPath = ""
 	o.PromptTemplateFilePath = ""
 	o.TracePath = filepath.Join(os.TempDir(), "kubectl-ai-trace.txt")
+	o.TraceFormat = string(journal.FormatYAML)
+	o.Redact = true
 	o.RemoveWorkDir = false
+	o.ReadOnly = false
+	o.PlanMode = false
+	o.DiscoverCluster = false
+	o.ClusterInfoTTL = 10 * time.Minute
 }
 
 func (o *Options) LoadConfiguration(b []byte) error {
@@ -223,15 +280,45 @@ func (opt *Options) bindCLIFlagsToViper(f *pflag.FlagSet) error {
 	f.IntVar(&opt.MaxIterations, "max-iterations", opt.MaxIterations, "maximum number of iterations agent will try before giving up")
 	f.StringVar(&opt.KubeConfigPath, "kubeconfig", opt.KubeConfigPath, "path to kubeconfig file")
 	f.StringVar(&opt.PromptTemplateFilePath, "prompt-template-file-path", opt.PromptTemplateFilePath, "path to custom prompt template file")
+	f.StringArrayVar(&opt.InstructionFiles, "instructions", opt.InstructionFiles, "path to a file of additional instructions for the system prompt (e.g. organization-wide instructions); can be repeated")
+	f.BoolVar(&opt.DiscoverCluster, "discover-cluster", opt.DiscoverCluster, "gather information about the cluster (version, namespaces, API groups, CRDs and nodes) for the language model before the first query")
+	f.DurationVar(&opt.ClusterInfoTTL, "cluster-info-ttl", opt.ClusterInfoTTL, "how long to cache the information gathered by --discover-cluster")
 	f.StringVar(&opt.TracePath, "trace-path", opt.TracePath, "path to the trace file")
+	f.StringVar(&opt.TraceFormat, "trace-format", opt.TraceFormat, "format of the trace file: yaml, or jsonl (one JSON event per line, for other tools); view either with: kubectl-ai trace")
+	f.StringVar(&opt.OTelEndpoint, "otel-endpoint", opt.OTelEndpoint, "base URL of an OpenTelemetry (OTLP/HTTP) collector to export traces and metrics to, e.g. http://localhost:4318")
+	f.StringVar(&opt.AuditLogPath, "audit-log", opt.AuditLogPath, "path to an append-only, tamper-evident log of queries, commands, approvals and results (check it with: kubectl-ai audit verify)")
+	f.BoolVar(&opt.AuditSyslog, "audit-syslog", opt.AuditSyslog, "also send audit log entries to syslog")
+	f.StringVar(&opt.AuditWebhook, "audit-webhook", opt.AuditWebhook, "also POST audit log entries, as JSON, to this URL")
+	f.BoolVar(&opt.Redact, "redact", opt.Redact, "mask secrets (Secret data, tokens, private keys and cloud credentials) in tool results before they are sent to the language model, or written to the trace and audit log")
+	f.StringArrayVar(&opt.RedactPatterns, "redact-pattern", opt.RedactPatterns, "regular expression of other values to redact; if it has capture groups, only they are masked; can be repeated")
 	f.BoolVar(&opt.RemoveWorkDir, "remove-workdir", opt.RemoveWorkDir, "remove the temporary working directory after execution")
 
 	f.StringVar(&opt.ProviderID, "llm-provider", opt.ProviderID, "language model provider")
 	f.StringVar(&opt.ModelID, "model", opt.ModelID, "language model e.g. gemini-2.0-flash-thinking-exp-01-21, gemini-2.0-flash")
 	f.BoolVar(&opt.SkipPermissions, "skip-permissions", opt.SkipPermissions, "(dangerous) skip asking for confirmation before executing kubectl commands that modify resources")
 	f.BoolVar(&opt.MCPServer, "mcp-server", opt.MCPServer, "run in MCP server mode")
+	f.BoolVar(&opt.ReadOnly, "read-only", opt.ReadOnly, "refuse commands that may modify the cluster (only read-only kubectl commands are run, and bash is disabled), e.g. for on-call triage")
+	f.StringVar(&opt.ImpersonateUser, "as", opt.ImpersonateUser, "user to impersonate for all commands run by the agent, as with kubectl --as")
+	f.StringArrayVar(&opt.ImpersonateGroups, "as-group", opt.ImpersonateGroups, "group to impersonate for all commands run by the agent, as with kubectl --as-group; can be repeated")
+	f.StringVar(&opt.ServiceAccount, "service-account", opt.ServiceAccount, "service account (namespace/name) to run all commands as; a token for it is requested with your credentials")
+	f.StringVar(&opt.TokenFile, "token-file", opt.TokenFile, "file containing a bearer token to run all commands with, instead of the credentials in the kubeconfig")
+	f.BoolVar(&opt.Escalate, "escalate", opt.Escalate, "when the agent runs as another identity (--as, --service-account or --token-file), retry commands you approve with your own credentials if that identity is forbidden to run them")
+	f.BoolVar(&opt.PlanMode, "plan", opt.PlanMode, "investigate with read-only commands, then propose a plan of changes to approve, edit or reject before any of them are made")
 	f.BoolVar(&opt.EnableToolUseShim, "enable-tool-use-shim", opt.EnableToolUseShim, "enable tool use shim")
 	f.BoolVar(&opt.Quiet, "quiet", opt.Quiet, "run in non-interactive mode, requires a query to be provided as a positional argument")
+	f.StringArrayVar(&opt.Attachments, "attach", opt.Attachments, "file (text or image) to send along with the first query; can be repeated. Files can also be attached in a query with @path")
+
+	gen := &opt.GenerationConfig
+	f.Var(newOptionalFlag(&gen.Temperature, parseFloat32), "temperature", "sampling temperature for the language model (default is provider-specific)")
+	f.Var(newOptionalFlag(&gen.TopP, parseFloat32), "top-p", "nucleus sampling probability for the language model")
+	f.Var(newOptionalFlag(&gen.TopK, parseInt32), "top-k", "top-k sampling for the language model")
+	f.Var(newOptionalFlag(&gen.MaxOutputTokens, parseInt32), "max-output-tokens", "maximum number of tokens in each response from the language model")
+	f.StringSliceVar(&gen.StopSequences, "stop-sequences", gen.StopSequences, "sequences that stop generation by the language model")
+	f.Var(newOptionalFlag(&gen.Seed, parseInt64), "seed", "random seed for the language model, for more reproducible responses")
+	f.Var(newOptionalFlag(&gen.ThinkingBudget, parseInt32), "thinking-budget", "number of tokens the language model may spend on reasoning")
+	f.Var(newOptionalFlag(&gen.IncludeThoughts, strconv.ParseBool), "include-thoughts", "ask the language model to return its reasoning (needed for gemini; other providers return it when the model produces it)")
+	f.Lookup("include-thoughts").NoOptDefVal = "true"
+	f.BoolVar(&opt.ShowThinking, "show-thinking", opt.ShowThinking, "show the full reasoning of the language model, rather than a summary")
 
 	// viper binds and env var prefixes
 	if err := loadViperFlags(f); err != nil {
@@ -241,6 +328,51 @@ func (opt *Options) bindCLIFlagsToViper(f *pflag.FlagSet) error {
 	return nil
 }
 
+// optionalFlag is a pflag.Value for an optional setting; the target is left nil unless the flag is set.
+type optionalFlag[T any] struct {
+	target **T
+	parse  func(string) (T, error)
+}
+
+func newOptionalFlag[T any](target **T, parse func(string) (T, error)) *optionalFlag[T] {
+	return &optionalFlag[T]{target: target, parse: parse}
+}
+
+func (f *optionalFlag[T]) String() string {
+	if *f.target == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", **f.target)
+}
+
+func (f *optionalFlag[T]) Set(s string) error {
+	v, err := f.parse(s)
+	if err != nil {
+		return err
+	}
+	*f.target = &v
+	return nil
+}
+
+func (f *optionalFlag[T]) Type() string {
+	var v T
+	return fmt.Sprintf("%T", v)
+}
+
+func parseFloat32(s string) (float32, error) {
+	v, err := strconv.ParseFloat(s, 32)
+	return float32(v), err
+}
+
+func parseInt32(s string) (int32, error) {
+	v, err := strconv.ParseInt(s, 10, 32)
+	return int32(v), err
+}
+
+func parseInt64(s string) (int64, error) {
+	return strconv.ParseInt(s, 10, 64)
+}
+
 // loadViperFlags sets flag values from viper configuration.
 func loadViperFlags(f *pflag.FlagSet) error {
 	// Note that viper.AllSettings does not work with automatic env vars
@@ -270,8 +402,76 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 		return fmt.Errorf("failed to resolve kubeconfig path: %w", err)
 	}
 
+	traceFormat, err := journal.ParseFormat(opt.TraceFormat)
+	if err != nil {
+		return err
+	}
+
+	redactor, err := newRedactor(opt)
+	if err != nil {
+		return err
+	}
+
+	telemetryConfig := telemetry.Config{
+		Endpoint:       opt.OTelEndpoint,
+		ServiceVersion: version,
+	}
+	if telemetryConfig.Enabled() {
+		shutdown, err := telemetry.Setup(ctx, telemetryConfig)
+		if err != nil {
+			return fmt.Errorf("setting up telemetry: %w", err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdown(ctx); err != nil {
+				klog.Warningf("exporting telemetry: %v", err)
+			}
+		}()
+	}
+
+	identity := kubeconfig.Identity{
+		ImpersonateUser:   opt.ImpersonateUser,
+		ImpersonateGroups: opt.ImpersonateGroups,
+	}
+	if opt.TokenFile != "" {
+		// Paths in a kubeconfig are relative to the kubeconfig, and the copy we write is in a temporary directory
+		tokenFile, err := filepath.Abs(opt.TokenFile)
+		if err != nil {
+			return fmt.Errorf("resolving --token-file: %w", err)
+		}
+		identity.TokenFile = tokenFile
+	}
+	if opt.ServiceAccount != "" {
+		if !identity.IsZero() {
+			return fmt.Errorf("--service-account cannot be combined with --as, --as-group or --token-file")
+		}
+		serviceAccountIdentity, err := kubeconfig.ServiceAccountIdentity(ctx, opt.KubeConfigPath, opt.ServiceAccount)
+		if err != nil {
+			return err
+		}
+		identity = serviceAccountIdentity
+	}
+	// userKubeconfig keeps the user's own credentials, for escalation
+	userKubeconfig := opt.KubeConfigPath
+	if !identity.IsZero() {
+		// Tools run with a copy of the kubeconfig that impersonates the identity;
+		// the kubectl tool also refuses flags that would override it (see LockIdentity).
+		dir, err := os.MkdirTemp("", "kubectl-ai-kubeconfig-*")
+		if err != nil {
+			return fmt.Errorf("creating directory for kubeconfig: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "kubeconfig")
+		if err := kubeconfig.Write(opt.KubeConfigPath, path, identity); err != nil {
+			return err
+		}
+		klog.Infof("running tools %s", identity)
+		opt.KubeConfigPath = path
+	}
+
 	if opt.MCPServer {
-		if err := startMCPServer(ctx, opt); err != nil {
+		if err := startMCPServer(ctx, opt, redactor); err != nil {
 			return fmt.Errorf("failed to start MCP server: %w", err)
 		}
 	}
@@ -288,6 +488,11 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 		return fmt.Errorf("failed to resolve query input %w", err)
 	}
 
+	attachments, err := agent.LoadAttachments(opt.Attachments)
+	if err != nil {
+		return fmt.Errorf("loading attachments: %w", err)
+	}
+
 	klog.Info("Application started", "pid", os.Getpid())
 
 	llmClient, err := gollm.NewClient(ctx, opt.ProviderID)
@@ -296,9 +501,19 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 	}
 	defer llmClient.Close()
 
+	if err := llmClient.SetGenerationConfig(&opt.GenerationConfig); err != nil {
+		var unsupported *gollm.UnsupportedGenerationSettingsError
+		if !errors.As(err, &unsupported) {
+			return fmt.Errorf("setting generation config: %w", err)
+		}
+		// The remaining settings are still applied, so this is only a warning.
+		klog.Warning(err)
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+
 	var recorder journal.Recorder
 	if opt.TracePath != "" {
-		fileRecorder, err := journal.NewFileRecorder(opt.TracePath)
+		fileRecorder, err := journal.NewFileRecorderWithFormat(opt.TracePath, traceFormat)
 		if err != nil {
 			return fmt.Errorf("creating trace recorder: %w", err)
 		}
@@ -309,6 +524,17 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 		recorder = &journal.LogRecorder{}
 		defer recorder.Close()
 	}
+	if redactor != nil {
+		recorder = redact.NewRecorder(recorder, redactor)
+	}
+
+	auditLog, err := openAuditLog(opt)
+	if err != nil {
+		return err
+	}
+	if auditLog != nil {
+		defer auditLog.Close()
+	}
 
 	doc := ui.NewDocument()
 
@@ -323,17 +549,38 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 		return err
 	}
 
+	var clusterInfo *clusterinfo.Info
+	if opt.DiscoverCluster {
+		clusterInfo, err = loadClusterInfo(ctx, opt)
+		if err != nil {
+			// The LLM can still discover the cluster itself with tools
+			klog.Warningf("unable to gather cluster information: %v", err)
+		}
+	}
+
 	conversation := &agent.Conversation{
 		Model:              opt.ModelID,
 		Kubeconfig:         opt.KubeConfigPath,
 		LLM:                llmClient,
 		MaxIterations:      opt.MaxIterations,
 		PromptTemplateFile: opt.PromptTemplateFilePath,
-		Tools:              tools.Default(),
+		InstructionFiles:   opt.InstructionFiles,
+		Tools:              agentTools(opt),
 		Recorder:           recorder,
+		Audit:              auditLog,
+		Redactor:           redactor,
 		RemoveWorkDir:      opt.RemoveWorkDir,
 		SkipPermissions:    opt.SkipPermissions,
+		ReadOnly:           opt.ReadOnly,
+		Identity:           identity.String(),
+		LockIdentity:       !identity.IsZero(),
 		EnableToolUseShim:  opt.EnableToolUseShim,
+		PlanMode:           opt.PlanMode,
+		ShowThinking:       opt.ShowThinking,
+		ClusterInfo:        clusterInfo,
+	}
+	if opt.Escalate && !identity.IsZero() {
+		conversation.EscalationKubeconfig = userKubeconfig
 	}
 
 	err = conversation.Init(ctx, doc)
@@ -348,6 +595,7 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 		ui:           u,
 		conversation: conversation,
 		LLM:          llmClient,
+		attachments:  attachments,
 	}
 
 	if opt.Quiet {
@@ -368,6 +616,8 @@ type session struct {
 	conversation    *agent.Conversation
 	availableModels []string
 	LLM             gollm.Client
+	// attachments are sent with the next query, then cleared
+	attachments []any
 }
 
 // repl is a read-eval-print loop for the chat session.
@@ -429,6 +679,25 @@ func (s *session) listModels(ctx context.Context) ([]string, error) {
 	return s.availableModels, nil
 }
 
+// toggleThinking switches between showing the reasoning of the model in full and summarizing it,
+// for the most recent reasoning and any that follows.
+func (s *session) toggleThinking() {
+	s.conversation.ShowThinking = !s.conversation.ShowThinking
+
+	blocks := s.doc.Blocks()
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if block, ok := blocks[i].(*ui.ThinkingBlock); ok {
+			block.SetCollapsed(!s.conversation.ShowThinking)
+			return
+		}
+	}
+	if s.conversation.ShowThinking {
+		s.doc.AddBlock(ui.NewAgentTextBlock().SetText("The reasoning of the model will be shown in full.\n"))
+	} else {
+		s.doc.AddBlock(ui.NewAgentTextBlock().SetText("The reasoning of the model will be summarized.\n"))
+	}
+}
+
 func (s *session) answerQuery(ctx context.Context, query string) error {
 	switch {
 	case query == "model":
@@ -441,6 +710,17 @@ func (s *session) answerQuery(ctx context.Context, query string) error {
 		infoBlock.AppendText(fmt.Sprintf("Version: `%s`\n", version))
 		s.doc.AddBlock(infoBlock)
 
+	case query == "tasks":
+		tasks := tools.BackgroundTasks()
+		if len(tasks) == 0 {
+			s.doc.AddBlock(ui.NewAgentTextBlock().SetText("No background tasks have been started.\n"))
+			break
+		}
+		s.doc.AddBlock(tools.BackgroundTasksTable(tasks...))
+
+	case query == "thinking":
+		s.toggleThinking()
+
 	case query == "models":
 		models, err := s.listModels(ctx)
 		if err != nil {
@@ -452,7 +732,16 @@ func (s *session) answerQuery(ctx context.Context, query string) error {
 		s.doc.AddBlock(infoBlock)
 
 	default:
-		return s.conversation.RunOneRound(ctx, query)
+		query, attachments, err := agent.ExtractAttachments(query)
+		if err != nil {
+			return err
+		}
+		attachments = append(s.attachments, attachments...)
+		s.attachments = nil
+		if len(attachments) != 0 {
+			s.doc.AddBlock(ui.NewAgentTextBlock().SetText(fmt.Sprintf("Attached: %s\n", agent.DescribeAttachments(attachments))))
+		}
+		return s.conversation.RunOneRound(ctx, query, attachments...)
 	}
 	return nil
 }
@@ -541,6 +830,42 @@ func resolveQueryInput(hasStdInData bool, args []string) (string, error) {
 	}
 }
 
+// agentTools returns the tools available to the agent; bash is not offered in read-only mode, as it would refuse every command.
+func agentTools(opt Options) tools.Tools {
+	all := tools.Default()
+	if opt.ReadOnly {
+		return all.Without("bash")
+	}
+	return all
+}
+
+// loadClusterInfo gathers information about the cluster for the system prompt, using the cache if it is fresh enough.
+func loadClusterInfo(ctx context.Context, opt Options) (*clusterinfo.Info, error) {
+	cacheDir, err := clusterinfo.DefaultCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	cache := &clusterinfo.Cache{Dir: cacheDir, TTL: opt.ClusterInfoTTL}
+	return cache.Load(ctx, opt.KubeConfigPath)
+}
+
+// newRedactor builds the redactor configured by opt, or returns nil if redaction is disabled.
+func newRedactor(opt Options) (*redact.Redactor, error) {
+	config := opt.Redaction
+	if !opt.Redact || config.Disabled {
+		klog.Warning("redaction of secrets is disabled")
+		return nil, nil
+	}
+	for _, pattern := range opt.RedactPatterns {
+		config.Patterns = append(config.Patterns, redact.Pattern{Regexp: pattern})
+	}
+	redactor, err := redact.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("configuring redaction: %w", err)
+	}
+	return redactor, nil
+}
+
 func resolveKubeConfigPath(opt *Options) error {
 	switch {
 	case opt.KubeConfigPath != "":
@@ -558,12 +883,12 @@ func resolveKubeConfigPath(opt *Options) error {
 	return nil
 }
 
-func startMCPServer(ctx context.Context, opt Options) error {
+func startMCPServer(ctx context.Context, opt Options, redactor *redact.Redactor) error {
 	workDir := filepath.Join(os.TempDir(), "kubectl-ai-mcp")
 	if err := os.MkdirAll(workDir, 0755); err != nil {
 		return fmt.Errorf("error creating work directory: %w", err)
 	}
-	mcpServer, err := newKubectlMCPServer(ctx, opt.KubeConfigPath, tools.Default(), workDir)
+	mcpServer, err := newKubectlMCPServer(ctx, opt.KubeConfigPath, agentTools(opt), workDir, opt.ReadOnly, redactor)
 	if err != nil {
 		return fmt.Errorf("creating mcp server: %w", err)
 	}
@@ -18,6 +18,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/kubectlcmd"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/redact"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -29,12 +31,17 @@ type kubectlMCPServer struct {
 	server        *server.MCPServer
 	tools         tools.Tools
 	workDir       string
+	readOnly      bool
+	// redactor, if set, masks secrets in tool results before they are returned to the client
+	redactor *redact.Redactor
 }
 
-func newKubectlMCPServer(ctx context.Context, kubectlConfig string, tools tools.Tools, workDir string) (*kubectlMCPServer, error) {
+func newKubectlMCPServer(ctx context.Context, kubectlConfig string, tools tools.Tools, workDir string, readOnly bool, redactor *redact.Redactor) (*kubectlMCPServer, error) {
 	s := &kubectlMCPServer{
 		kubectlConfig: kubectlConfig,
 		workDir:       workDir,
+		readOnly:      readOnly,
+		redactor:      redactor,
 		server: server.NewMCPServer(
 			"kubectl-ai",
 			"0.0.1",
@@ -57,22 +64,51 @@ func newKubectlMCPServer(ctx context.Context, kubectlConfig string, tools tools.
 	return s, nil
 }
 func (s *kubectlMCPServer) Serve(ctx context.Context) error {
+	// Port-forwards and background tasks started by tool calls keep running until they are closed
+	defer tools.ClosePortForwards()
+	defer tools.CancelBackgroundTasks()
 	return server.ServeStdio(s.server)
 }
 
+// handleToolCall runs a tool call. The MCP client is responsible for approving calls, so for calls that run a command,
+// we tell it what the command does: the result's metadata has a commandClass, which is one of the kubectlcmd classes
+// (read, streaming, write, unknown or interactive).
 func (s *kubectlMCPServer) handleToolCall(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	result, err := s.runToolCall(ctx, request)
+	if command, ok := request.Params.Arguments["command"].(string); ok && result != nil {
+		class := commandClass(command)
+		klog.FromContext(ctx).Info("Classified command", "tool", request.Params.Name, "class", class)
+		result.Meta = map[string]any{"commandClass": string(class)}
+	}
+	return result, err
+}
+
+// commandClass returns the class of the command, which is unknown if it can't be parsed,
+// as the tools then assume that it modifies resources.
+func commandClass(command string) kubectlcmd.Class {
+	line, err := kubectlcmd.Parse(command)
+	if err != nil {
+		return kubectlcmd.ClassUnknown
+	}
+	return line.Class()
+}
+
+func (s *kubectlMCPServer) runToolCall(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 
 	log := klog.FromContext(ctx)
 
 	name := request.Params.Name
-	command := request.Params.Arguments["command"].(string)
-	modifiesResource := request.Params.Arguments["modifies_resource"].(string)
-	log.Info("Received tool call", "tool", name, "command", command, "modifies_resource", modifiesResource)
+	arguments := request.Params.Arguments
+	if arguments == nil {
+		arguments = map[string]any{}
+	}
+	log.Info("Received tool call", "tool", name, "arguments", arguments)
 
 	ctx = context.WithValue(ctx, "kubeconfig", s.kubectlConfig)
 	ctx = context.WithValue(ctx, "work_dir", s.workDir)
+	ctx = context.WithValue(ctx, "read_only", s.readOnly)
 
-	tool := tools.Lookup(name)
+	tool := s.tools.Lookup(name)
 	if tool == nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -81,11 +117,22 @@ func (s *kubectlMCPServer) handleToolCall(ctx context.Context, request mcp.CallT
 					Text: fmt.Sprintf("Error: Tool %s not found", name),
 				},
 			},
+			IsError: true,
 		}, nil
 	}
-	output, err := tool.Run(ctx, map[string]any{
-		"command": command,
-	})
+	if err := tools.ValidateToolArguments(tool, arguments); err != nil {
+		log.Info("Rejecting invalid tool call", "tool", name, "err", err)
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Error: %v", err),
+				},
+			},
+			IsError: true,
+		}, nil
+	}
+	output, err := tool.Run(ctx, arguments)
 	if err != nil {
 		log.Error(err, "Error running tool call")
 		return &mcp.CallToolResult{
@@ -99,7 +146,7 @@ func (s *kubectlMCPServer) handleToolCall(ctx context.Context, request mcp.CallT
 		}, nil
 	}
 
-	result, err := tools.ToolResultToMap(output)
+	result, err := tools.RenderResultForLLM(output)
 	if err != nil {
 		log.Error(err, "Error converting tool call output to result")
 		return &mcp.CallToolResult{
@@ -113,6 +160,28 @@ func (s *kubectlMCPServer) handleToolCall(ctx context.Context, request mcp.CallT
 		}, nil
 	}
 
+	if s.redactor != nil {
+		// Typed results can hold any type, so we redact their JSON form
+		m, err := tools.ToolResultToMap(result)
+		if err != nil {
+			log.Error(err, "Error converting tool call result for redaction")
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{
+					mcp.TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Error: %v", err),
+					},
+				},
+				IsError: true,
+			}, nil
+		}
+		masked, count := s.redactor.Value(name, m)
+		result = masked.(map[string]any)
+		if count != 0 {
+			result["redacted"] = redact.Note(count)
+		}
+	}
+
 	log.Info("Tool call output", "tool", name, "result", result)
 
 	return &mcp.CallToolResult{
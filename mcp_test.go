@@ -0,0 +1,59 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestHandleToolCallReportsCommandClass(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments map[string]any
+		wantClass any
+	}{
+		{name: "read", arguments: map[string]any{"command": "kubectl get pods | grep web"}, wantClass: "read"},
+		{name: "write", arguments: map[string]any{"command": "kubectl get pods -o name | xargs kubectl delete"}, wantClass: "write"},
+		{name: "interactive", arguments: map[string]any{"command": "kubectl edit deploy/web"}, wantClass: "interactive"},
+		{name: "streaming", arguments: map[string]any{"command": "kubectl logs -f web"}, wantClass: "streaming"},
+		{name: "unparseable", arguments: map[string]any{"command": "kubectl get pods 'unterminated"}, wantClass: "unknown"},
+		{name: "no command", arguments: map[string]any{"target": "pod/web"}},
+		{name: "no arguments"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The tool doesn't exist, so the call fails before anything is run, but the client is still told the class
+			s := &kubectlMCPServer{tools: tools.Tools{}}
+			request := mcp.CallToolRequest{}
+			request.Params.Name = "missing"
+			request.Params.Arguments = test.arguments
+
+			result, err := s.handleToolCall(context.Background(), request)
+			if err != nil {
+				t.Fatalf("handleToolCall failed: %v", err)
+			}
+			if !result.IsError {
+				t.Errorf("got result %+v, want an error for a missing tool", result)
+			}
+			if got := result.Meta["commandClass"]; got != test.wantClass {
+				t.Errorf("got command class %v, want %v", got, test.wantClass)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+)
+
+// attachmentReference matches an @path reference in a query, such as "@deploy.yaml" or "@~/Desktop/error.png".
+// The @ must start a word, so that email addresses are not matched.
+var attachmentReference = regexp.MustCompile(`(^|\s)@(\S+)`)
+
+// ExtractAttachments finds @path references to files in the query, and loads the files as attachments.
+// The @ is removed from references that name an existing file; other references are left unchanged.
+func ExtractAttachments(query string) (string, []any, error) {
+	var attachments []any
+	var loadErr error
+
+	rewritten := attachmentReference.ReplaceAllStringFunc(query, func(match string) string {
+		submatches := attachmentReference.FindStringSubmatch(match)
+		prefix, ref := submatches[1], submatches[2]
+		// Allow trailing punctuation, as in "what is wrong with @deploy.yaml?"
+		trimmed := strings.TrimRight(ref, ".,;:!?)")
+
+		path := expandHome(trimmed)
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			return match
+		}
+
+		attachment, err := gollm.LoadAttachment(path)
+		if err != nil {
+			if loadErr == nil {
+				loadErr = err
+			}
+			return match
+		}
+		attachments = append(attachments, attachment)
+		return prefix + ref
+	})
+	if loadErr != nil {
+		return "", nil, loadErr
+	}
+	return rewritten, attachments, nil
+}
+
+// LoadAttachments loads the files named by paths as attachments, e.g. from the --attach flag.
+func LoadAttachments(paths []string) ([]any, error) {
+	var attachments []any
+	for _, path := range paths {
+		attachment, err := gollm.LoadAttachment(expandHome(path))
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+// DescribeAttachments returns a short description of the attachments, for showing to the user.
+func DescribeAttachments(attachments []any) string {
+	var names []string
+	for _, attachment := range attachments {
+		switch v := attachment.(type) {
+		case gollm.FileContent:
+			names = append(names, v.Name)
+		case gollm.ImageContent:
+			names = append(names, fmt.Sprintf("%s (image)", v.Name))
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
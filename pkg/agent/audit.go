@@ -0,0 +1,154 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/audit"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui"
+	"k8s.io/klog/v2"
+)
+
+// Who (other than the user) decided that a tool call could run, as recorded in the audit log.
+const (
+	approvedBySkipPermissions = "skip-permissions"
+	// approvedByReadOnly is used in read-only mode, where the tools refuse anything that may modify resources.
+	approvedByReadOnly = "read-only"
+	// approvedByNotRequired is used for calls that did not need approval, as they do not modify resources.
+	approvedByNotRequired = "not-required"
+	deniedByPlanMode      = "plan-mode"
+)
+
+// auditActor returns who is recorded as approving or denying actions in the audit log: the user running kubectl-ai.
+func (a *Conversation) auditActor() string {
+	if a.Audit == nil {
+		return "user"
+	}
+	return a.Audit.Actor()
+}
+
+// audit writes an entry to the audit log, if auditing is enabled.
+// Actions should not be taken if they cannot be audited, so callers stop when this returns an error.
+func (a *Conversation) audit(ctx context.Context, entryType string, details map[string]any) error {
+	if a.Audit == nil {
+		return nil
+	}
+	if err := a.Audit.Write(ctx, entryType, details); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	return nil
+}
+
+// auditToolCallProposed records a tool call requested by the LLM, with any secrets in its arguments masked.
+func (a *Conversation) auditToolCallProposed(ctx context.Context, call gollm.FunctionCall) error {
+	if a.Audit == nil {
+		return nil
+	}
+	arguments, count := a.Redactor.Value(call.Name, call.Arguments)
+	details := map[string]any{
+		"tool":      call.Name,
+		"arguments": arguments,
+	}
+	if count != 0 {
+		details["argumentsRedacted"] = count
+	}
+	return a.audit(ctx, audit.TypeToolCallProposed, details)
+}
+
+// auditToolCallDecision records whether a tool call was approved or denied, and by whom.
+func (a *Conversation) auditToolCallDecision(ctx context.Context, toolCall *tools.ToolCall, approved bool, by string) error {
+	if a.Audit == nil {
+		return nil
+	}
+	decision := "denied"
+	if approved {
+		decision = "approved"
+	}
+	details := map[string]any{
+		"tool":     toolCall.Name(),
+		"decision": decision,
+		"by":       by,
+	}
+	a.auditText(details, toolCall, "command", toolCall.PrettyPrint())
+	return a.audit(ctx, audit.TypeToolCallDecision, details)
+}
+
+// auditPlanDecision records the user's decision on a plan, and the plan they decided on.
+func (a *Conversation) auditPlanDecision(ctx context.Context, plan *Plan, decision string, by string) error {
+	if a.Audit == nil {
+		return nil
+	}
+	details := map[string]any{
+		"decision": decision,
+		"by":       by,
+	}
+	// The plan's commands may hold secrets, which are masked in its JSON form
+	planMap, err := toMap(plan)
+	if err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	masked, count := a.Redactor.Value("", planMap)
+	details["plan"] = masked
+	if count != 0 {
+		details["planRedacted"] = count
+	}
+	return a.audit(ctx, audit.TypePlanDecision, details)
+}
+
+// auditToolCallResult records the outcome of running a tool call. The call has already run, so a failure to
+// audit it is reported rather than stopping the round.
+func (a *Conversation) auditToolCallResult(ctx context.Context, toolCall *tools.ToolCall, identity string, output any, err error) {
+	if a.Audit == nil {
+		return
+	}
+	details := map[string]any{
+		"tool":     toolCall.Name(),
+		"identity": identity,
+	}
+	a.auditText(details, toolCall, "command", toolCall.PrettyPrint())
+	if err != nil {
+		details["error"] = err.Error()
+	} else if execResult, ok := output.(*tools.ExecResult); ok {
+		details["exitCode"] = execResult.ExitCode
+		if execResult.Error != "" {
+			details["error"] = execResult.Error
+		}
+		a.auditText(details, toolCall, "stdout", execResult.Stdout)
+		a.auditText(details, toolCall, "stderr", execResult.Stderr)
+	} else if b, err := json.Marshal(output); err == nil {
+		a.auditText(details, toolCall, "output", string(b))
+	} else {
+		a.auditText(details, toolCall, "output", fmt.Sprintf("%v", output))
+	}
+
+	if err := a.audit(ctx, audit.TypeToolCallResult, details); err != nil {
+		klog.Errorf("auditing result of %s: %v", toolCall.PrettyPrint(), err)
+		a.doc.AddBlock(ui.NewErrorBlock().SetText(fmt.Sprintf("  Unable to record the result in the audit log: %v\n", err)))
+	}
+}
+
+// auditText adds the command or output of a tool call to the details of an audit entry, with any secrets masked.
+func (a *Conversation) auditText(details map[string]any, toolCall *tools.ToolCall, key string, text string) {
+	text, count := a.Redactor.String(toolCall.Name(), text)
+	audit.Text(details, key, text)
+	if count != 0 {
+		details[key+"Redacted"] = count
+	}
+}
@@ -18,16 +18,21 @@ import (
 	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"html/template"
 	"io"
 	"os"
 	"sort"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/audit"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/clusterinfo"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/redact"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/telemetry"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui"
 	"k8s.io/klog/v2"
@@ -41,7 +46,10 @@ type Conversation struct {
 
 	// PromptTemplateFile allows specifying a custom template file
 	PromptTemplateFile string
-	Model              string
+	// InstructionFiles are extra instruction files (e.g. organization-wide instructions) added to the system prompt,
+	// after the user's instructions and before any project instructions.
+	InstructionFiles []string
+	Model            string
 
 	RemoveWorkDir bool
 
@@ -50,21 +58,61 @@ type Conversation struct {
 	Kubeconfig      string
 	SkipPermissions bool
 
+	// ReadOnly makes the tools refuse commands that may modify the cluster, so there is nothing to confirm.
+	ReadOnly bool
+
+	// Identity describes who Kubeconfig authenticates as (e.g. an impersonated user), recorded in the journal for every tool call.
+	Identity string
+	// LockIdentity is set when Identity is not the user's own: the kubectl tool then refuses commands that override
+	// it (e.g. with --as or $KUBECONFIG), and such commands in other tools need confirmation.
+	LockIdentity bool
+	// EscalationKubeconfig, if set, holds the user's own credentials. A call the user approved that the agent's
+	// identity is forbidden to make is retried with these credentials.
+	EscalationKubeconfig string
+
 	Tools tools.Tools
 
 	EnableToolUseShim bool
 
+	// PlanMode restricts the LLM to read-only commands while it investigates; it must propose any changes
+	// as a plan, which the user approves as a whole before the steps are run.
+	PlanMode bool
+
+	// ClusterInfo describes the cluster, if it was gathered before the conversation started
+	ClusterInfo *clusterinfo.Info
+
+	// ShowThinking expands the reasoning of the LLM in the UI, rather than only showing a summary
+	ShowThinking bool
+
 	// Recorder captures events for diagnostics
 	Recorder journal.Recorder
 
+	// Audit, if set, records queries, tool calls, approvals and results in a tamper-evident log
+	Audit *audit.Log
+
+	// Redactor, if set, masks secrets in tool results before they are sent to the LLM or written to the audit log
+	Redactor *redact.Redactor
+
 	// doc is the document which renders the conversation
 	doc *ui.Document
 
 	llmChat gollm.Chat
 
 	workDir string
+
+	// consecutiveToolFailures counts tool calls that failed in a row, see recordToolFailure
+	consecutiveToolFailures int
 }
 
+// maxConsecutiveToolFailures is the number of tool calls that can fail in a row
+// (invalid calls or errors running the tool) before we stop the round,
+// rather than letting the LLM keep retrying the same mistake.
+const maxConsecutiveToolFailures = 5
+
+// llmRetryBackoff is how long we wait before retrying a failed LLM request for the first time; it is a variable
+// so that tests don't have to wait.
+var llmRetryBackoff = 10 * time.Second
+
 func (s *Conversation) Init(ctx context.Context, doc *ui.Document) error {
 	log := klog.FromContext(ctx)
 
@@ -77,23 +125,68 @@ func (s *Conversation) Init(ctx context.Context, doc *ui.Document) error {
 
 	log.Info("Created temporary working directory", "workDir", workDir)
 
+	if s.PlanMode && s.EnableToolUseShim {
+		return fmt.Errorf("plan mode requires a model with native tool use, and cannot be used with the tool-use shim")
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("getting current directory: %w", err)
+	}
+	layers, err := LoadPromptLayers(cwd, s.Kubeconfig, s.InstructionFiles)
+	if err != nil {
+		return fmt.Errorf("loading instructions: %w", err)
+	}
+	for _, file := range layers.Files() {
+		log.Info("Adding instructions to system prompt", "path", file.Path, "scope", file.Scope)
+	}
+
 	systemPrompt, err := s.generatePrompt(ctx, defaultSystemPromptTemplate, PromptData{
 		Tools:             s.Tools,
 		EnableToolUseShim: s.EnableToolUseShim,
+		PlanMode:          s.PlanMode,
+		ReadOnly:          s.ReadOnly,
+		Instructions:      layers.Instructions,
+		Runbooks:          layers.Runbooks,
+		KubeContext:       layers.KubeContext,
+		ClusterInfo:       s.ClusterInfo,
 	})
 	if err != nil {
 		return fmt.Errorf("generating system prompt: %w", err)
 	}
 
+	s.Recorder.Write(ctx, &journal.Event{
+		Timestamp: time.Now(),
+		Action:    journal.ActionSystemPrompt,
+		Payload: map[string]any{
+			"prompt":       systemPrompt,
+			"kubeContext":  layers.KubeContext,
+			"instructions": layers.Files(),
+		},
+	})
+
+	if err := s.audit(ctx, audit.TypeSessionStart, map[string]any{
+		"model":           s.Model,
+		"kubeContext":     layers.KubeContext,
+		"identity":        s.Identity,
+		"readOnly":        s.ReadOnly,
+		"planMode":        s.PlanMode,
+		"skipPermissions": s.SkipPermissions,
+	}); err != nil {
+		return err
+	}
+
 	// Start a new chat session
 	s.llmChat = gollm.NewRetryChat(
 		s.LLM.StartChat(systemPrompt, s.Model),
 		gollm.RetryConfig{
 			MaxAttempts:    3,
-			InitialBackoff: 10 * time.Second,
+			InitialBackoff: llmRetryBackoff,
 			MaxBackoff:     60 * time.Second,
 			BackoffFactor:  2,
 			Jitter:         true,
+			// Pause for a while after repeated quota errors rather than burning retry attempts
+			CircuitBreaker: gollm.NewCircuitBreaker(2, 30*time.Second),
 		},
 	)
 
@@ -102,6 +195,9 @@ func (s *Conversation) Init(ctx context.Context, doc *ui.Document) error {
 		for _, tool := range s.Tools.AllTools() {
 			functionDefinitions = append(functionDefinitions, tool.FunctionDefinition())
 		}
+		if s.PlanMode {
+			functionDefinitions = append(functionDefinitions, submitPlanFunctionDefinition())
+		}
 		// Sort function definitions to help KV cache reuse
 		sort.Slice(functionDefinitions, func(i, j int) bool {
 			return functionDefinitions[i].Name < functionDefinitions[j].Name
@@ -117,6 +213,11 @@ func (s *Conversation) Init(ctx context.Context, doc *ui.Document) error {
 }
 
 func (c *Conversation) Close() error {
+	if err := c.audit(context.Background(), audit.TypeSessionEnd, nil); err != nil {
+		klog.Warningf("%v", err)
+	}
+	tools.ClosePortForwards()
+	tools.CancelBackgroundTasks()
 	if c.workDir != "" {
 		if c.RemoveWorkDir {
 			if err := os.RemoveAll(c.workDir); err != nil {
@@ -127,34 +228,98 @@ func (c *Conversation) Close() error {
 	return nil
 }
 
+// finishedTasksMessage tells the LLM which background tasks have finished since it was last told, and shows them
+// to the user. It returns "" if no tasks have finished.
+func (a *Conversation) finishedTasksMessage() string {
+	finished := tools.FinishedBackgroundTasks()
+	if len(finished) == 0 {
+		return ""
+	}
+	a.doc.AddBlock(tools.BackgroundTasksTable(finished...).SetTitle(fmt.Sprintf("finished background tasks (%d)", len(finished))))
+
+	var b strings.Builder
+	b.WriteString("These background tasks have finished since you were last told; use get_background_task to see their output if you need it:\n")
+	for _, task := range finished {
+		fmt.Fprintf(&b, "- %s (`%s`) %s after %s", task.ID, task.Command, task.Status, task.Duration)
+		switch {
+		case task.Result.Error != "":
+			fmt.Fprintf(&b, ": %s", task.Result.Error)
+		case task.Result.ExitCode != 0:
+			fmt.Fprintf(&b, " with exit code %d", task.Result.ExitCode)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
 // RunOneRound executes a chat-based agentic loop with the LLM using function calling.
-func (a *Conversation) RunOneRound(ctx context.Context, query string) error {
+// Attachments (gollm.FileContent or gollm.ImageContent) are sent along with the query.
+func (a *Conversation) RunOneRound(ctx context.Context, query string, attachments ...any) error {
+	ctx, round := telemetry.StartRound(ctx, a.Model)
+	err := a.runOneRound(ctx, round, query, attachments...)
+	round.End(err)
+	return err
+}
+
+func (a *Conversation) runOneRound(ctx context.Context, round *telemetry.RoundSpan, query string, attachments ...any) error {
 	log := klog.FromContext(ctx)
 	log.Info("Starting chat loop for query:", "query", query)
 
+	queryDetails := map[string]any{"query": query}
+	if len(attachments) != 0 {
+		queryDetails["attachments"] = DescribeAttachments(attachments)
+	}
+	if err := a.audit(ctx, audit.TypeQuery, queryDetails); err != nil {
+		return err
+	}
+
 	// currChatContent tracks chat content that needs to be sent
 	// to the LLM in each iteration of  the agentic loop below
 	var currChatContent []any
 
 	// Set the initial message to start the conversation
-	currChatContent = []any{query}
+	currChatContent = append([]any{query}, attachments...)
 
 	currentIteration := 0
 	maxIterations := a.MaxIterations
+	a.consecutiveToolFailures = 0
 
 	for currentIteration < maxIterations {
 		log.Info("Starting iteration", "iteration", currentIteration)
 
+		// Background tasks can finish while the LLM is working, not just between queries
+		if message := a.finishedTasksMessage(); message != "" {
+			if currentIteration == 0 {
+				currChatContent = append([]any{message}, currChatContent...)
+			} else {
+				currChatContent = append(currChatContent, message)
+			}
+		}
+
 		a.Recorder.Write(ctx, &journal.Event{
 			Timestamp: time.Now(),
 			Action:    "llm-chat",
 			Payload:   []any{currChatContent},
 		})
 
-		stream, err := a.llmChat.SendStreaming(ctx, currChatContent...)
-		if err != nil {
+		round.Iteration()
+		llmCtx, llmCall := telemetry.StartLLMCall(ctx, a.Model)
+		// failLLMCall records that the LLM call failed, in the journal and telemetry
+		failLLMCall := func(err error) error {
+			a.Recorder.Write(ctx, &journal.Event{
+				Timestamp: time.Now(),
+				Action:    journal.ActionLLMError,
+				Payload: map[string]any{
+					"error": err.Error(),
+				},
+			})
+			llmCall.End(err)
 			return err
 		}
+		stream, err := a.llmChat.SendStreaming(llmCtx, currChatContent...)
+		if err != nil {
+			return failLLMCall(err)
+		}
 
 		// Clear our "response" now that we sent the last response
 		currChatContent = nil
@@ -163,7 +328,7 @@ func (a *Conversation) RunOneRound(ctx context.Context, query string) error {
 			// convert the candidate response into a gollm.ChatResponse
 			stream, err = candidateToShimCandidate(stream)
 			if err != nil {
-				return err
+				return failLLMCall(err)
 			}
 		}
 
@@ -172,10 +337,13 @@ func (a *Conversation) RunOneRound(ctx context.Context, query string) error {
 		var functionCalls []gollm.FunctionCall
 
 		var agentTextBlock *ui.AgentTextBlock
+		var thinkingBlock *ui.ThinkingBlock
+		// thinking collects the reasoning in this response, for the journal
+		var thinking strings.Builder
 
 		for response, err := range stream {
 			if err != nil {
-				return fmt.Errorf("reading streaming LLM response: %w", err)
+				return failLLMCall(fmt.Errorf("reading streaming LLM response: %w", err))
 			}
 			if response == nil {
 				// end of streaming response
@@ -187,18 +355,39 @@ func (a *Conversation) RunOneRound(ctx context.Context, query string) error {
 				Action:    "llm-response",
 				Payload:   response,
 			})
+			llmCall.AddUsage(response.UsageMetadata())
 
 			if len(response.Candidates()) == 0 {
 				log.Error(nil, "No candidates in response")
-				return fmt.Errorf("no candidates in LLM response")
+				return failLLMCall(fmt.Errorf("no candidates in LLM response"))
 			}
 
 			candidate := response.Candidates()[0]
 
 			for _, part := range candidate.Parts() {
+				// Check if it's reasoning, which we keep out of the answer
+				if text, ok := part.AsThinking(); ok {
+					log.V(1).Info("thinking response", "text", text)
+					thinking.WriteString(text)
+					if thinkingBlock == nil {
+						if agentTextBlock != nil {
+							agentTextBlock.SetStreaming(false)
+							agentTextBlock = nil
+						}
+						thinkingBlock = ui.NewThinkingBlock().SetCollapsed(!a.ShowThinking)
+						thinkingBlock.SetStreaming(true)
+						a.doc.AddBlock(thinkingBlock)
+					}
+					thinkingBlock.AppendText(text)
+				}
+
 				// Check if it's a text response
 				if text, ok := part.AsText(); ok {
 					log.Info("text response", "text", text)
+					if thinkingBlock != nil {
+						thinkingBlock.SetStreaming(false)
+						thinkingBlock = nil
+					}
 					if agentTextBlock == nil {
 						agentTextBlock = ui.NewAgentTextBlock()
 						agentTextBlock.SetStreaming(true)
@@ -215,22 +404,113 @@ func (a *Conversation) RunOneRound(ctx context.Context, query string) error {
 			}
 		}
 
+		llmCall.End(nil)
+
 		if agentTextBlock != nil {
 			agentTextBlock.SetStreaming(false)
 		}
+		if thinkingBlock != nil {
+			thinkingBlock.SetStreaming(false)
+		}
+		if thinking.Len() != 0 {
+			a.Recorder.Write(ctx, &journal.Event{
+				Timestamp: time.Now(),
+				Action:    journal.ActionLLMThinking,
+				Payload: map[string]any{
+					"text": thinking.String(),
+				},
+			})
+		}
 
 		// TODO(droot): Run all function calls in parallel
 		// (may have to specify in the prompt to make these function calls independent)
 		for _, call := range functionCalls {
+			if a.PlanMode && call.Name == submitPlanFunctionName {
+				result, err := a.reviewAndRunPlan(ctx, call)
+				if err != nil {
+					if err == io.EOF {
+						return nil
+					}
+					var invalidCall *tools.InvalidToolCallError
+					if !errors.As(err, &invalidCall) {
+						return err
+					}
+					log.Info("rejecting invalid plan", "err", err)
+					a.doc.AddBlock(ui.NewErrorBlock().SetText(fmt.Sprintf("  Invalid plan: %v\n", err)))
+					if err := a.recordToolFailure(); err != nil {
+						return err
+					}
+					currChatContent = append(currChatContent, a.toolErrorResult(call, err))
+					continue
+				}
+				a.consecutiveToolFailures = 0
+				currChatContent = append(currChatContent, gollm.FunctionCallResult{
+					ID:     call.ID,
+					Name:   call.Name,
+					Result: result,
+				})
+				continue
+			}
+
 			toolCall, err := a.Tools.ParseToolInvocation(ctx, call.Name, call.Arguments)
 			if err != nil {
-				return fmt.Errorf("building tool call: %w", err)
+				var invalidCall *tools.InvalidToolCallError
+				if !errors.As(err, &invalidCall) {
+					return fmt.Errorf("building tool call: %w", err)
+				}
+				// Report the problem to the LLM so it can correct the call
+				log.Info("rejecting invalid tool call", "name", call.Name, "err", err)
+				a.doc.AddBlock(ui.NewErrorBlock().SetText(fmt.Sprintf("  Invalid tool call: %v\n", err)))
+				if err := a.recordToolFailure(); err != nil {
+					return err
+				}
+				currChatContent = append(currChatContent, a.toolErrorResult(call, err))
+				continue
+			}
+
+			if err := a.auditToolCallProposed(ctx, call); err != nil {
+				return err
+			}
+
+			modifiesResource := toolCall.ModifiesResource()
+			if a.LockIdentity && toolCall.OverridesIdentity() {
+				log.Info("tool call may override the agent's identity", "name", call.Name, "arguments", call.Arguments)
+				modifiesResource = "yes"
+			}
+			// In plan mode the investigation runs read-only: the tools themselves refuse anything that may modify
+			// the cluster, so we do not depend on the LLM's own description of the call.
+			readOnly := a.ReadOnly || a.PlanMode
+			if a.PlanMode && modifiesResource == "yes" {
+				// Changes must go through an approved plan
+				err := fmt.Errorf("in plan mode, only read-only commands can be run directly; propose changes with %s", submitPlanFunctionName)
+				log.Info("rejecting tool call in plan mode", "name", call.Name, "arguments", call.Arguments)
+				a.doc.AddBlock(ui.NewErrorBlock().SetText(fmt.Sprintf("  Not running %s: %v\n", toolCall.PrettyPrint(), err)))
+				if err := a.auditToolCallDecision(ctx, toolCall, false, deniedByPlanMode); err != nil {
+					return err
+				}
+				if err := a.recordToolFailure(); err != nil {
+					return err
+				}
+				currChatContent = append(currChatContent, a.toolErrorResult(call, err))
+				continue
 			}
 
 			s := toolCall.PrettyPrint()
 			a.doc.AddBlock(ui.NewFunctionCallRequestBlock().SetText(fmt.Sprintf("  Running: %s\n", s)))
+			// approved is set when the user explicitly approves this call
+			approved := false
+			// approvedBy records who allowed the call to run, for the audit log
+			approvedBy := approvedByNotRequired
+			if modifiesResource != "no" {
+				if readOnly {
+					approvedBy = approvedByReadOnly
+				} else if a.SkipPermissions {
+					approvedBy = approvedBySkipPermissions
+				}
+			}
 			// Ask for confirmation only if SkipPermissions is false AND the tool modifies resources.
-			if !a.SkipPermissions && call.Arguments["modifies_resource"] != "no" {
+			// In read-only mode the tools refuse anything that would need confirmation.
+			if !a.SkipPermissions && !readOnly && modifiesResource != "no" {
 				confirmationPrompt := `  Do you want to proceed ?
   1) Yes
   2) Yes, and don't ask me again
@@ -253,9 +533,16 @@ func (a *Conversation) RunOneRound(ctx context.Context, query string) error {
 				switch selectedChoice {
 				case "1":
 					// Proceed with the operation
+					approved = true
+					approvedBy = a.auditActor()
 				case "2":
+					approved = true
+					approvedBy = a.auditActor()
 					a.SkipPermissions = true
 				case "3":
+					if err := a.auditToolCallDecision(ctx, toolCall, false, a.auditActor()); err != nil {
+						return err
+					}
 					a.doc.AddBlock(ui.NewAgentTextBlock().SetText("Operation was skipped."))
 					observation := fmt.Sprintf("User didn't approve running %q.\n", call.Name)
 					currChatContent = append(currChatContent, observation)
@@ -269,20 +556,49 @@ func (a *Conversation) RunOneRound(ctx context.Context, query string) error {
 				}
 			}
 
-			ctx := journal.ContextWithRecorder(ctx, a.Recorder)
-			output, err := toolCall.InvokeTool(ctx, tools.InvokeToolOptions{
-				Kubeconfig: a.Kubeconfig,
-				WorkDir:    a.workDir,
-			})
+			if err := a.auditToolCallDecision(ctx, toolCall, true, approvedBy); err != nil {
+				return err
+			}
+
+			output, err := a.invokeTool(ctx, toolCall, approved, readOnly)
 			if err != nil {
-				return fmt.Errorf("executing action: %w", err)
+				// Report the failure to the LLM so it can decide how to proceed
+				log.Info("tool call failed", "name", call.Name, "err", err)
+				a.doc.AddBlock(ui.NewErrorBlock().SetText(fmt.Sprintf("  Error running %s: %v\n", call.Name, err)))
+				if err := a.recordToolFailure(); err != nil {
+					return err
+				}
+				currChatContent = append(currChatContent, a.toolErrorResult(call, err))
+				continue
+			}
+			a.consecutiveToolFailures = 0
+
+			if block := tools.RenderResultForUI(output); block != nil {
+				a.doc.AddBlock(block)
 			}
 
 			if a.EnableToolUseShim {
-				observation := fmt.Sprintf("Result of running %q:\n%s", call.Name, output)
-				currChatContent = append(currChatContent, observation)
+				var observation string
+				if _, typed := output.(tools.TypedResult); typed {
+					result, err := tools.RenderResultForLLM(output)
+					if err != nil {
+						return err
+					}
+					b, err := json.Marshal(result)
+					if err != nil {
+						return fmt.Errorf("converting result to json: %w", err)
+					}
+					observation = fmt.Sprintf("Result of running %q:\n%s", call.Name, b)
+				} else {
+					observation = fmt.Sprintf("Result of running %q:\n%s", call.Name, output)
+				}
+				currChatContent = append(currChatContent, a.redactText(call.Name, observation))
 			} else {
-				result, err := tools.ToolResultToMap(output)
+				result, err := tools.RenderResultForLLM(output)
+				if err != nil {
+					return err
+				}
+				result, err = a.redactResult(call.Name, result)
 				if err != nil {
 					return err
 				}
@@ -311,6 +627,63 @@ func (a *Conversation) RunOneRound(ctx context.Context, query string) error {
 	return fmt.Errorf("max iterations reached")
 }
 
+// invokeTool runs a tool call as the agent's identity; if readOnly is set, the tools refuse anything that may modify
+// the cluster. If the user approved the call and the agent is forbidden to make it, it is retried with the user's
+// own credentials when escalation is enabled.
+func (a *Conversation) invokeTool(ctx context.Context, toolCall *tools.ToolCall, approved bool, readOnly bool) (any, error) {
+	log := klog.FromContext(ctx)
+
+	ctx = journal.ContextWithRecorder(ctx, a.Recorder)
+	output, err := toolCall.InvokeTool(ctx, tools.InvokeToolOptions{
+		Kubeconfig:   a.Kubeconfig,
+		WorkDir:      a.workDir,
+		ReadOnly:     readOnly,
+		Identity:     a.Identity,
+		LockIdentity: a.LockIdentity,
+	})
+	a.auditToolCallResult(ctx, toolCall, a.Identity, output, err)
+	if err != nil || !approved || a.EscalationKubeconfig == "" || !tools.IsForbidden(output) {
+		return output, err
+	}
+
+	log.Info("retrying approved tool call with the user's own credentials", "identity", a.Identity)
+	a.doc.AddBlock(ui.NewFunctionCallRequestBlock().SetText(fmt.Sprintf("  Forbidden for %s; retrying with your own credentials\n", a.Identity)))
+	escalatedIdentity := "the user's own credentials (escalated)"
+	output, err = toolCall.InvokeTool(ctx, tools.InvokeToolOptions{
+		Kubeconfig:   a.EscalationKubeconfig,
+		WorkDir:      a.workDir,
+		ReadOnly:     readOnly,
+		Identity:     escalatedIdentity,
+		LockIdentity: a.LockIdentity,
+	})
+	a.auditToolCallResult(ctx, toolCall, escalatedIdentity, output, err)
+	return output, err
+}
+
+// recordToolFailure counts a failed tool call, returning an error once too many have failed in a row.
+func (a *Conversation) recordToolFailure() error {
+	a.consecutiveToolFailures++
+	if a.consecutiveToolFailures < maxConsecutiveToolFailures {
+		return nil
+	}
+	a.doc.AddBlock(ui.NewErrorBlock().SetText(fmt.Sprintf("Sorry, stopping after %d tool calls failed in a row.\n", a.consecutiveToolFailures)))
+	return fmt.Errorf("%d consecutive tool calls failed", a.consecutiveToolFailures)
+}
+
+// toolErrorResult builds the content that reports a failed tool call back to the LLM.
+func (a *Conversation) toolErrorResult(call gollm.FunctionCall, err error) any {
+	if a.EnableToolUseShim {
+		return fmt.Sprintf("Error running %q: %v\n", call.Name, err)
+	}
+	return gollm.FunctionCallResult{
+		ID:   call.ID,
+		Name: call.Name,
+		Result: map[string]any{
+			"error": err.Error(),
+		},
+	}
+}
+
 // toResult converts an arbitrary result to a map[string]any
 func toResult(v any) (map[string]any, error) {
 	b, err := json.Marshal(v)
@@ -336,7 +709,10 @@ func (a *Conversation) generatePrompt(_ context.Context, defaultPromptTemplate s
 		promptTemplate = string(content)
 	}
 
-	tmpl, err := template.New("promptTemplate").Parse(promptTemplate)
+	funcs := template.FuncMap{
+		"join": strings.Join,
+	}
+	tmpl, err := template.New("promptTemplate").Funcs(funcs).Parse(promptTemplate)
 	if err != nil {
 		return "", fmt.Errorf("building template for prompt: %w", err)
 	}
@@ -355,6 +731,19 @@ type PromptData struct {
 	Tools tools.Tools
 
 	EnableToolUseShim bool
+	// PlanMode indicates that changes must be proposed with submit_plan.
+	PlanMode bool
+	// ReadOnly indicates that the tools refuse commands that may modify the cluster.
+	ReadOnly bool
+
+	// Instructions are the user, organization and project instructions, from the most general to the most specific.
+	Instructions []InstructionFile
+	// Runbooks are the instructions specific to the current kube context.
+	Runbooks []InstructionFile
+	// KubeContext is the current kube context, if known.
+	KubeContext string
+	// ClusterInfo describes the cluster, if it was gathered before the conversation started.
+	ClusterInfo *clusterinfo.Info
 }
 
 func (a *PromptData) ToolsAsJSON() string {
@@ -371,6 +760,23 @@ func (a *PromptData) ToolsAsJSON() string {
 	return string(json)
 }
 
+// ResultSchemasAsJSON describes the structured results that tools may return, keyed by their "type" field.
+func (a *PromptData) ResultSchemasAsJSON() string {
+	schemas := make(map[string]any)
+	for resultType, schema := range tools.ResultSchemas() {
+		schemas[resultType] = schema.ToJSONSchema()
+	}
+	if len(schemas) == 0 {
+		return ""
+	}
+
+	json, err := json.MarshalIndent(schemas, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(json)
+}
+
 func (a *PromptData) ToolNames() string {
 	return strings.Join(a.Tools.Names(), ", ")
 }
@@ -384,8 +790,12 @@ type ReActResponse struct {
 type Action struct {
 	Name             string `json:"name"`
 	Reason           string `json:"reason"`
-	Command          string `json:"command"`
-	ModifiesResource string `json:"modifies_resource"`
+	Command          string `json:"command,omitempty"`
+	ModifiesResource string `json:"modifies_resource,omitempty"`
+	// RunInBackground starts the command as a background task
+	RunInBackground bool `json:"run_in_background,omitempty"`
+	// ID is the task or port-forward that get_background_task and close_port_forward act on
+	ID string `json:"id,omitempty"`
 }
 
 func extractJSON(s string) (string, bool) {
@@ -497,13 +907,13 @@ type ShimCandidate struct {
 }
 
 func (c *ShimCandidate) String() string {
-	return fmt.Sprintf("Thought: %s\nAnswer: %s\nAction: %s", c.candidate.Thought, c.candidate.Answer, c.candidate.Action)
+	return fmt.Sprintf("Thought: %s\nAnswer: %s\nAction: %v", c.candidate.Thought, c.candidate.Answer, c.candidate.Action)
 }
 
 func (c *ShimCandidate) Parts() []gollm.Part {
 	var parts []gollm.Part
 	if c.candidate.Thought != "" {
-		parts = append(parts, &ShimPart{text: c.candidate.Thought})
+		parts = append(parts, &ShimPart{thinking: c.candidate.Thought})
 	}
 	if c.candidate.Answer != "" {
 		parts = append(parts, &ShimPart{text: c.candidate.Answer})
@@ -515,14 +925,19 @@ func (c *ShimCandidate) Parts() []gollm.Part {
 }
 
 type ShimPart struct {
-	text   string
-	action *Action
+	text     string
+	thinking string
+	action   *Action
 }
 
 func (p *ShimPart) AsText() (string, bool) {
 	return p.text, p.text != ""
 }
 
+func (p *ShimPart) AsThinking() (string, bool) {
+	return p.thinking, p.thinking != ""
+}
+
 func (p *ShimPart) AsFunctionCalls() ([]gollm.FunctionCall, bool) {
 	if p.action != nil {
 		functionCallArgs, err := toMap(p.action)
@@ -0,0 +1,609 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/audit"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/redact"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTestConversation starts a conversation with a fake LLM playing back the turns, isolated from the user's
+// configuration and cluster. configure, if set, adjusts the conversation before it is initialized.
+func newTestConversation(t *testing.T, turns []*gollm.FakeTurn, configure func(*Conversation)) (*Conversation, *gollm.FakeClient, *ui.Document) {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("KUBECONFIG", filepath.Join(home, "no-such-kubeconfig"))
+	t.Chdir(t.TempDir())
+
+	llm := gollm.NewFakeClient(&gollm.FakeScript{Turns: turns})
+	conversation := &Conversation{
+		LLM:           llm,
+		Model:         "fake",
+		MaxIterations: 10,
+		Kubeconfig:    filepath.Join(home, "no-such-kubeconfig"),
+		RemoveWorkDir: true,
+		Tools:         tools.Default(),
+		Recorder:      &journal.LogRecorder{},
+	}
+	if configure != nil {
+		configure(conversation)
+	}
+
+	doc := ui.NewDocument()
+	if err := conversation.Init(context.Background(), doc); err != nil {
+		t.Fatalf("initializing conversation: %v", err)
+	}
+	t.Cleanup(func() { conversation.Close() })
+	return conversation, llm, doc
+}
+
+// agentText returns the text the agent showed the user.
+func agentText(doc *ui.Document) string {
+	var texts []string
+	for _, block := range doc.Blocks() {
+		if block, ok := block.(*ui.AgentTextBlock); ok {
+			texts = append(texts, block.Text())
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// errorText returns the errors the agent showed the user.
+func errorText(doc *ui.Document) string {
+	var texts []string
+	for _, block := range doc.Blocks() {
+		if block, ok := block.(*ui.ErrorBlock); ok {
+			texts = append(texts, block.Text())
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func TestRunOneRoundWithFakeLLM(t *testing.T) {
+	conversation, llm, doc := newTestConversation(t, []*gollm.FakeTurn{
+		{
+			Text: "Let me check.",
+			FunctionCalls: []gollm.FunctionCall{{
+				ID:   "call-1",
+				Name: "bash",
+				Arguments: map[string]any{
+					"command":           "echo hello-from-the-tool",
+					"modifies_resource": "no",
+				},
+			}},
+		},
+		{
+			// The result of the tool call is sent back to the LLM
+			ExpectContains: "hello-from-the-tool",
+			Chunks:         []string{"The command ", "printed a greeting."},
+		},
+	}, func(c *Conversation) {
+		c.SkipPermissions = true
+	})
+
+	if err := conversation.RunOneRound(context.Background(), "what does echo print?"); err != nil {
+		t.Fatalf("RunOneRound failed: %v", err)
+	}
+	if llm.Remaining() != 0 {
+		t.Errorf("got %d turns remaining, want all turns played", llm.Remaining())
+	}
+	if got, want := agentText(doc), "Let me check.\nThe command printed a greeting."; got != want {
+		t.Errorf("got agent text %q, want %q", got, want)
+	}
+}
+
+func TestRunOneRoundWithToolUseShim(t *testing.T) {
+	// The ReAct shim parses tool calls out of the text of the response; actions often omit optional fields
+	action := "```json\n" + `{"thought": "I should run echo", "action": {"name": "bash", "reason": "to see the output", "command": "echo hello-from-the-shim"}}` + "\n```"
+	answer := "```json\n" + `{"thought": "I have the output", "answer": "It printed hello-from-the-shim."}` + "\n```"
+
+	conversation, llm, doc := newTestConversation(t, []*gollm.FakeTurn{
+		{Text: action},
+		{Text: answer, ExpectContains: "Result of running \"bash\""},
+	}, func(c *Conversation) {
+		c.SkipPermissions = true
+		c.EnableToolUseShim = true
+	})
+
+	if err := conversation.RunOneRound(context.Background(), "what does echo print?"); err != nil {
+		t.Fatalf("RunOneRound failed: %v", err)
+	}
+	if llm.Remaining() != 0 {
+		t.Errorf("got %d turns remaining, want all turns played", llm.Remaining())
+	}
+	if got, want := agentText(doc), "It printed hello-from-the-shim."; got != want {
+		t.Errorf("got agent text %q, want %q", got, want)
+	}
+}
+
+func TestPlanModeInvestigationIsReadOnly(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "marker")
+
+	// The LLM claims that the command does not modify anything; it must not be trusted
+	conversation, llm, _ := newTestConversation(t, []*gollm.FakeTurn{
+		{
+			FunctionCalls: []gollm.FunctionCall{{
+				ID:   "call-1",
+				Name: "bash",
+				Arguments: map[string]any{
+					"command":           "touch " + marker,
+					"modifies_resource": "no",
+				},
+			}},
+		},
+		{
+			ExpectContains: "bash is disabled in read-only mode",
+			Text:           "I can only investigate.",
+		},
+	}, func(c *Conversation) {
+		c.PlanMode = true
+	})
+
+	if err := conversation.RunOneRound(context.Background(), "create the marker"); err != nil {
+		t.Fatalf("RunOneRound failed: %v", err)
+	}
+	if llm.Remaining() != 0 {
+		t.Errorf("got %d turns remaining, want all turns played", llm.Remaining())
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("got Stat(marker) error %v, want the command not to have run", err)
+	}
+}
+
+func TestAuditLogIsRedacted(t *testing.T) {
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.Open(auditPath)
+	if err != nil {
+		t.Fatalf("opening audit log: %v", err)
+	}
+	redactor, err := redact.New(redact.Config{})
+	if err != nil {
+		t.Fatalf("building redactor: %v", err)
+	}
+
+	const secret = "hunter2hunter2"
+	conversation, _, _ := newTestConversation(t, []*gollm.FakeTurn{
+		{
+			FunctionCalls: []gollm.FunctionCall{{
+				ID:   "call-1",
+				Name: "bash",
+				Arguments: map[string]any{
+					"command":           "echo DB_PASSWORD=" + secret,
+					"modifies_resource": "no",
+				},
+			}},
+		},
+		{Text: "done"},
+	}, func(c *Conversation) {
+		c.SkipPermissions = true
+		c.Audit = auditLog
+		c.Redactor = redactor
+	})
+
+	if err := conversation.RunOneRound(context.Background(), "print the password"); err != nil {
+		t.Fatalf("RunOneRound failed: %v", err)
+	}
+	if err := auditLog.Close(); err != nil {
+		t.Fatalf("closing audit log: %v", err)
+	}
+
+	b, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("reading audit log: %v", err)
+	}
+	if strings.Contains(string(b), secret) {
+		t.Errorf("audit log contains the secret:\n%s", b)
+	}
+	for _, want := range []string{audit.TypeToolCallProposed, audit.TypeToolCallDecision, audit.TypeToolCallResult, redact.Marker} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("audit log does not contain %q:\n%s", want, b)
+		}
+	}
+	if _, err := audit.VerifyFile(auditPath); err != nil {
+		t.Errorf("verifying audit log: %v", err)
+	}
+}
+
+func TestFinishedBackgroundTaskIsReportedMidRound(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "marker")
+
+	conversation, llm, _ := newTestConversation(t, []*gollm.FakeTurn{
+		{
+			// The second call waits until the background task has finished
+			FunctionCalls: []gollm.FunctionCall{
+				{
+					ID:   "call-1",
+					Name: "bash",
+					Arguments: map[string]any{
+						"command":           "touch " + marker,
+						"modifies_resource": "no",
+						"run_in_background": true,
+					},
+				},
+				{
+					ID:   "call-2",
+					Name: "bash",
+					Arguments: map[string]any{
+						"command":           "while [ ! -e " + marker + " ]; do sleep 0.1; done; sleep 0.5",
+						"modifies_resource": "no",
+					},
+				},
+			},
+		},
+		{
+			// The task finished during the round, so the LLM is told before its next step
+			ExpectContains: "These background tasks have finished",
+			Text:           "The marker was created.",
+		},
+	}, func(c *Conversation) {
+		c.SkipPermissions = true
+	})
+
+	if err := conversation.RunOneRound(context.Background(), "create the marker in the background"); err != nil {
+		t.Fatalf("RunOneRound failed: %v", err)
+	}
+	if llm.Remaining() != 0 {
+		t.Errorf("got %d turns remaining, want all turns played", llm.Remaining())
+	}
+}
+
+func TestToolUseShimRunsInBackground(t *testing.T) {
+	action := "```json\n" + `{"thought": "This takes a while", "action": {"name": "bash", "reason": "to wait", "command": "sleep 60", "run_in_background": true}}` + "\n```"
+	answer := "```json\n" + `{"thought": "It is running", "answer": "The command is running in the background."}` + "\n```"
+
+	// A foreground call would not return until the command had finished
+	conversation, llm, _ := newTestConversation(t, []*gollm.FakeTurn{
+		{Text: action},
+		{Text: answer, ExpectContains: "running in the background"},
+	}, func(c *Conversation) {
+		c.SkipPermissions = true
+		c.EnableToolUseShim = true
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := conversation.RunOneRound(ctx, "wait a minute"); err != nil {
+		t.Fatalf("RunOneRound failed: %v", err)
+	}
+	if llm.Remaining() != 0 {
+		t.Errorf("got %d turns remaining, want all turns played", llm.Remaining())
+	}
+}
+
+func TestRunOneRoundSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(tracerProvider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	conversation, _, _ := newTestConversation(t, []*gollm.FakeTurn{
+		{
+			FunctionCalls: []gollm.FunctionCall{{
+				ID:   "call-1",
+				Name: "bash",
+				Arguments: map[string]any{
+					"command":           "exit 3",
+					"modifies_resource": "no",
+				},
+			}},
+		},
+		{Text: "It exited with 3."},
+	}, func(c *Conversation) {
+		c.SkipPermissions = true
+	})
+
+	if err := conversation.RunOneRound(context.Background(), "how does it exit?"); err != nil {
+		t.Fatalf("RunOneRound failed: %v", err)
+	}
+
+	// Spans are exported as they end, so the round comes last
+	spans := exporter.GetSpans()
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	if got, want := strings.Join(names, ", "), "chat fake, tool bash, chat fake, kubectl-ai.round"; got != want {
+		t.Fatalf("got spans %q, want %q", got, want)
+	}
+	round := spans[len(spans)-1].SpanContext
+	for _, span := range spans[:len(spans)-1] {
+		if span.Parent.SpanID() != round.SpanID() {
+			t.Errorf("got span %q with parent %v, want the round %v", span.Name, span.Parent.SpanID(), round.SpanID())
+		}
+	}
+	exitCode := int64(-1)
+	for _, kv := range spans[1].Attributes {
+		if kv.Key == "kubectl_ai.tool.exit_code" {
+			exitCode = kv.Value.AsInt64()
+		}
+	}
+	if exitCode != 3 {
+		t.Errorf("got exit code %d, want 3", exitCode)
+	}
+}
+
+func TestTraceIsRedactedWithToolRules(t *testing.T) {
+	tracePath := filepath.Join(t.TempDir(), "trace.yaml")
+	fileRecorder, err := journal.NewFileRecorder(tracePath)
+	if err != nil {
+		t.Fatalf("opening trace: %v", err)
+	}
+	// The pattern only applies to bash, so the global rules alone would not mask the ticket
+	redactor, err := redact.New(redact.Config{
+		Tools: map[string]redact.ToolConfig{
+			"bash": {Patterns: []redact.Pattern{{Regexp: `ticket-[0-9]+`}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("building redactor: %v", err)
+	}
+
+	const secret = "ticket-8675309"
+	conversation, _, _ := newTestConversation(t, []*gollm.FakeTurn{
+		{
+			FunctionCalls: []gollm.FunctionCall{{
+				ID:   "call-1",
+				Name: "bash",
+				Arguments: map[string]any{
+					// The secret is only in the output of the command
+					"command":           "echo ticket-$((8675300 + 9))",
+					"modifies_resource": "no",
+				},
+			}},
+		},
+		{Text: "done"},
+	}, func(c *Conversation) {
+		c.SkipPermissions = true
+		c.Recorder = redact.NewRecorder(fileRecorder, redactor)
+		c.Redactor = redactor
+	})
+
+	if err := conversation.RunOneRound(context.Background(), "print the ticket"); err != nil {
+		t.Fatalf("RunOneRound failed: %v", err)
+	}
+	if err := fileRecorder.Close(); err != nil {
+		t.Fatalf("closing trace: %v", err)
+	}
+
+	b, err := os.ReadFile(tracePath)
+	if err != nil {
+		t.Fatalf("reading trace: %v", err)
+	}
+	if strings.Contains(string(b), secret) {
+		t.Errorf("trace contains the secret:\n%s", b)
+	}
+	for _, want := range []string{"tool-request", "tool-response", redact.Marker} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("trace does not contain %q:\n%s", want, b)
+		}
+	}
+}
+
+// confirmationAnswerer answers the confirmation prompts added to a document with scripted choices, in order.
+type confirmationAnswerer struct {
+	mutex   sync.Mutex
+	choices []string
+	// prompts counts the prompts that were answered
+	prompts  int
+	answered map[*ui.InputOptionBlock]bool
+}
+
+func (c *confirmationAnswerer) DocumentChanged(doc *ui.Document, block ui.Block) {
+	optionBlock, ok := block.(*ui.InputOptionBlock)
+	if !ok {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.answered[optionBlock] {
+		return
+	}
+	c.answered[optionBlock] = true
+	if c.prompts >= len(c.choices) {
+		// Out of answers, as if the user closed the input
+		optionBlock.Observable().Set("", io.EOF)
+		return
+	}
+	optionBlock.Observable().Set(c.choices[c.prompts], nil)
+	c.prompts++
+}
+
+// answerConfirmations answers the confirmation prompts in the document with the choices, in order.
+func answerConfirmations(t *testing.T, doc *ui.Document, choices ...string) *confirmationAnswerer {
+	answerer := &confirmationAnswerer{choices: choices, answered: make(map[*ui.InputOptionBlock]bool)}
+	subscription := doc.AddSubscription(answerer)
+	t.Cleanup(func() { subscription.Close() })
+	return answerer
+}
+
+func TestRunOneRoundConfirmation(t *testing.T) {
+	tests := []struct {
+		name        string
+		choice      string
+		wantRequest string
+		wantRun     bool
+	}{
+		{name: "approved", choice: "1", wantRequest: "created the marker", wantRun: true},
+		{name: "denied", choice: "3", wantRequest: "User didn't approve running \"bash\""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			marker := filepath.Join(t.TempDir(), "marker")
+			conversation, llm, doc := newTestConversation(t, []*gollm.FakeTurn{
+				{
+					FunctionCalls: []gollm.FunctionCall{{
+						ID:   "call-1",
+						Name: "bash",
+						Arguments: map[string]any{
+							"command":           "touch " + marker + " && echo created the marker",
+							"modifies_resource": "yes",
+						},
+					}},
+				},
+				{ExpectContains: test.wantRequest, Text: "Done."},
+			}, nil)
+			answerer := answerConfirmations(t, doc, test.choice)
+
+			if err := conversation.RunOneRound(context.Background(), "create the marker"); err != nil {
+				t.Fatalf("RunOneRound failed: %v", err)
+			}
+			if llm.Remaining() != 0 {
+				t.Errorf("got %d turns remaining, want all turns played", llm.Remaining())
+			}
+			if answerer.prompts != 1 {
+				t.Errorf("got %d confirmation prompts, want 1", answerer.prompts)
+			}
+			if _, err := os.Stat(marker); (err == nil) != test.wantRun {
+				t.Errorf("got Stat(marker) error %v, want the command run: %t", err, test.wantRun)
+			}
+			if conversation.SkipPermissions {
+				t.Errorf("got SkipPermissions set, want it only set by choice 2")
+			}
+		})
+	}
+}
+
+func TestRunOneRoundMaxIterations(t *testing.T) {
+	// The LLM keeps calling tools, never answering
+	var turns []*gollm.FakeTurn
+	for i := 0; i < 3; i++ {
+		turns = append(turns, &gollm.FakeTurn{
+			FunctionCalls: []gollm.FunctionCall{{
+				ID:        fmt.Sprintf("call-%d", i),
+				Name:      "bash",
+				Arguments: map[string]any{"command": "echo again", "modifies_resource": "no"},
+			}},
+		})
+	}
+	conversation, llm, doc := newTestConversation(t, turns, func(c *Conversation) {
+		c.MaxIterations = 2
+	})
+
+	err := conversation.RunOneRound(context.Background(), "loop forever")
+	if err == nil || !strings.Contains(err.Error(), "max iterations reached") {
+		t.Fatalf("got error %v, want max iterations reached", err)
+	}
+	if llm.Remaining() != 1 {
+		t.Errorf("got %d turns remaining, want 1 after 2 iterations", llm.Remaining())
+	}
+	if got := errorText(doc); !strings.Contains(got, "after 2 iterations") {
+		t.Errorf("got errors %q, want the user told that the iterations ran out", got)
+	}
+}
+
+func TestRunOneRoundLLMErrors(t *testing.T) {
+	previousBackoff := llmRetryBackoff
+	llmRetryBackoff = time.Millisecond
+	t.Cleanup(func() { llmRetryBackoff = previousBackoff })
+
+	unavailable := &gollm.FakeError{StatusCode: 503, Message: "unavailable"}
+	tests := []struct {
+		name      string
+		turns     []*gollm.FakeTurn
+		wantError string
+		wantText  string
+	}{
+		{
+			name:     "request retried",
+			turns:    []*gollm.FakeTurn{{Error: unavailable}, {Text: "All good."}},
+			wantText: "All good.",
+		},
+		{
+			name:     "first step of the stream retried",
+			turns:    []*gollm.FakeTurn{{StreamError: unavailable}, {Text: "All good."}},
+			wantText: "All good.",
+		},
+		{
+			name:      "request not retried",
+			turns:     []*gollm.FakeTurn{{Error: &gollm.FakeError{StatusCode: 400, Message: "bad request"}}},
+			wantError: "bad request",
+		},
+		{
+			name:      "retries exhausted",
+			turns:     []*gollm.FakeTurn{{Error: unavailable}, {Error: unavailable}, {Error: unavailable}},
+			wantError: "failed after 3 attempts",
+		},
+		{
+			// Once part of the response has been shown, the request can't be retried
+			name:      "stream fails after a chunk",
+			turns:     []*gollm.FakeTurn{{Chunks: []string{"There are "}, StreamError: unavailable}},
+			wantError: "reading streaming LLM response",
+			wantText:  "There are ",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracePath := filepath.Join(t.TempDir(), "trace.yaml")
+			recorder, err := journal.NewFileRecorder(tracePath)
+			if err != nil {
+				t.Fatalf("opening trace: %v", err)
+			}
+			conversation, llm, doc := newTestConversation(t, test.turns, func(c *Conversation) {
+				c.Recorder = recorder
+			})
+
+			err = conversation.RunOneRound(context.Background(), "how are things?")
+			if test.wantError == "" && err != nil {
+				t.Fatalf("RunOneRound failed: %v", err)
+			}
+			if test.wantError != "" && (err == nil || !strings.Contains(err.Error(), test.wantError)) {
+				t.Fatalf("got error %v, want it to contain %q", err, test.wantError)
+			}
+			if llm.Remaining() != 0 {
+				t.Errorf("got %d turns remaining, want all turns played", llm.Remaining())
+			}
+			if got := agentText(doc); got != test.wantText {
+				t.Errorf("got agent text %q, want %q", got, test.wantText)
+			}
+
+			// The failed LLM turn is an error in the timeline of the trace
+			if err := recorder.Close(); err != nil {
+				t.Fatalf("closing trace: %v", err)
+			}
+			events, err := journal.ParseEventsFromFile(tracePath)
+			if err != nil {
+				t.Fatalf("reading trace: %v", err)
+			}
+			steps := journal.Timeline(events)
+			if len(steps) != 1 || steps[0].Kind != journal.StepLLM {
+				t.Fatalf("got timeline %+v, want a single LLM turn", steps)
+			}
+			if gotError := steps[0].Error; test.wantError == "" && gotError != "" || !strings.Contains(gotError, test.wantError) {
+				t.Errorf("got step error %q, want it to contain %q", gotError, test.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// projectConfigDir is the directory holding project-level instructions and runbooks,
+// found by walking up from the current directory.
+const projectConfigDir = ".kubectl-ai"
+
+// maxInstructionFileSize limits the size of instruction files, as they are included in every request.
+const maxInstructionFileSize = 64 * 1024
+
+// InstructionFile is a file of additional instructions that is layered on top of the system prompt.
+type InstructionFile struct {
+	// Path is the path to the file.
+	Path string `json:"path"`
+	// Scope is where the instructions came from: "user", "project" or "flag" for instructions,
+	// or the kube context for runbooks.
+	Scope string `json:"scope"`
+	// Content is the text of the file.
+	Content string `json:"-"`
+}
+
+// PromptLayers are the additional instructions composed into the system prompt.
+type PromptLayers struct {
+	// Instructions apply to all clusters; they are ordered from the most general to the most specific.
+	Instructions []InstructionFile
+	// Runbooks are specific to the current kube context.
+	Runbooks []InstructionFile
+	// KubeContext is the current kube context, used to select the runbooks.
+	KubeContext string
+}
+
+// Files returns all the instruction files, in the order they appear in the prompt.
+func (l *PromptLayers) Files() []InstructionFile {
+	var files []InstructionFile
+	files = append(files, l.Instructions...)
+	files = append(files, l.Runbooks...)
+	return files
+}
+
+// LoadPromptLayers finds the instruction files and runbooks that apply to the current directory and kube context.
+//
+// Instructions are loaded, in order, from:
+//   - the user's config directory: ~/.config/kubectl-ai/instructions.md
+//   - any extra files (e.g. organization-wide instructions passed with --instructions)
+//   - .kubectl-ai/instructions.md in each directory from the root down to workDir
+//
+// Runbooks for the kube context are loaded from runbooks/<context>.md in the same directories.
+// Characters in the context name that are not safe in file names (such as the ":" and "/" in EKS ARNs) are replaced with "_".
+func LoadPromptLayers(workDir string, kubeconfig string, extraInstructionFiles []string) (*PromptLayers, error) {
+	layers := &PromptLayers{}
+
+	kubeContext, err := currentKubeContext(kubeconfig)
+	if err != nil {
+		// We can still run without runbooks; kubectl will report any problem with the kubeconfig.
+		klog.Warningf("unable to determine kube context for selecting runbooks: %v", err)
+	}
+	layers.KubeContext = kubeContext
+
+	type configDir struct {
+		path  string
+		scope string
+	}
+	var dirs []configDir
+	if userConfigDir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, configDir{path: filepath.Join(userConfigDir, "kubectl-ai"), scope: "user"})
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dir := filepath.Join(home, ".config", "kubectl-ai")
+		if len(dirs) == 0 || dirs[0].path != dir {
+			dirs = append(dirs, configDir{path: dir, scope: "user"})
+		}
+	}
+
+	var projectDirs []configDir
+	if workDir != "" {
+		for dir := filepath.Clean(workDir); ; dir = filepath.Dir(dir) {
+			projectDirs = append(projectDirs, configDir{path: filepath.Join(dir, projectConfigDir), scope: "project"})
+			if parent := filepath.Dir(dir); parent == dir {
+				break
+			}
+		}
+	}
+
+	// The user config dir comes first, then the extra files, then the project dirs from the root down
+	for _, dir := range dirs {
+		if err := layers.addInstructions(filepath.Join(dir.path, "instructions.md"), dir.scope, false); err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range extraInstructionFiles {
+		if err := layers.addInstructions(path, "flag", true); err != nil {
+			return nil, err
+		}
+	}
+	for i := len(projectDirs) - 1; i >= 0; i-- {
+		if err := layers.addInstructions(filepath.Join(projectDirs[i].path, "instructions.md"), "project", false); err != nil {
+			return nil, err
+		}
+	}
+
+	if kubeContext != "" {
+		runbookName := runbookFileName(kubeContext)
+		for _, dir := range dirs {
+			if err := layers.addRunbook(filepath.Join(dir.path, "runbooks", runbookName)); err != nil {
+				return nil, err
+			}
+		}
+		for i := len(projectDirs) - 1; i >= 0; i-- {
+			if err := layers.addRunbook(filepath.Join(projectDirs[i].path, "runbooks", runbookName)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return layers, nil
+}
+
+func (l *PromptLayers) addInstructions(path string, scope string, required bool) error {
+	content, found, err := readInstructionFile(path)
+	if err != nil {
+		return err
+	}
+	if !found {
+		if required {
+			return fmt.Errorf("instructions file %q not found", path)
+		}
+		return nil
+	}
+	l.Instructions = append(l.Instructions, InstructionFile{Path: path, Scope: scope, Content: content})
+	return nil
+}
+
+func (l *PromptLayers) addRunbook(path string) error {
+	content, found, err := readInstructionFile(path)
+	if err != nil || !found {
+		return err
+	}
+	l.Runbooks = append(l.Runbooks, InstructionFile{Path: path, Scope: l.KubeContext, Content: content})
+	return nil
+}
+
+// readInstructionFile reads an instruction file, returning found=false if it does not exist.
+func readInstructionFile(path string) (string, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("reading instructions: %w", err)
+	}
+	if info.IsDir() {
+		return "", false, nil
+	}
+	if info.Size() > maxInstructionFileSize {
+		return "", false, fmt.Errorf("instructions file %q is too large (%d bytes, the limit is %d)", path, info.Size(), maxInstructionFileSize)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("reading instructions: %w", err)
+	}
+	content := strings.TrimSpace(string(b))
+	if content == "" {
+		return "", false, nil
+	}
+	return content, true, nil
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// runbookFileName returns the name of the runbook file for a kube context.
+func runbookFileName(kubeContext string) string {
+	return unsafeFileNameChars.ReplaceAllString(kubeContext, "_") + ".md"
+}
+
+// currentKubeContext returns the current-context from the kubeconfig, which may be a list of files as in $KUBECONFIG.
+func currentKubeContext(kubeconfig string) (string, error) {
+	for _, path := range filepath.SplitList(kubeconfig) {
+		b, err := os.ReadFile(os.ExpandEnv(path))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", fmt.Errorf("reading kubeconfig: %w", err)
+		}
+		var config struct {
+			CurrentContext string `json:"current-context"`
+		}
+		if err := yaml.Unmarshal(b, &config); err != nil {
+			return "", fmt.Errorf("parsing kubeconfig %q: %w", path, err)
+		}
+		// As with kubectl, the first file that sets current-context wins
+		if config.CurrentContext != "" {
+			return config.CurrentContext, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const eksContext = "arn:aws:eks:us-east-1:123456789012:cluster/prod"
+
+// writeFile writes a file, creating its directory.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setHome points the user's home and config directories at a temporary directory, which it returns.
+func setHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	return home
+}
+
+func TestLoadPromptLayers(t *testing.T) {
+	home := setHome(t)
+	userDir := filepath.Join(home, ".config", "kubectl-ai")
+	writeFile(t, filepath.Join(userDir, "instructions.md"), "user\n")
+	writeFile(t, filepath.Join(userDir, "runbooks", "arn_aws_eks_us-east-1_123456789012_cluster_prod.md"), "user runbook")
+	// Runbooks for other contexts are not included
+	writeFile(t, filepath.Join(userDir, "runbooks", "staging.md"), "staging runbook")
+
+	org := filepath.Join(t.TempDir(), "org.md")
+	writeFile(t, org, "organization")
+
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+	app := filepath.Join(repo, "app")
+	workDir := filepath.Join(app, "src")
+	writeFile(t, filepath.Join(repo, ".kubectl-ai", "instructions.md"), "repo")
+	writeFile(t, filepath.Join(app, ".kubectl-ai", "instructions.md"), "app")
+	writeFile(t, filepath.Join(app, ".kubectl-ai", "runbooks", "arn_aws_eks_us-east-1_123456789012_cluster_prod.md"), "app runbook")
+	// Empty files are skipped
+	writeFile(t, filepath.Join(workDir, ".kubectl-ai", "instructions.md"), "\n  \n")
+
+	// The first kubeconfig that sets current-context wins, as with kubectl
+	kubeconfigDir := t.TempDir()
+	writeFile(t, filepath.Join(kubeconfigDir, "clusters"), "apiVersion: v1\nkind: Config\nclusters: []\n")
+	writeFile(t, filepath.Join(kubeconfigDir, "eks"), "apiVersion: v1\nkind: Config\ncurrent-context: "+eksContext+"\n")
+	writeFile(t, filepath.Join(kubeconfigDir, "staging"), "apiVersion: v1\nkind: Config\ncurrent-context: staging\n")
+	kubeconfig := strings.Join([]string{
+		filepath.Join(kubeconfigDir, "missing"),
+		filepath.Join(kubeconfigDir, "clusters"),
+		filepath.Join(kubeconfigDir, "eks"),
+		filepath.Join(kubeconfigDir, "staging"),
+	}, string(os.PathListSeparator))
+
+	layers, err := LoadPromptLayers(workDir, kubeconfig, []string{org})
+	if err != nil {
+		t.Fatalf("LoadPromptLayers failed: %v", err)
+	}
+	if layers.KubeContext != eksContext {
+		t.Errorf("got kube context %q, want %q", layers.KubeContext, eksContext)
+	}
+
+	type layer struct{ Scope, Content string }
+	var got []layer
+	for _, file := range layers.Files() {
+		got = append(got, layer{file.Scope, file.Content})
+	}
+	// From the most general to the most specific, then the runbooks
+	want := []layer{
+		{"user", "user"},
+		{"flag", "organization"},
+		{"project", "repo"},
+		{"project", "app"},
+		{eksContext, "user runbook"},
+		{eksContext, "app runbook"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got layers %q, want %q", got, want)
+	}
+}
+
+func TestLoadPromptLayersErrors(t *testing.T) {
+	setHome(t)
+	workDir := t.TempDir()
+
+	// Files passed with --instructions must exist
+	if _, err := LoadPromptLayers(workDir, "", []string{filepath.Join(workDir, "missing.md")}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("got error %v for a missing --instructions file, want not found", err)
+	}
+
+	large := filepath.Join(workDir, ".kubectl-ai", "instructions.md")
+	writeFile(t, large, strings.Repeat("x", maxInstructionFileSize+1))
+	if _, err := LoadPromptLayers(workDir, "", nil); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("got error %v for a large instructions file, want too large", err)
+	}
+
+	// A kubeconfig that can't be parsed only means there are no runbooks
+	if err := os.Remove(large); err != nil {
+		t.Fatal(err)
+	}
+	kubeconfig := filepath.Join(workDir, "kubeconfig")
+	writeFile(t, kubeconfig, "current-context: [")
+	layers, err := LoadPromptLayers(workDir, kubeconfig, nil)
+	if err != nil {
+		t.Fatalf("LoadPromptLayers failed: %v", err)
+	}
+	if layers.KubeContext != "" || len(layers.Files()) != 0 {
+		t.Errorf("got layers %+v, want none", layers)
+	}
+}
+
+func TestRunbookFileName(t *testing.T) {
+	tests := []struct {
+		kubeContext string
+		want        string
+	}{
+		{kubeContext: "kind-kind", want: "kind-kind.md"},
+		{kubeContext: "prod.example_1", want: "prod.example_1.md"},
+		{kubeContext: eksContext, want: "arn_aws_eks_us-east-1_123456789012_cluster_prod.md"},
+		{kubeContext: "gke_my-project_us-central1_prod", want: "gke_my-project_us-central1_prod.md"},
+		{kubeContext: "admin@prod cluster", want: "admin_prod_cluster.md"},
+		{kubeContext: "../../etc/passwd", want: ".._.._etc_passwd.md"},
+	}
+	for _, test := range tests {
+		if got := runbookFileName(test.kubeContext); got != test.want {
+			t.Errorf("runbookFileName(%q) = %q, want %q", test.kubeContext, got, test.want)
+		}
+	}
+}
@@ -25,5 +25,6 @@ type Agent interface {
 
 	// RunOneRound will send the query to the LLM, and go through cycles with the LLM,
 	// evaluating requested functions until we reach a stopping point.
-	RunOneRound(ctx context.Context, query string) error
+	// Attachments (gollm.FileContent or gollm.ImageContent) are sent along with the query.
+	RunOneRound(ctx context.Context, query string, attachments ...any) error
 }
@@ -0,0 +1,447 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// submitPlanFunctionName is the function the LLM calls in plan mode to propose the changes it wants to make.
+const submitPlanFunctionName = "submit_plan"
+
+// maxVerifiedOutputSize limits how much of a step's output we send to the LLM when checking the step.
+const maxVerifiedOutputSize = 8000
+
+// Plan is a set of changes proposed by the LLM in plan mode, to be approved by the user before they are made.
+type Plan struct {
+	// Summary describes the overall change.
+	Summary string `json:"summary"`
+	// Steps are run in order, stopping at the first step that does not have the expected effect.
+	Steps []PlanStep `json:"steps"`
+}
+
+// PlanStep is a single command in a Plan.
+type PlanStep struct {
+	// Description says what the step is for.
+	Description string `json:"description"`
+	// Tool is the tool that runs the command, e.g. "kubectl" or "bash".
+	Tool string `json:"tool"`
+	// Command is the command to run.
+	Command string `json:"command"`
+	// ExpectedEffect describes what the command should do, and is checked against its output.
+	ExpectedEffect string `json:"expected_effect"`
+}
+
+// PlanStepResult is the outcome of running a PlanStep.
+type PlanStepResult struct {
+	Step    int    `json:"step"`
+	Command string `json:"command"`
+	// Status is one of "succeeded", "failed" (the command returned an error), "deviated" (the command did not
+	// have the expected effect) or "not_run" (an earlier step failed or deviated).
+	Status      string         `json:"status"`
+	Explanation string         `json:"explanation,omitempty"`
+	Result      map[string]any `json:"result,omitempty"`
+}
+
+var planSchema = &gollm.Schema{
+	Type: gollm.TypeObject,
+	Properties: map[string]*gollm.Schema{
+		"summary": {
+			Type:        gollm.TypeString,
+			Description: "A short description of the overall change and why it is needed.",
+		},
+		"steps": {
+			Type:        gollm.TypeArray,
+			Description: "The commands to run, in order. Only include commands that change something; the investigation should already be done.",
+			Items: &gollm.Schema{
+				Type: gollm.TypeObject,
+				Properties: map[string]*gollm.Schema{
+					"description": {
+						Type:        gollm.TypeString,
+						Description: "What this step does and why.",
+					},
+					"tool": {
+						Type:        gollm.TypeString,
+						Description: "The tool that runs the command, e.g. kubectl or bash.",
+					},
+					"command": {
+						Type:        gollm.TypeString,
+						Description: "The complete command to run, as it would be passed to the tool.",
+					},
+					"expected_effect": {
+						Type:        gollm.TypeString,
+						Description: "What the command should do and print if it works, e.g. `deployment.apps/web scaled`. This is checked against the actual output before running the next step.",
+					},
+				},
+				Required: []string{"description", "tool", "command", "expected_effect"},
+			},
+		},
+	},
+	Required: []string{"summary", "steps"},
+}
+
+// submitPlanFunctionDefinition describes the submit_plan function to the LLM.
+func submitPlanFunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name: submitPlanFunctionName,
+		Description: "Proposes a plan of changes to the cluster. The user reviews the plan, and may edit or reject it; " +
+			"if they approve it, the steps are run in order, stopping at the first step that does not have the expected effect. " +
+			"The result reports the outcome of each step.",
+		Parameters: planSchema,
+	}
+}
+
+// parsePlan builds a Plan from the arguments of a submit_plan call, returning an *InvalidToolCallError if they are not valid.
+func (a *Conversation) parsePlan(arguments map[string]any) (*Plan, error) {
+	if err := planSchema.Validate(arguments); err != nil {
+		return nil, &tools.InvalidToolCallError{Name: submitPlanFunctionName, Err: err}
+	}
+	b, err := json.Marshal(arguments)
+	if err != nil {
+		return nil, fmt.Errorf("converting plan to json: %w", err)
+	}
+	plan := &Plan{}
+	if err := json.Unmarshal(b, plan); err != nil {
+		return nil, &tools.InvalidToolCallError{Name: submitPlanFunctionName, Err: err}
+	}
+	if err := a.validatePlan(plan); err != nil {
+		return nil, &tools.InvalidToolCallError{Name: submitPlanFunctionName, Err: err}
+	}
+	return plan, nil
+}
+
+// validatePlan checks that a plan (which may have been edited by the user) can be run.
+func (a *Conversation) validatePlan(plan *Plan) error {
+	if len(plan.Steps) == 0 {
+		return fmt.Errorf("the plan has no steps")
+	}
+	for i, step := range plan.Steps {
+		if strings.TrimSpace(step.Command) == "" {
+			return fmt.Errorf("step %d has no command", i+1)
+		}
+		if a.Tools.Lookup(step.Tool) == nil {
+			return fmt.Errorf("step %d uses unknown tool %q; available tools are %s", i+1, step.Tool, strings.Join(a.Tools.Names(), ", "))
+		}
+	}
+	return nil
+}
+
+// Markdown formats the plan for review by the user.
+func (p *Plan) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString("## Proposed plan\n\n")
+	if p.Summary != "" {
+		sb.WriteString(p.Summary + "\n\n")
+	}
+	for i, step := range p.Steps {
+		fmt.Fprintf(&sb, "%d. **%s**\n", i+1, step.Description)
+		fmt.Fprintf(&sb, "   - Run (%s): `` %s ``\n", step.Tool, step.Command)
+		fmt.Fprintf(&sb, "   - Expected: %s\n", step.ExpectedEffect)
+	}
+	return sb.String()
+}
+
+// reviewAndRunPlan shows the plan proposed in a submit_plan call to the user for approval, and runs it if approved.
+// It returns the result of the call, to be sent back to the LLM.
+func (a *Conversation) reviewAndRunPlan(ctx context.Context, call gollm.FunctionCall) (map[string]any, error) {
+	plan, err := a.parsePlan(call.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	edited := false
+	for {
+		a.doc.AddBlock(ui.NewAgentTextBlock().SetText(plan.Markdown()))
+		if a.SkipPermissions {
+			if err := a.auditPlanDecision(ctx, plan, "approved", approvedBySkipPermissions); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		optionsBlock := ui.NewInputOptionBlock().SetPrompt(`  Do you want to run this plan?
+  1) Yes, run all the steps
+  2) Edit the plan
+  3) No`)
+		optionsBlock.SetOptions([]string{"1", "2", "3"})
+		a.doc.AddBlock(optionsBlock)
+
+		choice, err := optionsBlock.Observable().Wait()
+		if err != nil {
+			return nil, err
+		}
+
+		switch choice {
+		case "1":
+			if err := a.auditPlanDecision(ctx, plan, "approved", a.auditActor()); err != nil {
+				return nil, err
+			}
+		case "2":
+			editedPlan, err := a.editPlan(plan)
+			if err != nil {
+				a.doc.AddBlock(ui.NewErrorBlock().SetText(fmt.Sprintf("  Unable to use the edited plan: %v\n", err)))
+			} else {
+				if err := a.auditPlanDecision(ctx, editedPlan, "edited", a.auditActor()); err != nil {
+					return nil, err
+				}
+				plan = editedPlan
+				edited = true
+			}
+			continue
+		case "3":
+			if err := a.auditPlanDecision(ctx, plan, "rejected", a.auditActor()); err != nil {
+				return nil, err
+			}
+			a.doc.AddBlock(ui.NewAgentTextBlock().SetText("Plan was rejected."))
+			a.recordPlan(ctx, plan, "rejected", nil)
+			return map[string]any{
+				"status":  "rejected",
+				"message": "The user rejected the plan. Do not make these changes; ask the user how they would like to proceed.",
+			}, nil
+		default:
+			return nil, fmt.Errorf("invalid plan review choice: %q", choice)
+		}
+		break
+	}
+
+	results := a.runPlan(ctx, plan, !a.SkipPermissions)
+
+	status := "completed"
+	for _, result := range results {
+		if result.Status != "succeeded" {
+			status = "stopped"
+			break
+		}
+	}
+	a.recordPlan(ctx, plan, status, results)
+
+	response := map[string]any{
+		"status": status,
+		"steps":  results,
+	}
+	if edited {
+		// The LLM needs to know what was actually run
+		response["message"] = "The user edited the plan before approving it."
+		response["plan"] = plan
+	}
+	return response, nil
+}
+
+// runPlan runs the steps of an approved plan in order, stopping at the first step that fails or does not have the expected effect.
+// approved is true if the user explicitly approved the plan (rather than skipping permissions).
+func (a *Conversation) runPlan(ctx context.Context, plan *Plan, approved bool) []PlanStepResult {
+	log := klog.FromContext(ctx)
+
+	var results []PlanStepResult
+	stopped := false
+	for i, step := range plan.Steps {
+		result := PlanStepResult{Step: i + 1, Command: step.Command}
+		if stopped {
+			result.Status = "not_run"
+			results = append(results, result)
+			continue
+		}
+
+		a.doc.AddBlock(ui.NewFunctionCallRequestBlock().SetText(fmt.Sprintf("  Step %d/%d: %s\n", i+1, len(plan.Steps), step.Command)))
+
+		status, explanation, output := a.runPlanStep(ctx, step, approved)
+		result.Status = status
+		result.Explanation = explanation
+		result.Result = output
+		results = append(results, result)
+
+		if status != "succeeded" {
+			log.Info("stopping plan", "step", i+1, "status", status, "explanation", explanation)
+			a.doc.AddBlock(ui.NewErrorBlock().SetText(fmt.Sprintf("  Stopped at step %d (%s): %s\n", i+1, status, explanation)))
+			stopped = true
+		}
+	}
+	if !stopped {
+		a.doc.AddBlock(ui.NewAgentTextBlock().SetText(fmt.Sprintf("All %d steps of the plan completed as expected.", len(plan.Steps))))
+	}
+	return results
+}
+
+// runPlanStep runs a single step, returning its status, an explanation if it did not succeed, and its output for the LLM.
+func (a *Conversation) runPlanStep(ctx context.Context, step PlanStep, approved bool) (string, string, map[string]any) {
+	toolCall, err := a.Tools.ParseToolInvocation(ctx, step.Tool, map[string]any{
+		"command":           step.Command,
+		"modifies_resource": "yes",
+	})
+	if err != nil {
+		return "failed", err.Error(), nil
+	}
+
+	output, err := a.invokeTool(ctx, toolCall, approved, a.ReadOnly)
+	if err != nil {
+		return "failed", err.Error(), nil
+	}
+	if block := tools.RenderResultForUI(output); block != nil {
+		a.doc.AddBlock(block)
+	}
+
+	result, err := tools.RenderResultForLLM(output)
+	if err != nil {
+		return "failed", err.Error(), nil
+	}
+	result, err = a.redactResult(toolCall.Name(), result)
+	if err != nil {
+		return "failed", err.Error(), nil
+	}
+
+	if execResult, ok := output.(*tools.ExecResult); ok {
+		if execResult.Error != "" {
+			return "failed", execResult.Error, result
+		}
+		if execResult.ExitCode != 0 {
+			return "failed", fmt.Sprintf("the command exited with code %d", execResult.ExitCode), result
+		}
+	}
+
+	asExpected, explanation := a.verifyPlanStep(ctx, step, result)
+	if !asExpected {
+		return "deviated", explanation, result
+	}
+	return "succeeded", "", result
+}
+
+// verifyPlanStep asks the LLM whether the output of a step matches its expected effect.
+// Steps that cannot be verified are treated as deviating, so that we stop rather than carry on blindly.
+func (a *Conversation) verifyPlanStep(ctx context.Context, step PlanStep, result map[string]any) (bool, string) {
+	output, err := json.Marshal(result)
+	if err != nil {
+		return false, fmt.Sprintf("unable to check the output: %v", err)
+	}
+	if len(output) > maxVerifiedOutputSize {
+		output = append(output[:maxVerifiedOutputSize], "..."...)
+	}
+
+	prompt := fmt.Sprintf(`A step of an approved change plan for a kubernetes cluster has been run.
+
+Command: %s
+Expected effect: %s
+Output:
+%s
+
+Did the command have the expected effect? Respond only with a JSON object of the form:
+{"as_expected": true or false, "explanation": "a short explanation"}`, step.Command, step.ExpectedEffect, output)
+
+	response, err := a.LLM.GenerateCompletion(ctx, &gollm.CompletionRequest{
+		Model:  a.Model,
+		Prompt: prompt,
+		Task:   gollm.TaskClassify,
+	})
+	if err != nil {
+		return false, fmt.Sprintf("unable to check the output: %v", err)
+	}
+
+	text := response.Response()
+	if cleaned, found := extractJSON(text); found {
+		text = cleaned
+	}
+	var verdict struct {
+		AsExpected  bool   `json:"as_expected"`
+		Explanation string `json:"explanation"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &verdict); err != nil {
+		return false, fmt.Sprintf("unable to check the output: unexpected response %q", response.Response())
+	}
+	return verdict.AsExpected, verdict.Explanation
+}
+
+// editPlan opens the plan in the user's editor, returning the edited plan.
+func (a *Conversation) editPlan(plan *Plan) (*Plan, error) {
+	b, err := yaml.Marshal(plan)
+	if err != nil {
+		return nil, fmt.Errorf("converting plan to yaml: %w", err)
+	}
+	path := filepath.Join(a.workDir, "plan.yaml")
+	header := "# Edit the plan, then save and exit. The steps are run in order.\n"
+	if err := os.WriteFile(path, append([]byte(header), b...), 0o600); err != nil {
+		return nil, fmt.Errorf("writing plan: %w", err)
+	}
+
+	if err := runEditor(path); err != nil {
+		return nil, err
+	}
+
+	b, err = os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading edited plan: %w", err)
+	}
+	edited := &Plan{}
+	if err := yaml.UnmarshalStrict(b, edited); err != nil {
+		return nil, fmt.Errorf("parsing edited plan: %w", err)
+	}
+	if err := a.validatePlan(edited); err != nil {
+		return nil, err
+	}
+	return edited, nil
+}
+
+// runEditor opens path in $VISUAL or $EDITOR (or vi), on the terminal even if stdin has been redirected.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	var stdin io.Reader = os.Stdin
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		stdin = tty
+	}
+
+	// The editor may include arguments, e.g. "code --wait"
+	cmd := exec.Command("sh", "-c", editor+` "$0"`, path)
+	cmd.Stdin = stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running editor %q: %w", editor, err)
+	}
+	return nil
+}
+
+// recordPlan writes the plan and its outcome to the journal.
+func (a *Conversation) recordPlan(ctx context.Context, plan *Plan, status string, results []PlanStepResult) {
+	a.Recorder.Write(ctx, &journal.Event{
+		Timestamp: time.Now(),
+		Action:    journal.ActionPlan,
+		Payload: map[string]any{
+			"plan":    plan,
+			"status":  status,
+			"results": results,
+		},
+	})
+}
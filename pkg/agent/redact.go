@@ -0,0 +1,54 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/redact"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+// redactResult masks secrets in the result of a tool call before it is sent to the LLM.
+// If any values were masked, the result says how many, so that the LLM does not mistake the marker for real data.
+func (a *Conversation) redactResult(tool string, result map[string]any) (map[string]any, error) {
+	if a.Redactor == nil {
+		return result, nil
+	}
+	// Typed results can hold any type, so we redact their JSON form
+	m, err := tools.ToolResultToMap(result)
+	if err != nil {
+		return nil, err
+	}
+	masked, count := a.Redactor.Value(tool, m)
+	if count == 0 {
+		return m, nil
+	}
+	klog.Infof("redacted %d values in the result of %s", count, tool)
+	result = masked.(map[string]any)
+	result["redacted"] = redact.Note(count)
+	return result, nil
+}
+
+// redactText masks secrets in the text of a tool result before it is sent to the LLM, noting how many were masked.
+func (a *Conversation) redactText(tool string, text string) string {
+	text, count := a.Redactor.String(tool, text)
+	if count == 0 {
+		return text
+	}
+	klog.Infof("redacted %d values in the result of %s", count, tool)
+	return fmt.Sprintf("%s\n(%s)", text, redact.Note(count))
+}
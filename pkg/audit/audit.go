@@ -0,0 +1,273 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit writes a tamper-evident log of the agent's actions: who asked what, which commands were proposed,
+// who approved or denied them, and what they did.
+//
+// The log is a file of JSON lines that is only ever appended to. Each entry includes the hash of the previous entry,
+// and its own hash, so that any change to (or removal of) an entry is detected by Verify.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+)
+
+// Types of audit entries.
+const (
+	TypeSessionStart = "session-start"
+	TypeQuery        = "query"
+	// TypeToolCallProposed is a tool call requested by the LLM.
+	TypeToolCallProposed = "tool-call-proposed"
+	// TypeToolCallDecision records whether a proposed tool call was approved or denied, and by whom.
+	TypeToolCallDecision = "tool-call-decision"
+	TypeToolCallResult   = "tool-call-result"
+	TypePlanDecision     = "plan-decision"
+	TypeSessionEnd       = "session-end"
+)
+
+// maxDetailSize limits the size of text (such as command output) in an entry; longer text is truncated,
+// and its hash is recorded so that it can still be matched against the full text.
+const maxDetailSize = 4096
+
+// Entry is a single record in the audit log.
+type Entry struct {
+	// Seq numbers the entries in the log, starting from 1.
+	Seq  int64     `json:"seq"`
+	Time time.Time `json:"time"`
+	// Session identifies the kubectl-ai run that wrote the entry.
+	Session string `json:"session"`
+	// Actor is the (operating system) user running kubectl-ai.
+	Actor   string         `json:"actor"`
+	Type    string         `json:"type"`
+	Details map[string]any `json:"details,omitempty"`
+	// PrevHash is the hash of the previous entry, or "" for the first entry.
+	PrevHash string `json:"prevHash"`
+	// Hash is the hex SHA-256 of the entry as written, without the hash itself.
+	Hash string `json:"-"`
+}
+
+// Sink receives a copy of every audit entry, e.g. to forward it to a central log.
+type Sink interface {
+	io.Closer
+	// Send delivers an entry; line is the entry exactly as written to the log, including its hash.
+	Send(ctx context.Context, entry *Entry, line []byte) error
+}
+
+// Log appends entries to an audit log file, and to any sinks.
+//
+// Entries are chained from the last entry in the file when it is opened, so only one process should write
+// to a log at a time; concurrent sessions should use separate files.
+type Log struct {
+	mutex    sync.Mutex
+	f        *os.File
+	sinks    []Sink
+	session  string
+	actor    string
+	seq      int64
+	prevHash string
+}
+
+// Open opens (or creates) the audit log at path for appending.
+func Open(path string, sinks ...Sink) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+
+	last, err := lastEntry(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	l := &Log{
+		f:       f,
+		sinks:   sinks,
+		session: uuid.NewString(),
+		actor:   currentActor(),
+	}
+	if last != nil {
+		l.seq = last.Seq
+		l.prevHash = last.Hash
+	}
+	return l, nil
+}
+
+// currentActor returns the name of the user running kubectl-ai.
+func currentActor() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// Actor returns the user recorded as the actor of entries.
+func (l *Log) Actor() string {
+	return l.actor
+}
+
+// Write appends an entry to the log, and sends it to the sinks.
+// Failures to write to the log are returned; failures of sinks are only logged, as they are best-effort copies.
+func (l *Log) Write(ctx context.Context, entryType string, details map[string]any) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	entry := &Entry{
+		Seq:      l.seq + 1,
+		Time:     time.Now().UTC(),
+		Session:  l.session,
+		Actor:    l.actor,
+		Type:     entryType,
+		Details:  details,
+		PrevHash: l.prevHash,
+	}
+	line, err := encodeEntry(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := l.f.Write(line); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	if err := l.f.Sync(); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	l.seq = entry.Seq
+	l.prevHash = entry.Hash
+
+	for _, sink := range l.sinks {
+		if err := sink.Send(ctx, entry, bytes.TrimSuffix(line, []byte("\n"))); err != nil {
+			klog.Warningf("sending audit entry %d to sink: %v", entry.Seq, err)
+		}
+	}
+	return nil
+}
+
+// Close closes the log and its sinks.
+func (l *Log) Close() error {
+	var errs []error
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := l.f.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("closing audit log: %v", errs)
+	}
+	return nil
+}
+
+// encodeEntry sets the hash of the entry, and returns the line to write for it.
+// The hash covers the JSON encoding of the entry (which includes PrevHash); the line is that JSON with the hash
+// appended as the last field, so that verification can recover the exact bytes that were hashed.
+func encodeEntry(entry *Entry) ([]byte, error) {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("encoding audit entry: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	entry.Hash = hex.EncodeToString(sum[:])
+
+	var line bytes.Buffer
+	line.Write(body[:len(body)-1])
+	fmt.Fprintf(&line, `,"hash":%q}`, entry.Hash)
+	line.WriteByte('\n')
+	return line.Bytes(), nil
+}
+
+// hashSuffix matches the hash appended to each line by encodeEntry.
+var hashSuffix = regexp.MustCompile(`,"hash":"([0-9a-f]{64})"}$`)
+
+// decodeEntry parses a line of the log, checking that the hash matches the contents.
+func decodeEntry(line []byte) (*Entry, error) {
+	match := hashSuffix.FindSubmatchIndex(line)
+	if match == nil {
+		return nil, fmt.Errorf("entry has no hash")
+	}
+	hash := string(line[match[2]:match[3]])
+	body := append(append([]byte{}, line[:match[0]]...), '}')
+
+	entry := &Entry{}
+	if err := json.Unmarshal(body, entry); err != nil {
+		return nil, fmt.Errorf("parsing entry: %w", err)
+	}
+	entry.Hash = hash
+
+	sum := sha256.Sum256(body)
+	if hex.EncodeToString(sum[:]) != hash {
+		return entry, fmt.Errorf("entry %d has been modified (hash does not match its contents)", entry.Seq)
+	}
+	return entry, nil
+}
+
+// lastEntry returns the last entry in the log, or nil if the log is empty.
+func lastEntry(f *os.File) (*Entry, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+	if info.Size() == 0 {
+		return nil, nil
+	}
+
+	// Entries are small (details are truncated), so the last one is near the end
+	const tailSize = 1 << 20
+	offset := max(info.Size()-tailSize, 0)
+	tail := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+
+	tail = bytes.TrimRight(tail, "\n")
+	line := tail[bytes.LastIndexByte(tail, '\n')+1:]
+	entry, err := decodeEntry(line)
+	if err != nil {
+		// Appending to a damaged log would hide the damage behind a valid-looking chain
+		return nil, fmt.Errorf("the last entry of the audit log is not valid (run `kubectl-ai audit verify`): %w", err)
+	}
+	return entry, nil
+}
+
+// Text sets key in details to a piece of text (such as command output), truncating it if it is long
+// and recording the hash of the full text.
+func Text(details map[string]any, key string, text string) {
+	if len(text) <= maxDetailSize {
+		details[key] = text
+		return
+	}
+	sum := sha256.Sum256([]byte(text))
+	details[key] = text[:maxDetailSize]
+	details[key+"Truncated"] = true
+	details[key+"Size"] = len(text)
+	details[key+"SHA256"] = hex.EncodeToString(sum[:])
+}
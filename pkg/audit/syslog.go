@@ -0,0 +1,47 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows && !plan9
+
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log/syslog"
+)
+
+// SyslogSink writes each entry, as JSON, to the system log.
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+var _ Sink = &SyslogSink{}
+
+// NewSyslogSink connects to the local syslog daemon, logging with the given tag.
+func NewSyslogSink(tag string) (*SyslogSink, error) {
+	writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, tag)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to syslog: %w", err)
+	}
+	return &SyslogSink{writer: writer}, nil
+}
+
+func (s *SyslogSink) Send(ctx context.Context, entry *Entry, line []byte) error {
+	return s.writer.Info(string(line))
+}
+
+func (s *SyslogSink) Close() error {
+	return s.writer.Close()
+}
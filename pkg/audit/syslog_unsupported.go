@@ -0,0 +1,40 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows || plan9
+
+package audit
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+// SyslogSink writes each entry to the system log; syslog is not available on this platform.
+type SyslogSink struct{}
+
+var _ Sink = &SyslogSink{}
+
+func NewSyslogSink(tag string) (*SyslogSink, error) {
+	return nil, fmt.Errorf("syslog is not supported on %s", runtime.GOOS)
+}
+
+func (s *SyslogSink) Send(ctx context.Context, entry *Entry, line []byte) error {
+	return fmt.Errorf("syslog is not supported on %s", runtime.GOOS)
+}
+
+func (s *SyslogSink) Close() error {
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// VerifyError reports the first entry of an audit log that fails verification.
+type VerifyError struct {
+	// Line is the (1-based) line of the log with the failing entry.
+	Line int
+	Err  error
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// VerifyResult summarizes a verified audit log.
+type VerifyResult struct {
+	// Entries is the number of entries in the log.
+	Entries int
+	// LastHash is the hash of the last entry. Keeping a copy of it elsewhere also detects
+	// entries being removed from the end of the log.
+	LastHash string
+}
+
+// VerifyFile checks the hash chain of the audit log at path.
+func VerifyFile(path string) (*VerifyResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	defer f.Close()
+	return Verify(f)
+}
+
+// Verify checks that every entry in the audit log r is unmodified, and chained to the entry before it.
+// It returns a *VerifyError for the first entry that is not.
+func Verify(r io.Reader) (*VerifyResult, error) {
+	result := &VerifyResult{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	var prev *Entry
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			return result, &VerifyError{Line: line, Err: fmt.Errorf("unexpected empty line")}
+		}
+		entry, err := decodeEntry(scanner.Bytes())
+		if err != nil {
+			return result, &VerifyError{Line: line, Err: err}
+		}
+		if prev == nil {
+			if entry.Seq != 1 || entry.PrevHash != "" {
+				return result, &VerifyError{Line: line, Err: fmt.Errorf("log does not start with the first entry (found entry %d)", entry.Seq)}
+			}
+		} else {
+			if entry.Seq != prev.Seq+1 {
+				return result, &VerifyError{Line: line, Err: fmt.Errorf("expected entry %d, found entry %d", prev.Seq+1, entry.Seq)}
+			}
+			if entry.PrevHash != prev.Hash {
+				return result, &VerifyError{Line: line, Err: fmt.Errorf("entry %d is not chained to entry %d", entry.Seq, prev.Seq)}
+			}
+		}
+		prev = entry
+		result.Entries++
+		result.LastHash = entry.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("reading audit log: %w", err)
+	}
+	return result, nil
+}
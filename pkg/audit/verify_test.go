@@ -0,0 +1,153 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestLog writes a log of entries, over two sessions, and returns its path and lines.
+func writeTestLog(t *testing.T) (string, [][]byte) {
+	t.Helper()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	for session := 0; session < 2; session++ {
+		log, err := Open(path)
+		if err != nil {
+			t.Fatalf("opening audit log: %v", err)
+		}
+		for _, entryType := range []string{TypeSessionStart, TypeQuery, TypeSessionEnd} {
+			if err := log.Write(ctx, entryType, map[string]any{"query": "list the pods"}); err != nil {
+				t.Fatalf("writing audit log: %v", err)
+			}
+		}
+		if err := log.Close(); err != nil {
+			t.Fatalf("closing audit log: %v", err)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading audit log: %v", err)
+	}
+	return path, bytes.SplitAfter(b, []byte("\n"))[:6]
+}
+
+func TestVerify(t *testing.T) {
+	path, lines := writeTestLog(t)
+
+	result, err := VerifyFile(path)
+	if err != nil {
+		t.Fatalf("VerifyFile failed: %v", err)
+	}
+	if result.Entries != 6 {
+		t.Errorf("got %d entries, want 6", result.Entries)
+	}
+	last, err := decodeEntry(bytes.TrimSuffix(lines[5], []byte("\n")))
+	if err != nil {
+		t.Fatalf("decoding last entry: %v", err)
+	}
+	if result.LastHash != last.Hash {
+		t.Errorf("got last hash %q, want %q", result.LastHash, last.Hash)
+	}
+
+	tests := []struct {
+		name string
+		// change returns the lines of a damaged copy of the log
+		change func(lines [][]byte) [][]byte
+		// wantLine is the line that should fail verification, and wantErr part of the error
+		wantLine int
+		wantErr  string
+	}{
+		{
+			name: "modified details",
+			change: func(lines [][]byte) [][]byte {
+				lines[2] = bytes.Replace(lines[2], []byte("list the pods"), []byte("list the nodes"), 1)
+				return lines
+			},
+			wantLine: 3,
+		},
+		{
+			name: "removed entry",
+			change: func(lines [][]byte) [][]byte {
+				return append(lines[:3:3], lines[4:]...)
+			},
+			wantLine: 4,
+			wantErr:  "expected entry 4, found entry 5",
+		},
+		{
+			name: "reordered entries",
+			change: func(lines [][]byte) [][]byte {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			wantLine: 2,
+			wantErr:  "expected entry 2, found entry 3",
+		},
+		{
+			name: "removed first entry",
+			change: func(lines [][]byte) [][]byte {
+				return lines[1:]
+			},
+			wantLine: 1,
+			wantErr:  "does not start with the first entry",
+		},
+		{
+			name: "empty line",
+			change: func(lines [][]byte) [][]byte {
+				return append(lines[:2:2], append([][]byte{[]byte("\n")}, lines[2:]...)...)
+			},
+			wantLine: 3,
+			wantErr:  "unexpected empty line",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			copied := make([][]byte, len(lines))
+			for i, line := range lines {
+				copied[i] = bytes.Clone(line)
+			}
+			_, err := Verify(bytes.NewReader(bytes.Join(test.change(copied), nil)))
+
+			var verifyErr *VerifyError
+			if !errors.As(err, &verifyErr) {
+				t.Fatalf("got error %v, want a *VerifyError", err)
+			}
+			if verifyErr.Line != test.wantLine || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("got error %q, want an error for line %d containing %q", err, test.wantLine, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenRefusesDamagedLog(t *testing.T) {
+	path, lines := writeTestLog(t)
+
+	// Appending to a log whose last entry was modified would chain new entries to the damage
+	lines[5] = bytes.Replace(lines[5], []byte("list the pods"), []byte("list the nodes"), 1)
+	if err := os.WriteFile(path, bytes.Join(lines, nil), 0o600); err != nil {
+		t.Fatalf("writing audit log: %v", err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Errorf("Open succeeded, want an error for the damaged last entry")
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clusterinfo gathers a summary of a kubernetes cluster (version, namespaces, API groups and so on),
+// so that the LLM does not have to spend its first iterations discovering it.
+package clusterinfo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/kubectl-utils/pkg/kube"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+// maxListedItems limits the number of namespaces and CRDs we include, to keep the system prompt a reasonable size.
+const maxListedItems = 100
+
+// collectTimeout bounds how long we spend gathering information before the first query.
+const collectTimeout = 15 * time.Second
+
+// Info is a summary of a kubernetes cluster.
+type Info struct {
+	// Context is the kubeconfig context used to reach the cluster.
+	Context string `json:"context,omitempty"`
+	// Server is the address of the API server.
+	Server string `json:"server,omitempty"`
+	// ServerVersion is the kubernetes version of the API server, e.g. "v1.32.2-gke.1182003".
+	ServerVersion string `json:"serverVersion,omitempty"`
+	// NodeCount is the number of nodes, or -1 if we could not list nodes.
+	NodeCount int `json:"nodeCount"`
+	// Namespaces are the names of the namespaces (up to maxListedItems), and NamespaceCount is the total number.
+	Namespaces     []string `json:"namespaces,omitempty"`
+	NamespaceCount int      `json:"namespaceCount"`
+	// APIGroups are the API groups served by the cluster, other than the core group.
+	APIGroups []string `json:"apiGroups,omitempty"`
+	// CRDs are the names of the installed CustomResourceDefinitions (up to maxListedItems), and CRDCount is the total number.
+	CRDs     []string `json:"crds,omitempty"`
+	CRDCount int      `json:"crdCount"`
+	// Warnings describe information we could not gather, typically because of RBAC.
+	Warnings []string `json:"warnings,omitempty"`
+	// CollectedAt is when the information was gathered; it may have come from the cache.
+	CollectedAt time.Time `json:"collectedAt"`
+}
+
+// Cache stores cluster information on disk, so that we don't gather it on every run.
+type Cache struct {
+	// Dir is where the cluster information is stored, one file per cluster.
+	Dir string
+	// TTL is how long cluster information is used before being gathered again.
+	TTL time.Duration
+}
+
+// DefaultCacheDir returns the directory for cached cluster information under the user's cache directory.
+func DefaultCacheDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("finding cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "kubectl-ai", "cluster-info"), nil
+}
+
+// Load returns information about the cluster in the current context of the kubeconfig,
+// from the cache if it is fresh enough, otherwise by querying the cluster.
+func (c *Cache) Load(ctx context.Context, kubeconfig string) (*Info, error) {
+	log := klog.FromContext(ctx)
+
+	client, err := kube.NewClient(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	kubeContext, server, err := client.CurrentContext()
+	if err != nil {
+		return nil, err
+	}
+
+	cachePath := filepath.Join(c.Dir, cacheFileName(kubeContext, server))
+	if info, err := c.read(cachePath); err != nil {
+		log.Info("ignoring cached cluster information", "path", cachePath, "error", err)
+	} else if info != nil {
+		log.Info("using cached cluster information", "path", cachePath, "collectedAt", info.CollectedAt)
+		return info, nil
+	}
+
+	info, err := Collect(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	info.Context = kubeContext
+	info.Server = server
+
+	if err := c.write(cachePath, info); err != nil {
+		// The cache is only an optimization
+		klog.Warningf("unable to cache cluster information: %v", err)
+	}
+	return info, nil
+}
+
+// read returns the cached information, or nil if there is none or it is older than the TTL.
+func (c *Cache) read(path string) (*Info, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	info := &Info{}
+	if err := json.Unmarshal(b, info); err != nil {
+		return nil, fmt.Errorf("parsing cached cluster information: %w", err)
+	}
+	if time.Since(info.CollectedAt) > c.TTL {
+		return nil, nil
+	}
+	return info, nil
+}
+
+func (c *Cache) write(path string, info *Info) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// cacheFileName returns the name of the cache file for a cluster; the server address is hashed
+// because the same context name may be used for different clusters in different kubeconfigs.
+func cacheFileName(kubeContext, server string) string {
+	hash := sha256.Sum256([]byte(server))
+	return unsafeFileNameChars.ReplaceAllString(kubeContext, "_") + "-" + hex.EncodeToString(hash[:])[:12] + ".json"
+}
+
+var (
+	namespacesGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	nodesGVR      = schema.GroupVersionResource{Version: "v1", Resource: "nodes"}
+	crdsGVR       = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+)
+
+// Collect gathers information about the cluster. Information that the user is not allowed to read is skipped
+// with a warning, but an error is returned if the cluster cannot be reached.
+func Collect(ctx context.Context, client *kube.Client) (*Info, error) {
+	ctx, cancel := context.WithTimeout(ctx, collectTimeout)
+	defer cancel()
+
+	info := &Info{CollectedAt: time.Now(), NodeCount: -1}
+
+	// We list namespaces first, as (unlike discovery) it honors the context,
+	// so we find out quickly if the cluster is unreachable.
+	names, err := listNames(ctx, client, namespacesGVR)
+	switch {
+	case err == nil:
+		info.NamespaceCount = len(names)
+		info.Namespaces = truncate(names)
+	case apierrors.IsForbidden(err):
+		info.Warnings = append(info.Warnings, "not allowed to list namespaces")
+	default:
+		return nil, fmt.Errorf("listing namespaces: %w", err)
+	}
+
+	version, err := client.DiscoveryClient.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("getting server version: %w", err)
+	}
+	info.ServerVersion = version.GitVersion
+
+	groups, err := client.DiscoveryClient.ServerGroups()
+	if err != nil {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("unable to list API groups: %v", err))
+	} else {
+		for _, group := range groups.Groups {
+			if group.Name != "" {
+				info.APIGroups = append(info.APIGroups, group.Name)
+			}
+		}
+		sort.Strings(info.APIGroups)
+	}
+
+	if count, err := countObjects(ctx, client, nodesGVR); err != nil {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("unable to list nodes: %v", err))
+	} else {
+		info.NodeCount = count
+	}
+
+	if crds, err := listNames(ctx, client, crdsGVR); err != nil {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("unable to list CRDs: %v", err))
+	} else {
+		info.CRDCount = len(crds)
+		info.CRDs = truncate(crds)
+	}
+
+	return info, nil
+}
+
+// listNames returns the sorted names of all the (cluster-scoped) objects of a resource type.
+func listNames(ctx context.Context, client *kube.Client, gvr schema.GroupVersionResource) ([]string, error) {
+	var names []string
+	opts := metav1.ListOptions{Limit: 500}
+	for {
+		list, err := client.ForGVR(gvr, "").List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			names = append(names, item.GetName())
+		}
+		opts.Continue = list.GetContinue()
+		if opts.Continue == "" {
+			break
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// countObjects returns the number of (cluster-scoped) objects of a resource type. Objects such as nodes are large,
+// so it lists a single one: the API server reports how many more there are, and we only page through all of them
+// if it does not.
+func countObjects(ctx context.Context, client *kube.Client, gvr schema.GroupVersionResource) (int, error) {
+	list, err := client.ForGVR(gvr, "").List(ctx, metav1.ListOptions{Limit: 1})
+	if err != nil {
+		return 0, err
+	}
+	if list.GetContinue() == "" {
+		return len(list.Items), nil
+	}
+	if remaining := list.GetRemainingItemCount(); remaining != nil {
+		return len(list.Items) + int(*remaining), nil
+	}
+	names, err := listNames(ctx, client, gvr)
+	if err != nil {
+		return 0, err
+	}
+	return len(names), nil
+}
+
+func truncate(names []string) []string {
+	if len(names) > maxListedItems {
+		return names[:maxListedItems]
+	}
+	return names
+}
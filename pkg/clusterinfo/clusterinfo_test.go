@@ -0,0 +1,276 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAPIServer serves discovery, namespaces and nodes, and refuses to list CRDs.
+type fakeAPIServer struct {
+	*httptest.Server
+
+	nodes int
+	// reportRemaining is whether lists of nodes report the remaining item count, as API servers do by default.
+	reportRemaining bool
+
+	mutex sync.Mutex
+	// nodeLimits are the limits of the requests to list nodes.
+	nodeLimits []string
+	// namespaceLists counts the requests to list namespaces, which every collection starts with.
+	namespaceLists int
+}
+
+func newFakeAPIServer(t *testing.T, nodes int, reportRemaining bool) *fakeAPIServer {
+	t.Helper()
+
+	s := &fakeAPIServer{nodes: nodes, reportRemaining: reportRemaining}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// list returns a list of objects of the kind with the names, paged as the API server does.
+func list(kind string, names []string, r *http.Request, reportRemaining bool) map[string]any {
+	start, _ := strconv.Atoi(r.URL.Query().Get("continue"))
+	end := len(names)
+	if limit, _ := strconv.Atoi(r.URL.Query().Get("limit")); limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	var items []any
+	for _, name := range names[start:end] {
+		items = append(items, map[string]any{"apiVersion": "v1", "kind": kind, "metadata": map[string]any{"name": name}})
+	}
+	metadata := map[string]any{"resourceVersion": "1"}
+	if end < len(names) {
+		metadata["continue"] = strconv.Itoa(end)
+		if reportRemaining {
+			metadata["remainingItemCount"] = len(names) - end
+		}
+	}
+	return map[string]any{"apiVersion": "v1", "kind": "List", "metadata": metadata, "items": items}
+}
+
+func (s *fakeAPIServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var response any
+	switch r.URL.Path {
+	case "/version":
+		response = map[string]any{"major": "1", "minor": "32", "gitVersion": "v1.32.2"}
+	case "/api":
+		response = map[string]any{"kind": "APIVersions", "versions": []string{"v1"}}
+	case "/apis":
+		response = map[string]any{"kind": "APIGroupList", "apiVersion": "v1", "groups": []any{
+			map[string]any{"name": "apps", "versions": []any{map[string]any{"groupVersion": "apps/v1", "version": "v1"}}},
+		}}
+	case "/api/v1/namespaces":
+		s.namespaceLists++
+		response = list("Namespace", []string{"kube-system", "default"}, r, true)
+	case "/api/v1/nodes":
+		s.nodeLimits = append(s.nodeLimits, r.URL.Query().Get("limit"))
+		var names []string
+		for i := range s.nodes {
+			names = append(names, fmt.Sprintf("node-%d", i))
+		}
+		response = list("Node", names, r, s.reportRemaining)
+	case "/apis/apiextensions.k8s.io/v1/customresourcedefinitions":
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]any{
+			"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "Forbidden", "code": http.StatusForbidden,
+			"message": `customresourcedefinitions.apiextensions.k8s.io is forbidden`,
+		})
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeKubeconfig writes a kubeconfig for the server, with the context name.
+func writeKubeconfig(t *testing.T, server, kubeContext string) string {
+	t.Helper()
+
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: fake
+  cluster:
+    server: %s
+contexts:
+- name: %q
+  context:
+    cluster: fake
+    user: fake
+users:
+- name: fake
+  user: {}
+current-context: %q
+`, server, kubeContext, kubeContext)
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCollectCountsNodes(t *testing.T) {
+	tests := []struct {
+		name            string
+		nodes           int
+		reportRemaining bool
+		wantLimits      []string
+	}{
+		{name: "no nodes", nodes: 0, reportRemaining: true, wantLimits: []string{"1"}},
+		{name: "one node", nodes: 1, reportRemaining: true, wantLimits: []string{"1"}},
+		// Only a single node is fetched to count them
+		{name: "remaining count", nodes: 1200, reportRemaining: true, wantLimits: []string{"1"}},
+		{name: "no remaining count", nodes: 1200, wantLimits: []string{"1", "500", "500", "500"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newFakeAPIServer(t, test.nodes, test.reportRemaining)
+			cache := &Cache{Dir: t.TempDir(), TTL: time.Minute}
+
+			info, err := cache.Load(context.Background(), writeKubeconfig(t, server.URL, "fake"))
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if info.NodeCount != test.nodes {
+				t.Errorf("got %d nodes, want %d", info.NodeCount, test.nodes)
+			}
+			if !reflect.DeepEqual(server.nodeLimits, test.wantLimits) {
+				t.Errorf("got node lists with limits %q, want %q", server.nodeLimits, test.wantLimits)
+			}
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	server := newFakeAPIServer(t, 3, true)
+	cache := &Cache{Dir: t.TempDir(), TTL: time.Minute}
+
+	info, err := cache.Load(context.Background(), writeKubeconfig(t, server.URL, "fake"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if info.Context != "fake" || info.Server != server.URL || info.ServerVersion != "v1.32.2" {
+		t.Errorf("got context %q, server %q and version %q", info.Context, info.Server, info.ServerVersion)
+	}
+	if want := []string{"default", "kube-system"}; !reflect.DeepEqual(info.Namespaces, want) || info.NamespaceCount != 2 {
+		t.Errorf("got namespaces %q (%d), want %q", info.Namespaces, info.NamespaceCount, want)
+	}
+	if want := []string{"apps"}; !reflect.DeepEqual(info.APIGroups, want) {
+		t.Errorf("got API groups %q, want %q", info.APIGroups, want)
+	}
+	// CRDs are forbidden, which is a warning rather than an error
+	if len(info.Warnings) != 1 || !strings.Contains(info.Warnings[0], "unable to list CRDs") {
+		t.Errorf("got warnings %q, want one about CRDs", info.Warnings)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	server := newFakeAPIServer(t, 3, true)
+	kubeconfig := writeKubeconfig(t, server.URL, "arn:aws:eks:us-east-1:123456789012:cluster/prod")
+	cache := &Cache{Dir: t.TempDir(), TTL: time.Minute}
+	ctx := context.Background()
+
+	first, err := cache.Load(ctx, kubeconfig)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	// The cache file is named after the context, made safe for file names
+	files, err := filepath.Glob(filepath.Join(cache.Dir, "arn_aws_eks_us-east-1_123456789012_cluster_prod-*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("got cache files %q (%v), want one for the context", files, err)
+	}
+
+	// Within the TTL, the cluster is not queried again
+	second, err := cache.Load(ctx, kubeconfig)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if server.namespaceLists != 1 {
+		t.Errorf("got %d collections, want the second Load to use the cache", server.namespaceLists)
+	}
+	if !second.CollectedAt.Equal(first.CollectedAt) {
+		t.Errorf("got collectedAt %s, want the cached %s", second.CollectedAt, first.CollectedAt)
+	}
+
+	// Once it has expired, it is collected again
+	cache.TTL = time.Nanosecond
+	if _, err := cache.Load(ctx, kubeconfig); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if server.namespaceLists != 2 {
+		t.Errorf("got %d collections, want the expired information collected again", server.namespaceLists)
+	}
+
+	// A cache file that can't be parsed is ignored
+	if err := os.WriteFile(files[0], []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cache.TTL = time.Minute
+	if _, err := cache.Load(ctx, kubeconfig); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if server.namespaceLists != 3 {
+		t.Errorf("got %d collections, want a broken cache file ignored", server.namespaceLists)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	// The same context name for two clusters, as in two kubeconfigs
+	server1 := newFakeAPIServer(t, 1, true)
+	server2 := newFakeAPIServer(t, 2, true)
+	cache := &Cache{Dir: t.TempDir(), TTL: time.Minute}
+	ctx := context.Background()
+
+	for _, server := range []*fakeAPIServer{server1, server2, server1, server2} {
+		info, err := cache.Load(ctx, writeKubeconfig(t, server.URL, "prod"))
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if info.NodeCount != server.nodes || info.Server != server.URL {
+			t.Errorf("got %d nodes from %s, want the information of %s", info.NodeCount, info.Server, server.URL)
+		}
+	}
+	if server1.namespaceLists != 1 || server2.namespaceLists != 1 {
+		t.Errorf("got %d and %d collections, want each cluster collected once", server1.namespaceLists, server2.namespaceLists)
+	}
+
+	if cacheFileName("prod", server1.URL) == cacheFileName("prod", server2.URL) {
+		t.Errorf("got the same cache file for two servers")
+	}
+	if cacheFileName("prod", server1.URL) == cacheFileName("staging", server1.URL) {
+		t.Errorf("got the same cache file for two contexts")
+	}
+}
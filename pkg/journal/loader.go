@@ -17,6 +17,7 @@ package journal
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -35,11 +36,18 @@ func ParseEventsFromFile(p string) ([]*Event, error) {
 	return ParseEvents(f)
 }
 
-// ParseEvents will read the events from the reader
+// ParseEvents will read the events from the reader, in either of the formats written by FileRecorder.
 func ParseEvents(r io.Reader) ([]*Event, error) {
+	br := bufio.NewReader(r)
+	if isJSONL(br) {
+		return parseJSONLEvents(br)
+	}
+
 	var events []*Event
 
-	scanner := bufio.NewScanner(r)
+	scanner := bufio.NewScanner(br)
+	// Events such as the system prompt can be larger than the default limit
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
 	scanner.Split(splitYAML)
 	for scanner.Scan() {
 		b := scanner.Bytes()
@@ -54,6 +62,49 @@ func ParseEvents(r io.Reader) ([]*Event, error) {
 			events = append(events, event)
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading events: %w", err)
+	}
+
+	return events, nil
+}
+
+// maxEventSize is the largest event we read from a trace.
+const maxEventSize = 64 * 1024 * 1024
+
+// isJSONL returns true if the trace starts with a JSON object, rather than YAML.
+func isJSONL(r *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		b, err := r.Peek(n)
+		if err != nil || len(b) < n {
+			return false
+		}
+		switch b[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{':
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// parseJSONLEvents reads events written one per line as JSON.
+func parseJSONLEvents(r io.Reader) ([]*Event, error) {
+	var events []*Event
+
+	decoder := json.NewDecoder(r)
+	for {
+		event := &Event{}
+		if err := decoder.Decode(event); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("parsing json: %w", err)
+		}
+		events = append(events, event)
+	}
 
 	return events, nil
 }
@@ -0,0 +1,100 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsJSONL(t *testing.T) {
+	tests := []struct {
+		trace string
+		want  bool
+	}{
+		{trace: `{"timestamp":"2025-06-01T10:00:00Z","action":"llm-chat"}`, want: true},
+		{trace: "\n\n  \r\n\t{\"action\":\"llm-chat\"}", want: true},
+		{trace: "timestamp: \"2025-06-01T10:00:00Z\"\naction: llm-chat\n"},
+		{trace: "---\naction: llm-chat\n"},
+		{trace: "\n  \n"},
+		{trace: ""},
+	}
+	for _, test := range tests {
+		if got := isJSONL(bufio.NewReader(strings.NewReader(test.trace))); got != test.want {
+			t.Errorf("isJSONL(%q) = %t, want %t", test.trace, got, test.want)
+		}
+	}
+}
+
+func TestParseEvents(t *testing.T) {
+	yamlTrace := `timestamp: "2025-06-01T10:00:00Z"
+action: llm-chat
+payload:
+- - why is web crashing?
+
+
+---
+
+timestamp: "2025-06-01T10:00:02Z"
+action: llm-error
+payload:
+  error: unavailable
+
+
+---
+
+`
+	jsonlTrace := `{"timestamp":"2025-06-01T10:00:00Z","action":"llm-chat","payload":[["why is web crashing?"]]}
+{"timestamp":"2025-06-01T10:00:02Z","action":"llm-error","payload":{"error":"unavailable"}}
+`
+	want := []*Step{
+		{Kind: StepLLM, Start: at(0), Duration: 2 * time.Second, Description: `sent "why is web crashing?"`, Error: "unavailable"},
+	}
+
+	for name, trace := range map[string]string{"yaml": yamlTrace, "jsonl": jsonlTrace} {
+		t.Run(name, func(t *testing.T) {
+			events, err := ParseEvents(strings.NewReader(trace))
+			if err != nil {
+				t.Fatalf("ParseEvents failed: %v", err)
+			}
+			got := Timeline(events)
+			if toJSON(got) != toJSON(want) {
+				t.Errorf("got timeline\n%s\nwant\n%s", toJSON(got), toJSON(want))
+			}
+		})
+	}
+
+	if _, err := ParseEvents(strings.NewReader("{\"action\": \n")); err == nil {
+		t.Errorf("got no error for a truncated JSONL trace")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"yaml", "jsonl"} {
+		if got, err := ParseFormat(s); err != nil || string(got) != s {
+			t.Errorf("ParseFormat(%q) = (%q, %v), want (%q, nil)", s, got, err, s)
+		}
+	}
+	for _, s := range []string{"", "json", "YAML"} {
+		if _, err := ParseFormat(s); err == nil {
+			t.Errorf("ParseFormat(%q) succeeded, want an error", s)
+		}
+	}
+	if _, err := NewFileRecorderWithFormat("trace", "json"); err == nil {
+		t.Errorf("NewFileRecorderWithFormat succeeded with an unknown format, want an error")
+	}
+}
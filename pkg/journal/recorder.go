@@ -17,6 +17,7 @@ package journal
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -33,19 +34,48 @@ type Recorder interface {
 	Write(ctx context.Context, event *Event) error
 }
 
+// Format is the format of a trace file written by FileRecorder. ParseEvents reads either format.
+type Format string
+
+const (
+	// FormatYAML writes each event as a YAML document, separated by "---"; it is the easiest to read.
+	FormatYAML Format = "yaml"
+	// FormatJSONL writes each event as a line of JSON; it is faster to parse, and easier to process with other tools.
+	FormatJSONL Format = "jsonl"
+)
+
+// ParseFormat parses the name of a trace format.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case FormatYAML, FormatJSONL:
+		return Format(s), nil
+	}
+	return "", fmt.Errorf("unknown trace format %q (supported formats are %q and %q)", s, FormatYAML, FormatJSONL)
+}
+
 // FileRecorder writes a structured log of the agent's actions and observations to a file.
 type FileRecorder struct {
-	f *os.File
+	f      *os.File
+	format Format
 }
 
-// NewFileRecorder creates a new FileRecorder that writes to the given file.
+// NewFileRecorder creates a new FileRecorder that writes YAML to the given file.
 func NewFileRecorder(path string) (*FileRecorder, error) {
+	return NewFileRecorderWithFormat(path, FormatYAML)
+}
+
+// NewFileRecorderWithFormat creates a new FileRecorder that writes to the given file in the given format.
+func NewFileRecorderWithFormat(path string, format Format) (*FileRecorder, error) {
+	if _, err := ParseFormat(string(format)); err != nil {
+		return nil, err
+	}
 	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return nil, fmt.Errorf("opening file: %w", err)
 	}
 	return &FileRecorder{
-		f: file,
+		f:      file,
+		format: format,
 	}, nil
 }
 
@@ -59,14 +89,23 @@ func (r *FileRecorder) Write(ctx context.Context, event *Event) error {
 		event.Timestamp = time.Now()
 	}
 
-	yamlBytes, err := yaml.Marshal(event)
-	if err != nil {
-		return fmt.Errorf("marshalling event: %w", err)
-	}
 	var b bytes.Buffer
-	b.Write(yamlBytes)
-	b.Write([]byte("\n\n---\n\n"))
-	_, err = r.f.Write(b.Bytes())
+	if r.format == FormatJSONL {
+		jsonBytes, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("marshalling event: %w", err)
+		}
+		b.Write(jsonBytes)
+		b.WriteByte('\n')
+	} else {
+		yamlBytes, err := yaml.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("marshalling event: %w", err)
+		}
+		b.Write(yamlBytes)
+		b.Write([]byte("\n\n---\n\n"))
+	}
+	_, err := r.f.Write(b.Bytes())
 	return err
 }
 
@@ -79,6 +118,18 @@ type Event struct {
 // ActionUIRender is for an event that indicates we wrote output to the UI
 const ActionUIRender = "ui.render"
 
+// ActionLLMThinking is for an event that records the reasoning ("thinking") returned by the LLM
+const ActionLLMThinking = "llm-thinking"
+
+// ActionLLMError is for an event that records an LLM request that failed, or a response that failed while streaming
+const ActionLLMError = "llm-error"
+
+// ActionSystemPrompt is for an event that records the composed system prompt, and the instruction files it was built from
+const ActionSystemPrompt = "system-prompt"
+
+// ActionPlan is for an event that records a plan proposed in plan mode, whether it was approved, and the outcome of each step
+const ActionPlan = "plan"
+
 // GetString is a helper to get a string value from the Payload
 func (e *Event) GetString(key string) (string, bool) {
 	if e.Payload == nil {
@@ -0,0 +1,399 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of timeline steps.
+const (
+	StepLLM  = "llm"
+	StepTool = "tool"
+	StepPlan = "plan"
+)
+
+// Tokens counts the tokens used by an LLM turn. Counts are zero if the provider did not report them.
+type Tokens struct {
+	Input  int64 `json:"input,omitempty"`
+	Output int64 `json:"output,omitempty"`
+	Total  int64 `json:"total,omitempty"`
+}
+
+func (t *Tokens) add(other Tokens) {
+	t.Input += other.Input
+	t.Output += other.Output
+	t.Total += other.Total
+}
+
+// Max returns the largest of each count in t and other. Streaming providers report counts that grow with
+// each chunk of a response, or only report them in the last chunk, so this gives the counts for the whole response.
+func (t Tokens) Max(other Tokens) Tokens {
+	return Tokens{
+		Input:  max(t.Input, other.Input),
+		Output: max(t.Output, other.Output),
+		Total:  max(t.Total, other.Total),
+	}
+}
+
+// Step is an entry in the timeline of a trace: an LLM turn, a tool call, or a plan.
+type Step struct {
+	Kind     string        `json:"kind"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	// Name is the tool that was called, for tool calls.
+	Name string `json:"name,omitempty"`
+	// Description summarizes the step, e.g. the command that was run.
+	Description string `json:"description"`
+	Error       string `json:"error,omitempty"`
+	// Tokens is set for LLM turns.
+	Tokens *Tokens `json:"tokens,omitempty"`
+}
+
+// ToolSummary aggregates the calls to a single tool.
+type ToolSummary struct {
+	Name     string        `json:"name"`
+	Calls    int           `json:"calls"`
+	Errors   int           `json:"errors"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Summary aggregates a timeline.
+type Summary struct {
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+
+	LLMTurns    int           `json:"llmTurns"`
+	LLMDuration time.Duration `json:"llmDuration"`
+	Tokens      Tokens        `json:"tokens"`
+
+	ToolCalls    int            `json:"toolCalls"`
+	ToolDuration time.Duration  `json:"toolDuration"`
+	Tools        []*ToolSummary `json:"tools,omitempty"`
+
+	Plans  int `json:"plans,omitempty"`
+	Errors int `json:"errors"`
+}
+
+// Timeline returns the LLM turns, tool calls and plans in the events, in the order they started.
+func Timeline(events []*Event) []*Step {
+	var steps []*Step
+
+	var turn *Step
+	// turnTokens are the largest counts seen in the responses of the current turn
+	var turnTokens Tokens
+	endTurn := func() {
+		if turn == nil {
+			return
+		}
+		if turnTokens != (Tokens{}) {
+			tokens := turnTokens
+			if tokens.Total == 0 {
+				tokens.Total = tokens.Input + tokens.Output
+			}
+			turn.Tokens = &tokens
+		}
+		turn = nil
+		turnTokens = Tokens{}
+	}
+
+	toolCalls := make(map[string]*Step)
+	for _, event := range events {
+		switch event.Action {
+		case "llm-chat":
+			endTurn()
+			turn = &Step{
+				Kind:        StepLLM,
+				Start:       event.Timestamp,
+				Description: describeChat(event.Payload),
+			}
+			steps = append(steps, turn)
+
+		case "llm-response":
+			if turn == nil {
+				continue
+			}
+			turn.Duration = event.Timestamp.Sub(turn.Start)
+			turnTokens = turnTokens.Max(tokenUsage(event.Payload))
+
+		case ActionLLMError:
+			if turn == nil {
+				continue
+			}
+			turn.Duration = event.Timestamp.Sub(turn.Start)
+			turn.Error, _ = event.GetString("error")
+			if turn.Error == "" {
+				turn.Error = "the LLM request failed"
+			}
+
+		case "tool-request":
+			endTurn()
+			payload, _ := event.Payload.(map[string]any)
+			name, _ := payload["name"].(string)
+			step := &Step{
+				Kind:        StepTool,
+				Start:       event.Timestamp,
+				Name:        name,
+				Description: describeToolCall(payload),
+			}
+			if identity, _ := payload["identity"].(string); identity != "" {
+				step.Description += fmt.Sprintf(" (as %s)", identity)
+			}
+			if id, _ := payload["id"].(string); id != "" {
+				toolCalls[id] = step
+			}
+			steps = append(steps, step)
+
+		case "tool-response":
+			payload, _ := event.Payload.(map[string]any)
+			id, _ := payload["id"].(string)
+			step := toolCalls[id]
+			if step == nil {
+				continue
+			}
+			delete(toolCalls, id)
+			step.Duration = event.Timestamp.Sub(step.Start)
+			step.Error = toolError(payload)
+
+		case ActionPlan:
+			endTurn()
+			payload, _ := event.Payload.(map[string]any)
+			status, _ := payload["status"].(string)
+			step := &Step{
+				Kind:        StepPlan,
+				Start:       event.Timestamp,
+				Description: "plan " + status,
+			}
+			if plan, ok := payload["plan"].(map[string]any); ok {
+				if summary, _ := plan["summary"].(string); summary != "" {
+					step.Description += ": " + summary
+				}
+			}
+			if status == "stopped" {
+				step.Error = "the plan stopped before all its steps were run"
+			}
+			steps = append(steps, step)
+		}
+	}
+	endTurn()
+
+	// Tool calls with no response never finished, e.g. because kubectl-ai was interrupted
+	for _, step := range toolCalls {
+		step.Error = "no response was recorded"
+	}
+
+	return steps
+}
+
+// Summarize aggregates the steps of a timeline.
+func Summarize(steps []*Step) *Summary {
+	summary := &Summary{}
+	tools := make(map[string]*ToolSummary)
+	var end time.Time
+	for _, step := range steps {
+		if summary.Start.IsZero() || step.Start.Before(summary.Start) {
+			summary.Start = step.Start
+		}
+		if stepEnd := step.Start.Add(step.Duration); stepEnd.After(end) {
+			end = stepEnd
+		}
+		if step.Error != "" {
+			summary.Errors++
+		}
+
+		switch step.Kind {
+		case StepLLM:
+			summary.LLMTurns++
+			summary.LLMDuration += step.Duration
+			if step.Tokens != nil {
+				summary.Tokens.add(*step.Tokens)
+			}
+		case StepTool:
+			summary.ToolCalls++
+			summary.ToolDuration += step.Duration
+			tool := tools[step.Name]
+			if tool == nil {
+				tool = &ToolSummary{Name: step.Name}
+				tools[step.Name] = tool
+				summary.Tools = append(summary.Tools, tool)
+			}
+			tool.Calls++
+			tool.Duration += step.Duration
+			if step.Error != "" {
+				tool.Errors++
+			}
+		case StepPlan:
+			summary.Plans++
+		}
+	}
+	if !summary.Start.IsZero() {
+		summary.Duration = end.Sub(summary.Start)
+	}
+	sort.Slice(summary.Tools, func(i, j int) bool {
+		return summary.Tools[i].Name < summary.Tools[j].Name
+	})
+	return summary
+}
+
+// describeChat summarizes what was sent to the LLM in an llm-chat event.
+func describeChat(payload any) string {
+	// The payload is a list holding the list of contents sent
+	var contents []any
+	if outer, ok := payload.([]any); ok && len(outer) == 1 {
+		contents, _ = outer[0].([]any)
+	}
+
+	var texts []string
+	results := 0
+	for _, content := range contents {
+		switch content := content.(type) {
+		case string:
+			texts = append(texts, content)
+		case map[string]any:
+			// gollm.FunctionCallResult
+			if _, ok := content["result"]; ok {
+				results++
+			}
+		}
+	}
+
+	var parts []string
+	if len(texts) != 0 {
+		parts = append(parts, fmt.Sprintf("sent %q", truncate(strings.Join(texts, " "), 80)))
+	}
+	if results != 0 {
+		parts = append(parts, fmt.Sprintf("sent %d tool result(s)", results))
+	}
+	if len(parts) == 0 {
+		return "LLM turn"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describeToolCall summarizes a tool-request event.
+func describeToolCall(payload map[string]any) string {
+	arguments, _ := payload["arguments"].(map[string]any)
+	if command, ok := arguments["command"].(string); ok {
+		return truncate(command, 120)
+	}
+	var args []string
+	for k, v := range arguments {
+		args = append(args, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(args)
+	name, _ := payload["name"].(string)
+	return truncate(fmt.Sprintf("%s(%s)", name, strings.Join(args, ", ")), 120)
+}
+
+// toolError returns the error of a tool-response event, including commands that failed.
+func toolError(payload map[string]any) string {
+	if err, _ := payload["error"].(string); err != "" {
+		return err
+	}
+	response, _ := payload["response"].(map[string]any)
+	if err, _ := response["error"].(string); err != "" {
+		return err
+	}
+	if exitCode, ok := response["exit_code"].(float64); ok && exitCode != 0 {
+		message := fmt.Sprintf("exit code %d", int(exitCode))
+		if stderr, _ := response["stderr"].(string); stderr != "" {
+			message += ": " + truncate(strings.TrimSpace(stderr), 120)
+		}
+		return message
+	}
+	return ""
+}
+
+// tokenUsage extracts the token counts from an llm-response event.
+// Each provider reports usage differently, so we look for the fields used by each of them.
+func tokenUsage(payload any) Tokens {
+	var tokens Tokens
+
+	response, _ := payload.(map[string]any)
+	raw, _ := response["raw"].(map[string]any)
+	for _, m := range []map[string]any{
+		// gemini
+		mapField(raw, "usageMetadata"),
+		// openai, azopenai and llama.cpp
+		mapField(raw, "usage"),
+		// ollama
+		raw,
+		// recorded fixtures
+		mapField(response, "usage"),
+	} {
+		if m == nil {
+			continue
+		}
+		tokens = tokens.Max(usageTokens(m))
+	}
+	return tokens
+}
+
+// TokensFromUsage returns the token counts in the usage reported by a provider (see gollm.ChatResponse.UsageMetadata).
+func TokensFromUsage(usage any) Tokens {
+	if usage == nil {
+		return Tokens{}
+	}
+	b, err := json.Marshal(usage)
+	if err != nil {
+		return Tokens{}
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return Tokens{}
+	}
+	return usageTokens(m)
+}
+
+// usageTokens reads the token counts from usage, using the field names of any of the providers.
+func usageTokens(usage map[string]any) Tokens {
+	return Tokens{
+		Input:  intField(usage, "promptTokenCount", "prompt_tokens", "prompt_eval_count"),
+		Output: intField(usage, "candidatesTokenCount", "completion_tokens", "eval_count"),
+		Total:  intField(usage, "totalTokenCount", "total_tokens"),
+	}
+}
+
+func mapField(m map[string]any, key string) map[string]any {
+	v, _ := m[key].(map[string]any)
+	return v
+}
+
+// intField returns the value of the first of the keys that is a number in m.
+func intField(m map[string]any, keys ...string) int64 {
+	for _, key := range keys {
+		switch v := m[key].(type) {
+		case float64:
+			return int64(v)
+		case int64:
+			return v
+		case int:
+			return int64(v)
+		}
+	}
+	return 0
+}
+
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
@@ -0,0 +1,154 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var traceStart = time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+
+// at returns the time the given number of seconds into the trace.
+func at(seconds float64) time.Time {
+	return traceStart.Add(time.Duration(seconds * float64(time.Second)))
+}
+
+// testEvents are the events of a session: an LLM turn that calls two tools, one of which fails, a turn that
+// proposes a plan, and a turn that fails.
+func testEvents() []*Event {
+	return []*Event{
+		{Timestamp: at(0), Action: ActionSystemPrompt, Payload: map[string]any{"prompt": "You are a Kubernetes assistant."}},
+		{Timestamp: at(0), Action: "llm-chat", Payload: []any{[]any{"why is web crashing?"}}},
+		{Timestamp: at(1), Action: "llm-response", Payload: map[string]any{"raw": map[string]any{"usage": map[string]any{"prompt_tokens": 100, "completion_tokens": 5}}}},
+		{Timestamp: at(2), Action: "llm-response", Payload: map[string]any{"raw": map[string]any{"usage": map[string]any{"prompt_tokens": 100, "completion_tokens": 20}}}},
+		{Timestamp: at(2), Action: "tool-request", Payload: map[string]any{"id": "call-1", "name": "kubectl", "arguments": map[string]any{"command": "kubectl get pods"}}},
+		{Timestamp: at(2), Action: "tool-request", Payload: map[string]any{"id": "call-2", "name": "kubectl", "identity": "system:serviceaccount:ops:reader", "arguments": map[string]any{"command": "kubectl logs web"}}},
+		{Timestamp: at(3), Action: "tool-response", Payload: map[string]any{"id": "call-1", "response": map[string]any{"stdout": "web-1 0/1 CrashLoopBackOff"}}},
+		{Timestamp: at(3.5), Action: "tool-response", Payload: map[string]any{"id": "call-2", "response": map[string]any{"exit_code": 1, "stderr": "Error from server (NotFound)\n"}}},
+		{Timestamp: at(4), Action: "llm-chat", Payload: []any{[]any{map[string]any{"name": "kubectl", "result": "..."}, map[string]any{"name": "kubectl", "result": "..."}}}},
+		{Timestamp: at(6), Action: "llm-response", Payload: map[string]any{"raw": map[string]any{"usageMetadata": map[string]any{"promptTokenCount": 300, "candidatesTokenCount": 40, "totalTokenCount": 350}}}},
+		{Timestamp: at(6), Action: ActionPlan, Payload: map[string]any{"status": "stopped", "plan": map[string]any{"summary": "restart web"}}},
+		{Timestamp: at(7), Action: ActionUIRender, Payload: map[string]any{"text": "ignored"}},
+		{Timestamp: at(8), Action: "llm-chat", Payload: []any{[]any{"try again"}}},
+		{Timestamp: at(9), Action: ActionLLMError, Payload: map[string]any{"error": "reading streaming LLM response: unavailable"}},
+		{Timestamp: at(10), Action: "tool-request", Payload: map[string]any{"id": "call-3", "name": "bash", "arguments": map[string]any{"cmd": "ls", "dir": "/tmp"}}},
+	}
+}
+
+var wantTimeline = []*Step{
+	{Kind: StepLLM, Start: at(0), Duration: 2 * time.Second, Description: `sent "why is web crashing?"`, Tokens: &Tokens{Input: 100, Output: 20, Total: 120}},
+	{Kind: StepTool, Start: at(2), Duration: time.Second, Name: "kubectl", Description: "kubectl get pods"},
+	{Kind: StepTool, Start: at(2), Duration: 1500 * time.Millisecond, Name: "kubectl", Description: "kubectl logs web (as system:serviceaccount:ops:reader)", Error: "exit code 1: Error from server (NotFound)"},
+	{Kind: StepLLM, Start: at(4), Duration: 2 * time.Second, Description: "sent 2 tool result(s)", Tokens: &Tokens{Input: 300, Output: 40, Total: 350}},
+	{Kind: StepPlan, Start: at(6), Description: "plan stopped: restart web", Error: "the plan stopped before all its steps were run"},
+	{Kind: StepLLM, Start: at(8), Duration: time.Second, Description: `sent "try again"`, Error: "reading streaming LLM response: unavailable"},
+	{Kind: StepTool, Start: at(10), Name: "bash", Description: "bash(cmd=ls, dir=/tmp)", Error: "no response was recorded"},
+}
+
+func TestTimeline(t *testing.T) {
+	// Read the events back as from a trace, where numbers are float64
+	got := Timeline(roundTrip(t, testEvents()))
+	if !reflect.DeepEqual(got, wantTimeline) {
+		t.Errorf("got timeline\n%s\nwant\n%s", toJSON(got), toJSON(wantTimeline))
+	}
+}
+
+func TestTimelineFromTraceFiles(t *testing.T) {
+	// Both formats give the same timeline
+	for _, format := range []Format{FormatYAML, FormatJSONL} {
+		t.Run(string(format), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "trace")
+			recorder, err := NewFileRecorderWithFormat(path, format)
+			if err != nil {
+				t.Fatalf("creating recorder: %v", err)
+			}
+			for _, event := range testEvents() {
+				if err := recorder.Write(context.Background(), event); err != nil {
+					t.Fatalf("writing event: %v", err)
+				}
+			}
+			if err := recorder.Close(); err != nil {
+				t.Fatalf("closing recorder: %v", err)
+			}
+
+			events, err := ParseEventsFromFile(path)
+			if err != nil {
+				t.Fatalf("parsing trace: %v", err)
+			}
+			if len(events) != len(testEvents()) {
+				t.Fatalf("got %d events, want %d", len(events), len(testEvents()))
+			}
+			if got := Timeline(events); !reflect.DeepEqual(got, wantTimeline) {
+				t.Errorf("got timeline\n%s\nwant\n%s", toJSON(got), toJSON(wantTimeline))
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	got := Summarize(wantTimeline)
+	want := &Summary{
+		Start:    at(0),
+		Duration: 10 * time.Second,
+
+		LLMTurns:    3,
+		LLMDuration: 5 * time.Second,
+		Tokens:      Tokens{Input: 400, Output: 60, Total: 470},
+
+		ToolCalls:    3,
+		ToolDuration: 2500 * time.Millisecond,
+		Tools: []*ToolSummary{
+			{Name: "bash", Calls: 1, Errors: 1},
+			{Name: "kubectl", Calls: 2, Errors: 1, Duration: 2500 * time.Millisecond},
+		},
+
+		Plans:  1,
+		Errors: 4,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got summary\n%s\nwant\n%s", toJSON(got), toJSON(want))
+	}
+
+	if got := Summarize(nil); !reflect.DeepEqual(got, &Summary{}) {
+		t.Errorf("got summary %s for no steps, want an empty summary", toJSON(got))
+	}
+}
+
+// roundTrip returns the events as they are read back from a trace.
+func roundTrip(t *testing.T, events []*Event) []*Event {
+	t.Helper()
+	b, err := json.Marshal(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []*Event
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func toJSON(v any) string {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
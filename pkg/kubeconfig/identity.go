@@ -0,0 +1,147 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kubeconfig builds kubeconfig files that make the agent's tool calls run as a different identity
+// from the user's own, e.g. an impersonated user with fewer permissions.
+package kubeconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Identity is who the agent's tool calls run as. The zero value means the user's own credentials.
+// An identity either impersonates a user (and groups), or authenticates with a bearer token, but not both.
+type Identity struct {
+	// ImpersonateUser is the user to impersonate, as with kubectl --as.
+	ImpersonateUser string `json:"impersonateUser,omitempty"`
+	// ImpersonateGroups are the groups to impersonate, as with kubectl --as-group.
+	ImpersonateGroups []string `json:"impersonateGroups,omitempty"`
+
+	// ServiceAccount names the service account ("namespace/name") the Token was issued for, if any.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// Token is a bearer token that replaces the user's credentials.
+	Token string `json:"-"`
+	// TokenFile is a file holding a bearer token that replaces the user's credentials; it is read on every request.
+	TokenFile string `json:"tokenFile,omitempty"`
+}
+
+// IsZero returns true if the identity is the user's own.
+func (i Identity) IsZero() bool {
+	return i.ImpersonateUser == "" && len(i.ImpersonateGroups) == 0 && i.Token == "" && i.TokenFile == ""
+}
+
+// usesToken returns true if the identity replaces the user's credentials with a bearer token.
+func (i Identity) usesToken() bool {
+	return i.Token != "" || i.TokenFile != ""
+}
+
+// Validate checks that the identity is consistent.
+func (i Identity) Validate() error {
+	impersonates := i.ImpersonateUser != "" || len(i.ImpersonateGroups) != 0
+	if impersonates && i.usesToken() {
+		return fmt.Errorf("cannot both impersonate a user and use a service account or token")
+	}
+	if i.Token != "" && i.TokenFile != "" {
+		return fmt.Errorf("cannot use both a token and a token file")
+	}
+	if i.ImpersonateUser == "" && len(i.ImpersonateGroups) != 0 {
+		return fmt.Errorf("impersonating groups also requires impersonating a user")
+	}
+	return nil
+}
+
+func (i Identity) String() string {
+	if i.IsZero() {
+		return "the user's own credentials"
+	}
+	switch {
+	case i.ServiceAccount != "":
+		return fmt.Sprintf("service account %q", i.ServiceAccount)
+	case i.TokenFile != "":
+		return fmt.Sprintf("token from %q", i.TokenFile)
+	case i.Token != "":
+		return "token"
+	}
+	var parts []string
+	if i.ImpersonateUser != "" {
+		parts = append(parts, fmt.Sprintf("user %q", i.ImpersonateUser))
+	}
+	if len(i.ImpersonateGroups) != 0 {
+		parts = append(parts, fmt.Sprintf("groups %q", i.ImpersonateGroups))
+	}
+	return "impersonating " + strings.Join(parts, " and ")
+}
+
+// Write writes a kubeconfig to path that is a copy of the kubeconfig(s) in src (which may be a list, as in $KUBECONFIG),
+// changed so that every user in it acts as the identity; a token identity only applies to the current context, and
+// the other contexts are dropped. Credentials are inlined, so the copy is written with
+// permissions that only allow the current user to read it.
+func Write(src string, path string, identity Identity) error {
+	if err := identity.Validate(); err != nil {
+		return err
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.Precedence = filepath.SplitList(src)
+	config, err := rules.Load()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	if err := clientcmdapi.FlattenConfig(config); err != nil {
+		return fmt.Errorf("flattening kubeconfig: %w", err)
+	}
+
+	if identity.usesToken() {
+		// A token is only valid for the cluster it was issued by, so only the current context is kept,
+		// with the user's own credentials (certificates, exec plugins etc.) replaced by the token.
+		current := config.Contexts[config.CurrentContext]
+		if current == nil || config.Clusters[current.Cluster] == nil {
+			return fmt.Errorf("kubeconfig has no current context and cluster to use the token with")
+		}
+		config.Contexts = map[string]*clientcmdapi.Context{config.CurrentContext: current}
+		config.Clusters = map[string]*clientcmdapi.Cluster{current.Cluster: config.Clusters[current.Cluster]}
+		config.AuthInfos = map[string]*clientcmdapi.AuthInfo{
+			current.AuthInfo: {
+				Token:     identity.Token,
+				TokenFile: identity.TokenFile,
+			},
+		}
+	} else {
+		for _, authInfo := range config.AuthInfos {
+			if identity.ImpersonateUser != "" {
+				authInfo.Impersonate = identity.ImpersonateUser
+			}
+			if len(identity.ImpersonateGroups) != 0 {
+				authInfo.ImpersonateGroups = identity.ImpersonateGroups
+			}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("creating directory for kubeconfig: %w", err)
+	}
+	if err := clientcmd.WriteToFile(*config, path); err != nil {
+		return fmt.Errorf("writing kubeconfig: %w", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		return fmt.Errorf("writing kubeconfig: %w", err)
+	}
+	return nil
+}
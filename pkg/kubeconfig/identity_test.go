@@ -0,0 +1,85 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeconfig
+
+import (
+	"path/filepath"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// writeTestKubeconfig writes a kubeconfig with a context for each of two clusters, with prod as the current context.
+func writeTestKubeconfig(t *testing.T) string {
+	t.Helper()
+	config := clientcmdapi.NewConfig()
+	for _, name := range []string{"prod", "staging"} {
+		config.Clusters[name] = &clientcmdapi.Cluster{Server: "https://" + name + ".example.com"}
+		config.AuthInfos[name+"-admin"] = &clientcmdapi.AuthInfo{Token: name + "-admin-token"}
+		config.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: name + "-admin"}
+	}
+	config.CurrentContext = "prod"
+
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := clientcmd.WriteToFile(*config, path); err != nil {
+		t.Fatalf("writing kubeconfig: %v", err)
+	}
+	return path
+}
+
+func TestWriteToken(t *testing.T) {
+	src := writeTestKubeconfig(t)
+	dest := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := Write(src, dest, Identity{ServiceAccount: "agents/kubectl-ai", Token: "sa-token"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	config, err := clientcmd.LoadFromFile(dest)
+	if err != nil {
+		t.Fatalf("loading written kubeconfig: %v", err)
+	}
+	// The token must not be sent to the other clusters
+	if len(config.Contexts) != 1 || len(config.Clusters) != 1 || len(config.AuthInfos) != 1 {
+		t.Errorf("got %d contexts, %d clusters and %d users, want only the current context", len(config.Contexts), len(config.Clusters), len(config.AuthInfos))
+	}
+	if config.CurrentContext != "prod" || config.Clusters["prod"] == nil {
+		t.Errorf("got current context %q, want prod", config.CurrentContext)
+	}
+	if authInfo := config.AuthInfos["prod-admin"]; authInfo == nil || authInfo.Token != "sa-token" {
+		t.Errorf("got user %+v for the current context, want the service account token", authInfo)
+	}
+}
+
+func TestWriteImpersonation(t *testing.T) {
+	src := writeTestKubeconfig(t)
+	dest := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := Write(src, dest, Identity{ImpersonateUser: "agent", ImpersonateGroups: []string{"readers"}}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	config, err := clientcmd.LoadFromFile(dest)
+	if err != nil {
+		t.Fatalf("loading written kubeconfig: %v", err)
+	}
+	if len(config.AuthInfos) != 2 {
+		t.Fatalf("got %d users, want both users kept", len(config.AuthInfos))
+	}
+	for name, authInfo := range config.AuthInfos {
+		if authInfo.Impersonate != "agent" || len(authInfo.ImpersonateGroups) != 1 || authInfo.Token != name+"-token" {
+			t.Errorf("got user %s %+v, want its own token, impersonating agent and readers", name, authInfo)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeconfig
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/kubectl-utils/pkg/kube"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// serviceAccountTokenLifetime is how long we ask service account tokens to be valid for; it should outlast a session.
+// The API server may issue a token with a shorter lifetime.
+const serviceAccountTokenLifetime = 8 * time.Hour
+
+var serviceAccountsGVR = schema.GroupVersionResource{Version: "v1", Resource: "serviceaccounts"}
+
+// ServiceAccountIdentity requests a token for the service account ("namespace/name") using the credentials
+// in the kubeconfig, as with `kubectl create token`, and returns an Identity that uses it.
+func ServiceAccountIdentity(ctx context.Context, kubeconfig string, serviceAccount string) (Identity, error) {
+	namespace, name, ok := strings.Cut(serviceAccount, "/")
+	if !ok || namespace == "" || name == "" {
+		return Identity{}, fmt.Errorf("service account must be given as namespace/name, got %q", serviceAccount)
+	}
+
+	client, err := kube.NewClient(kubeconfig)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	tokenRequest := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "authentication.k8s.io/v1",
+		"kind":       "TokenRequest",
+		"spec": map[string]any{
+			"expirationSeconds": int64(serviceAccountTokenLifetime.Seconds()),
+		},
+	}}
+	response, err := client.ForGVR(serviceAccountsGVR, namespace).Create(ctx, tokenRequest, metav1.CreateOptions{}, "token")
+	if err != nil {
+		return Identity{}, fmt.Errorf("requesting token for service account %q: %w", serviceAccount, err)
+	}
+	token, _, err := unstructured.NestedString(response.Object, "status", "token")
+	if err != nil || token == "" {
+		return Identity{}, fmt.Errorf("requesting token for service account %q: no token in response", serviceAccount)
+	}
+	return Identity{ServiceAccount: serviceAccount, Token: token}, nil
+}
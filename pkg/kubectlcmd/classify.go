@@ -0,0 +1,156 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubectlcmd
+
+// Class classifies what a kubectl command does.
+type Class string
+
+const (
+	// ClassRead is for commands that only read, such as get, describe and logs, and dry runs.
+	ClassRead Class = "read"
+	// ClassStreaming is for commands that read, but keep running until they are stopped,
+	// such as `logs -f`, `get -w`, port-forward and proxy.
+	ClassStreaming Class = "streaming"
+	// ClassWrite is for commands that may modify the cluster (or the kubeconfig), such as apply, delete and exec.
+	ClassWrite Class = "write"
+	// ClassUnknown is for commands we don't recognize, such as plugins, which may do anything.
+	ClassUnknown Class = "unknown"
+	// ClassInteractive is for commands that need a user at a terminal, such as edit and `exec -it`.
+	ClassInteractive Class = "interactive"
+)
+
+// rank orders the classes by risk.
+func (c Class) rank() int {
+	switch c {
+	case ClassRead:
+		return 0
+	case ClassStreaming:
+		return 1
+	case ClassWrite:
+		return 2
+	case ClassUnknown:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// MayModify returns true if commands of the class may modify the cluster.
+func (c Class) MayModify() bool {
+	return c == ClassWrite || c == ClassUnknown || c == ClassInteractive
+}
+
+// OpensAccess returns true if the command opens access to workloads or the API server, as port-forward, proxy and
+// attach do. These don't modify the cluster, but should be approved like commands that do.
+func (i *Invocation) OpensAccess() bool {
+	switch i.Verb {
+	case "port-forward", "proxy", "attach":
+		return !i.HasFlag("-h", "--help")
+	}
+	return false
+}
+
+// readVerbs are the kubectl commands that only read. Commands with subcommands map to the subcommands that only read.
+var readVerbs = map[string][]string{
+	"get":           nil,
+	"describe":      nil,
+	"logs":          nil,
+	"top":           nil,
+	"explain":       nil,
+	"events":        nil,
+	"diff":          nil,
+	"wait":          nil,
+	"version":       nil,
+	"api-resources": nil,
+	"api-versions":  nil,
+	"cluster-info":  {"", "dump"},
+	"completion":    nil,
+	"kustomize":     nil,
+	"options":       nil,
+	"help":          nil,
+	"auth":          {"can-i", "whoami"},
+	"config":        {"view", "current-context", "get-contexts", "get-clusters", "get-users"},
+	"rollout":       {"status", "history"},
+	"apply":         {"view-last-applied"},
+	"plugin":        {"list"},
+}
+
+// writeVerbs are the kubectl commands that modify the cluster, or the kubeconfig.
+var writeVerbs = map[string]bool{
+	"create": true, "apply": true, "replace": true, "patch": true, "delete": true,
+	"edit": true, "scale": true, "autoscale": true, "expose": true, "run": true,
+	"set": true, "label": true, "annotate": true, "rollout": true,
+	"cordon": true, "uncordon": true, "drain": true, "taint": true,
+	"certificate": true, "config": true, "auth": true,
+	"exec": true, "attach": true, "cp": true, "debug": true,
+}
+
+// Class classifies the command.
+func (i *Invocation) Class() Class {
+	if i.HasFlag("-h", "--help") {
+		return ClassRead
+	}
+
+	switch i.Verb {
+	case "edit":
+		return ClassInteractive
+	case "apply":
+		if i.Subcommand == "edit-last-applied" {
+			return ClassInteractive
+		}
+	case "exec", "attach", "run", "debug":
+		// A terminal, or input that kubectl waits for, needs a user
+		if i.TTY || (i.Stdin && !i.StdinProvided) {
+			return ClassInteractive
+		}
+		if i.Verb == "attach" {
+			return ClassStreaming
+		}
+	case "port-forward", "proxy":
+		return ClassStreaming
+	case "logs":
+		if i.Follow {
+			return ClassStreaming
+		}
+	case "get", "events":
+		if i.Watch {
+			return ClassStreaming
+		}
+	}
+
+	if subcommands, ok := readVerbs[i.Verb]; ok {
+		if subcommands == nil {
+			return ClassRead
+		}
+		for _, subcommand := range subcommands {
+			if i.Subcommand == subcommand {
+				return ClassRead
+			}
+		}
+	}
+
+	if writeVerbs[i.Verb] {
+		switch i.Verb {
+		case "exec", "attach", "cp", "debug", "config":
+			// These can't be dry runs
+		default:
+			if i.DryRun == "client" || i.DryRun == "server" {
+				return ClassRead
+			}
+		}
+		return ClassWrite
+	}
+	return ClassUnknown
+}
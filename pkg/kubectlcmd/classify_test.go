@@ -0,0 +1,106 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubectlcmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInvocationClass(t *testing.T) {
+	tests := []struct {
+		args string
+		want Class
+	}{
+		{"get pods", ClassRead},
+		{"describe deploy web", ClassRead},
+		{"logs web --tail=10", ClassRead},
+		{"auth can-i delete pods", ClassRead},
+		{"config view", ClassRead},
+		{"rollout status deploy/web", ClassRead},
+		{"cluster-info", ClassRead},
+		{"apply -f deploy.yaml --dry-run=server", ClassRead},
+		{"delete pod web --dry-run=client", ClassRead},
+		{"delete pod web --dry-run=none", ClassWrite},
+		{"delete pod web -h", ClassRead},
+
+		{"get pods -w", ClassStreaming},
+		{"logs -f web", ClassStreaming},
+		{"logs web --follow", ClassStreaming},
+		{"port-forward svc/web 8080:80", ClassStreaming},
+		{"proxy", ClassStreaming},
+		{"attach web", ClassStreaming},
+
+		{"apply -f deploy.yaml", ClassWrite},
+		{"delete pod web", ClassWrite},
+		{"scale deploy/web --replicas=3", ClassWrite},
+		{"rollout restart deploy/web", ClassWrite},
+		{"config use-context prod", ClassWrite},
+		{"auth reconcile -f rbac.yaml", ClassWrite},
+		{"exec web -- ls", ClassWrite},
+		// exec can't be a dry run
+		{"exec web --dry-run=client -- rm -rf /data", ClassWrite},
+		{"cp web:/tmp/a ./a", ClassWrite},
+		{"drain node-1", ClassWrite},
+
+		{"edit deploy/web", ClassInteractive},
+		{"apply edit-last-applied deploy/web", ClassInteractive},
+		{"exec -it web -- sh", ClassInteractive},
+		// -i waits for input, unless the command line provides it
+		{"exec -i web -- sh", ClassInteractive},
+		{"run debug --image=busybox -it", ClassInteractive},
+
+		{"foo bar", ClassUnknown},
+		{"", ClassUnknown},
+	}
+
+	for _, test := range tests {
+		t.Run(test.args, func(t *testing.T) {
+			if got := ParseInvocation(strings.Fields(test.args)).Class(); got != test.want {
+				t.Errorf("got class %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMayModify(t *testing.T) {
+	for class, want := range map[Class]bool{
+		ClassRead:        false,
+		ClassStreaming:   false,
+		ClassWrite:       true,
+		ClassUnknown:     true,
+		ClassInteractive: true,
+	} {
+		if got := class.MayModify(); got != want {
+			t.Errorf("%s.MayModify() = %v, want %v", class, got, want)
+		}
+	}
+}
+
+func TestOpensAccess(t *testing.T) {
+	for args, want := range map[string]bool{
+		"port-forward svc/web 8080:80": true,
+		"proxy --port=8001":            true,
+		"attach web":                   true,
+		"port-forward --help":          false,
+		"logs -f web":                  false,
+		"get pods -w":                  false,
+		"exec web -- ls":               false,
+	} {
+		if got := ParseInvocation(strings.Fields(args)).OpensAccess(); got != want {
+			t.Errorf("OpensAccess() for %q = %v, want %v", args, got, want)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubectlcmd
+
+import (
+	"strings"
+)
+
+// Invocation is a single run of kubectl, e.g. `kubectl get pods -n kube-system -o yaml`.
+type Invocation struct {
+	// Args are the arguments passed to kubectl.
+	Args []string
+
+	// Verb is the kubectl command, e.g. "get" or "rollout".
+	Verb string
+	// Subcommand is the subcommand, for commands that have them, e.g. "status" for `kubectl rollout status`
+	// or "secret" for `kubectl create secret generic`.
+	Subcommand string
+	// Resource is the resource type, e.g. "pods" for `kubectl get pods` or `kubectl delete pod/web`.
+	Resource string
+	// Names are the names of the resources, e.g. "web" for `kubectl delete pod/web`.
+	Names []string
+
+	Namespace     string
+	AllNamespaces bool
+	// Output is the output format (-o), e.g. "yaml" or "jsonpath={.status}".
+	Output string
+	// DryRun is the dry-run mode, "client", "server" or "none", or "" if it was not set.
+	DryRun string
+	// Filenames are the files of manifests passed with -f, where "-" is standard input.
+	Filenames []string
+
+	// Watch is set for `kubectl get -w`, and Follow for `kubectl logs -f`.
+	Watch  bool
+	Follow bool
+	// Stdin and TTY are set by -i and -t, e.g. for `kubectl exec -it`.
+	Stdin bool
+	TTY   bool
+	// StdinProvided is true if the command line passes input to kubectl, e.g. with a pipe or a here-document.
+	StdinProvided bool
+
+	// Flags are the flags passed, as written (e.g. "-n" or "--namespace"), mapped to their values ("" for boolean flags).
+	Flags map[string]string
+	// Command is the command after "--", e.g. the command to run in the container for `kubectl exec`.
+	Command []string
+}
+
+// HasFlag returns true if any of the flags (e.g. "--as") was passed.
+func (i *Invocation) HasFlag(names ...string) bool {
+	for _, name := range names {
+		if _, ok := i.Flags[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the command, e.g. "kubectl rollout status".
+func (i *Invocation) String() string {
+	s := "kubectl"
+	if i.Verb != "" {
+		s += " " + i.Verb
+	}
+	if i.Subcommand != "" {
+		s += " " + i.Subcommand
+	}
+	return s
+}
+
+// flagsWithValues are the kubectl flags that take a value, which may be passed as a separate argument
+// (e.g. "-n default"). Flags that are not listed are assumed to be boolean.
+var flagsWithValues = map[string]bool{
+	// global flags
+	"-n": true, "--namespace": true,
+	"--context": true, "--cluster": true, "--user": true, "--kubeconfig": true,
+	"-s": true, "--server": true,
+	"--token": true, "--username": true, "--password": true,
+	"--as": true, "--as-group": true, "--as-uid": true,
+	"--certificate-authority": true, "--client-certificate": true, "--client-key": true, "--tls-server-name": true,
+	"--request-timeout": true, "--cache-dir": true,
+	"-v": true, "--v": true, "--vmodule": true, "--log-file": true,
+
+	// command flags
+	"-o": true, "--output": true, "--template": true,
+	"-l": true, "--selector": true, "--field-selector": true,
+	"-L": true, "--label-columns": true, "--sort-by": true, "--chunk-size": true,
+	"-f": true, "--filename": true, "-k": true, "--kustomize": true,
+	"-c": true, "--container": true,
+	"--since": true, "--since-time": true, "--tail": true, "--limit-bytes": true, "--max-log-requests": true,
+	"--timeout": true, "--for": true, "--pod-running-timeout": true,
+	"-p": true, "--patch": true, "--patch-file": true, "--type": true,
+	"--replicas": true, "--current-replicas": true, "--resource-version": true,
+	"--image": true, "--port": true, "--target-port": true, "--protocol": true, "--name": true,
+	"--env": true, "--labels": true, "--overrides": true, "--restart": true,
+	"--grace-period": true, "--field-manager": true, "--subresource": true,
+	"--prune-allowlist": true, "--selector-ignore": true,
+	"--min": true, "--max": true, "--cpu-percent": true,
+	"--address": true, "--api-group": true, "--api-version": true, "--raw": true,
+	"--from-literal": true, "--from-file": true, "--from-env-file": true,
+	"--docker-server": true, "--docker-username": true, "--docker-password": true, "--docker-email": true,
+	"--cert": true, "--key": true, "--role": true, "--clusterrole": true, "--serviceaccount": true, "--group": true,
+	"--verb": true, "--resource": true, "--resource-name": true, "--duration": true, "--audience": true,
+	"--profile": true, "--target": true, "--copy-to": true, "--set-image": true, "--containers": true,
+	"--to-revision": true, "--revision": true, "--local-ssd-count": true,
+}
+
+// verbsWithSubcommands are the kubectl commands whose first argument is a subcommand.
+var verbsWithSubcommands = map[string]bool{
+	"auth": true, "config": true, "rollout": true, "set": true, "certificate": true,
+	"create": true, "top": true, "apply": true, "plugin": true, "cluster-info": true, "alpha": true,
+}
+
+// ParseInvocation parses the arguments passed to kubectl.
+func ParseInvocation(args []string) *Invocation {
+	inv := &Invocation{
+		Args:  args,
+		Flags: make(map[string]string),
+	}
+
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			inv.Command = args[i+1:]
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			positional = append(positional, arg)
+			if inv.Verb == "" {
+				inv.Verb = arg
+			}
+			continue
+		}
+
+		if strings.HasPrefix(arg, "--") {
+			name, value, hasValue := strings.Cut(arg, "=")
+			if !hasValue && inv.takesValue(name) && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			inv.setFlag(name, value, hasValue)
+			continue
+		}
+
+		// Short flags can be combined, e.g. -it, and can include their value, e.g. -nkube-system or -o=yaml
+		for j := 1; j < len(arg); j++ {
+			name := "-" + arg[j:j+1]
+			if !inv.takesValue(name) {
+				inv.setFlag(name, "", false)
+				continue
+			}
+			value := strings.TrimPrefix(arg[j+1:], "=")
+			if value == "" && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			inv.setFlag(name, value, true)
+			break
+		}
+	}
+
+	if len(positional) != 0 {
+		positional = positional[1:]
+	}
+	if verbsWithSubcommands[inv.Verb] && len(positional) != 0 {
+		inv.Subcommand = positional[0]
+		positional = positional[1:]
+	}
+	inv.parseResource(positional)
+	return inv
+}
+
+// Rewrite returns the arguments to kubectl with the verb replaced, and the named flags (and their values) removed,
+// e.g. to turn `kubectl edit deploy/web -n prod` into `kubectl get deploy/web -n prod`.
+func (i *Invocation) Rewrite(verb string, removeFlags ...string) []string {
+	removed := make(map[string]bool)
+	for _, name := range removeFlags {
+		removed[name] = true
+	}
+
+	var args []string
+	verbSeen := false
+	for j := 0; j < len(i.Args); j++ {
+		arg := i.Args[j]
+		switch {
+		case arg == "--":
+			return append(args, i.Args[j:]...)
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			if !verbSeen {
+				verbSeen = true
+				arg = verb
+			}
+			args = append(args, arg)
+			continue
+		}
+
+		if strings.HasPrefix(arg, "--") {
+			name, _, hasValue := strings.Cut(arg, "=")
+			separateValue := !hasValue && i.takesValue(name)
+			if !removed[name] {
+				args = append(args, arg)
+				if separateValue && j+1 < len(i.Args) {
+					args = append(args, i.Args[j+1])
+				}
+			}
+			if separateValue {
+				j++
+			}
+			continue
+		}
+
+		// Short flags can be combined, e.g. -it, so they are removed one at a time
+		kept := "-"
+		separateValue := false
+		for k := 1; k < len(arg); k++ {
+			name := "-" + arg[k:k+1]
+			if !i.takesValue(name) {
+				if !removed[name] {
+					kept += arg[k : k+1]
+				}
+				continue
+			}
+			separateValue = strings.TrimPrefix(arg[k+1:], "=") == "" && j+1 < len(i.Args)
+			if removed[name] {
+				if separateValue {
+					j++
+				}
+				separateValue = false
+			} else {
+				kept += arg[k:]
+			}
+			break
+		}
+		if kept != "-" {
+			args = append(args, kept)
+		}
+		if separateValue {
+			j++
+			args = append(args, i.Args[j])
+		}
+	}
+	return args
+}
+
+// takesValue returns true if the flag takes a value. Some short flags mean different things for different commands.
+func (i *Invocation) takesValue(name string) bool {
+	switch {
+	case name == "-f" && i.Verb == "logs":
+		// --follow
+		return false
+	case name == "-p" && i.Verb != "patch":
+		// --previous for logs; exec and port-forward have a deprecated -p for the pod
+		return i.Verb == "exec" || i.Verb == "port-forward"
+	}
+	return flagsWithValues[name]
+}
+
+// setFlag records a flag and its value.
+func (i *Invocation) setFlag(name, value string, hasValue bool) {
+	i.Flags[name] = value
+	switch name {
+	case "-n", "--namespace":
+		i.Namespace = value
+	case "-A", "--all-namespaces":
+		i.AllNamespaces = !hasValue || value == "true"
+	case "-o", "--output":
+		i.Output = value
+	case "-f", "--filename":
+		if i.Verb == "logs" && name == "-f" {
+			i.Follow = true
+		} else {
+			i.Filenames = append(i.Filenames, value)
+		}
+	case "--dry-run":
+		if !hasValue {
+			// The deprecated boolean form means a client-side dry run
+			value = "client"
+		}
+		i.DryRun = value
+	case "-w", "--watch", "--watch-only":
+		i.Watch = !hasValue || value == "true"
+	case "--follow":
+		i.Follow = !hasValue || value == "true"
+	case "-i", "--stdin":
+		i.Stdin = !hasValue || value == "true"
+	case "-t", "--tty":
+		i.TTY = !hasValue || value == "true"
+	}
+}
+
+// parseResource sets the resource type and names from the arguments after the verb (and subcommand).
+func (i *Invocation) parseResource(args []string) {
+	switch i.Verb {
+	case "get", "describe", "delete", "edit", "patch", "label", "annotate", "scale", "wait", "explain",
+		"expose", "autoscale", "replace", "taint", "events":
+	case "rollout", "set", "top":
+	case "cordon", "uncordon", "drain":
+		i.Resource = "nodes"
+		i.Names = args
+		return
+	case "logs", "exec", "attach", "port-forward", "debug", "cp":
+		// These take a pod (or a resource/name that selects a pod), and other arguments
+		if len(args) == 0 {
+			return
+		}
+		resource, name, ok := strings.Cut(args[0], "/")
+		if ok {
+			i.Resource = resource
+			i.Names = []string{name}
+		} else if i.Verb != "cp" {
+			i.Resource = "pods"
+			i.Names = []string{args[0]}
+		}
+		return
+	case "create":
+		// e.g. kubectl create secret generic <name>, or kubectl create deployment <name>
+		i.Resource = i.Subcommand
+		if i.Resource == "secret" && len(args) != 0 {
+			args = args[1:]
+		}
+		i.Names = args
+		return
+	default:
+		return
+	}
+	if i.Verb == "set" {
+		// Arguments such as container=image and NAME=value are not names
+		var kept []string
+		for _, arg := range args {
+			if !strings.Contains(arg, "=") {
+				kept = append(kept, arg)
+			}
+		}
+		args = kept
+	}
+	if i.Verb == "top" {
+		// kubectl top pod <name>
+		i.Resource = i.Subcommand
+		i.Names = args
+		return
+	}
+
+	for _, arg := range args {
+		if resource, name, ok := strings.Cut(arg, "/"); ok {
+			if i.Resource == "" {
+				i.Resource = resource
+			}
+			i.Names = append(i.Names, name)
+			continue
+		}
+		if i.Resource == "" {
+			i.Resource = arg
+			continue
+		}
+		if (i.Verb == "label" || i.Verb == "annotate" || i.Verb == "taint") && strings.ContainsAny(arg, "=-:") {
+			// key=value arguments, and key- to remove
+			continue
+		}
+		i.Names = append(i.Names, arg)
+	}
+}
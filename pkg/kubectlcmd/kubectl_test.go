@@ -0,0 +1,163 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubectlcmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseInvocation(t *testing.T) {
+	tests := []struct {
+		args string
+		want Invocation
+	}{
+		{
+			args: "get pods -n kube-system -o yaml",
+			want: Invocation{Verb: "get", Resource: "pods", Namespace: "kube-system", Output: "yaml"},
+		},
+		{
+			args: "get deploy/web deploy/api --namespace=prod",
+			want: Invocation{Verb: "get", Resource: "deploy", Names: []string{"web", "api"}, Namespace: "prod"},
+		},
+		{
+			args: "get pods -A -w",
+			want: Invocation{Verb: "get", Resource: "pods", AllNamespaces: true, Watch: true},
+		},
+		{
+			// Short flags with their value attached
+			args: "get pods -nkube-system -o=json",
+			want: Invocation{Verb: "get", Resource: "pods", Namespace: "kube-system", Output: "json"},
+		},
+		{
+			args: "delete pod web --dry-run=server",
+			want: Invocation{Verb: "delete", Resource: "pod", Names: []string{"web"}, DryRun: "server"},
+		},
+		{
+			// The deprecated boolean form is a client-side dry run
+			args: "apply -f deploy.yaml --dry-run",
+			want: Invocation{Verb: "apply", Filenames: []string{"deploy.yaml"}, DryRun: "client"},
+		},
+		{
+			args: "rollout status deployment/web",
+			want: Invocation{Verb: "rollout", Subcommand: "status", Resource: "deployment", Names: []string{"web"}},
+		},
+		{
+			args: "create secret generic db --from-literal=password=x",
+			want: Invocation{Verb: "create", Subcommand: "secret", Resource: "secret", Names: []string{"db"}},
+		},
+		{
+			// -f follows logs, rather than naming a file
+			args: "logs -f web -c app",
+			want: Invocation{Verb: "logs", Resource: "pods", Names: []string{"web"}, Follow: true},
+		},
+		{
+			args: "exec -it deploy/web -- sh -c 'ls /'",
+			want: Invocation{Verb: "exec", Resource: "deploy", Names: []string{"web"}, Stdin: true, TTY: true, Command: []string{"sh", "-c", "'ls", "/'"}},
+		},
+		{
+			args: "label pod web app=web tier-",
+			want: Invocation{Verb: "label", Resource: "pod", Names: []string{"web"}},
+		},
+		{
+			args: "set image deployment/web app=nginx:1.27",
+			want: Invocation{Verb: "set", Subcommand: "image", Resource: "deployment", Names: []string{"web"}},
+		},
+		{
+			args: "drain node-1 --ignore-daemonsets",
+			want: Invocation{Verb: "drain", Resource: "nodes", Names: []string{"node-1"}},
+		},
+		{
+			args: "top pod web",
+			want: Invocation{Verb: "top", Subcommand: "pod", Resource: "pod", Names: []string{"web"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.args, func(t *testing.T) {
+			got := ParseInvocation(strings.Fields(test.args))
+			// Args and Flags are checked separately
+			got.Args = nil
+			got.Flags = nil
+			if !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("got %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+}
+
+func TestInvocationFlags(t *testing.T) {
+	inv := ParseInvocation(strings.Fields("get pods -l app=web --as=admin -it --show-labels"))
+	want := map[string]string{"-l": "app=web", "--as": "admin", "-i": "", "-t": "", "--show-labels": ""}
+	if !reflect.DeepEqual(inv.Flags, want) {
+		t.Errorf("got flags %v, want %v", inv.Flags, want)
+	}
+	if !inv.HasFlag("--as", "--user") || inv.HasFlag("--user") {
+		t.Errorf("HasFlag does not match the flags %v", inv.Flags)
+	}
+	if got := ParseInvocation(strings.Fields("rollout restart deploy/web")).String(); got != "kubectl rollout restart" {
+		t.Errorf("got String() %q, want %q", got, "kubectl rollout restart")
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	tests := []struct {
+		args   string
+		verb   string
+		remove []string
+		want   string
+	}{
+		{
+			args: "edit deploy/web -n prod",
+			verb: "get",
+			want: "get deploy/web -n prod",
+		},
+		{
+			args:   "edit deploy/web -n prod --output yaml",
+			verb:   "get",
+			remove: []string{"--output"},
+			want:   "get deploy/web -n prod",
+		},
+		{
+			// Combined short flags are removed one at a time
+			args:   "exec -it web -- sh",
+			verb:   "exec",
+			remove: []string{"-t"},
+			want:   "exec -i web -- sh",
+		},
+		{
+			args:   "logs -f web -c app",
+			verb:   "logs",
+			remove: []string{"-f"},
+			want:   "logs web -c app",
+		},
+		{
+			args:   "-n prod logs web -c app",
+			verb:   "logs",
+			remove: []string{"-c"},
+			want:   "-n prod logs web",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.args, func(t *testing.T) {
+			got := strings.Join(ParseInvocation(strings.Fields(test.args)).Rewrite(test.verb, test.remove...), " ")
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
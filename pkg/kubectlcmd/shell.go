@@ -0,0 +1,719 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kubectlcmd parses shell command lines, as run by the kubectl and bash tools, and finds the kubectl
+// commands they run (including in pipelines, subshells, command substitutions and through wrappers such as xargs),
+// with their verb, resource, namespace, output format and dry-run mode. Each kubectl command is classified by
+// whether it reads or writes the cluster, needs a terminal, or streams output until it is stopped.
+//
+// The parser understands the shell syntax commands use in practice, not all of bash. Callers that enforce a
+// policy should treat a command line that fails to parse as unsafe.
+package kubectlcmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// CommandLine is a parsed shell command line.
+type CommandLine struct {
+	// Commands are the simple commands in the command line, in the order they appear, including those in
+	// subshells and command substitutions, and scripts run with `bash -c`.
+	Commands []*Command
+
+	// Substitution is true if the command line uses command substitution ($(...) or backticks),
+	// so some arguments are only known when it runs.
+	Substitution bool
+	// Subshell is true if the command line groups commands with (...) or {...}.
+	Subshell bool
+	// ControlFlow is true if the command line uses loops or conditionals (for, while, if, case).
+	ControlFlow bool
+}
+
+// Command is a simple command: a program and its arguments.
+type Command struct {
+	// Name is the program, as written, e.g. "kubectl" or "/usr/local/bin/kubectl".
+	Name string
+	Args []string
+	// Env are the variables assigned for the command, e.g. "KUBECONFIG=/tmp/config".
+	Env []string
+	// Redirects are the redirections of the command's input and output, other than here-documents.
+	Redirects []Redirect
+	// Heredoc is the text of a here-document (<<EOF) or here-string (<<<) passed as the command's input.
+	Heredoc string
+	// Piped is true if the command reads the output of the previous command in a pipeline.
+	Piped bool
+	// Background is true if the command is run in the background, with &.
+	Background bool
+
+	// Kubectl is set if the command runs kubectl, either directly or through a wrapper such as xargs, env or timeout.
+	Kubectl *Invocation
+	// Opaque is true if we can't tell what the command does to the cluster: the program is only known when the
+	// command line runs (e.g. `$KUBECTL delete pod web`), it runs a script we can't see, or it is a client of the
+	// cluster whose commands we don't classify, such as helm or a kubectl plugin.
+	Opaque bool
+}
+
+// Redirect is a redirection of a command's input or output, e.g. "2>/dev/null".
+type Redirect struct {
+	// Op is the redirection operator, e.g. ">", ">>", "<", ">&" or "&>".
+	Op string
+	// Target is the file, or the file descriptor being duplicated.
+	Target string
+}
+
+// Discards returns true if the redirection only discards output, or merges stdout and stderr.
+func (r Redirect) Discards() bool {
+	switch r.Op {
+	case ">", ">|", ">>", "&>", "&>>":
+		return r.Target == "/dev/null"
+	case ">&":
+		return r.Target == "1" || r.Target == "2" || r.Target == "/dev/null"
+	}
+	return false
+}
+
+// Program returns the base name of the program, e.g. "kubectl" for "/usr/local/bin/kubectl".
+func (c *Command) Program() string {
+	return filepath.Base(c.Name)
+}
+
+// StdinProvided returns true if the command's input comes from a pipe, file or here-document.
+func (c *Command) StdinProvided() bool {
+	if c.Piped || c.Heredoc != "" {
+		return true
+	}
+	for _, redirect := range c.Redirects {
+		if redirect.Op == "<" {
+			return true
+		}
+	}
+	return false
+}
+
+// Invocations returns the kubectl commands run by the command line.
+func (l *CommandLine) Invocations() []*Invocation {
+	var invocations []*Invocation
+	for _, command := range l.Commands {
+		if command.Kubectl != nil {
+			invocations = append(invocations, command.Kubectl)
+		}
+	}
+	return invocations
+}
+
+// Opaque returns true if the command line runs any command whose effect on the cluster we can't tell (see Command.Opaque).
+func (l *CommandLine) Opaque() bool {
+	for _, command := range l.Commands {
+		if command.Opaque {
+			return true
+		}
+	}
+	return false
+}
+
+// Class returns the class of the riskiest kubectl command in the command line, or ClassRead if it runs none.
+// Commands other than kubectl are not classified; see Opaque.
+func (l *CommandLine) Class() Class {
+	class := ClassRead
+	for _, invocation := range l.Invocations() {
+		if invocation.Class().rank() > class.rank() {
+			class = invocation.Class()
+		}
+	}
+	return class
+}
+
+// maxDepth limits the nesting of subshells, substitutions and scripts run with `bash -c`.
+const maxDepth = 16
+
+// Parse parses a shell command line.
+func Parse(commandLine string) (*CommandLine, error) {
+	p := &parser{s: commandLine, line: &CommandLine{}}
+	if err := p.parseList(0, 0); err != nil {
+		return nil, err
+	}
+	if p.i < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q in command", p.s[p.i])
+	}
+	if len(p.heredocs) != 0 {
+		return nil, fmt.Errorf("here-document is not terminated by %q", p.heredocs[0].delimiter)
+	}
+	// Here-documents are read after the commands that use them, so we can only tell now where input comes from
+	for _, command := range p.line.Commands {
+		if command.Kubectl != nil {
+			command.Kubectl.StdinProvided = command.StdinProvided()
+		}
+	}
+	return p.line, nil
+}
+
+// parser is a recursive-descent parser of shell command lines.
+type parser struct {
+	s    string
+	i    int
+	line *CommandLine
+	// heredocs are here-documents whose body starts on the next line
+	heredocs []*pendingHeredoc
+	// backtick is set while parsing a `...` substitution, where a backtick ends the substitution
+	backtick bool
+}
+
+type pendingHeredoc struct {
+	command   *Command
+	delimiter string
+	stripTabs bool
+}
+
+// commandBuilder collects the words of a simple command as it is parsed.
+type commandBuilder struct {
+	words     []string
+	redirects []Redirect
+	command   *Command
+	piped     bool
+}
+
+func (b *commandBuilder) empty() bool {
+	return len(b.words) == 0 && len(b.redirects) == 0 && b.command == nil
+}
+
+// parseList parses commands until the end of the command line, or until the terminator (')' or '`') of a
+// subshell or command substitution, which it consumes.
+func (p *parser) parseList(terminator byte, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("command is nested too deeply")
+	}
+
+	b := &commandBuilder{}
+	endCommand := func(background bool) error {
+		command, err := p.endCommand(b, depth)
+		if err != nil {
+			return err
+		}
+		if command != nil {
+			command.Background = background
+		}
+		b = &commandBuilder{}
+		return nil
+	}
+
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.i++
+		case c == '#':
+			// A comment runs to the end of the line
+			for p.i < len(p.s) && p.s[p.i] != '\n' {
+				p.i++
+			}
+		case c == '\\' && p.peek(1) == '\n':
+			p.i += 2
+		case c == '\n':
+			p.i++
+			if err := endCommand(false); err != nil {
+				return err
+			}
+			if err := p.readHeredocs(); err != nil {
+				return err
+			}
+		case c == ';':
+			// ";;" ends a case pattern, which we treat like ";"
+			for p.i < len(p.s) && p.s[p.i] == ';' {
+				p.i++
+			}
+			if err := endCommand(false); err != nil {
+				return err
+			}
+		case c == '&' && p.peek(1) == '&', c == '|' && p.peek(1) == '|':
+			p.i += 2
+			if err := endCommand(false); err != nil {
+				return err
+			}
+		case c == '&' && p.peek(1) == '>':
+			if err := p.parseRedirect(b, depth); err != nil {
+				return err
+			}
+		case c == '&':
+			p.i++
+			if err := endCommand(true); err != nil {
+				return err
+			}
+		case c == '|':
+			p.i++
+			if p.peek(0) == '&' {
+				// |& also pipes stderr
+				p.i++
+			}
+			if err := endCommand(false); err != nil {
+				return err
+			}
+			b.piped = true
+		case c == '(':
+			p.i++
+			p.line.Subshell = true
+			if len(b.words) != 0 {
+				// A function definition, e.g. f() { ...; }
+				if p.peek(0) != ')' {
+					return fmt.Errorf("unexpected ( in command")
+				}
+				p.i++
+				p.line.ControlFlow = true
+				b = &commandBuilder{}
+				continue
+			}
+			if err := p.parseList(')', depth+1); err != nil {
+				return err
+			}
+		case c == ')' || (c == '`' && p.backtick):
+			if c != terminator {
+				return fmt.Errorf("unexpected %q in command", c)
+			}
+			p.i++
+			return endCommand(false)
+		case c == '<' || c == '>':
+			if err := p.parseRedirect(b, depth); err != nil {
+				return err
+			}
+		default:
+			word, err := p.parseWord(depth)
+			if err != nil {
+				return err
+			}
+			if p.peek(0) == '<' || p.peek(0) == '>' {
+				if isFileDescriptor(word) {
+					// The file descriptor of a redirection, e.g. the 2 in 2>/dev/null
+					if err := p.parseRedirect(b, depth); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			b.words = append(b.words, word)
+		}
+	}
+
+	if terminator != 0 {
+		return fmt.Errorf("missing %q in command", terminator)
+	}
+	return endCommand(false)
+}
+
+func (p *parser) peek(offset int) byte {
+	if p.i+offset < len(p.s) {
+		return p.s[p.i+offset]
+	}
+	return 0
+}
+
+var fileDescriptor = regexp.MustCompile(`^[0-9]+$`)
+
+func isFileDescriptor(word string) bool {
+	return fileDescriptor.MatchString(word)
+}
+
+// redirectOperators are the redirection operators, longest first.
+var redirectOperators = []string{"&>>", "<<<", "<<-", "&>", ">>", ">&", ">|", "<<", "<&", "<>", "<", ">"}
+
+// parseRedirect parses a redirection, and its target.
+func (p *parser) parseRedirect(b *commandBuilder, depth int) error {
+	op := ""
+	for _, candidate := range redirectOperators {
+		if strings.HasPrefix(p.s[p.i:], candidate) {
+			op = candidate
+			break
+		}
+	}
+	p.i += len(op)
+	for p.peek(0) == ' ' || p.peek(0) == '\t' {
+		p.i++
+	}
+	if p.i >= len(p.s) || strings.IndexByte(" \t\n;&|()<>", p.s[p.i]) >= 0 {
+		return fmt.Errorf("missing target of %s redirection", op)
+	}
+
+	if op == "<<" || op == "<<-" {
+		// The delimiter is taken literally, so we read it without expanding it
+		start := p.i
+		delimiter, err := p.parseWord(depth)
+		if err != nil {
+			return err
+		}
+		if strings.ContainsAny(p.s[start:p.i], "'\"\\") {
+			delimiter = strings.NewReplacer("'", "", "\"", "", "\\", "").Replace(p.s[start:p.i])
+		}
+		if b.command == nil {
+			b.command = &Command{}
+		}
+		p.heredocs = append(p.heredocs, &pendingHeredoc{command: b.command, delimiter: delimiter, stripTabs: op == "<<-"})
+		return nil
+	}
+
+	target, err := p.parseWord(depth)
+	if err != nil {
+		return err
+	}
+	if op == "<<<" {
+		if b.command == nil {
+			b.command = &Command{}
+		}
+		b.command.Heredoc = target + "\n"
+		return nil
+	}
+	if op == "<&" || op == ">&" {
+		target = strings.TrimSuffix(target, "-")
+	}
+	b.redirects = append(b.redirects, Redirect{Op: op, Target: target})
+	return nil
+}
+
+// readHeredocs reads the bodies of pending here-documents, which follow the line that started them.
+func (p *parser) readHeredocs() error {
+	for len(p.heredocs) != 0 {
+		heredoc := p.heredocs[0]
+		var body strings.Builder
+		for {
+			if p.i >= len(p.s) {
+				return fmt.Errorf("here-document is not terminated by %q", heredoc.delimiter)
+			}
+			end := strings.IndexByte(p.s[p.i:], '\n')
+			var line string
+			if end < 0 {
+				line = p.s[p.i:]
+				p.i = len(p.s)
+			} else {
+				line = p.s[p.i : p.i+end]
+				p.i += end + 1
+			}
+			if heredoc.stripTabs {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == heredoc.delimiter {
+				break
+			}
+			body.WriteString(line)
+			body.WriteByte('\n')
+		}
+		heredoc.command.Heredoc = body.String()
+		p.heredocs = p.heredocs[1:]
+	}
+	return nil
+}
+
+// parseWord parses a word, removing quotes. Command substitutions in the word are parsed as commands,
+// and left in the word as written.
+func (p *parser) parseWord(depth int) (string, error) {
+	var word strings.Builder
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		switch {
+		case strings.IndexByte(" \t\r\n;&|()<>", c) >= 0, c == '`' && p.backtick:
+			return word.String(), nil
+		case c == '\'':
+			end := strings.IndexByte(p.s[p.i+1:], '\'')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated quote in command")
+			}
+			word.WriteString(p.s[p.i+1 : p.i+1+end])
+			p.i += end + 2
+		case c == '"':
+			p.i++
+			if err := p.parseDoubleQuoted(&word, depth); err != nil {
+				return "", err
+			}
+		case c == '\\':
+			if p.peek(1) == '\n' {
+				p.i += 2
+				continue
+			}
+			if p.i+1 < len(p.s) {
+				word.WriteByte(p.s[p.i+1])
+			}
+			p.i += 2
+		case c == '$' && p.peek(1) == '(' && p.peek(2) == '(':
+			// Arithmetic expansion, which does not run commands
+			end := strings.Index(p.s[p.i:], "))")
+			if end < 0 {
+				return "", fmt.Errorf("unterminated arithmetic expansion in command")
+			}
+			word.WriteString(p.s[p.i : p.i+end+2])
+			p.i += end + 2
+		case c == '$' && p.peek(1) == '(', c == '`':
+			if err := p.parseSubstitution(&word, depth); err != nil {
+				return "", err
+			}
+		case c == '$' && p.peek(1) == '\'':
+			// ANSI-C quoting; escapes are kept as written
+			end := strings.IndexByte(p.s[p.i+2:], '\'')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated quote in command")
+			}
+			word.WriteString(p.s[p.i+2 : p.i+2+end])
+			p.i += end + 3
+		default:
+			word.WriteByte(c)
+			p.i++
+		}
+	}
+	return word.String(), nil
+}
+
+// parseDoubleQuoted parses the rest of a double-quoted string, after the opening quote.
+func (p *parser) parseDoubleQuoted(word *strings.Builder, depth int) error {
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		switch {
+		case c == '"':
+			p.i++
+			return nil
+		case c == '\\' && p.i+1 < len(p.s) && strings.IndexByte("\\\"$`\n", p.s[p.i+1]) >= 0:
+			if p.s[p.i+1] != '\n' {
+				word.WriteByte(p.s[p.i+1])
+			}
+			p.i += 2
+		case c == '$' && p.peek(1) == '(' && p.peek(2) != '(', c == '`':
+			if err := p.parseSubstitution(word, depth); err != nil {
+				return err
+			}
+		default:
+			word.WriteByte(c)
+			p.i++
+		}
+	}
+	return fmt.Errorf("unterminated quote in command")
+}
+
+// parseSubstitution parses a command substitution, $(...) or `...`, adding its commands to the command line.
+func (p *parser) parseSubstitution(word *strings.Builder, depth int) error {
+	start := p.i
+	terminator := byte(')')
+	if p.s[p.i] == '`' {
+		terminator = '`'
+		p.i++
+	} else {
+		p.i += 2
+	}
+	p.line.Substitution = true
+	backtick := p.backtick
+	p.backtick = terminator == '`'
+	err := p.parseList(terminator, depth+1)
+	p.backtick = backtick
+	if err != nil {
+		return err
+	}
+	word.WriteString(p.s[start:p.i])
+	return nil
+}
+
+// reservedWords start or end compound commands; the command that follows them is still run.
+var reservedWords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true,
+	"while": true, "until": true, "do": true, "done": true,
+	"{": true, "}": true, "!": true, "time": true, "esac": true,
+}
+
+// variableAssignment matches an assignment before a command, e.g. FOO=bar.
+var variableAssignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// endCommand finishes the simple command being built, adding it to the command line.
+func (p *parser) endCommand(b *commandBuilder, depth int) (*Command, error) {
+	if b.empty() {
+		return nil, nil
+	}
+
+	words := b.words
+	for len(words) != 0 && reservedWords[words[0]] {
+		switch words[0] {
+		case "{", "}":
+			p.line.Subshell = true
+		case "time", "!":
+		default:
+			p.line.ControlFlow = true
+		}
+		words = words[1:]
+	}
+	if len(words) != 0 {
+		switch words[0] {
+		case "for", "select", "case", "function":
+			// The rest of the words are a variable, values or patterns, not a command
+			p.line.ControlFlow = true
+			words = nil
+		}
+	}
+	// The closing } of a group can end the command, as in `{ kubectl get pods; }`
+	if n := len(words); n != 0 && words[n-1] == "}" {
+		p.line.Subshell = true
+		words = words[:n-1]
+	}
+
+	command := b.command
+	if command == nil {
+		command = &Command{}
+	}
+	for len(words) != 0 && variableAssignment.MatchString(words[0]) {
+		command.Env = append(command.Env, words[0])
+		words = words[1:]
+	}
+	if len(words) == 0 && len(command.Env) == 0 && len(b.redirects) == 0 && command.Heredoc == "" && b.command == nil {
+		return nil, nil
+	}
+	if len(words) != 0 {
+		command.Name = words[0]
+		command.Args = words[1:]
+	}
+	command.Redirects = b.redirects
+	command.Piped = b.piped
+	p.line.Commands = append(p.line.Commands, command)
+
+	if err := p.unwrap(command, words, depth); err != nil {
+		return nil, err
+	}
+	return command, nil
+}
+
+// kubectlPrograms are programs that take the same commands as kubectl.
+var kubectlPrograms = map[string]bool{
+	"kubectl": true, "kubectl.exe": true, "oc": true, "kubecolor": true,
+}
+
+// clusterPrograms are programs that may change the cluster, but whose commands we don't classify.
+// "k" is a common name for a kubectl alias or wrapper script, which we can't see.
+var clusterPrograms = map[string]bool{
+	"k": true, "helm": true, "kubectl-ai": true,
+	"flux": true, "argocd": true, "istioctl": true, "kn": true, "velero": true, "kapp": true,
+	"source": true, ".": true,
+}
+
+// isOpaqueProgram returns true if we can't tell what running the program (as written) does to the cluster.
+func isOpaqueProgram(program string) bool {
+	name := filepath.Base(program)
+	switch {
+	case strings.ContainsAny(program, "$`"):
+		// Expanded when the command runs, e.g. $KUBECTL or $(which kubectl)
+		return true
+	case strings.HasPrefix(program, "./"), strings.HasPrefix(program, "../"):
+		// A script or program of the user's
+		return true
+	case strings.HasPrefix(name, "kubectl-"):
+		// A kubectl plugin
+		return true
+	}
+	return clusterPrograms[name]
+}
+
+// unwrap finds the program run by a command, looking through wrappers such as xargs, env and timeout,
+// and parses the scripts run by shells (bash -c) and watch.
+func (p *parser) unwrap(command *Command, argv []string, depth int) error {
+	for len(argv) != 0 {
+		if depth > maxDepth {
+			return fmt.Errorf("command is nested too deeply")
+		}
+		depth++
+
+		name := filepath.Base(argv[0])
+		args := argv[1:]
+		if kubectlPrograms[name] && !strings.ContainsAny(argv[0], "$`") {
+			command.Kubectl = ParseInvocation(args)
+			return nil
+		}
+		if isOpaqueProgram(argv[0]) {
+			command.Opaque = true
+			return nil
+		}
+		switch name {
+		case "bash", "sh", "zsh", "dash", "ksh":
+			for i, arg := range args {
+				if arg == "-c" && i+1 < len(args) {
+					return p.parseScript(args[i+1], depth)
+				}
+				if !strings.HasPrefix(arg, "-") {
+					break
+				}
+			}
+			// A script from a file or standard input
+			command.Opaque = true
+			return nil
+		case "eval":
+			return p.parseScript(strings.Join(args, " "), depth)
+		case "watch":
+			// watch runs its arguments with sh -c
+			args = skipOptions(args, "-n", "--interval", "-d", "-g", "-t", "-x", "-e", "-b", "-c", "-p", "-w")
+			if len(args) == 0 {
+				return nil
+			}
+			return p.parseScript(strings.Join(args, " "), depth)
+		case "xargs":
+			argv = skipOptions(args, "-I", "-i", "-n", "-P", "-L", "-l", "-s", "-d", "-E", "-e", "-a",
+				"--max-args", "--max-procs", "--max-lines", "--max-chars", "--delimiter", "--replace", "--arg-file", "--eof")
+		case "env":
+			argv = skipOptions(args, "-u", "--unset", "-C", "--chdir", "-S", "--split-string")
+			for len(argv) != 0 && variableAssignment.MatchString(argv[0]) {
+				command.Env = append(command.Env, argv[0])
+				argv = argv[1:]
+			}
+		case "timeout":
+			argv = skipOptions(args, "-s", "--signal", "-k", "--kill-after")
+			if len(argv) != 0 {
+				// The duration
+				argv = argv[1:]
+			}
+		case "sudo":
+			argv = skipOptions(args, "-u", "--user", "-g", "--group", "-C", "-D", "-h", "-p", "-r", "-t", "-U")
+		case "nice":
+			argv = skipOptions(args, "-n", "--adjustment")
+		case "stdbuf":
+			argv = skipOptions(args, "-i", "-o", "-e")
+		case "nohup", "command", "exec", "builtin", "time":
+			argv = skipOptions(args)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// parseScript parses a script run by another command, such as `bash -c`, adding its commands to the command line.
+func (p *parser) parseScript(script string, depth int) error {
+	inner := &parser{s: script, line: p.line}
+	if err := inner.parseList(0, depth+1); err != nil {
+		return err
+	}
+	if len(inner.heredocs) != 0 {
+		return fmt.Errorf("here-document is not terminated by %q", inner.heredocs[0].delimiter)
+	}
+	return nil
+}
+
+// skipOptions skips the options at the start of args, where optionsWithValues take a separate value.
+func skipOptions(args []string, optionsWithValues ...string) []string {
+	for len(args) != 0 {
+		arg := args[0]
+		if arg == "--" {
+			return args[1:]
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return args
+		}
+		args = args[1:]
+		for _, option := range optionsWithValues {
+			if arg == option && len(args) != 0 {
+				args = args[1:]
+				break
+			}
+		}
+	}
+	return args
+}
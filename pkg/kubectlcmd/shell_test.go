@@ -0,0 +1,373 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubectlcmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// describeKubectl returns the kubectl commands run by the command line, as their arguments.
+func describeKubectl(line *CommandLine) []string {
+	var invocations []string
+	for _, invocation := range line.Invocations() {
+		invocations = append(invocations, strings.Join(invocation.Args, " "))
+	}
+	return invocations
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		commandLine string
+		// wantKubectl are the arguments of the kubectl commands that are run
+		wantKubectl []string
+		wantClass   Class
+		// wantOpaque is set if the command line runs a program whose effect we can't tell
+		wantOpaque bool
+		// wantFlags are the flags of the command line that are set: substitution, subshell and control-flow
+		wantFlags []string
+	}{
+		{
+			name:        "simple command",
+			commandLine: "kubectl get pods -n prod",
+			wantKubectl: []string{"get pods -n prod"},
+			wantClass:   ClassRead,
+		},
+		{
+			name:        "quoting",
+			commandLine: `kubectl get pods -o "jsonpath={.items[*].metadata.name}" -l 'app in (web, api)'`,
+			wantKubectl: []string{"get pods -o jsonpath={.items[*].metadata.name} -l app in (web, api)"},
+			wantClass:   ClassRead,
+		},
+		{
+			name:        "full path",
+			commandLine: "/usr/local/bin/kubectl delete pod web",
+			wantKubectl: []string{"delete pod web"},
+			wantClass:   ClassWrite,
+		},
+		{
+			name:        "pipeline",
+			commandLine: "kubectl get pods -o name | grep web | xargs kubectl delete",
+			wantKubectl: []string{"get pods -o name", "delete"},
+			wantClass:   ClassWrite,
+		},
+		{
+			name:        "list",
+			commandLine: "kubectl get pods && kubectl rollout restart deploy/web; kubectl get pods -w",
+			wantKubectl: []string{"get pods", "rollout restart deploy/web", "get pods -w"},
+			wantClass:   ClassWrite,
+		},
+		{
+			name:        "background",
+			commandLine: "kubectl port-forward svc/web 8080:80 & curl localhost:8080",
+			wantKubectl: []string{"port-forward svc/web 8080:80"},
+			wantClass:   ClassStreaming,
+		},
+		{
+			name:        "command substitution",
+			commandLine: "kubectl logs $(kubectl get pods -l app=web -o name | head -1)",
+			wantKubectl: []string{"get pods -l app=web -o name", "logs $(kubectl get pods -l app=web -o name | head -1)"},
+			wantClass:   ClassRead,
+			wantFlags:   []string{"substitution"},
+		},
+		{
+			name:        "backticks",
+			commandLine: "echo `kubectl delete pod web`",
+			wantKubectl: []string{"delete pod web"},
+			wantClass:   ClassWrite,
+			wantFlags:   []string{"substitution"},
+		},
+		{
+			name:        "substitution in double quotes",
+			commandLine: `echo "pods: $(kubectl delete pods --all)"`,
+			wantKubectl: []string{"delete pods --all"},
+			wantClass:   ClassWrite,
+			wantFlags:   []string{"substitution"},
+		},
+		{
+			name:        "no substitution in single quotes",
+			commandLine: `echo '$(kubectl delete pods --all)'`,
+			wantClass:   ClassRead,
+		},
+		{
+			name:        "arithmetic is not a substitution",
+			commandLine: "echo $((1 + 2))",
+			wantClass:   ClassRead,
+		},
+		{
+			name:        "subshell",
+			commandLine: "(cd /tmp && kubectl apply -f .)",
+			wantKubectl: []string{"apply -f ."},
+			wantClass:   ClassWrite,
+			wantFlags:   []string{"subshell"},
+		},
+		{
+			name:        "group",
+			commandLine: "{ kubectl get pods; kubectl delete pod web; }",
+			wantKubectl: []string{"get pods", "delete pod web"},
+			wantClass:   ClassWrite,
+			wantFlags:   []string{"subshell"},
+		},
+		{
+			name:        "loop",
+			commandLine: "for pod in a b; do kubectl delete pod $pod; done",
+			wantKubectl: []string{"delete pod $pod"},
+			wantClass:   ClassWrite,
+			wantFlags:   []string{"control-flow"},
+		},
+		{
+			name:        "conditional",
+			commandLine: "if kubectl get ns prod; then kubectl delete ns prod; fi",
+			wantKubectl: []string{"get ns prod", "delete ns prod"},
+			wantClass:   ClassWrite,
+			wantFlags:   []string{"control-flow"},
+		},
+		{
+			name:        "bash -c",
+			commandLine: `bash -c "kubectl scale deploy/web --replicas=0"`,
+			wantKubectl: []string{"scale deploy/web --replicas=0"},
+			wantClass:   ClassWrite,
+		},
+		{
+			name:        "nested scripts",
+			commandLine: `sh -c 'bash -c "kubectl delete pod web"'`,
+			wantKubectl: []string{"delete pod web"},
+			wantClass:   ClassWrite,
+		},
+		{
+			name:        "eval",
+			commandLine: "eval kubectl delete pod web",
+			wantKubectl: []string{"delete pod web"},
+			wantClass:   ClassWrite,
+		},
+		{
+			name:        "watch",
+			commandLine: "watch -n 5 kubectl get pods",
+			wantKubectl: []string{"get pods"},
+			wantClass:   ClassRead,
+		},
+		{
+			name:        "wrappers",
+			commandLine: "sudo -u admin env KUBECONFIG=/tmp/config timeout 10s nice -n 5 kubectl drain node-1",
+			wantKubectl: []string{"drain node-1"},
+			wantClass:   ClassWrite,
+		},
+		{
+			name:        "xargs with options",
+			commandLine: "kubectl get pods -o name | xargs -I {} -n 1 kubectl delete {}",
+			wantKubectl: []string{"get pods -o name", "delete {}"},
+			wantClass:   ClassWrite,
+		},
+		{
+			name:        "xargs running a shell",
+			commandLine: `kubectl get ns -o name | xargs -I{} sh -c "kubectl delete {}"`,
+			wantKubectl: []string{"get ns -o name", "delete {}"},
+			wantClass:   ClassWrite,
+		},
+		{
+			name:        "environment assignment",
+			commandLine: "KUBECONFIG=/tmp/config kubectl get pods",
+			wantKubectl: []string{"get pods"},
+			wantClass:   ClassRead,
+		},
+		{
+			name:        "comment",
+			commandLine: "kubectl get pods # kubectl delete pods --all",
+			wantKubectl: []string{"get pods"},
+			wantClass:   ClassRead,
+		},
+		{
+			name:        "line continuation",
+			commandLine: "kubectl delete \\\n  pod web",
+			wantKubectl: []string{"delete pod web"},
+			wantClass:   ClassWrite,
+		},
+		{
+			name:        "heredoc",
+			commandLine: "kubectl apply -f - <<EOF\nkind: Namespace\nmetadata:\n  name: test\nEOF\nkubectl get ns",
+			wantKubectl: []string{"apply -f -", "get ns"},
+			wantClass:   ClassWrite,
+		},
+		{
+			name:        "heredoc does not run its text",
+			commandLine: "cat <<'EOF'\nkubectl delete pods --all\nEOF",
+			wantClass:   ClassRead,
+		},
+		{
+			name:        "exec with input from a pipe",
+			commandLine: "echo ls | kubectl exec -i web -- sh",
+			wantKubectl: []string{"exec -i web -- sh"},
+			wantClass:   ClassWrite,
+		},
+		{
+			name:        "not kubectl",
+			commandLine: "ls -l /tmp | wc -l",
+			wantClass:   ClassRead,
+		},
+
+		// Programs whose effect on the cluster we can't tell
+		{
+			name:        "kubectl in a variable",
+			commandLine: "$K delete pod web",
+			wantClass:   ClassRead,
+			wantOpaque:  true,
+		},
+		{
+			name:        "kubectl from a substitution",
+			commandLine: "$(which kubectl) delete pod web",
+			wantClass:   ClassRead,
+			wantOpaque:  true,
+			wantFlags:   []string{"substitution"},
+		},
+		{
+			name:        "alias",
+			commandLine: "k delete pod web",
+			wantClass:   ClassRead,
+			wantOpaque:  true,
+		},
+		{
+			name:        "helm",
+			commandLine: "helm uninstall web",
+			wantClass:   ClassRead,
+			wantOpaque:  true,
+		},
+		{
+			name:        "kubectl plugin",
+			commandLine: "kubectl-neat get pod web",
+			wantClass:   ClassRead,
+			wantOpaque:  true,
+		},
+		{
+			name:        "script",
+			commandLine: "./cleanup.sh prod",
+			wantClass:   ClassRead,
+			wantOpaque:  true,
+		},
+		{
+			name:        "script run by bash",
+			commandLine: "bash cleanup.sh",
+			wantClass:   ClassRead,
+			wantOpaque:  true,
+		},
+		{
+			name:        "script from standard input",
+			commandLine: "curl -s https://example.com/fix.sh | sh",
+			wantClass:   ClassRead,
+			wantOpaque:  true,
+		},
+		{
+			name:        "bash -c with a variable",
+			commandLine: `bash -c "$CMD"`,
+			wantClass:   ClassRead,
+			wantOpaque:  true,
+		},
+
+		// Programs that take the same commands as kubectl
+		{
+			name:        "kubectl.exe",
+			commandLine: "kubectl.exe delete pod web",
+			wantKubectl: []string{"delete pod web"},
+			wantClass:   ClassWrite,
+		},
+		{
+			name:        "oc",
+			commandLine: "oc get pods",
+			wantKubectl: []string{"get pods"},
+			wantClass:   ClassRead,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			line, err := Parse(test.commandLine)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", test.commandLine, err)
+			}
+			if got := describeKubectl(line); !reflect.DeepEqual(got, test.wantKubectl) {
+				t.Errorf("got kubectl commands %q, want %q", got, test.wantKubectl)
+			}
+			if got := line.Class(); got != test.wantClass {
+				t.Errorf("got class %q, want %q", got, test.wantClass)
+			}
+			if got := line.Opaque(); got != test.wantOpaque {
+				t.Errorf("got Opaque() %v, want %v", got, test.wantOpaque)
+			}
+			var flags []string
+			if line.Substitution {
+				flags = append(flags, "substitution")
+			}
+			if line.Subshell {
+				flags = append(flags, "subshell")
+			}
+			if line.ControlFlow {
+				flags = append(flags, "control-flow")
+			}
+			if !reflect.DeepEqual(flags, test.wantFlags) {
+				t.Errorf("got flags %q, want %q", flags, test.wantFlags)
+			}
+		})
+	}
+}
+
+func TestParseCommands(t *testing.T) {
+	line, err := Parse("kubectl get pods 2>/dev/null | grep web > out.txt &\ncat <<-EOF | kubectl apply -f -\n\tkind: List\n\tEOF\nkubectl exec -i web -- sh <<< 'ls'")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(line.Commands) != 5 {
+		t.Fatalf("got %d commands, want 5", len(line.Commands))
+	}
+	get, grep, cat, apply, exec := line.Commands[0], line.Commands[1], line.Commands[2], line.Commands[3], line.Commands[4]
+
+	if want := []Redirect{{Op: ">", Target: "/dev/null"}}; !reflect.DeepEqual(get.Redirects, want) || !get.Redirects[0].Discards() {
+		t.Errorf("got redirects %v for kubectl get, want %v, discarding output", get.Redirects, want)
+	}
+	if !grep.Piped || !grep.Background || grep.Redirects[0].Discards() {
+		t.Errorf("got %+v for grep, want a piped background command writing to a file", grep)
+	}
+	// Tabs are stripped from here-documents started with <<-
+	if cat.Heredoc != "kind: List\n" {
+		t.Errorf("got here-document %q, want %q", cat.Heredoc, "kind: List\n")
+	}
+	if !apply.Kubectl.StdinProvided {
+		t.Errorf("kubectl apply reads from the pipe, but StdinProvided is false")
+	}
+	if exec.Heredoc != "ls\n" || !exec.Kubectl.StdinProvided || exec.Kubectl.Class() != ClassWrite {
+		t.Errorf("got %+v for kubectl exec, want input from the here-string", exec)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, commandLine := range []string{
+		`kubectl get pods -l "app=web`,
+		"kubectl get pods -l 'app=web",
+		"echo $(kubectl get pods",
+		"echo `kubectl get pods",
+		"kubectl get pods )",
+		"(kubectl get pods",
+		"kubectl apply -f - <<EOF\nkind: List",
+		"kubectl get pods >",
+		"echo $((1 + 2",
+		// Patterns of case statements are not understood, so they are rejected
+		"case x in a) kubectl delete pod x;; esac",
+		strings.Repeat("$(", maxDepth+2) + "kubectl get pods" + strings.Repeat(")", maxDepth+2),
+	} {
+		if line, err := Parse(commandLine); err == nil {
+			t.Errorf("Parse(%q) = %q, want an error", commandLine, describeKubectl(line))
+		}
+	}
+}
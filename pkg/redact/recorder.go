@@ -0,0 +1,75 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+)
+
+// Recorder is a journal.Recorder that masks secrets in the payload of each event before passing it on.
+// Tool requests and responses are redacted with the rules of their tool, other events with the global rules.
+type Recorder struct {
+	inner    journal.Recorder
+	redactor *Redactor
+}
+
+var _ journal.Recorder = &Recorder{}
+
+// NewRecorder returns a recorder that redacts events, and writes them to inner.
+func NewRecorder(inner journal.Recorder, redactor *Redactor) *Recorder {
+	return &Recorder{inner: inner, redactor: redactor}
+}
+
+// Close closes the underlying recorder.
+func (r *Recorder) Close() error {
+	return r.inner.Close()
+}
+
+func (r *Recorder) Write(ctx context.Context, event *journal.Event) error {
+	if event.Payload == nil {
+		return r.inner.Write(ctx, event)
+	}
+
+	// Payloads are arbitrary types, so we redact their JSON form
+	b, err := json.Marshal(event.Payload)
+	if err != nil {
+		return fmt.Errorf("marshalling event: %w", err)
+	}
+	var payload any
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return fmt.Errorf("unmarshalling event: %w", err)
+	}
+	payload, _ = r.redactor.Value(eventTool(event.Action, payload), payload)
+
+	return r.inner.Write(ctx, &journal.Event{
+		Timestamp: event.Timestamp,
+		Action:    event.Action,
+		Payload:   payload,
+	})
+}
+
+// eventTool returns the tool that a tool-request or tool-response event is about, or "" for other events.
+func eventTool(action string, payload any) string {
+	if action != "tool-request" && action != "tool-response" {
+		return ""
+	}
+	m, _ := payload.(map[string]any)
+	tool, _ := m["name"].(string)
+	return tool
+}
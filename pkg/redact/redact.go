@@ -0,0 +1,291 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redact masks secrets (such as the data of Kubernetes Secrets, bearer tokens, private keys and
+// cloud credentials) in tool results, before they are sent to the LLM or written to a trace.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// Marker replaces each redacted value.
+const Marker = "[REDACTED]"
+
+// Config configures redaction. The zero value redacts with all the built-in rules.
+type Config struct {
+	// Disabled turns off redaction entirely.
+	Disabled bool `json:"disabled,omitempty"`
+	// DisabledRules are built-in rules (by name) that are not applied.
+	DisabledRules []string `json:"disabledRules,omitempty"`
+	// Patterns are additional values to redact.
+	Patterns []Pattern `json:"patterns,omitempty"`
+	// Tools overrides the configuration for the results of individual tools, keyed by tool name.
+	Tools map[string]ToolConfig `json:"tools,omitempty"`
+}
+
+// ToolConfig configures redaction of the results of a single tool, in addition to the global Config.
+type ToolConfig struct {
+	// Disabled turns off redaction of the tool's results.
+	Disabled bool `json:"disabled,omitempty"`
+	// DisabledRules are built-in rules (by name) that are not applied to the tool's results.
+	DisabledRules []string `json:"disabledRules,omitempty"`
+	// Patterns are additional values to redact in the tool's results.
+	Patterns []Pattern `json:"patterns,omitempty"`
+}
+
+// Pattern is a user-defined regular expression to redact. If the expression has capture groups,
+// only the text they match is redacted (e.g. the value in `password=(\S+)`); otherwise the whole match is.
+type Pattern struct {
+	Name   string `json:"name,omitempty"`
+	Regexp string `json:"regexp"`
+}
+
+// rule is a regular expression of values to redact.
+type rule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// secretDataRule is the name of the rule that masks the data of Kubernetes Secrets, which is structural rather than a regexp.
+const secretDataRule = "secret-data"
+
+// builtinRules are the rules applied unless disabled. Where a rule has capture groups, only the groups are redacted,
+// so that the LLM can still see which field held a secret.
+var builtinRules = []rule{
+	{"private-key", regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----`)},
+	{"bearer-token", regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]{8,}=*)`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]+`)},
+	// kubeconfig credentials, e.g. from `kubectl config view --raw`
+	{"kubeconfig-credentials", regexp.MustCompile(`(?m)^\s*(?:token|client-key-data|password|id-token|refresh-token|access-token):\s*["']?([^\s"']+)`)},
+	{"aws-access-key", regexp.MustCompile(`\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`)},
+	{"aws-secret-key", regexp.MustCompile(`(?i)\baws_secret_access_key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{20,})`)},
+	{"gcp-api-key", regexp.MustCompile(`\b(AIza[0-9A-Za-z_-]{35})`)},
+	{"azure-key", regexp.MustCompile(`(?i)\b(?:AccountKey|SharedAccessKey|SharedAccessSignature)=([^;\s"']+)`)},
+	{"github-token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})`)},
+	{"slack-token", regexp.MustCompile(`\b(xox[abposr]-[A-Za-z0-9-]{10,})`)},
+	// Environment variables and settings whose names suggest a secret, e.g. from `env` or `kubectl set env --list`
+	{"secret-variable", regexp.MustCompile(`(?i)\b[A-Z0-9_]*(?:SECRET|PASSWORD|PASSWD|TOKEN|API_KEY|APIKEY|ACCESS_KEY|PRIVATE_KEY|CREDENTIALS?)[A-Z0-9_]*=([^\s"']+)`)},
+}
+
+// RuleNames returns the names of the built-in rules.
+func RuleNames() []string {
+	names := []string{secretDataRule}
+	for _, rule := range builtinRules {
+		names = append(names, rule.name)
+	}
+	return names
+}
+
+// Redactor masks secrets in text and structured values.
+type Redactor struct {
+	// rules are the rules for each tool, and for "" (no specific tool)
+	rules map[string]*toolRules
+}
+
+// toolRules are the rules that apply to a tool.
+type toolRules struct {
+	disabled   bool
+	secretData bool
+	rules      []rule
+}
+
+// New builds a redactor for the configuration, returning an error if a pattern is not valid.
+func New(config Config) (*Redactor, error) {
+	r := &Redactor{rules: make(map[string]*toolRules)}
+
+	if err := checkRuleNames(config.DisabledRules); err != nil {
+		return nil, err
+	}
+	global, err := compilePatterns(config.Patterns)
+	if err != nil {
+		return nil, err
+	}
+	r.rules[""] = buildRules(config.Disabled, config.DisabledRules, global)
+
+	for tool, toolConfig := range config.Tools {
+		patterns, err := compilePatterns(toolConfig.Patterns)
+		if err != nil {
+			return nil, fmt.Errorf("tool %q: %w", tool, err)
+		}
+		if err := checkRuleNames(toolConfig.DisabledRules); err != nil {
+			return nil, fmt.Errorf("tool %q: %w", tool, err)
+		}
+		disabledRules := append(slices.Clone(config.DisabledRules), toolConfig.DisabledRules...)
+		r.rules[tool] = buildRules(config.Disabled || toolConfig.Disabled, disabledRules, append(slices.Clone(global), patterns...))
+	}
+	return r, nil
+}
+
+// checkRuleNames returns an error if any of the names is not a built-in rule.
+func checkRuleNames(names []string) error {
+	known := RuleNames()
+	for _, name := range names {
+		if !slices.Contains(known, name) {
+			return fmt.Errorf("unknown redaction rule %q (the rules are %q)", name, known)
+		}
+	}
+	return nil
+}
+
+func compilePatterns(patterns []Pattern) ([]rule, error) {
+	var rules []rule
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern.Regexp)
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %q is not valid: %w", pattern.Regexp, err)
+		}
+		name := pattern.Name
+		if name == "" {
+			name = fmt.Sprintf("pattern-%d", i+1)
+		}
+		rules = append(rules, rule{name: name, re: re})
+	}
+	return rules, nil
+}
+
+func buildRules(disabled bool, disabledRules []string, patterns []rule) *toolRules {
+	if disabled {
+		return &toolRules{disabled: true}
+	}
+	t := &toolRules{secretData: !slices.Contains(disabledRules, secretDataRule)}
+	for _, rule := range builtinRules {
+		if !slices.Contains(disabledRules, rule.name) {
+			t.rules = append(t.rules, rule)
+		}
+	}
+	t.rules = append(t.rules, patterns...)
+	return t
+}
+
+// rulesFor returns the rules for a tool ("" for text that is not from a specific tool).
+func (r *Redactor) rulesFor(tool string) *toolRules {
+	if rules, ok := r.rules[tool]; ok {
+		return rules
+	}
+	return r.rules[""]
+}
+
+// String masks the secrets in s (e.g. the output of a command run by tool), returning the masked text
+// and the number of values that were masked.
+func (r *Redactor) String(tool string, s string) (string, int) {
+	if r == nil {
+		return s, 0
+	}
+	rules := r.rulesFor(tool)
+	if rules.disabled {
+		return s, 0
+	}
+
+	count := 0
+	if rules.secretData {
+		var n int
+		s, n = redactSecretManifests(s)
+		count += n
+	}
+	for _, rule := range rules.rules {
+		var n int
+		s, n = applyRule(rule.re, s)
+		count += n
+	}
+	return s, count
+}
+
+// Value masks the secrets in a value decoded from JSON (maps, slices and strings), such as a tool result
+// rendered for the LLM. It returns the masked copy, and the number of values that were masked.
+func (r *Redactor) Value(tool string, v any) (any, int) {
+	if r == nil || r.rulesFor(tool).disabled {
+		return v, 0
+	}
+	return r.value(tool, v)
+}
+
+func (r *Redactor) value(tool string, v any) (any, int) {
+	switch v := v.(type) {
+	case string:
+		return r.String(tool, v)
+	case map[string]any:
+		count := 0
+		if r.rulesFor(tool).secretData && isSecret(v) {
+			v, count = redactSecretObject(v)
+		}
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			masked, n := r.value(tool, value)
+			out[key] = masked
+			count += n
+		}
+		return out, count
+	case []any:
+		count := 0
+		out := make([]any, len(v))
+		for i, value := range v {
+			masked, n := r.value(tool, value)
+			out[i] = masked
+			count += n
+		}
+		return out, count
+	default:
+		return v, 0
+	}
+}
+
+// Note describes how many values were masked, for the LLM.
+func Note(count int) string {
+	if count == 1 {
+		return "1 value was redacted (replaced with " + Marker + ") because it looked like a secret; it cannot be recovered"
+	}
+	return fmt.Sprintf("%d values were redacted (replaced with %s) because they looked like secrets; they cannot be recovered", count, Marker)
+}
+
+// applyRule replaces the matches of re in s (or just their capture groups, if it has any) with Marker.
+func applyRule(re *regexp.Regexp, s string) (string, int) {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, 0
+	}
+
+	count := 0
+	var out []byte
+	last := 0
+	for _, match := range matches {
+		// Redact the capture groups, or the whole match if there are none
+		spans := [][2]int{{match[0], match[1]}}
+		if len(match) > 2 {
+			spans = nil
+			for i := 2; i+1 < len(match); i += 2 {
+				if match[i] >= 0 && match[i+1] > match[i] {
+					spans = append(spans, [2]int{match[i], match[i+1]})
+				}
+			}
+		}
+		for _, span := range spans {
+			if span[0] < last {
+				continue
+			}
+			if s[span[0]:span[1]] == Marker {
+				// Already redacted by another rule
+				continue
+			}
+			out = append(out, s[last:span[0]]...)
+			out = append(out, Marker...)
+			last = span[1]
+			count++
+		}
+	}
+	out = append(out, s[last:]...)
+	return string(out), count
+}
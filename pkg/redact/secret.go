@@ -0,0 +1,171 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// lastAppliedAnnotation holds the previous manifest of an object applied with `kubectl apply`, which includes the data of Secrets.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// yamlSeparator splits a YAML stream into documents.
+var yamlSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+
+// isSecret returns true if m is a Kubernetes Secret.
+func isSecret(m map[string]any) bool {
+	kind, _ := m["kind"].(string)
+	return kind == "Secret"
+}
+
+// redactSecretObject returns a copy of the Secret with the values of its data and stringData masked,
+// and the number of values that were masked.
+func redactSecretObject(secret map[string]any) (map[string]any, int) {
+	out := make(map[string]any, len(secret))
+	for key, value := range secret {
+		out[key] = value
+	}
+
+	count := 0
+	for _, field := range []string{"data", "stringData"} {
+		data, ok := secret[field].(map[string]any)
+		if !ok {
+			continue
+		}
+		masked := make(map[string]any, len(data))
+		for key, value := range data {
+			if s, ok := value.(string); ok && (s == "" || s == Marker) {
+				masked[key] = value
+				continue
+			}
+			masked[key] = Marker
+			count++
+		}
+		out[field] = masked
+	}
+
+	metadata, _ := secret["metadata"].(map[string]any)
+	annotations, _ := metadata["annotations"].(map[string]any)
+	if lastApplied, ok := annotations[lastAppliedAnnotation].(string); ok {
+		var previous any
+		if err := json.Unmarshal([]byte(lastApplied), &previous); err == nil {
+			if previous, n := redactSecrets(previous); n != 0 {
+				if b, err := json.Marshal(previous); err == nil {
+					maskedAnnotations := make(map[string]any, len(annotations))
+					for key, value := range annotations {
+						maskedAnnotations[key] = value
+					}
+					maskedAnnotations[lastAppliedAnnotation] = string(b) + "\n"
+					maskedMetadata := make(map[string]any, len(metadata))
+					for key, value := range metadata {
+						maskedMetadata[key] = value
+					}
+					maskedMetadata["annotations"] = maskedAnnotations
+					out["metadata"] = maskedMetadata
+					count += n
+				}
+			}
+		}
+	}
+	return out, count
+}
+
+// redactSecrets masks the data of any Secrets in v (e.g. a Secret, or a List of them), returning the masked copy.
+func redactSecrets(v any) (any, int) {
+	switch v := v.(type) {
+	case map[string]any:
+		if isSecret(v) {
+			return redactSecretObject(v)
+		}
+		count := 0
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			masked, n := redactSecrets(value)
+			out[key] = masked
+			count += n
+		}
+		return out, count
+	case []any:
+		count := 0
+		out := make([]any, len(v))
+		for i, value := range v {
+			masked, n := redactSecrets(value)
+			out[i] = masked
+			count += n
+		}
+		return out, count
+	default:
+		return v, 0
+	}
+}
+
+// redactSecretManifests masks the data of Secrets in text holding JSON or YAML manifests, such as the output of
+// `kubectl get secret -o yaml`. Manifests that hold a Secret are re-encoded, so their formatting may change.
+func redactSecretManifests(s string) (string, int) {
+	if !strings.Contains(s, "Secret") {
+		return s, 0
+	}
+
+	if trimmed := strings.TrimSpace(s); strings.HasPrefix(trimmed, "{") {
+		var v any
+		if err := json.Unmarshal([]byte(trimmed), &v); err != nil {
+			return s, 0
+		}
+		v, count := redactSecrets(v)
+		if count == 0 {
+			return s, 0
+		}
+		b, err := json.MarshalIndent(v, "", "    ")
+		if err != nil {
+			return s, 0
+		}
+		return string(b) + "\n", count
+	}
+
+	docs := yamlSeparator.Split(s, -1)
+	count := 0
+	for i, doc := range docs {
+		if !strings.Contains(doc, "Secret") {
+			continue
+		}
+		var v any
+		if err := yaml.Unmarshal([]byte(doc), &v); err != nil {
+			continue
+		}
+		v, n := redactSecrets(v)
+		if n == 0 {
+			continue
+		}
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			continue
+		}
+		if i != 0 {
+			// Keep the newline that followed the separator
+			docs[i] = "\n" + string(b)
+		} else {
+			docs[i] = string(b)
+		}
+		count += n
+	}
+	if count == 0 {
+		return s, 0
+	}
+	return strings.Join(docs, "---"), count
+}
@@ -0,0 +1,197 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/klog/v2"
+)
+
+const instrumentationName = "github.com/GoogleCloudPlatform/kubectl-ai"
+
+// Attribute keys. LLM calls use the names from the OpenTelemetry semantic conventions for generative AI.
+const (
+	attrModel        = attribute.Key("gen_ai.request.model")
+	attrInputTokens  = attribute.Key("gen_ai.usage.input_tokens")
+	attrOutputTokens = attribute.Key("gen_ai.usage.output_tokens")
+	attrTokenType    = attribute.Key("gen_ai.token.type")
+	attrTool         = attribute.Key("kubectl_ai.tool.name")
+	attrExitCode     = attribute.Key("kubectl_ai.tool.exit_code")
+	attrIterations   = attribute.Key("kubectl_ai.round.iterations")
+	attrError        = attribute.Key("error")
+)
+
+// instruments are the metrics we record. They are created on first use, from the global meter provider.
+type instruments struct {
+	roundDuration metric.Float64Histogram
+	llmDuration   metric.Float64Histogram
+	llmTokens     metric.Int64Counter
+	toolDuration  metric.Float64Histogram
+}
+
+var getInstruments = sync.OnceValue(func() *instruments {
+	meter := otel.Meter(instrumentationName)
+	i := &instruments{}
+	var err error
+	if i.roundDuration, err = meter.Float64Histogram("kubectl_ai.round.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of answering a query, including all LLM and tool calls")); err != nil {
+		klog.Warningf("creating metric: %v", err)
+	}
+	if i.llmDuration, err = meter.Float64Histogram("gen_ai.client.operation.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of calls to the LLM")); err != nil {
+		klog.Warningf("creating metric: %v", err)
+	}
+	if i.llmTokens, err = meter.Int64Counter("gen_ai.client.token.usage",
+		metric.WithUnit("{token}"), metric.WithDescription("Tokens used by calls to the LLM")); err != nil {
+		klog.Warningf("creating metric: %v", err)
+	}
+	if i.toolDuration, err = meter.Float64Histogram("kubectl_ai.tool.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of tool calls")); err != nil {
+		klog.Warningf("creating metric: %v", err)
+	}
+	return i
+})
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// endSpan records the outcome of a span, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// RoundSpan tracks a round of the conversation, i.e. answering a single query.
+type RoundSpan struct {
+	ctx        context.Context
+	span       trace.Span
+	start      time.Time
+	model      string
+	iterations int
+}
+
+// StartRound starts the span of a round of the conversation.
+func StartRound(ctx context.Context, model string) (context.Context, *RoundSpan) {
+	ctx, span := tracer().Start(ctx, "kubectl-ai.round", trace.WithAttributes(attrModel.String(model)))
+	return ctx, &RoundSpan{ctx: ctx, span: span, start: time.Now(), model: model}
+}
+
+// Iteration counts a call to the LLM in the round.
+func (s *RoundSpan) Iteration() {
+	s.iterations++
+}
+
+// End ends the round, which failed if err is not nil.
+func (s *RoundSpan) End(err error) {
+	s.span.SetAttributes(attrIterations.Int(s.iterations))
+	endSpan(s.span, err)
+
+	if histogram := getInstruments().roundDuration; histogram != nil {
+		histogram.Record(s.ctx, time.Since(s.start).Seconds(), metric.WithAttributes(
+			attrModel.String(s.model),
+			attrError.Bool(err != nil),
+		))
+	}
+}
+
+// LLMCallSpan tracks a call to the LLM.
+type LLMCallSpan struct {
+	ctx    context.Context
+	span   trace.Span
+	start  time.Time
+	model  string
+	tokens journal.Tokens
+}
+
+// StartLLMCall starts the span of a call to the LLM.
+func StartLLMCall(ctx context.Context, model string) (context.Context, *LLMCallSpan) {
+	ctx, span := tracer().Start(ctx, "chat "+model, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("gen_ai.operation.name", "chat"),
+		attrModel.String(model),
+	))
+	return ctx, &LLMCallSpan{ctx: ctx, span: span, start: time.Now(), model: model}
+}
+
+// AddUsage records the usage reported in a response (see gollm.ChatResponse.UsageMetadata).
+// It can be called for each chunk of a streaming response.
+func (s *LLMCallSpan) AddUsage(usage any) {
+	s.tokens = s.tokens.Max(journal.TokensFromUsage(usage))
+}
+
+// End ends the call, which failed if err is not nil.
+func (s *LLMCallSpan) End(err error) {
+	if s.tokens.Input != 0 || s.tokens.Output != 0 {
+		s.span.SetAttributes(attrInputTokens.Int64(s.tokens.Input), attrOutputTokens.Int64(s.tokens.Output))
+	}
+	endSpan(s.span, err)
+
+	instruments := getInstruments()
+	if instruments.llmDuration != nil {
+		instruments.llmDuration.Record(s.ctx, time.Since(s.start).Seconds(), metric.WithAttributes(
+			attrModel.String(s.model),
+			attrError.Bool(err != nil),
+		))
+	}
+	if instruments.llmTokens != nil {
+		if s.tokens.Input != 0 {
+			instruments.llmTokens.Add(s.ctx, s.tokens.Input, metric.WithAttributes(attrModel.String(s.model), attrTokenType.String("input")))
+		}
+		if s.tokens.Output != 0 {
+			instruments.llmTokens.Add(s.ctx, s.tokens.Output, metric.WithAttributes(attrModel.String(s.model), attrTokenType.String("output")))
+		}
+	}
+}
+
+// ToolCallSpan tracks a tool call.
+type ToolCallSpan struct {
+	ctx   context.Context
+	span  trace.Span
+	start time.Time
+	tool  string
+}
+
+// StartToolCall starts the span of a tool call.
+func StartToolCall(ctx context.Context, tool string) (context.Context, *ToolCallSpan) {
+	ctx, span := tracer().Start(ctx, "tool "+tool, trace.WithAttributes(attrTool.String(tool)))
+	return ctx, &ToolCallSpan{ctx: ctx, span: span, start: time.Now(), tool: tool}
+}
+
+// End ends the tool call. A call that ran a command records its exit code; err is set if the call failed.
+func (s *ToolCallSpan) End(exitCode *int, err error) {
+	if exitCode != nil {
+		s.span.SetAttributes(attrExitCode.Int(*exitCode))
+	}
+	endSpan(s.span, err)
+
+	if histogram := getInstruments().toolDuration; histogram != nil {
+		histogram.Record(s.ctx, time.Since(s.start).Seconds(), metric.WithAttributes(
+			attrTool.String(s.tool),
+			attrError.Bool(err != nil),
+		))
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordTelemetry installs global providers that keep spans and metrics in memory, for the duration of the test.
+func recordTelemetry(t *testing.T) (*tracetest.InMemoryExporter, *sdkmetric.ManualReader) {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	previousTracerProvider, previousMeterProvider := otel.GetTracerProvider(), otel.GetMeterProvider()
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetMeterProvider(meterProvider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousTracerProvider)
+		otel.SetMeterProvider(previousMeterProvider)
+		tracerProvider.Shutdown(context.Background())
+		meterProvider.Shutdown(context.Background())
+	})
+	return exporter, reader
+}
+
+// attributes returns the attributes of a span, keyed by name.
+func attributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestSpanTree(t *testing.T) {
+	exporter, reader := recordTelemetry(t)
+
+	ctx, round := StartRound(context.Background(), "gemini-test")
+	for i := 0; i < 2; i++ {
+		round.Iteration()
+		_, llmCall := StartLLMCall(ctx, "gemini-test")
+		llmCall.AddUsage(map[string]any{"promptTokenCount": 10, "candidatesTokenCount": 3})
+		// Streaming responses report the usage so far with each chunk
+		llmCall.AddUsage(map[string]any{"promptTokenCount": 10, "candidatesTokenCount": 5})
+		llmCall.End(nil)
+	}
+	exitCode := 1
+	_, toolCall := StartToolCall(ctx, "kubectl")
+	toolCall.End(&exitCode, nil)
+	_, toolCall = StartToolCall(ctx, "bash")
+	toolCall.End(nil, errors.New("command not found"))
+	round.End(nil)
+
+	spans := exporter.GetSpans()
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	wantNames := []string{"chat gemini-test", "chat gemini-test", "tool kubectl", "tool bash", "kubectl-ai.round"}
+	if len(names) != len(wantNames) {
+		t.Fatalf("got spans %q, want %q", names, wantNames)
+	}
+	for i := range wantNames {
+		if names[i] != wantNames[i] {
+			t.Fatalf("got spans %q, want %q", names, wantNames)
+		}
+	}
+
+	roundSpan := spans[4]
+	if roundSpan.Parent.IsValid() {
+		t.Errorf("got round span with parent %v, want a root span", roundSpan.Parent.SpanID())
+	}
+	if got := attributes(roundSpan)[attrIterations].AsInt64(); got != 2 {
+		t.Errorf("got %d iterations, want 2", got)
+	}
+	for _, span := range spans[:4] {
+		if span.Parent.SpanID() != roundSpan.SpanContext.SpanID() || span.SpanContext.TraceID() != roundSpan.SpanContext.TraceID() {
+			t.Errorf("got span %q with parent %v, want the round span %v", span.Name, span.Parent.SpanID(), roundSpan.SpanContext.SpanID())
+		}
+	}
+
+	llmAttributes := attributes(spans[0])
+	if got := llmAttributes[attrModel].AsString(); got != "gemini-test" {
+		t.Errorf("got model %q, want %q", got, "gemini-test")
+	}
+	if got, want := [2]int64{llmAttributes[attrInputTokens].AsInt64(), llmAttributes[attrOutputTokens].AsInt64()}, [2]int64{10, 5}; got != want {
+		t.Errorf("got input and output tokens %v, want %v", got, want)
+	}
+
+	if got := attributes(spans[2])[attrExitCode].AsInt64(); got != 1 {
+		t.Errorf("got exit code %d, want 1", got)
+	}
+	if spans[2].Status.Code == codes.Error {
+		t.Errorf("got error status for a command that ran, want unset")
+	}
+	if spans[3].Status.Code != codes.Error || spans[3].Status.Description != "command not found" {
+		t.Errorf("got status %+v for the failed tool call, want an error", spans[3].Status)
+	}
+
+	var metrics metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &metrics); err != nil {
+		t.Fatalf("collecting metrics: %v", err)
+	}
+	tokens := map[string]int64{}
+	durations := map[string]uint64{}
+	for _, scope := range metrics.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, point := range data.DataPoints {
+					tokenType, _ := point.Attributes.Value(attrTokenType)
+					tokens[tokenType.AsString()] += point.Value
+				}
+			case metricdata.Histogram[float64]:
+				for _, point := range data.DataPoints {
+					durations[m.Name] += point.Count
+				}
+			}
+		}
+	}
+	if tokens["input"] != 20 || tokens["output"] != 10 {
+		t.Errorf("got token usage %v, want 20 input and 10 output", tokens)
+	}
+	wantDurations := map[string]uint64{
+		"kubectl_ai.round.duration":        1,
+		"gen_ai.client.operation.duration": 2,
+		"kubectl_ai.tool.duration":         2,
+	}
+	for name, want := range wantDurations {
+		if got := durations[name]; got != want {
+			t.Errorf("got %d %s measurements, want %d", got, name, want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package telemetry exports OpenTelemetry traces and metrics of agent runs: a span for each round of
+// the conversation, with child spans for each call to the LLM and each tool call, and metrics of their
+// latency, errors and token usage.
+//
+// Instrumentation uses the global OpenTelemetry providers, so it does nothing until Setup installs an exporter.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Config configures the export of telemetry.
+type Config struct {
+	// Endpoint is the base URL of an OTLP/HTTP collector, e.g. http://localhost:4318.
+	// Traces are sent to <Endpoint>/v1/traces and metrics to <Endpoint>/v1/metrics.
+	// If empty, the standard OTEL_EXPORTER_OTLP_* environment variables are used, if they are set.
+	Endpoint string
+	// ServiceVersion is recorded as the service.version of the telemetry.
+	ServiceVersion string
+	// MetricInterval is how often metrics are exported; they are also exported on shutdown.
+	MetricInterval time.Duration
+}
+
+// Enabled returns true if the config, or the environment, specifies where to export telemetry.
+func (c Config) Enabled() bool {
+	if c.Endpoint != "" {
+		return true
+	}
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Setup installs global trace and meter providers that export to an OTLP collector.
+// The returned function flushes any telemetry that has not been exported yet, and stops exporting.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	var traceOptions []otlptracehttp.Option
+	var metricOptions []otlpmetrichttp.Option
+	if config.Endpoint != "" {
+		endpoint := strings.TrimSuffix(config.Endpoint, "/")
+		traceOptions = append(traceOptions, otlptracehttp.WithEndpointURL(endpoint+"/v1/traces"))
+		metricOptions = append(metricOptions, otlpmetrichttp.WithEndpointURL(endpoint+"/v1/metrics"))
+	}
+
+	traceExporter, err := otlptracehttp.New(ctx, traceOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("creating trace exporter: %w", err)
+	}
+	metricExporter, err := otlpmetrichttp.New(ctx, metricOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("creating metric exporter: %w", err)
+	}
+
+	res := resource.NewSchemaless(
+		attribute.String("service.name", "kubectl-ai"),
+		attribute.String("service.version", config.ServiceVersion),
+	)
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(traceExporter),
+		sdktrace.WithResource(res),
+	)
+
+	interval := config.MetricInterval
+	if interval == 0 {
+		interval = 30 * time.Second
+	}
+	meterProvider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(interval))),
+		sdkmetric.WithResource(res),
+	)
+
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetMeterProvider(meterProvider)
+
+	shutdown := func(ctx context.Context) error {
+		return errors.Join(
+			tracerProvider.Shutdown(ctx),
+			meterProvider.Shutdown(ctx),
+		)
+	}
+	return shutdown, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetupExportsOnShutdown(t *testing.T) {
+	// A collector that counts the requests to each path
+	var mutex sync.Mutex
+	requests := make(map[string]int)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "application/x-protobuf" || len(body) == 0 {
+			t.Errorf("got request to %s with content type %q and %d bytes, want a protobuf payload", r.URL.Path, r.Header.Get("Content-Type"), len(body))
+		}
+		mutex.Lock()
+		requests[r.URL.Path]++
+		mutex.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer collector.Close()
+
+	previousTracerProvider, previousMeterProvider := otel.GetTracerProvider(), otel.GetMeterProvider()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousTracerProvider)
+		otel.SetMeterProvider(previousMeterProvider)
+	})
+
+	ctx := context.Background()
+	// The trailing slash is not doubled in the export URLs, and metrics are only exported on shutdown
+	shutdown, err := Setup(ctx, Config{Endpoint: collector.URL + "/", ServiceVersion: "test", MetricInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	roundCtx, round := StartRound(ctx, "gemini-test")
+	round.Iteration()
+	_, llmCall := StartLLMCall(roundCtx, "gemini-test")
+	llmCall.AddUsage(map[string]any{"promptTokenCount": 10, "candidatesTokenCount": 3})
+	llmCall.End(nil)
+	round.End(nil)
+
+	mutex.Lock()
+	metricsBeforeShutdown := requests["/v1/metrics"]
+	mutex.Unlock()
+	if metricsBeforeShutdown != 0 {
+		t.Errorf("got %d metric exports before shutdown, want none within the interval", metricsBeforeShutdown)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := shutdown(shutdownCtx); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, path := range []string{"/v1/traces", "/v1/metrics"} {
+		if requests[path] == 0 {
+			t.Errorf("got no request to %s, want the telemetry flushed on shutdown (requests: %v)", path, requests)
+		}
+	}
+}
+
+func TestConfigEnabled(t *testing.T) {
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"} {
+		t.Setenv(name, "")
+	}
+	if (Config{}).Enabled() {
+		t.Errorf("got telemetry enabled with no endpoint")
+	}
+	if !(Config{Endpoint: "http://localhost:4318"}).Enabled() {
+		t.Errorf("got telemetry disabled with an endpoint")
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://localhost:4318/v1/traces")
+	if !(Config{}).Enabled() {
+		t.Errorf("got telemetry disabled with OTEL_EXPORTER_OTLP_TRACES_ENDPOINT set")
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
 )
@@ -69,6 +70,7 @@ func (t *BashTool) FunctionDefinition() *gollm.FunctionDefinition {
 				},
 				"modifies_resource": {
 					Type: gollm.TypeString,
+					Enum: []string{"yes", "no", "unknown"},
 					Description: `Whether the command modifies a kubernetes resource.
 Possible values:
 - "yes" if the command modifies a resource
@@ -76,35 +78,58 @@ Possible values:
 - "unknown" if the command's effect on the resource is unknown
 `,
 				},
+				"run_in_background": runInBackgroundParameter,
 			},
+			Required: []string{"command"},
 		},
 	}
 }
 
 func (t *BashTool) Run(ctx context.Context, args map[string]any) (any, error) {
-	kubeconfig := ctx.Value("kubeconfig").(string)
-	workDir := ctx.Value("work_dir").(string)
-	command := args["command"].(string)
+	kubeconfig := kubeconfigFromContext(ctx)
+	workDir := workDirFromContext(ctx)
+	command, err := stringArgument(args, "command")
+	if err != nil {
+		return nil, err
+	}
 
-	if strings.Contains(command, "kubectl edit") {
-		return &ExecResult{Error: "interactive mode not supported for kubectl, please use non-interactive commands"}, nil
+	if readOnlyFromContext(ctx) {
+		// We can't tell what an arbitrary script does, so bash is disabled rather than sandboxed
+		return &ExecResult{Error: "bash is disabled in read-only mode; use the kubectl tool to run read-only kubectl commands"}, nil
 	}
-	if strings.Contains(command, "kubectl port-forward") {
-		return &ExecResult{Error: "port-forwarding is not allowed because assistant is running in an unattended mode, please try some other alternative"}, nil
+
+	if background, _ := args["run_in_background"].(bool); background {
+		return startBackgroundTask(ctx, command, workDir, kubeconfig), nil
 	}
 
+	return runShellCommand(ctx, command, workDir, kubeconfig)
+}
+
+// shellCommand returns the command to run a command line with bash, using the kubeconfig if one is set.
+func shellCommand(ctx context.Context, command, workDir, kubeconfig string) (*exec.Cmd, error) {
+	env, err := commandEnv(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
 	cmd := exec.CommandContext(ctx, bashBin, "-c", command)
 	cmd.Dir = workDir
-	cmd.Env = os.Environ()
+	cmd.Env = env
+	// Once the command is cancelled, don't wait for processes it started in the background to close its output
+	cmd.WaitDelay = time.Second
+	return cmd, nil
+}
+
+// commandEnv returns the environment for commands run by the tools, with KUBECONFIG set if a kubeconfig is set.
+func commandEnv(kubeconfig string) ([]string, error) {
+	env := os.Environ()
 	if kubeconfig != "" {
 		kubeconfig, err := expandShellVar(kubeconfig)
 		if err != nil {
 			return nil, err
 		}
-		cmd.Env = append(cmd.Env, "KUBECONFIG="+kubeconfig)
+		env = append(env, "KUBECONFIG="+kubeconfig)
 	}
-
-	return executeCommand(cmd)
+	return env, nil
 }
 
 type ExecResult struct {
@@ -112,6 +137,9 @@ type ExecResult struct {
 	Stdout   string `json:"stdout,omitempty"`
 	Stderr   string `json:"stderr,omitempty"`
 	ExitCode int    `json:"exit_code,omitempty"`
+	// Note tells the LLM how the command was run, when that is not what it asked for,
+	// e.g. that a command that streams its output was stopped.
+	Note string `json:"note,omitempty"`
 }
 
 func executeCommand(cmd *exec.Cmd) (*ExecResult, error) {
@@ -36,3 +36,12 @@ type Tool interface {
 	// Run invokes the tool, the agent calls this when the LLM requests tool invocation.
 	Run(ctx context.Context, args map[string]any) (any, error)
 }
+
+// ReadOnlyTool is implemented by tools that never modify the cluster, such as tools that only wait or read.
+// Calls to them do not need confirmation, and are allowed in plan mode.
+type ReadOnlyTool interface {
+	Tool
+
+	// IsReadOnly returns true if the tool never modifies the cluster.
+	IsReadOnly() bool
+}
@@ -0,0 +1,290 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/kubectlcmd"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui"
+)
+
+func init() {
+	RegisterResultRenderer(ResultTypeResourceTable, &resourceTableRenderer{})
+	RegisterResultRenderer(ResultTypeEventList, &eventListRenderer{})
+}
+
+const (
+	ResultTypeResourceTable = "resource_table"
+	ResultTypeEventList     = "event_list"
+)
+
+// ResourceTable is the result of listing resources with `kubectl get`, parsed from the default table output.
+type ResourceTable struct {
+	// Resource is the resource type as passed to kubectl, such as "pods" or "deploy".
+	Resource string     `json:"resource"`
+	Columns  []string   `json:"columns"`
+	Rows     [][]string `json:"rows"`
+	// Warnings holds anything kubectl printed to stderr.
+	Warnings string `json:"warnings,omitempty"`
+}
+
+func (r *ResourceTable) ResultType() string {
+	return ResultTypeResourceTable
+}
+
+// EventList is the result of listing events with `kubectl get events`.
+type EventList struct {
+	Events []Event `json:"events"`
+	// Warnings holds anything kubectl printed to stderr.
+	Warnings string `json:"warnings,omitempty"`
+}
+
+func (r *EventList) ResultType() string {
+	return ResultTypeEventList
+}
+
+// Event is a single kubernetes event.
+type Event struct {
+	Namespace string `json:"namespace,omitempty"`
+	LastSeen  string `json:"lastSeen,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Object    string `json:"object,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// typedKubectlResult converts the output of a kubectl command into a typed result where we know how to parse it.
+// Otherwise (or if parsing fails) the ExecResult is returned unchanged.
+func typedKubectlResult(command string, result *ExecResult) any {
+	if result == nil || result.Error != "" || result.ExitCode != 0 || strings.TrimSpace(result.Stdout) == "" {
+		return result
+	}
+
+	resource, ok := parseSimpleGetCommand(command)
+	if !ok {
+		return result
+	}
+
+	columns, rows, ok := parseKubectlTable(result.Stdout)
+	if !ok {
+		return result
+	}
+
+	switch resource {
+	case "events", "event", "ev":
+		events, ok := toEvents(columns, rows)
+		if !ok {
+			break
+		}
+		return &EventList{Events: events, Warnings: result.Stderr}
+	}
+
+	return &ResourceTable{
+		Resource: resource,
+		Columns:  columns,
+		Rows:     rows,
+		Warnings: result.Stderr,
+	}
+}
+
+// parseSimpleGetCommand returns the resource type if command is a single `kubectl get <resource>`
+// that prints the default table output.
+func parseSimpleGetCommand(command string) (string, bool) {
+	line, err := kubectlcmd.Parse(command)
+	if err != nil || len(line.Commands) != 1 || line.Substitution || line.Subshell || line.ControlFlow {
+		return "", false
+	}
+	cmd := line.Commands[0]
+	if cmd.Name != "kubectl" || len(cmd.Redirects) != 0 || cmd.Background {
+		return "", false
+	}
+
+	invocation := cmd.Kubectl
+	if invocation.Verb != "get" || invocation.Watch || len(invocation.Filenames) != 0 {
+		return "", false
+	}
+	// Anything other than the default output is passed through as-is
+	if invocation.Output != "" && invocation.Output != "wide" {
+		return "", false
+	}
+	// Without the headings we can't tell where the columns are
+	if noHeaders, ok := invocation.Flags["--no-headers"]; ok && noHeaders != "false" {
+		return "", false
+	}
+	// Multiple resource types (e.g. "pods,services" or "all") print multiple tables
+	resource := invocation.Resource
+	if resource == "" || resource == "all" || strings.Contains(resource, ",") {
+		return "", false
+	}
+	return resource, true
+}
+
+// headerColumn matches a column heading; headings may contain single spaces, e.g. "NOMINATED NODE".
+var headerColumn = regexp.MustCompile(`\S+( \S+)*`)
+
+// parseKubectlTable parses the aligned table printed by kubectl get, using the positions of the headings
+// to split each row (values may contain spaces, e.g. "2 (5m ago)"). Positions are counted in runes, as kubectl
+// aligns the columns by rune.
+func parseKubectlTable(output string) ([]string, [][]string, bool) {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	header := lines[0]
+
+	matches := headerColumn.FindAllStringIndex(header, -1)
+	if len(matches) == 0 {
+		return nil, nil, false
+	}
+	var columns []string
+	var starts []int
+	for _, match := range matches {
+		columns = append(columns, header[match[0]:match[1]])
+		starts = append(starts, utf8.RuneCountInString(header[:match[0]]))
+	}
+
+	var rows [][]string
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			// A blank line means there are multiple tables, which we don't handle
+			return nil, nil, false
+		}
+		runes := []rune(line)
+		row := make([]string, len(starts))
+		for i, start := range starts {
+			if start >= len(runes) {
+				break
+			}
+			end := len(runes)
+			if i+1 < len(starts) && starts[i+1] < end {
+				end = starts[i+1]
+			}
+			row[i] = strings.TrimSpace(string(runes[start:end]))
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, true
+}
+
+// toEvents maps the columns of the kubectl get events table to Events.
+func toEvents(columns []string, rows [][]string) ([]Event, bool) {
+	index := make(map[string]int)
+	for i, column := range columns {
+		index[column] = i
+	}
+	for _, required := range []string{"REASON", "OBJECT", "MESSAGE"} {
+		if _, found := index[required]; !found {
+			return nil, false
+		}
+	}
+
+	get := func(row []string, column string) string {
+		if i, found := index[column]; found {
+			return row[i]
+		}
+		return ""
+	}
+
+	events := make([]Event, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, Event{
+			Namespace: get(row, "NAMESPACE"),
+			LastSeen:  get(row, "LAST SEEN"),
+			Type:      get(row, "TYPE"),
+			Reason:    get(row, "REASON"),
+			Object:    get(row, "OBJECT"),
+			Message:   get(row, "MESSAGE"),
+		})
+	}
+	return events, true
+}
+
+type resourceTableRenderer struct{}
+
+func (r *resourceTableRenderer) Schema() *gollm.Schema {
+	return schemaFor(ResourceTable{})
+}
+
+func (r *resourceTableRenderer) RenderForLLM(result TypedResult) (map[string]any, error) {
+	// The columns and rows are already compact, and more so than a list of objects.
+	return ToolResultToMap(result)
+}
+
+func (r *resourceTableRenderer) RenderForUI(result TypedResult) ui.Block {
+	table := result.(*ResourceTable)
+	return ui.NewTableBlock().
+		SetTitle(fmt.Sprintf("%s (%d)", table.Resource, len(table.Rows))).
+		SetTable(table.Columns, table.Rows)
+}
+
+// eventTable is an EventList as sent to the LLM: a table, like ResourceTable, so that the keys are not repeated
+// for every event.
+type eventTable struct {
+	Columns  []string   `json:"columns"`
+	Rows     [][]string `json:"rows"`
+	Warnings string     `json:"warnings,omitempty"`
+}
+
+// table returns the events as the columns and rows of kubectl's table; the namespace is only included if known.
+func (l *EventList) table() ([]string, [][]string) {
+	withNamespace := false
+	for _, event := range l.Events {
+		if event.Namespace != "" {
+			withNamespace = true
+		}
+	}
+
+	columns := []string{"LAST SEEN", "TYPE", "REASON", "OBJECT", "MESSAGE"}
+	if withNamespace {
+		columns = append([]string{"NAMESPACE"}, columns...)
+	}
+	rows := make([][]string, 0, len(l.Events))
+	for _, event := range l.Events {
+		row := []string{event.LastSeen, event.Type, event.Reason, event.Object, event.Message}
+		if withNamespace {
+			row = append([]string{event.Namespace}, row...)
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows
+}
+
+type eventListRenderer struct{}
+
+func (r *eventListRenderer) Schema() *gollm.Schema {
+	return schemaFor(eventTable{})
+}
+
+func (r *eventListRenderer) RenderForLLM(result TypedResult) (map[string]any, error) {
+	list := result.(*EventList)
+	columns, rows := list.table()
+	return ToolResultToMap(&eventTable{Columns: columns, Rows: rows, Warnings: list.Warnings})
+}
+
+func (r *eventListRenderer) RenderForUI(result TypedResult) ui.Block {
+	list := result.(*EventList)
+	warnings := 0
+	for _, event := range list.Events {
+		if event.Type == "Warning" {
+			warnings++
+		}
+	}
+	columns, rows := list.table()
+	return ui.NewTableBlock().
+		SetTitle(fmt.Sprintf("events (%d, %d warnings)", len(list.Events), warnings)).
+		SetTable(columns, rows)
+}
@@ -0,0 +1,201 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSimpleGetCommand(t *testing.T) {
+	tests := []struct {
+		command      string
+		wantResource string
+		wantOK       bool
+	}{
+		{command: "kubectl get pods", wantResource: "pods", wantOK: true},
+		{command: "kubectl get pods -o wide", wantResource: "pods", wantOK: true},
+		{command: "kubectl get pods -A", wantResource: "pods", wantOK: true},
+		{command: "kubectl get deploy/web -n prod", wantResource: "deploy", wantOK: true},
+		{command: "kubectl get pods --no-headers=false", wantResource: "pods", wantOK: true},
+		{command: "kubectl get pods --no-headers"},
+		{command: "kubectl get --no-headers pods -o wide"},
+		{command: "kubectl get pods --no-headers=true"},
+		{command: "kubectl get pods -o yaml"},
+		{command: "kubectl get pods -w"},
+		{command: "kubectl get pods,services"},
+		{command: "kubectl get all"},
+		{command: "kubectl get -f web.yaml"},
+		{command: "kubectl get pods | grep web"},
+		{command: "kubectl get pods > pods.txt"},
+		{command: "kubectl describe pods"},
+	}
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			resource, ok := parseSimpleGetCommand(test.command)
+			if resource != test.wantResource || ok != test.wantOK {
+				t.Errorf("got (%q, %t), want (%q, %t)", resource, ok, test.wantResource, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseKubectlTable(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		wantColumns []string
+		wantRows    [][]string
+		wantOK      bool
+	}{
+		{
+			name: "default",
+			output: "NAME    READY   STATUS    RESTARTS   AGE\n" +
+				"web-1   1/1     Running   0          5m\n" +
+				"web-2   0/1     Pending   0          1m\n",
+			wantColumns: []string{"NAME", "READY", "STATUS", "RESTARTS", "AGE"},
+			wantRows: [][]string{
+				{"web-1", "1/1", "Running", "0", "5m"},
+				{"web-2", "0/1", "Pending", "0", "1m"},
+			},
+			wantOK: true,
+		},
+		{
+			name: "values with spaces",
+			output: "NAME    READY   STATUS             RESTARTS      AGE\n" +
+				"web-1   0/1     CrashLoopBackOff   2 (5m ago)    10m\n" +
+				"web-2   1/1     Running            0             10m\n",
+			wantColumns: []string{"NAME", "READY", "STATUS", "RESTARTS", "AGE"},
+			wantRows: [][]string{
+				{"web-1", "0/1", "CrashLoopBackOff", "2 (5m ago)", "10m"},
+				{"web-2", "1/1", "Running", "0", "10m"},
+			},
+			wantOK: true,
+		},
+		{
+			name: "wide",
+			output: "NAME    READY   STATUS    RESTARTS   AGE   IP          NODE     NOMINATED NODE   READINESS GATES\n" +
+				"web-1   1/1     Running   0          5m    10.0.0.12   node-a   <none>           <none>\n",
+			wantColumns: []string{"NAME", "READY", "STATUS", "RESTARTS", "AGE", "IP", "NODE", "NOMINATED NODE", "READINESS GATES"},
+			wantRows: [][]string{
+				{"web-1", "1/1", "Running", "0", "5m", "10.0.0.12", "node-a", "<none>", "<none>"},
+			},
+			wantOK: true,
+		},
+		{
+			name: "all namespaces",
+			output: "NAMESPACE     NAME                      READY   STATUS    RESTARTS   AGE\n" +
+				"kube-system   coredns-5d78c9869d-abcde  1/1     Running   0          2d\n" +
+				"prod          web-1                     1/1     Running   0          5m\n",
+			wantColumns: []string{"NAMESPACE", "NAME", "READY", "STATUS", "RESTARTS", "AGE"},
+			wantRows: [][]string{
+				{"kube-system", "coredns-5d78c9869d-abcde", "1/1", "Running", "0", "2d"},
+				{"prod", "web-1", "1/1", "Running", "0", "5m"},
+			},
+			wantOK: true,
+		},
+		{
+			name: "events with a message longer than the heading",
+			output: "LAST SEEN   TYPE      REASON    OBJECT      MESSAGE\n" +
+				"2m          Warning   BackOff   pod/web-1   Back-off restarting failed container web in pod web-1\n",
+			wantColumns: []string{"LAST SEEN", "TYPE", "REASON", "OBJECT", "MESSAGE"},
+			wantRows: [][]string{
+				{"2m", "Warning", "BackOff", "pod/web-1", "Back-off restarting failed container web in pod web-1"},
+			},
+			wantOK: true,
+		},
+		{
+			name: "non-ASCII values",
+			output: "NAME    DESCRIPTION   AGE\n" +
+				"été-à   naïve         5m\n",
+			wantColumns: []string{"NAME", "DESCRIPTION", "AGE"},
+			wantRows:    [][]string{{"été-à", "naïve", "5m"}},
+			wantOK:      true,
+		},
+		{
+			name: "multiple tables",
+			output: "NAME    READY   STATUS    RESTARTS   AGE\n" +
+				"web-1   1/1     Running   0          5m\n" +
+				"\n" +
+				"NAME         TYPE        CLUSTER-IP   EXTERNAL-IP   PORT(S)   AGE\n" +
+				"service/web  ClusterIP   10.0.0.1     <none>        80/TCP    5m\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			columns, rows, ok := parseKubectlTable(test.output)
+			if ok != test.wantOK {
+				t.Fatalf("got ok %t, want %t", ok, test.wantOK)
+			}
+			if !reflect.DeepEqual(columns, test.wantColumns) {
+				t.Errorf("got columns %q, want %q", columns, test.wantColumns)
+			}
+			if !reflect.DeepEqual(rows, test.wantRows) {
+				t.Errorf("got rows %q, want %q", rows, test.wantRows)
+			}
+		})
+	}
+}
+
+func TestTypedKubectlResult(t *testing.T) {
+	pods := "NAME    READY   STATUS    RESTARTS   AGE\nweb-1   1/1     Running   0          5m\n"
+	if _, ok := typedKubectlResult("kubectl get pods", &ExecResult{Stdout: pods}).(*ResourceTable); !ok {
+		t.Errorf("got no table for kubectl get pods")
+	}
+	if _, ok := typedKubectlResult("kubectl get pods --no-headers", &ExecResult{Stdout: "web-1   1/1   Running   0   5m\n"}).(*ExecResult); !ok {
+		t.Errorf("got a typed result for kubectl get pods --no-headers, want the output as-is")
+	}
+	if _, ok := typedKubectlResult("kubectl get pods", &ExecResult{Stdout: pods, ExitCode: 1}).(*ExecResult); !ok {
+		t.Errorf("got a typed result for a failed command, want the output as-is")
+	}
+}
+
+func TestEventListRenderForLLM(t *testing.T) {
+	output := "NAMESPACE   LAST SEEN   TYPE      REASON    OBJECT      MESSAGE\n" +
+		"prod        2m          Warning   BackOff   pod/web-1   Back-off restarting failed container\n" +
+		"prod        5m          Normal    Pulled    pod/web-1   Container image \"web:1\" already present\n"
+	list, ok := typedKubectlResult("kubectl get events -A", &ExecResult{Stdout: output, Stderr: "a warning"}).(*EventList)
+	if !ok {
+		t.Fatalf("got no event list for kubectl get events -A")
+	}
+
+	got, err := RenderResultForLLM(list)
+	if err != nil {
+		t.Fatalf("RenderResultForLLM failed: %v", err)
+	}
+	want := map[string]any{
+		"type":    ResultTypeEventList,
+		"columns": []any{"NAMESPACE", "LAST SEEN", "TYPE", "REASON", "OBJECT", "MESSAGE"},
+		"rows": []any{
+			[]any{"prod", "2m", "Warning", "BackOff", "pod/web-1", "Back-off restarting failed container"},
+			[]any{"prod", "5m", "Normal", "Pulled", "pod/web-1", "Container image \"web:1\" already present"},
+		},
+		"warnings": "a warning",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Without -A, there is no namespace column
+	list.Events = list.Events[:1]
+	list.Events[0].Namespace = ""
+	got, err = RenderResultForLLM(list)
+	if err != nil {
+		t.Fatalf("RenderResultForLLM failed: %v", err)
+	}
+	if columns := got["columns"].([]any); len(columns) != 5 || columns[0] != "LAST SEEN" {
+		t.Errorf("got columns %v, want them without the namespace", columns)
+	}
+}
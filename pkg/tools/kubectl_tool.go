@@ -16,11 +16,11 @@ package tools
 
 import (
 	"context"
-	"os"
-	"os/exec"
+	"fmt"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/kubectlcmd"
 )
 
 func init() {
@@ -34,7 +34,9 @@ func (t *Kubectl) Name() string {
 }
 
 func (t *Kubectl) Description() string {
-	return "Executes a kubectl command against the user's Kubernetes cluster. Use this tool only when you need to query or modify the state of the user's Kubernetes cluster."
+	return "Executes a kubectl command against the user's Kubernetes cluster. Use this tool only when you need to query or modify the state of the user's Kubernetes cluster. " +
+		"There is no terminal: commands that stream output (such as logs -f and get -w) are stopped after a few seconds and return their output so far, " +
+		"kubectl port-forward runs in the background until it is closed with close_port_forward, and kubectl edit shows the resource, which can then be changed with kubectl patch."
 }
 
 func (t *Kubectl) FunctionDefinition() *gollm.FunctionDefinition {
@@ -57,6 +59,7 @@ assistant: kubectl get pod my-pod -o jsonpath='{.status.phase}'
 				},
 				"modifies_resource": {
 					Type: gollm.TypeString,
+					Enum: []string{"yes", "no", "unknown"},
 					Description: `Whether the command modifies a kubernetes resource.
 Possible values:
 - "yes" if the command modifies a resource
@@ -64,37 +67,67 @@ Possible values:
 - "unknown" if the command's effect on the resource is unknown
 `,
 				},
+				"run_in_background": runInBackgroundParameter,
 			},
+			Required: []string{"command"},
 		},
 	}
 }
 
 func (t *Kubectl) Run(ctx context.Context, args map[string]any) (any, error) {
-	kubeconfig := ctx.Value("kubeconfig").(string)
-	workDir := ctx.Value("work_dir").(string)
-	command := args["command"].(string)
+	kubeconfig := kubeconfigFromContext(ctx)
+	workDir := workDirFromContext(ctx)
+	command, err := stringArgument(args, "command")
+	if err != nil {
+		return nil, err
+	}
 
-	return runKubectlCommand(ctx, command, workDir, kubeconfig)
-}
+	if readOnlyFromContext(ctx) {
+		if err := checkReadOnlyKubectlCommand(command); err != nil {
+			return &ExecResult{Error: fmt.Sprintf("refusing to run the command in read-only mode: %v", err)}, nil
+		}
+	}
+	if lockIdentityFromContext(ctx) {
+		if err := checkIdentityOverrides(command); err != nil {
+			return &ExecResult{Error: fmt.Sprintf("refusing to run the command as another identity: %v", err)}, nil
+		}
+	}
 
-func runKubectlCommand(ctx context.Context, command, workDir, kubeconfig string) (*ExecResult, error) {
-	if strings.Contains(command, "kubectl edit") {
-		return &ExecResult{Error: "interactive mode not supported for kubectl, please use non-interactive commands"}, nil
+	if background, _ := args["run_in_background"].(bool); background {
+		return startBackgroundTask(ctx, command, workDir, kubeconfig), nil
 	}
-	if strings.Contains(command, "kubectl port-forward") {
-		return &ExecResult{Error: "port-forwarding is not allowed because assistant is running in an unattended mode, please try some other alternative"}, nil
+
+	result, err := runShellCommand(ctx, command, workDir, kubeconfig)
+	if err != nil {
+		return nil, err
 	}
+	if execResult, ok := result.(*ExecResult); ok {
+		return typedKubectlResult(command, execResult), nil
+	}
+	return result, nil
+}
 
-	cmd := exec.CommandContext(ctx, bashBin, "-c", command)
-	cmd.Env = os.Environ()
-	cmd.Dir = workDir
-	if kubeconfig != "" {
-		kubeconfig, err := expandShellVar(kubeconfig)
-		if err != nil {
-			return nil, err
-		}
-		cmd.Env = append(cmd.Env, "KUBECONFIG="+kubeconfig)
+// IsForbidden returns true if the result of a tool call shows that the API server refused the request
+// because of RBAC, e.g. "Error from server (Forbidden): ...".
+func IsForbidden(result any) bool {
+	execResult, ok := result.(*ExecResult)
+	if !ok {
+		return false
 	}
+	return strings.Contains(execResult.Stderr, "(Forbidden)")
+}
 
-	return executeCommand(cmd)
+// unattendedCommandError returns why the command line cannot be run without a user at a terminal, or "" if it can.
+func unattendedCommandError(line *kubectlcmd.CommandLine) string {
+	for _, invocation := range line.Invocations() {
+		switch {
+		case invocation.Verb == "port-forward":
+			return "kubectl port-forward can only be run on its own, as a single command; it is then started in the background, and can be closed with " + closePortForwardToolName
+		case invocation.Verb == "edit":
+			return "kubectl edit can only be run on its own, as a single command; it then shows the resource, which can be changed with kubectl patch"
+		case invocation.Class() == kubectlcmd.ClassInteractive:
+			return "interactive mode not supported for kubectl, please use non-interactive commands"
+		}
+	}
+	return ""
 }
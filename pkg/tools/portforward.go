@@ -0,0 +1,339 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/kubectlcmd"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui"
+	"k8s.io/klog/v2"
+)
+
+func init() {
+	RegisterTool(&ClosePortForward{})
+	RegisterResultRenderer(ResultTypePortForward, &portForwardRenderer{})
+}
+
+const (
+	ResultTypePortForward = "port_forward"
+
+	closePortForwardToolName = "close_port_forward"
+
+	// portForwardStartTimeout is how long we wait for kubectl port-forward to start forwarding.
+	portForwardStartTimeout = 15 * time.Second
+)
+
+// PortForwardSession is the result of starting `kubectl port-forward`, which keeps running in the background
+// until it is closed with the close_port_forward tool.
+type PortForwardSession struct {
+	// ID identifies the session, for close_port_forward.
+	ID      string `json:"id"`
+	Command string `json:"command"`
+	// Forwarding are the local addresses and the ports they forward to, e.g. "127.0.0.1:8080 -> 80".
+	Forwarding []string `json:"forwarding"`
+	Message    string   `json:"message"`
+}
+
+func (r *PortForwardSession) ResultType() string {
+	return ResultTypePortForward
+}
+
+// portForward is a running `kubectl port-forward`.
+type portForward struct {
+	id      string
+	command string
+	cmd     *exec.Cmd
+
+	// forwarding receives the "Forwarding from" lines printed by kubectl.
+	forwarding chan string
+	// done is closed when kubectl has exited, after which output and err are set.
+	done   chan struct{}
+	stderr lockedBuffer
+	output []string
+	err    error
+}
+
+// portForwards are the running port-forward sessions.
+var portForwards = struct {
+	mutex    sync.Mutex
+	sessions map[string]*portForward
+	lastID   int
+}{sessions: make(map[string]*portForward)}
+
+// startPortForward starts `kubectl port-forward` in the background, and waits for it to start forwarding.
+// If it exits before then, its output is returned as an ExecResult.
+func startPortForward(ctx context.Context, program string, invocation *kubectlcmd.Invocation, workDir, kubeconfig string) (any, error) {
+	env, err := commandEnv(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// The session outlives the tool call, so it is not bound to ctx
+	cmd := exec.Command(program, invocation.Args...)
+	cmd.Dir = workDir
+	cmd.Env = env
+	// Keep the session running if the user interrupts a later request
+	setProcessGroup(cmd)
+
+	pf := &portForward{
+		command:    shellQuote(program, invocation.Args),
+		cmd:        cmd,
+		forwarding: make(chan string, 16),
+		done:       make(chan struct{}),
+	}
+	cmd.Stderr = &pf.stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("creating pipe for port-forward: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return &ExecResult{Error: fmt.Sprintf("starting port-forward: %v", err)}, nil
+	}
+	go pf.run(stdout)
+
+	var forwarding []string
+	timeout := time.After(portForwardStartTimeout)
+	for len(forwarding) == 0 {
+		select {
+		case address := <-pf.forwarding:
+			forwarding = append(forwarding, address)
+		case <-pf.done:
+			return pf.execResult(), nil
+		case <-timeout:
+			pf.stop()
+			result := pf.execResult()
+			result.Error = fmt.Sprintf("kubectl port-forward did not start forwarding within %s", portForwardStartTimeout)
+			return result, nil
+		case <-ctx.Done():
+			pf.stop()
+			return nil, ctx.Err()
+		}
+	}
+	// kubectl prints a line for each local address (e.g. IPv4 and IPv6) at the same time
+	moreAddresses := time.After(100 * time.Millisecond)
+collect:
+	for {
+		select {
+		case address := <-pf.forwarding:
+			forwarding = append(forwarding, address)
+		case <-moreAddresses:
+			break collect
+		}
+	}
+
+	portForwards.mutex.Lock()
+	portForwards.lastID++
+	pf.id = fmt.Sprintf("pf-%d", portForwards.lastID)
+	portForwards.sessions[pf.id] = pf
+	portForwards.mutex.Unlock()
+	klog.Infof("started port-forward %s: %s", pf.id, pf.command)
+
+	return &PortForwardSession{
+		ID:         pf.id,
+		Command:    pf.command,
+		Forwarding: forwarding,
+		Message: fmt.Sprintf("The port-forward is running in the background, so the forwarded ports can be used (e.g. with curl) in later commands. "+
+			"Close it with %s (id %q) when it is no longer needed.", closePortForwardToolName, pf.id),
+	}, nil
+}
+
+// run reads the output of kubectl until it exits.
+func (pf *portForward) run(stdout io.Reader) {
+	defer close(pf.done)
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if address, ok := strings.CutPrefix(line, "Forwarding from "); ok {
+			select {
+			case pf.forwarding <- address:
+			default:
+			}
+			continue
+		}
+		// Other output is mostly "Handling connection for 8080"; keep only the most recent lines
+		if len(pf.output) == 20 {
+			pf.output = pf.output[1:]
+		}
+		pf.output = append(pf.output, line)
+	}
+	pf.err = pf.cmd.Wait()
+	klog.V(2).Infof("port-forward %q exited: %v", pf.command, pf.err)
+}
+
+// stop kills kubectl, if it is still running, and waits for it to exit.
+func (pf *portForward) stop() {
+	select {
+	case <-pf.done:
+		return
+	default:
+	}
+	if err := killProcessGroup(pf.cmd); err != nil {
+		klog.Warningf("stopping port-forward %q: %v", pf.command, err)
+	}
+	<-pf.done
+}
+
+// execResult returns the output of kubectl, once it has exited.
+func (pf *portForward) execResult() *ExecResult {
+	result := &ExecResult{
+		Stdout: strings.Join(pf.output, "\n"),
+		Stderr: pf.stderr.String(),
+	}
+	var exitError *exec.ExitError
+	if errors.As(pf.err, &exitError) {
+		result.ExitCode = exitError.ExitCode()
+	}
+	return result
+}
+
+// ClosePortForwards stops all the running port-forward sessions.
+func ClosePortForwards() {
+	portForwards.mutex.Lock()
+	sessions := portForwards.sessions
+	portForwards.sessions = make(map[string]*portForward)
+	portForwards.mutex.Unlock()
+
+	for _, pf := range sessions {
+		pf.stop()
+	}
+}
+
+// ClosePortForward is the tool that closes a port-forward session.
+type ClosePortForward struct{}
+
+func (t *ClosePortForward) Name() string {
+	return closePortForwardToolName
+}
+
+func (t *ClosePortForward) Description() string {
+	return "Closes a port-forward that was started in the background by running kubectl port-forward."
+}
+
+// IsReadOnly implements ReadOnlyTool. It only stops a local kubectl process.
+func (t *ClosePortForward) IsReadOnly() bool {
+	return true
+}
+
+func (t *ClosePortForward) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"id": {
+					Type:        gollm.TypeString,
+					Description: `The id of the port-forward, as returned when it was started, e.g. "pf-1".`,
+				},
+			},
+			Required: []string{"id"},
+		},
+	}
+}
+
+func (t *ClosePortForward) Run(ctx context.Context, args map[string]any) (any, error) {
+	id, err := stringArgument(args, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	portForwards.mutex.Lock()
+	pf := portForwards.sessions[id]
+	delete(portForwards.sessions, id)
+	var running []string
+	for id := range portForwards.sessions {
+		running = append(running, id)
+	}
+	portForwards.mutex.Unlock()
+
+	if pf == nil {
+		sort.Strings(running)
+		if len(running) == 0 {
+			return &ExecResult{Error: fmt.Sprintf("port-forward %q not found; no port-forwards are running", id)}, nil
+		}
+		return &ExecResult{Error: fmt.Sprintf("port-forward %q not found; the running port-forwards are %s", id, strings.Join(running, ", "))}, nil
+	}
+
+	exited := false
+	select {
+	case <-pf.done:
+		exited = true
+	default:
+		pf.stop()
+	}
+	result := pf.execResult()
+	// kubectl exits with an error when it is killed, as it would when interrupted; that is expected here
+	result.ExitCode = 0
+	if exited {
+		result.Note = fmt.Sprintf("port-forward %s had already exited (%v) before it was closed", id, pf.err)
+	} else {
+		result.Note = fmt.Sprintf("port-forward %s closed", id)
+	}
+	klog.Infof("closed port-forward %s", id)
+	return result, nil
+}
+
+// lockedBuffer is a bytes.Buffer that can be read while a command is writing to it.
+type lockedBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+type portForwardRenderer struct{}
+
+func (r *portForwardRenderer) Schema() *gollm.Schema {
+	return schemaFor(PortForwardSession{})
+}
+
+func (r *portForwardRenderer) RenderForLLM(result TypedResult) (map[string]any, error) {
+	return ToolResultToMap(result)
+}
+
+func (r *portForwardRenderer) RenderForUI(result TypedResult) ui.Block {
+	session := result.(*PortForwardSession)
+	var rows [][]string
+	for _, forwarding := range session.Forwarding {
+		local, remote, _ := strings.Cut(forwarding, " -> ")
+		rows = append(rows, []string{local, remote})
+	}
+	return ui.NewTableBlock().
+		SetTitle(fmt.Sprintf("port-forward %s (running in the background)", session.ID)).
+		SetTable([]string{"LOCAL", "REMOTE"}, rows)
+}
@@ -0,0 +1,130 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPortForwardExitsBeforeForwarding(t *testing.T) {
+	installFakeKubectl(t, `
+echo 'error: services "web" not found' >&2
+exit 1
+`)
+	t.Cleanup(ClosePortForwards)
+
+	response, err := runShellCommand(context.Background(), "kubectl port-forward svc/web 8080:80", t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("runShellCommand failed: %v", err)
+	}
+	result, ok := response.(*ExecResult)
+	if !ok {
+		t.Fatalf("got %T, want an *ExecResult", response)
+	}
+	if result.ExitCode != 1 || !strings.Contains(result.Stderr, `services "web" not found`) {
+		t.Errorf("got result %+v, want the error of kubectl", result)
+	}
+
+	portForwards.mutex.Lock()
+	sessions := len(portForwards.sessions)
+	portForwards.mutex.Unlock()
+	if sessions != 0 {
+		t.Errorf("got %d sessions, want none for a port-forward that exited", sessions)
+	}
+}
+
+func TestPortForwardSession(t *testing.T) {
+	installFakeKubectl(t, `
+echo "Forwarding from 127.0.0.1:8080 -> 80"
+echo "Forwarding from [::1]:8080 -> 80"
+echo "Handling connection for 8080"
+exec sleep 60
+`)
+	t.Cleanup(ClosePortForwards)
+
+	response, err := runShellCommand(context.Background(), "kubectl port-forward svc/web 8080:80", t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("runShellCommand failed: %v", err)
+	}
+	session, ok := response.(*PortForwardSession)
+	if !ok {
+		t.Fatalf("got %+v, want a *PortForwardSession", response)
+	}
+	if want := []string{"127.0.0.1:8080 -> 80", "[::1]:8080 -> 80"}; !reflect.DeepEqual(session.Forwarding, want) {
+		t.Errorf("got forwarding %q, want %q", session.Forwarding, want)
+	}
+	if session.Command != "kubectl port-forward svc/web 8080:80" {
+		t.Errorf("got command %q", session.Command)
+	}
+
+	closeTool := &ClosePortForward{}
+	response, err = closeTool.Run(context.Background(), map[string]any{"id": session.ID})
+	if err != nil {
+		t.Fatalf("closing the port-forward: %v", err)
+	}
+	result := response.(*ExecResult)
+	if result.Error != "" || result.ExitCode != 0 || result.Note != "port-forward "+session.ID+" closed" {
+		t.Errorf("got result %+v, want the session closed", result)
+	}
+	if !strings.Contains(result.Stdout, "Handling connection for 8080") {
+		t.Errorf("got stdout %q, want the output of kubectl", result.Stdout)
+	}
+
+	// It can only be closed once
+	response, err = closeTool.Run(context.Background(), map[string]any{"id": session.ID})
+	if err != nil {
+		t.Fatalf("closing the port-forward again: %v", err)
+	}
+	if result := response.(*ExecResult); !strings.Contains(result.Error, "no port-forwards are running") {
+		t.Errorf("got error %q, want the session not found", result.Error)
+	}
+}
+
+func TestClosePortForwardAfterExit(t *testing.T) {
+	installFakeKubectl(t, `
+echo "Forwarding from 127.0.0.1:8080 -> 80"
+read line
+echo "error: lost connection to pod" >&2
+exit 1
+`)
+	t.Cleanup(ClosePortForwards)
+
+	// kubectl exits when its input is closed, after it has started forwarding
+	response, err := runShellCommand(context.Background(), "kubectl port-forward pod/web 8080:80", t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("runShellCommand failed: %v", err)
+	}
+	session, ok := response.(*PortForwardSession)
+	if !ok {
+		t.Fatalf("got %+v, want a *PortForwardSession", response)
+	}
+
+	portForwards.mutex.Lock()
+	pf := portForwards.sessions[session.ID]
+	portForwards.mutex.Unlock()
+	<-pf.done
+
+	response, err = (&ClosePortForward{}).Run(context.Background(), map[string]any{"id": session.ID})
+	if err != nil {
+		t.Fatalf("closing the port-forward: %v", err)
+	}
+	result := response.(*ExecResult)
+	if !strings.Contains(result.Note, "had already exited") || !strings.Contains(result.Stderr, "lost connection") {
+		t.Errorf("got result %+v, want the session reported as exited, with its output", result)
+	}
+}
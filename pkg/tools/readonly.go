@@ -0,0 +1,150 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/kubectlcmd"
+)
+
+// readOnlyFromContext returns true if InvokeTool was asked to refuse commands that may modify the cluster.
+func readOnlyFromContext(ctx context.Context) bool {
+	readOnly, _ := ctx.Value("read_only").(bool)
+	return readOnly
+}
+
+// readOnlyFilterCommands are the commands that may read the output of kubectl in a pipeline.
+// Commands that can write files or run other commands (such as sed, awk and xargs) are not included.
+var readOnlyFilterCommands = map[string]bool{
+	"grep": true, "egrep": true, "fgrep": true,
+	"head": true, "tail": true,
+	"wc": true, "sort": true, "uniq": true, "cut": true, "tr": true, "column": true,
+	"jq": true, "base64": true,
+}
+
+// identityFlags are the kubectl flags that change who a command runs as. They are refused in read-only mode, and
+// whenever the agent runs as an identity other than the user's own, so that the identity cannot be bypassed.
+var identityFlags = []string{
+	"--as", "--as-group", "--as-uid", "--user", "--token", "--username", "--password",
+	"--kubeconfig", "--client-certificate", "--client-key",
+}
+
+// lockIdentityFromContext returns true if InvokeTool was asked to refuse commands that override the agent's identity.
+func lockIdentityFromContext(ctx context.Context) bool {
+	lockIdentity, _ := ctx.Value("lock_identity").(bool)
+	return lockIdentity
+}
+
+// checkIdentityOverrides returns an error if the command changes who kubectl runs as, with one of the identityFlags
+// or by setting $KUBECONFIG.
+func checkIdentityOverrides(command string) error {
+	line, err := kubectlcmd.Parse(command)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range line.Commands {
+		assignments := cmd.Env
+		if cmd.Program() == "export" {
+			assignments = append(append([]string{}, cmd.Env...), cmd.Args...)
+		}
+		for _, assignment := range assignments {
+			if strings.HasPrefix(assignment, "KUBECONFIG=") {
+				return fmt.Errorf("setting KUBECONFIG is not allowed")
+			}
+		}
+		if cmd.Kubectl == nil {
+			continue
+		}
+		for _, flag := range identityFlags {
+			if cmd.Kubectl.HasFlag(flag) {
+				return fmt.Errorf("the %s flag is not allowed", flag)
+			}
+		}
+	}
+	return nil
+}
+
+// checkReadOnlyKubectlCommand returns an error unless the command only runs read-only kubectl commands,
+// optionally piped through simple filters such as grep.
+func checkReadOnlyKubectlCommand(command string) error {
+	line, err := kubectlcmd.Parse(command)
+	if err != nil {
+		return err
+	}
+	switch {
+	case line.Substitution:
+		return fmt.Errorf("command substitution is not allowed")
+	case line.Subshell:
+		return fmt.Errorf("subshells are not allowed")
+	case line.ControlFlow:
+		return fmt.Errorf("loops and conditionals are not allowed")
+	}
+
+	for _, cmd := range line.Commands {
+		if cmd.Background {
+			return fmt.Errorf("background commands are not allowed")
+		}
+		if len(cmd.Env) != 0 {
+			return fmt.Errorf("setting environment variables is not allowed")
+		}
+		for _, redirect := range cmd.Redirects {
+			// Allow only discarding output, e.g. 2>/dev/null or 2>&1
+			if !redirect.Discards() {
+				return fmt.Errorf("redirection is not allowed")
+			}
+		}
+		if cmd.Name == "" {
+			continue
+		}
+
+		name := cmd.Program()
+		if name == "kubectl" {
+			if err := checkReadOnlyKubectlInvocation(cmd.Kubectl); err != nil {
+				return err
+			}
+			continue
+		}
+		if !cmd.Piped || !readOnlyFilterCommands[name] {
+			return fmt.Errorf("%q is not allowed; only read-only kubectl commands (optionally piped to filters such as grep or jq) can be run", name)
+		}
+	}
+	return nil
+}
+
+// checkReadOnlyKubectlInvocation checks a single kubectl invocation.
+func checkReadOnlyKubectlInvocation(invocation *kubectlcmd.Invocation) error {
+	for _, flag := range identityFlags {
+		if invocation.HasFlag(flag) {
+			return fmt.Errorf("the %s flag is not allowed in read-only mode", flag)
+		}
+	}
+
+	if invocation.Verb == "" {
+		return fmt.Errorf("kubectl requires a command")
+	}
+	switch invocation.Class() {
+	case kubectlcmd.ClassRead:
+		return nil
+	case kubectlcmd.ClassStreaming:
+		// Watching and following logs only read, but attach, port-forward and proxy give access to the workloads
+		if !invocation.OpensAccess() {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not allowed in read-only mode", invocation)
+}
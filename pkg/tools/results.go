@@ -0,0 +1,156 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui"
+	"k8s.io/klog/v2"
+)
+
+// TypedResult is implemented by tool results that have a well-known structure (rather than raw stdout/stderr),
+// so that they can be rendered compactly for the LLM and richly in the UI.
+type TypedResult interface {
+	// ResultType identifies the kind of result; it is the key into the renderer registry.
+	ResultType() string
+}
+
+// ResultRenderer renders a type of TypedResult.
+type ResultRenderer interface {
+	// Schema describes the result as sent to the LLM by RenderForLLM.
+	Schema() *gollm.Schema
+
+	// RenderForLLM returns the compact representation of the result that is sent to the LLM.
+	RenderForLLM(result TypedResult) (map[string]any, error)
+
+	// RenderForUI returns a block showing the result to the user, or nil if the result should not be shown.
+	RenderForUI(result TypedResult) ui.Block
+}
+
+var resultRenderers = struct {
+	mutex     sync.Mutex
+	renderers map[string]ResultRenderer
+}{renderers: make(map[string]ResultRenderer)}
+
+// RegisterResultRenderer registers the renderer for a result type; it is intended to be called from init.
+func RegisterResultRenderer(resultType string, renderer ResultRenderer) {
+	resultRenderers.mutex.Lock()
+	defer resultRenderers.mutex.Unlock()
+
+	if _, exists := resultRenderers.renderers[resultType]; exists {
+		panic("result renderer " + resultType + " already registered")
+	}
+	resultRenderers.renderers[resultType] = renderer
+}
+
+func lookupResultRenderer(result any) (TypedResult, ResultRenderer) {
+	typed, ok := result.(TypedResult)
+	if !ok || isNil(typed) {
+		return nil, nil
+	}
+
+	resultRenderers.mutex.Lock()
+	defer resultRenderers.mutex.Unlock()
+
+	renderer := resultRenderers.renderers[typed.ResultType()]
+	if renderer == nil {
+		klog.Warningf("no renderer registered for tool result type %q", typed.ResultType())
+		return nil, nil
+	}
+	return typed, renderer
+}
+
+// RenderResultForLLM converts a tool result into the map sent to the LLM as the function call result.
+// Typed results are rendered by their registered renderer; other results are converted with ToolResultToMap.
+func RenderResultForLLM(result any) (map[string]any, error) {
+	typed, renderer := lookupResultRenderer(result)
+	if renderer == nil {
+		return ToolResultToMap(result)
+	}
+
+	m, err := renderer.RenderForLLM(typed)
+	if err != nil {
+		return nil, fmt.Errorf("rendering %s result: %w", typed.ResultType(), err)
+	}
+	m["type"] = typed.ResultType()
+	return m, nil
+}
+
+// RenderResultForUI returns a block to show a tool result to the user, or nil if there is nothing to show.
+// Only typed results are shown.
+func RenderResultForUI(result any) ui.Block {
+	typed, renderer := lookupResultRenderer(result)
+	if renderer == nil {
+		return nil
+	}
+	return renderer.RenderForUI(typed)
+}
+
+// ResultSchemas returns the schema of each registered result type, keyed by result type.
+// The "type" property of each schema identifies the result type in the rendered result.
+func ResultSchemas() map[string]*gollm.Schema {
+	resultRenderers.mutex.Lock()
+	defer resultRenderers.mutex.Unlock()
+
+	schemas := make(map[string]*gollm.Schema, len(resultRenderers.renderers))
+	for resultType, renderer := range resultRenderers.renderers {
+		schema := renderer.Schema()
+		if schema == nil {
+			continue
+		}
+		if schema.Type == gollm.TypeObject {
+			if schema.Properties == nil {
+				schema.Properties = make(map[string]*gollm.Schema)
+			}
+			schema.Properties["type"] = &gollm.Schema{Type: gollm.TypeString, Enum: []string{resultType}}
+		}
+		schemas[resultType] = schema
+	}
+	return schemas
+}
+
+// ResultTypes returns the registered result types, sorted.
+func ResultTypes() []string {
+	resultRenderers.mutex.Lock()
+	defer resultRenderers.mutex.Unlock()
+
+	var resultTypes []string
+	for resultType := range resultRenderers.renderers {
+		resultTypes = append(resultTypes, resultType)
+	}
+	sort.Strings(resultTypes)
+	return resultTypes
+}
+
+// schemaFor builds the schema for a result struct, for use in ResultRenderer.Schema.
+func schemaFor(v any) *gollm.Schema {
+	schema, err := gollm.BuildSchemaFor(reflect.TypeOf(v))
+	if err != nil {
+		klog.Warningf("building schema for %T: %v", v, err)
+		return nil
+	}
+	return schema
+}
+
+// isNil returns true for typed nil pointers wrapped in an interface.
+func isNil(v any) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
@@ -0,0 +1,240 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/kubectlcmd"
+	"k8s.io/klog/v2"
+)
+
+// The limits of commands that stream their output; they are variables so that tests don't have to wait.
+var (
+	// boundedCaptureTimeout is how long commands that stream their output (e.g. `kubectl logs -f`) run.
+	boundedCaptureTimeout = 10 * time.Second
+	// boundedCaptureMaxLines is how many lines of output are captured from commands that stream their output.
+	boundedCaptureMaxLines = 200
+)
+
+var (
+	errCaptureTimeout = errors.New("bounded capture timed out")
+	errCaptureLines   = errors.New("bounded capture reached its line limit")
+)
+
+// editOnlyFlags are the flags of `kubectl edit` that `kubectl get` does not accept.
+var editOnlyFlags = []string{
+	"--save-config", "--windows-line-endings", "--validate", "--field-manager", "--output-patch", "--record",
+}
+
+// runShellCommand runs a command line for the kubectl and bash tools. There is no user at a terminal, so kubectl
+// commands that need one, or that run until they are stopped, are adapted:
+//   - `kubectl port-forward` is started in the background, and returns a session that can be closed later
+//   - `kubectl edit` shows the resource instead, so that it can be changed with kubectl patch
+//   - `kubectl exec -it` runs the command without a terminal or input
+//   - commands that stream their output, such as `kubectl logs -f` and `kubectl get -w`, are stopped after
+//     boundedCaptureTimeout or boundedCaptureMaxLines lines, and return what they printed until then
+//
+// The first three are only adapted when they are the whole command line.
+func runShellCommand(ctx context.Context, command, workDir, kubeconfig string) (any, error) {
+	line, err := kubectlcmd.Parse(command)
+	if err != nil {
+		// bash accepts more than we understand, so the command is run as is
+		klog.V(2).Infof("not checking command %q: %v", command, err)
+		return runCommand(ctx, command, workDir, kubeconfig)
+	}
+
+	if name, invocation := singleKubectlCommand(line); invocation != nil {
+		switch {
+		case invocation.Verb == "port-forward":
+			return startPortForward(ctx, name, invocation, workDir, kubeconfig)
+
+		case invocation.Verb == "edit":
+			// edit accepts -o json or yaml, as get does
+			args := invocation.Rewrite("get", editOnlyFlags...)
+			if invocation.Output == "" {
+				args = append(args, "-o", "yaml")
+			}
+			result, err := runBounded(ctx, shellQuote(name, args), workDir, kubeconfig)
+			if err != nil {
+				return nil, err
+			}
+			result.Note = editNote(invocation)
+			return result, nil
+
+		case invocation.Verb == "exec" && invocation.Class() == kubectlcmd.ClassInteractive && len(invocation.Command) != 0:
+			args := invocation.Rewrite("exec", "-i", "--stdin", "-t", "--tty")
+			result, err := runBounded(ctx, shellQuote(name, args), workDir, kubeconfig)
+			if err != nil {
+				return nil, err
+			}
+			if result.Note == "" {
+				result.Note = "The command was run without a terminal or input, as there is no user to interact with it."
+			}
+			return result, nil
+		}
+	}
+
+	if message := unattendedCommandError(line); message != "" {
+		return &ExecResult{Error: message}, nil
+	}
+	if line.Class() == kubectlcmd.ClassStreaming {
+		return runBounded(ctx, command, workDir, kubeconfig)
+	}
+	return runCommand(ctx, command, workDir, kubeconfig)
+}
+
+// runCommand runs a command line with bash, until it exits.
+func runCommand(ctx context.Context, command, workDir, kubeconfig string) (*ExecResult, error) {
+	cmd, err := shellCommand(ctx, command, workDir, kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return executeCommand(cmd)
+}
+
+// runBounded runs a command line with bash until it exits, or until it has run for boundedCaptureTimeout or
+// printed boundedCaptureMaxLines lines, whichever comes first. A command that is stopped is not an error:
+// the result holds the output captured until then, and a note saying why it was stopped.
+func runBounded(ctx context.Context, command, workDir, kubeconfig string) (*ExecResult, error) {
+	boundedCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	boundedCtx, cancelTimeout := context.WithTimeoutCause(boundedCtx, boundedCaptureTimeout, errCaptureTimeout)
+	defer cancelTimeout()
+
+	cmd, err := shellCommand(boundedCtx, command, workDir, kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+
+	stdout := &lineLimitWriter{
+		maxLines:     boundedCaptureMaxLines,
+		limitReached: func() { cancel(errCaptureLines) },
+	}
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	result := &ExecResult{
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+	}
+	if ctx.Err() == nil {
+		switch cause := context.Cause(boundedCtx); {
+		case errors.Is(cause, errCaptureTimeout):
+			result.Note = fmt.Sprintf("The command streams its output until it is stopped, so it was stopped after %s; this is its output until then.", boundedCaptureTimeout)
+			return result, nil
+		case errors.Is(cause, errCaptureLines):
+			result.Note = fmt.Sprintf("The command was stopped after printing %d lines; this is its output until then.", boundedCaptureMaxLines)
+			return result, nil
+		}
+	}
+	if err != nil {
+		var exitError *exec.ExitError
+		if !errors.As(err, &exitError) {
+			return nil, err
+		}
+		result.ExitCode = exitError.ExitCode()
+	}
+	return result, nil
+}
+
+// lineLimitWriter captures output up to maxLines lines, calling limitReached when it has that many.
+// Further output is discarded.
+type lineLimitWriter struct {
+	maxLines     int
+	limitReached func()
+
+	lines  int
+	output bytes.Buffer
+}
+
+func (w *lineLimitWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) != 0 && w.lines < w.maxLines {
+		i := bytes.IndexByte(p, '\n')
+		if i == -1 {
+			w.output.Write(p)
+			break
+		}
+		w.output.Write(p[:i+1])
+		p = p[i+1:]
+		w.lines++
+		if w.lines == w.maxLines {
+			w.limitReached()
+		}
+	}
+	return n, nil
+}
+
+func (w *lineLimitWriter) String() string {
+	return w.output.String()
+}
+
+// singleKubectlCommand returns the program (e.g. "kubectl") and the kubectl invocation if the command line
+// runs a single kubectl command, with no input and no output redirected to files, or nil otherwise.
+func singleKubectlCommand(line *kubectlcmd.CommandLine) (string, *kubectlcmd.Invocation) {
+	if len(line.Commands) != 1 || line.Substitution || line.Subshell || line.ControlFlow {
+		return "", nil
+	}
+	command := line.Commands[0]
+	if command.Kubectl == nil || command.Program() != "kubectl" || len(command.Env) != 0 || command.StdinProvided() {
+		return "", nil
+	}
+	for _, redirect := range command.Redirects {
+		if !redirect.Discards() {
+			return "", nil
+		}
+	}
+	return command.Name, command.Kubectl
+}
+
+// editNote tells the LLM how to make the change it wanted to make with `kubectl edit`.
+func editNote(invocation *kubectlcmd.Invocation) string {
+	target := invocation.Resource
+	if len(invocation.Names) != 0 {
+		target += " " + invocation.Names[0]
+	}
+	if invocation.Namespace != "" {
+		target += " -n " + invocation.Namespace
+	}
+	return fmt.Sprintf("kubectl edit needs a user with an editor, so the resource was shown with kubectl get instead. "+
+		"To change it, use kubectl patch, e.g. `kubectl patch %s --type=merge -p '{\"spec\":{...}}'`, "+
+		"or kubectl apply with the changed manifest.", target)
+}
+
+// shellQuote returns a command line that runs the program with the arguments.
+func shellQuote(program string, args []string) string {
+	quoted := []string{program}
+	for _, arg := range args {
+		if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,@%+") == "" {
+			quoted = append(quoted, arg)
+			continue
+		}
+		quoted = append(quoted, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+	}
+	return strings.Join(quoted, " ")
+}
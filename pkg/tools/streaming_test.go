@@ -0,0 +1,270 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// installFakeKubectl puts a kubectl that runs the shell script first on the PATH.
+func installFakeKubectl(t *testing.T, script string) {
+	t.Helper()
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "kubectl"), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// setCaptureLimits lowers the limits of runBounded for the test.
+func setCaptureLimits(t *testing.T, timeout time.Duration, maxLines int) {
+	oldTimeout, oldMaxLines := boundedCaptureTimeout, boundedCaptureMaxLines
+	boundedCaptureTimeout, boundedCaptureMaxLines = timeout, maxLines
+	t.Cleanup(func() {
+		boundedCaptureTimeout, boundedCaptureMaxLines = oldTimeout, oldMaxLines
+	})
+}
+
+func TestLineLimitWriter(t *testing.T) {
+	limitReached := 0
+	w := &lineLimitWriter{maxLines: 3, limitReached: func() { limitReached++ }}
+
+	// Lines split across writes, and several lines in one write
+	for _, p := range []string{"one\ntw", "o\n", "three\nfour\n", "five\n"} {
+		if n, err := w.Write([]byte(p)); n != len(p) || err != nil {
+			t.Fatalf("Write(%q) = (%d, %v), want (%d, nil)", p, n, err, len(p))
+		}
+	}
+	if got, want := w.String(), "one\ntwo\nthree\n"; got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+	if limitReached != 1 {
+		t.Errorf("limitReached was called %d times, want once", limitReached)
+	}
+
+	// A last line without a newline is kept
+	w = &lineLimitWriter{maxLines: 3, limitReached: func() { t.Errorf("limitReached was called before the limit") }}
+	w.Write([]byte("one\ntwo"))
+	if got, want := w.String(), "one\ntwo"; got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+}
+
+func TestRunBounded(t *testing.T) {
+	tests := []struct {
+		name         string
+		command      string
+		timeout      time.Duration
+		maxLines     int
+		wantStdout   string
+		wantExitCode int
+		wantNote     string
+		// maxDuration is how long the command may take, to check that it was stopped
+		maxDuration time.Duration
+	}{
+		{
+			name:        "exits",
+			command:     "echo one; echo two >&2; exit 3",
+			timeout:     time.Minute,
+			maxLines:    10,
+			wantStdout:  "one\n",
+			maxDuration: 30 * time.Second,
+			// The exit code of a command that exits by itself is kept
+			wantExitCode: 3,
+		},
+		{
+			name:        "stopped after maxLines",
+			command:     "i=0; while true; do i=$((i+1)); echo line $i; done",
+			timeout:     time.Minute,
+			maxLines:    3,
+			wantStdout:  "line 1\nline 2\nline 3\n",
+			wantNote:    "stopped after printing 3 lines",
+			maxDuration: 30 * time.Second,
+		},
+		{
+			name:        "stopped after the timeout",
+			command:     "echo started; while true; do sleep 0.05; done",
+			timeout:     500 * time.Millisecond,
+			maxLines:    10,
+			wantStdout:  "started\n",
+			wantNote:    "stopped after 500ms",
+			maxDuration: 10 * time.Second,
+		},
+		{
+			name:        "stopped with a process in the background",
+			command:     "sleep 60 & echo started; wait",
+			timeout:     500 * time.Millisecond,
+			maxLines:    10,
+			wantStdout:  "started\n",
+			wantNote:    "stopped after 500ms",
+			maxDuration: 10 * time.Second,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setCaptureLimits(t, test.timeout, test.maxLines)
+
+			start := time.Now()
+			result, err := runBounded(context.Background(), test.command, t.TempDir(), "")
+			if err != nil {
+				t.Fatalf("runBounded failed: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > test.maxDuration {
+				t.Errorf("the command ran for %s, want it stopped within %s", elapsed, test.maxDuration)
+			}
+			if result.Stdout != test.wantStdout {
+				t.Errorf("got stdout %q, want %q", result.Stdout, test.wantStdout)
+			}
+			if result.ExitCode != test.wantExitCode {
+				t.Errorf("got exit code %d, want %d", result.ExitCode, test.wantExitCode)
+			}
+			if test.wantNote == "" && result.Note != "" || !strings.Contains(result.Note, test.wantNote) {
+				t.Errorf("got note %q, want it to contain %q", result.Note, test.wantNote)
+			}
+		})
+	}
+}
+
+func TestRunBoundedCancelled(t *testing.T) {
+	setCaptureLimits(t, time.Minute, 10)
+
+	// A command stopped because the user cancelled the request is an error, not a bounded capture
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	result, err := runBounded(ctx, "echo started; sleep 60", t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("runBounded failed: %v", err)
+	}
+	if result.Note != "" || result.ExitCode == 0 {
+		t.Errorf("got result %+v for a cancelled command, want it killed without a note", result)
+	}
+}
+
+func TestRunShellCommand(t *testing.T) {
+	// A fake kubectl that prints each of its arguments in brackets, and streams its output for logs -f
+	installFakeKubectl(t, `
+if [ "$1" = logs ]; then
+	while true; do echo log line; done
+fi
+for arg in "$@"; do printf '[%s]' "$arg"; done
+echo
+`)
+	setCaptureLimits(t, 10*time.Second, 5)
+
+	tests := []struct {
+		name       string
+		command    string
+		wantStdout string
+		wantNote   string
+		wantError  string
+	}{
+		{
+			name:       "edit shows the resource as YAML",
+			command:    "kubectl edit deployment web -n prod",
+			wantStdout: "[get][deployment][web][-n][prod][-o][yaml]\n",
+			wantNote:   "kubectl patch deployment web -n prod",
+		},
+		{
+			name:       "edit keeps the output format",
+			command:    "kubectl edit deploy/web -o json",
+			wantStdout: "[get][deploy/web][-o][json]\n",
+			wantNote:   "kubectl patch deploy web",
+		},
+		{
+			name:       "edit drops the flags get does not accept",
+			command:    "kubectl edit cm settings --save-config --windows-line-endings --validate=false --field-manager mine --output-patch --record",
+			wantStdout: "[get][cm][settings][-o][yaml]\n",
+			wantNote:   "kubectl patch cm settings",
+		},
+		{
+			name:       "exec -it runs without a terminal",
+			command:    `kubectl exec -it web -- sh -c 'echo "it'\''s $HOME"'`,
+			wantStdout: `[exec][web][--][sh][-c][echo "it's $HOME"]` + "\n",
+			wantNote:   "without a terminal or input",
+		},
+		{
+			name:       "streaming output is bounded",
+			command:    "kubectl logs -f web",
+			wantStdout: strings.Repeat("log line\n", 5),
+			wantNote:   "stopped after printing 5 lines",
+		},
+		{
+			name:       "other commands are run as they are",
+			command:    "kubectl get pods -o 'jsonpath={.items[*].metadata.name}'",
+			wantStdout: "[get][pods][-o][jsonpath={.items[*].metadata.name}]\n",
+		},
+		{
+			name:      "edit in a pipeline is refused",
+			command:   "kubectl edit deployment web | head -1",
+			wantError: "kubectl edit can only be run on its own",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := runShellCommand(context.Background(), test.command, t.TempDir(), "")
+			if err != nil {
+				t.Fatalf("runShellCommand failed: %v", err)
+			}
+			result, ok := response.(*ExecResult)
+			if !ok {
+				t.Fatalf("got %T, want an *ExecResult", response)
+			}
+			if result.Stdout != test.wantStdout {
+				t.Errorf("got stdout %q, want %q", result.Stdout, test.wantStdout)
+			}
+			if test.wantError == "" && result.Error != "" || !strings.Contains(result.Error, test.wantError) {
+				t.Errorf("got error %q, want it to contain %q", result.Error, test.wantError)
+			}
+			if test.wantNote == "" && result.Note != "" || !strings.Contains(result.Note, test.wantNote) {
+				t.Errorf("got note %q, want it to contain %q", result.Note, test.wantNote)
+			}
+		})
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"get", "pods", "-n", "kube-system", "--selector=app=web,tier!=db"}, want: "kubectl get pods -n kube-system '--selector=app=web,tier!=db'"},
+		{args: []string{"get", "deploy/web", "-o", "jsonpath={.spec}"}, want: "kubectl get deploy/web -o 'jsonpath={.spec}'"},
+		{args: []string{"label", "pod", "web", ""}, want: "kubectl label pod web ''"},
+		{args: []string{"exec", "web", "--", "sh", "-c", "echo 'hi' $HOME; ls *"}, want: `kubectl exec web -- sh -c 'echo '\''hi'\'' $HOME; ls *'`},
+		{args: []string{"annotate", "pod", "web", "note=two\nlines"}, want: "kubectl annotate pod web 'note=two\nlines'"},
+	}
+	for _, test := range tests {
+		got := shellQuote("kubectl", test.args)
+		if got != test.want {
+			t.Errorf("shellQuote(%q) = %q, want %q", test.args, got, test.want)
+		}
+
+		// bash splits the command line back into the same arguments
+		out, err := exec.Command(bashBin, "-c", strings.Replace(got, "kubectl", `printf '[%s]'`, 1)).Output()
+		if err != nil {
+			t.Fatalf("running %q: %v", got, err)
+		}
+		want := "[" + strings.Join(test.args, "][") + "]"
+		if string(out) != want {
+			t.Errorf("bash split %q into %q, want %q", got, out, want)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/kubectlcmd"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui"
+	"k8s.io/klog/v2"
+)
+
+func init() {
+	RegisterTool(&GetBackgroundTask{})
+	RegisterResultRenderer(ResultTypeBackgroundTask, &backgroundTaskRenderer{})
+}
+
+const (
+	ResultTypeBackgroundTask = "background_task"
+
+	getBackgroundTaskToolName = "get_background_task"
+
+	// backgroundTaskTimeout bounds how long a background task runs.
+	backgroundTaskTimeout = 30 * time.Minute
+	// maxBackgroundTaskWait bounds how long get_background_task waits for a task to finish.
+	maxBackgroundTaskWait = 5 * time.Minute
+)
+
+// runInBackgroundParameter is the parameter of the kubectl and bash tools that starts the command as a background task.
+var runInBackgroundParameter = &gollm.Schema{
+	Type: gollm.TypeBoolean,
+	Description: `Run the command in the background, for commands that take a long time, such as kubectl rollout status, kubectl wait or kubectl drain.
+Commands that stream their output, such as kubectl logs -f or kubectl get -w, run until they exit or the task is stopped, rather than for a few seconds.
+The call returns a task id straight away; use ` + getBackgroundTaskToolName + ` to check on the task or wait for it to finish.
+You are also told when background tasks have finished, before your next step.`,
+}
+
+// TaskStatus is the status of a background task.
+type TaskStatus string
+
+const (
+	TaskRunning   TaskStatus = "running"
+	TaskSucceeded TaskStatus = "succeeded"
+	TaskFailed    TaskStatus = "failed"
+)
+
+// BackgroundTask describes a command running in the background, started with run_in_background.
+type BackgroundTask struct {
+	ID      string     `json:"id"`
+	Command string     `json:"command"`
+	Status  TaskStatus `json:"status"`
+	// Started is when the task started, in RFC 3339 format.
+	Started string `json:"started"`
+	// Duration is how long the task has been running, or how long it ran once it has finished.
+	Duration string `json:"duration"`
+	// Result is the result of the command, once it has finished.
+	Result  *ExecResult `json:"result,omitempty"`
+	Message string      `json:"message,omitempty"`
+}
+
+func (r *BackgroundTask) ResultType() string {
+	return ResultTypeBackgroundTask
+}
+
+// backgroundTask is a command running in the background.
+type backgroundTask struct {
+	id      string
+	command string
+	started time.Time
+	cancel  context.CancelFunc
+
+	// done is closed when the command has finished, after which finished and result are set.
+	done     chan struct{}
+	finished time.Time
+	result   *ExecResult
+
+	// reported is set once the LLM has been told that the task finished.
+	reported bool
+}
+
+// backgroundTasks are the tasks started in this session, in the order they were started.
+var backgroundTasks = struct {
+	mutex  sync.Mutex
+	tasks  []*backgroundTask
+	lastID int
+	wg     sync.WaitGroup
+}{}
+
+// startBackgroundTask starts running a command line in the background. Unlike runShellCommand, commands that
+// stream their output are not stopped after boundedCaptureTimeout: they run until they exit, or the task is stopped.
+func startBackgroundTask(ctx context.Context, command, workDir, kubeconfig string) any {
+	if line, err := kubectlcmd.Parse(command); err == nil {
+		for _, invocation := range line.Invocations() {
+			switch invocation.Verb {
+			case "port-forward":
+				return &ExecResult{Error: "kubectl port-forward always runs in the background; run it without run_in_background"}
+			case "edit":
+				return &ExecResult{Error: "kubectl edit cannot run in the background; run it without run_in_background to see the resource, and change it with kubectl patch"}
+			}
+		}
+		if message := unattendedCommandError(line); message != "" {
+			return &ExecResult{Error: message}
+		}
+	}
+
+	// The task outlives the tool call, but keeps its values, such as the journal recorder
+	taskCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), backgroundTaskTimeout)
+	task := &backgroundTask{
+		command: command,
+		started: time.Now(),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	backgroundTasks.mutex.Lock()
+	backgroundTasks.lastID++
+	task.id = fmt.Sprintf("task-%d", backgroundTasks.lastID)
+	backgroundTasks.tasks = append(backgroundTasks.tasks, task)
+	backgroundTasks.wg.Add(1)
+	backgroundTasks.mutex.Unlock()
+
+	klog.Infof("started background task %s: %s", task.id, command)
+	go task.run(taskCtx, workDir, kubeconfig)
+
+	info := task.info()
+	info.Message = fmt.Sprintf("The command is running in the background. Use %s (id %q) to check on it, or to wait for it to finish.", getBackgroundTaskToolName, task.id)
+	return info
+}
+
+// run runs the command until it finishes, or the task is cancelled.
+func (t *backgroundTask) run(ctx context.Context, workDir, kubeconfig string) {
+	defer backgroundTasks.wg.Done()
+	defer t.cancel()
+
+	result, err := runBackgroundCommand(ctx, t.command, workDir, kubeconfig)
+	if err != nil {
+		result = &ExecResult{Error: err.Error()}
+	}
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		result.Error = fmt.Sprintf("the task was stopped after %s", backgroundTaskTimeout)
+	case context.Canceled:
+		result.Error = "the task was cancelled"
+	}
+
+	backgroundTasks.mutex.Lock()
+	t.finished = time.Now()
+	t.result = result
+	close(t.done)
+	backgroundTasks.mutex.Unlock()
+
+	info := t.info()
+	klog.Infof("background task %s %s after %s", t.id, info.Status, info.Duration)
+	journal.RecorderFromContext(ctx).Write(ctx, &journal.Event{
+		Timestamp: time.Now(),
+		Action:    "background-task-finished",
+		Payload:   info,
+	})
+}
+
+// runBackgroundCommand runs a command line with bash until it exits, or ctx is done. The processes it started are
+// stopped with it, and the output it printed until then is kept.
+func runBackgroundCommand(ctx context.Context, command, workDir, kubeconfig string) (*ExecResult, error) {
+	cmd, err := shellCommand(ctx, command, workDir, kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	return executeCommand(cmd)
+}
+
+// info describes the task. The result is only set once the task has finished.
+func (t *backgroundTask) info() *BackgroundTask {
+	info := &BackgroundTask{
+		ID:      t.id,
+		Command: t.command,
+		Status:  TaskRunning,
+		Started: t.started.Format(time.RFC3339),
+	}
+	select {
+	case <-t.done:
+		info.Result = t.result
+		info.Duration = t.finished.Sub(t.started).Round(time.Second).String()
+		if t.result.Error != "" || t.result.ExitCode != 0 {
+			info.Status = TaskFailed
+		} else {
+			info.Status = TaskSucceeded
+		}
+	default:
+		info.Duration = time.Since(t.started).Round(time.Second).String()
+	}
+	return info
+}
+
+// BackgroundTasks returns the background tasks started in this session, in the order they were started.
+func BackgroundTasks() []*BackgroundTask {
+	backgroundTasks.mutex.Lock()
+	defer backgroundTasks.mutex.Unlock()
+
+	var tasks []*BackgroundTask
+	for _, task := range backgroundTasks.tasks {
+		tasks = append(tasks, task.info())
+	}
+	return tasks
+}
+
+// FinishedBackgroundTasks returns the background tasks that have finished since the LLM was last told about them,
+// and marks them as reported.
+func FinishedBackgroundTasks() []*BackgroundTask {
+	backgroundTasks.mutex.Lock()
+	defer backgroundTasks.mutex.Unlock()
+
+	var tasks []*BackgroundTask
+	for _, task := range backgroundTasks.tasks {
+		if task.reported || task.result == nil {
+			continue
+		}
+		task.reported = true
+		tasks = append(tasks, task.info())
+	}
+	return tasks
+}
+
+// CancelBackgroundTasks stops the background tasks that are still running, and waits for them to finish.
+func CancelBackgroundTasks() {
+	backgroundTasks.mutex.Lock()
+	for _, task := range backgroundTasks.tasks {
+		task.cancel()
+	}
+	backgroundTasks.mutex.Unlock()
+
+	backgroundTasks.wg.Wait()
+}
+
+// GetBackgroundTask is the tool that checks on, or waits for, a background task.
+type GetBackgroundTask struct{}
+
+func (t *GetBackgroundTask) Name() string {
+	return getBackgroundTaskToolName
+}
+
+func (t *GetBackgroundTask) Description() string {
+	return "Returns the status of a command started with run_in_background, and its output once it has finished. It can wait for the command to finish."
+}
+
+// IsReadOnly implements ReadOnlyTool. It only reports on tasks; the commands they run were confirmed when they were started.
+func (t *GetBackgroundTask) IsReadOnly() bool {
+	return true
+}
+
+func (t *GetBackgroundTask) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"id": {
+					Type:        gollm.TypeString,
+					Description: `The id of the task, as returned when it was started, e.g. "task-1".`,
+				},
+				"wait_seconds": {
+					Type:        gollm.TypeInteger,
+					Description: fmt.Sprintf(`How long to wait for the task to finish, in seconds, at most %d. If 0 or omitted, the status is returned straight away.`, int(maxBackgroundTaskWait.Seconds())),
+				},
+			},
+			Required: []string{"id"},
+		},
+	}
+}
+
+func (t *GetBackgroundTask) Run(ctx context.Context, args map[string]any) (any, error) {
+	id, err := stringArgument(args, "id")
+	if err != nil {
+		return nil, err
+	}
+	var wait time.Duration
+	switch seconds := args["wait_seconds"].(type) {
+	case float64:
+		wait = time.Duration(seconds * float64(time.Second))
+	case int:
+		wait = time.Duration(seconds) * time.Second
+	}
+	wait = max(min(wait, maxBackgroundTaskWait), 0)
+
+	backgroundTasks.mutex.Lock()
+	var task *backgroundTask
+	var ids []string
+	for _, candidate := range backgroundTasks.tasks {
+		ids = append(ids, candidate.id)
+		if candidate.id == id {
+			task = candidate
+		}
+	}
+	backgroundTasks.mutex.Unlock()
+
+	if task == nil {
+		if len(ids) == 0 {
+			return &ExecResult{Error: fmt.Sprintf("background task %q not found; no tasks have been started", id)}, nil
+		}
+		return &ExecResult{Error: fmt.Sprintf("background task %q not found; the tasks are %s", id, strings.Join(ids, ", "))}, nil
+	}
+
+	if wait != 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-task.done:
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	backgroundTasks.mutex.Lock()
+	defer backgroundTasks.mutex.Unlock()
+	info := task.info()
+	if info.Status != TaskRunning {
+		// There is no need to report it again
+		task.reported = true
+	} else if wait != 0 {
+		info.Message = fmt.Sprintf("The task is still running after waiting %s.", wait)
+	}
+	return info, nil
+}
+
+type backgroundTaskRenderer struct{}
+
+func (r *backgroundTaskRenderer) Schema() *gollm.Schema {
+	return schemaFor(BackgroundTask{})
+}
+
+func (r *backgroundTaskRenderer) RenderForLLM(result TypedResult) (map[string]any, error) {
+	return ToolResultToMap(result)
+}
+
+func (r *backgroundTaskRenderer) RenderForUI(result TypedResult) ui.Block {
+	task := result.(*BackgroundTask)
+	return BackgroundTasksTable(task).SetTitle("background task " + task.ID)
+}
+
+// BackgroundTasksTable returns a table showing the status of the tasks.
+func BackgroundTasksTable(tasks ...*BackgroundTask) *ui.TableBlock {
+	var rows [][]string
+	for _, task := range tasks {
+		status := string(task.Status)
+		if task.Result != nil && task.Result.Error != "" {
+			status += ": " + task.Result.Error
+		} else if task.Result != nil && task.Result.ExitCode != 0 {
+			status += fmt.Sprintf(" (exit code %d)", task.Result.ExitCode)
+		}
+		rows = append(rows, []string{task.ID, status, task.Duration, task.Command})
+	}
+	return ui.NewTableBlock().
+		SetTitle(fmt.Sprintf("background tasks (%d)", len(tasks))).
+		SetTable([]string{"ID", "STATUS", "DURATION", "COMMAND"}, rows)
+}
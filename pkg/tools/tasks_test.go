@@ -0,0 +1,69 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackgroundTaskRunsStreamingCommandsToCompletion(t *testing.T) {
+	// A fake kubectl that streams more lines than a foreground call captures
+	bin := t.TempDir()
+	script := "#!/bin/sh\nseq 1 300\n"
+	if err := os.WriteFile(filepath.Join(bin, "kubectl"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Cleanup(CancelBackgroundTasks)
+
+	ctx := context.Background()
+	started, ok := startBackgroundTask(ctx, "kubectl logs -f web", t.TempDir(), "").(*BackgroundTask)
+	if !ok {
+		t.Fatalf("got %T from startBackgroundTask, want a task", started)
+	}
+	response, err := (&GetBackgroundTask{}).Run(ctx, map[string]any{"id": started.ID, "wait_seconds": 30})
+	if err != nil {
+		t.Fatalf("waiting for the task: %v", err)
+	}
+	task := response.(*BackgroundTask)
+	if task.Status != TaskSucceeded {
+		t.Fatalf("got status %q (result %+v), want %q", task.Status, task.Result, TaskSucceeded)
+	}
+	if got := strings.Count(task.Result.Stdout, "\n"); got != 300 {
+		t.Errorf("got %d lines of output, want all 300", got)
+	}
+	if task.Result.Note != "" {
+		t.Errorf("got note %q, want none", task.Result.Note)
+	}
+}
+
+func TestBackgroundTaskRejectsUnattendedCommands(t *testing.T) {
+	for _, command := range []string{
+		"kubectl port-forward svc/web 8080:80",
+		"kubectl edit deployment web",
+		"kubectl exec -it web -- sh",
+	} {
+		t.Run(command, func(t *testing.T) {
+			result, ok := startBackgroundTask(context.Background(), command, t.TempDir(), "").(*ExecResult)
+			if !ok || result.Error == "" {
+				t.Errorf("got %+v, want an error", result)
+			}
+		})
+	}
+}
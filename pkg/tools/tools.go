@@ -17,6 +17,7 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"maps"
 	"slices"
@@ -25,7 +26,10 @@ import (
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/kubectlcmd"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/telemetry"
 	"github.com/google/uuid"
+	"k8s.io/klog/v2"
 )
 
 func Lookup(name string) Tool {
@@ -65,6 +69,17 @@ func (t *Tools) Names() []string {
 	return names
 }
 
+// Without returns a copy of the tools, without the named tools.
+func (t *Tools) Without(names ...string) Tools {
+	filtered := Tools{tools: make(map[string]Tool)}
+	for name, tool := range t.tools {
+		if !slices.Contains(names, name) {
+			filtered.tools[name] = tool
+		}
+	}
+	return filtered
+}
+
 func (t *Tools) RegisterTool(tool Tool) {
 	if _, exists := t.tools[tool.Name()]; exists {
 		panic("tool already registered: " + tool.Name())
@@ -78,9 +93,14 @@ type ToolCall struct {
 	arguments map[string]any
 }
 
+// Name returns the name of the tool being called.
+func (t *ToolCall) Name() string {
+	return t.name
+}
+
 func (t *ToolCall) PrettyPrint() string {
-	if command, ok := t.arguments["command"]; ok {
-		return command.(string)
+	if command, ok := t.arguments["command"].(string); ok {
+		return command
 	}
 	var args []string
 	for k, v := range t.arguments {
@@ -90,11 +110,81 @@ func (t *ToolCall) PrettyPrint() string {
 	return fmt.Sprintf("%s(%s)", t.name, strings.Join(args, ", "))
 }
 
+// ModifiesResource returns whether the call may modify a resource: "yes", "no" or "unknown".
+// Calls to a ReadOnlyTool are "no". For commands, what we can tell from the command overrides the LLM's own
+// assessment (the modifies_resource argument): a command that we can't parse, or that runs kubectl commands which
+// write to the cluster or open access to it (such as port-forward), is "yes", and a command that runs programs whose
+// effect we can't tell (such as $KUBECTL or helm) is at least "unknown". Otherwise, this is the LLM's assessment.
+func (t *ToolCall) ModifiesResource() string {
+	if readOnly, ok := t.tool.(ReadOnlyTool); ok && readOnly.IsReadOnly() {
+		return "no"
+	}
+	modifies, _ := t.arguments["modifies_resource"].(string)
+	if command, ok := t.arguments["command"].(string); ok {
+		line, err := kubectlcmd.Parse(command)
+		if err != nil {
+			klog.Infof("cannot parse command %q (%v), so assuming that it modifies resources", command, err)
+			return "yes"
+		}
+		if line.Class().MayModify() {
+			if modifies != "yes" {
+				klog.Infof("command %q runs %s commands, although it was described as modifies_resource=%q", command, line.Class(), modifies)
+			}
+			return "yes"
+		}
+		for _, invocation := range line.Invocations() {
+			if invocation.OpensAccess() {
+				return "yes"
+			}
+		}
+		if line.Opaque() && modifies != "yes" {
+			return "unknown"
+		}
+	}
+	if modifies == "" {
+		return "unknown"
+	}
+	return modifies
+}
+
+// OverridesIdentity returns true if the call runs a command that may change who kubectl runs as, with flags such as
+// --as or --kubeconfig or by setting $KUBECONFIG, or a command that cannot be parsed.
+func (t *ToolCall) OverridesIdentity() bool {
+	command, ok := t.arguments["command"].(string)
+	return ok && checkIdentityOverrides(command) != nil
+}
+
+// InvalidToolCallError is returned when the LLM requests a tool that does not exist, or passes invalid arguments.
+// It should be reported back to the LLM so that it can correct the call.
+type InvalidToolCallError struct {
+	Name string
+	Err  error
+}
+
+func (e *InvalidToolCallError) Error() string {
+	return fmt.Sprintf("invalid call to tool %q: %v", e.Name, e.Err)
+}
+
+func (e *InvalidToolCallError) Unwrap() error {
+	return e.Err
+}
+
 // ParseToolInvocation parses a request from the LLM into a tool call.
+// The arguments are validated against the tool's schema; problems are returned as an *InvalidToolCallError.
 func (t *Tools) ParseToolInvocation(ctx context.Context, name string, arguments map[string]any) (*ToolCall, error) {
 	tool := t.Lookup(name)
 	if tool == nil {
-		return nil, fmt.Errorf("tool %q not recognized", name)
+		return nil, &InvalidToolCallError{
+			Name: name,
+			Err:  fmt.Errorf("tool %q not recognized, available tools are: %s", name, strings.Join(t.sortedNames(), ", ")),
+		}
+	}
+
+	if arguments == nil {
+		arguments = make(map[string]any)
+	}
+	if err := ValidateToolArguments(tool, arguments); err != nil {
+		return nil, &InvalidToolCallError{Name: name, Err: err}
 	}
 
 	return &ToolCall{
@@ -104,20 +194,82 @@ func (t *Tools) ParseToolInvocation(ctx context.Context, name string, arguments
 	}, nil
 }
 
+// ValidateToolArguments checks the arguments against the schema in the tool's FunctionDefinition.
+func ValidateToolArguments(tool Tool, arguments map[string]any) error {
+	schema := tool.FunctionDefinition().Parameters
+	if schema == nil {
+		return nil
+	}
+	// Convert to map[string]any explicitly, so that a nil map is validated as an empty object.
+	var value any = arguments
+	if arguments == nil {
+		value = map[string]any{}
+	}
+	if err := schema.Validate(value); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+func (t *Tools) sortedNames() []string {
+	names := t.Names()
+	sort.Strings(names)
+	return names
+}
+
+// kubeconfigFromContext returns the kubeconfig path set by InvokeTool, or "" if none is set.
+func kubeconfigFromContext(ctx context.Context) string {
+	kubeconfig, _ := ctx.Value("kubeconfig").(string)
+	return kubeconfig
+}
+
+// workDirFromContext returns the working directory set by InvokeTool, or "" if none is set.
+func workDirFromContext(ctx context.Context) string {
+	workDir, _ := ctx.Value("work_dir").(string)
+	return workDir
+}
+
+// stringArgument returns a required string argument, or an error if it is missing or not a string.
+func stringArgument(args map[string]any, name string) (string, error) {
+	v, found := args[name]
+	if !found {
+		return "", fmt.Errorf("argument %q is required", name)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("argument %q must be a string, got %T", name, v)
+	}
+	return s, nil
+}
+
 type InvokeToolOptions struct {
 	WorkDir string
 
 	Kubeconfig string
+
+	// ReadOnly makes tools refuse to run commands that may modify the cluster.
+	ReadOnly bool
+
+	// Identity describes who the Kubeconfig authenticates as, for the journal.
+	Identity string
+
+	// LockIdentity makes tools refuse commands that override the identity of the Kubeconfig, with kubectl flags
+	// such as --as or --kubeconfig or by setting $KUBECONFIG.
+	LockIdentity bool
 }
 
 type ToolRequestEvent struct {
 	CallID    string         `json:"id,omitempty"`
 	Name      string         `json:"name,omitempty"`
 	Arguments map[string]any `json:"arguments,omitempty"`
+	// Identity is who the call ran as, e.g. an impersonated user or a service account.
+	Identity string `json:"identity,omitempty"`
 }
 
 type ToolResponseEvent struct {
-	CallID   string `json:"id,omitempty"`
+	CallID string `json:"id,omitempty"`
+	// Name is the tool, so that the response can be redacted with its rules.
+	Name     string `json:"name,omitempty"`
 	Response any    `json:"response,omitempty"`
 	Error    string `json:"error,omitempty"`
 }
@@ -134,17 +286,23 @@ func (t *ToolCall) InvokeTool(ctx context.Context, opt InvokeToolOptions) (any,
 			CallID:    callID,
 			Name:      t.name,
 			Arguments: t.arguments,
+			Identity:  opt.Identity,
 		},
 	})
 
 	ctx = context.WithValue(ctx, "kubeconfig", opt.Kubeconfig)
 	ctx = context.WithValue(ctx, "work_dir", opt.WorkDir)
+	ctx = context.WithValue(ctx, "read_only", opt.ReadOnly)
+	ctx = context.WithValue(ctx, "lock_identity", opt.LockIdentity)
 
+	ctx, span := telemetry.StartToolCall(ctx, t.name)
 	response, err := t.tool.Run(ctx, t.arguments)
+	span.End(toolCallOutcome(response, err))
 
 	{
 		ev := ToolResponseEvent{
 			CallID:   callID,
+			Name:     t.name,
 			Response: response,
 		}
 		if err != nil {
@@ -157,7 +315,26 @@ func (t *ToolCall) InvokeTool(ctx context.Context, opt InvokeToolOptions) (any,
 		})
 	}
 
-	return response, nil
+	return response, err
+}
+
+// toolCallOutcome returns the exit code of a tool call that ran a command, and an error if the call failed
+// (including commands that could not run, or exited with an error), for telemetry.
+func toolCallOutcome(response any, err error) (*int, error) {
+	if err != nil {
+		return nil, err
+	}
+	execResult, ok := response.(*ExecResult)
+	if !ok {
+		return nil, nil
+	}
+	if execResult.Error != "" {
+		return &execResult.ExitCode, errors.New(execResult.Error)
+	}
+	if execResult.ExitCode != 0 {
+		return &execResult.ExitCode, fmt.Errorf("command exited with code %d", execResult.ExitCode)
+	}
+	return &execResult.ExitCode, nil
 }
 
 // ToolResultToMap converts an arbitrary result to a map[string]any
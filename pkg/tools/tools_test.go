@@ -0,0 +1,258 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestModifiesResource(t *testing.T) {
+	tests := []struct {
+		name      string
+		tool      string
+		arguments map[string]any
+		want      string
+	}{
+		{
+			name:      "read-only command, as described",
+			tool:      "kubectl",
+			arguments: map[string]any{"command": "kubectl get pods", "modifies_resource": "no"},
+			want:      "no",
+		},
+		{
+			name:      "writes, although described as read-only",
+			tool:      "kubectl",
+			arguments: map[string]any{"command": "kubectl get pods && kubectl delete pod web", "modifies_resource": "no"},
+			want:      "yes",
+		},
+		{
+			name:      "unknown kubectl command",
+			tool:      "bash",
+			arguments: map[string]any{"command": "kubectl neat get pod web", "modifies_resource": "no"},
+			want:      "yes",
+		},
+		{
+			name:      "port-forward opens access to the cluster",
+			tool:      "kubectl",
+			arguments: map[string]any{"command": "kubectl port-forward svc/web 8080:80", "modifies_resource": "no"},
+			want:      "yes",
+		},
+		{
+			name:      "following logs only reads",
+			tool:      "kubectl",
+			arguments: map[string]any{"command": "kubectl logs -f web", "modifies_resource": "no"},
+			want:      "no",
+		},
+		{
+			name:      "cannot be parsed",
+			tool:      "bash",
+			arguments: map[string]any{"command": "case x in a) kubectl delete pod x;; esac", "modifies_resource": "no"},
+			want:      "yes",
+		},
+		{
+			name:      "kubectl in a variable",
+			tool:      "bash",
+			arguments: map[string]any{"command": "$K delete pod web", "modifies_resource": "no"},
+			want:      "unknown",
+		},
+		{
+			name:      "helm",
+			tool:      "bash",
+			arguments: map[string]any{"command": "helm uninstall web", "modifies_resource": "no"},
+			want:      "unknown",
+		},
+		{
+			name:      "helm, described as modifying",
+			tool:      "bash",
+			arguments: map[string]any{"command": "helm uninstall web", "modifies_resource": "yes"},
+			want:      "yes",
+		},
+		{
+			name:      "other programs are as described",
+			tool:      "bash",
+			arguments: map[string]any{"command": "ls /tmp", "modifies_resource": "no"},
+			want:      "no",
+		},
+		{
+			name:      "not described",
+			tool:      "bash",
+			arguments: map[string]any{"command": "ls /tmp"},
+			want:      "unknown",
+		},
+		{
+			name:      "read-only tool",
+			tool:      "get_background_task",
+			arguments: map[string]any{"id": "1"},
+			want:      "no",
+		},
+	}
+
+	tools := Default()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			call, err := tools.ParseToolInvocation(context.Background(), test.tool, test.arguments)
+			if err != nil {
+				t.Fatalf("ParseToolInvocation failed: %v", err)
+			}
+			if got := call.ModifiesResource(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckReadOnlyKubectlCommand(t *testing.T) {
+	allowed := []string{
+		"kubectl get pods -n prod",
+		"kubectl describe deploy web",
+		"kubectl logs web --tail=100 2>&1",
+		"kubectl get pods -w",
+		"kubectl get pods -o json | jq '.items[].metadata.name' | sort | uniq -c",
+		"kubectl get events 2>/dev/null | grep -i error | head -20",
+		"kubectl delete pod web --dry-run=server",
+		"kubectl auth can-i list pods",
+	}
+	for _, command := range allowed {
+		if err := checkReadOnlyKubectlCommand(command); err != nil {
+			t.Errorf("checkReadOnlyKubectlCommand(%q) = %v, want it allowed", command, err)
+		}
+	}
+
+	refused := []string{
+		"kubectl delete pod web",
+		"kubectl get pods && kubectl delete pod web",
+		"kubectl get pods -o name | xargs kubectl delete",
+		"kubectl logs $(kubectl get pods -o name | head -1)",
+		"(kubectl get pods)",
+		"for i in 1 2; do kubectl get pods; done",
+		"kubectl get pods &",
+		"KUBECONFIG=/tmp/admin kubectl get pods",
+		"kubectl get pods > pods.txt",
+		"kubectl get pods --as=system:admin",
+		"kubectl get pods --kubeconfig=/tmp/admin",
+		"kubectl port-forward svc/web 8080:80",
+		"kubectl exec web -- ls",
+		"kubectl edit deploy/web",
+		"kubectl",
+		"grep secret /etc/passwd",
+		"kubectl get pods | sh",
+		"kubectl get pods | sed -e 's/a/b/w /tmp/out'",
+		"bash -c 'kubectl get pods'",
+		"env kubectl get pods",
+		"$K get pods",
+		"kubectl.exe get pods",
+		"kubectl apply -f - <<EOF\nkind: List\nEOF",
+		"kubectl get pods -l 'app=web",
+	}
+	for _, command := range refused {
+		if err := checkReadOnlyKubectlCommand(command); err == nil {
+			t.Errorf("checkReadOnlyKubectlCommand(%q) succeeded, want it refused", command)
+		}
+	}
+}
+
+func TestCheckIdentityOverrides(t *testing.T) {
+	allowed := []string{
+		"kubectl get pods -n prod",
+		"kubectl config current-context",
+		"FOO=bar kubectl get pods",
+		"export NAMESPACE=prod",
+		"kubectl get pods -o name | xargs kubectl delete",
+	}
+	for _, command := range allowed {
+		if err := checkIdentityOverrides(command); err != nil {
+			t.Errorf("checkIdentityOverrides(%q) = %v, want it allowed", command, err)
+		}
+	}
+
+	refused := []string{
+		"kubectl delete pod web --as=system:admin",
+		"kubectl get pods --as-group system:masters --as admin",
+		"kubectl get secrets --token=abc",
+		"kubectl --kubeconfig /tmp/admin get pods",
+		"kubectl get pods --user admin",
+		"kubectl get pods --client-certificate=admin.crt --client-key=admin.key",
+		"KUBECONFIG=/tmp/admin kubectl get pods",
+		"env KUBECONFIG=/tmp/admin kubectl get pods",
+		"export KUBECONFIG=/tmp/admin && kubectl get pods",
+		"KUBECONFIG=/tmp/admin",
+		"kubectl get pods -o name | xargs kubectl delete --as=admin",
+		"echo $(kubectl get secret web --token=abc)",
+		"kubectl get pods -l 'app=web",
+	}
+	for _, command := range refused {
+		if err := checkIdentityOverrides(command); err == nil {
+			t.Errorf("checkIdentityOverrides(%q) succeeded, want it refused", command)
+		}
+	}
+}
+
+func TestLockIdentity(t *testing.T) {
+	// A fake kubectl that reports its arguments, so that we can tell whether it ran
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho ran kubectl \"$@\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "kubectl"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	tools := Default()
+	tests := []struct {
+		name         string
+		tool         string
+		command      string
+		lockIdentity bool
+		wantRefused  bool
+		wantOverride bool
+	}{
+		{name: "kubectl with the agent's identity", tool: "kubectl", command: "kubectl describe pods", lockIdentity: true},
+		{name: "kubectl --as", tool: "kubectl", command: "kubectl describe pods --as=admin", lockIdentity: true, wantRefused: true, wantOverride: true},
+		{name: "kubectl with KUBECONFIG", tool: "kubectl", command: "KUBECONFIG=/tmp/admin kubectl describe pods", lockIdentity: true, wantRefused: true, wantOverride: true},
+		{name: "kubectl --as with the user's own identity", tool: "kubectl", command: "kubectl describe pods --as=admin", wantOverride: true},
+		// bash runs them, once the agent has asked for confirmation
+		{name: "bash --kubeconfig", tool: "bash", command: "kubectl --kubeconfig /tmp/admin describe pods", lockIdentity: true, wantOverride: true},
+		{name: "bash with the agent's identity", tool: "bash", command: "kubectl describe pods", lockIdentity: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			call, err := tools.ParseToolInvocation(context.Background(), test.tool, map[string]any{"command": test.command, "modifies_resource": "no"})
+			if err != nil {
+				t.Fatalf("ParseToolInvocation failed: %v", err)
+			}
+			if got := call.OverridesIdentity(); got != test.wantOverride {
+				t.Errorf("got OverridesIdentity() = %t, want %t", got, test.wantOverride)
+			}
+
+			response, err := call.InvokeTool(context.Background(), InvokeToolOptions{WorkDir: t.TempDir(), LockIdentity: test.lockIdentity})
+			if err != nil {
+				t.Fatalf("InvokeTool failed: %v", err)
+			}
+			result, ok := response.(*ExecResult)
+			if !ok {
+				t.Fatalf("got %T, want an *ExecResult", response)
+			}
+			if refused := !strings.Contains(result.Stdout, "ran kubectl"); refused != test.wantRefused {
+				t.Errorf("got result %+v, want refused %t", result, test.wantRefused)
+			}
+			if test.wantRefused && !strings.Contains(result.Error, "refusing") {
+				t.Errorf("got error %q, want the command refused", result.Error)
+			}
+		})
+	}
+}
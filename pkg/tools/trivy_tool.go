@@ -20,12 +20,17 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"sort"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui"
+	"k8s.io/klog/v2"
 )
 
 func init() {
 	RegisterTool(&ScanImageWithTrivy{})
+	RegisterResultRenderer(ResultTypeVulnerabilityReport, &vulnerabilityReportRenderer{})
 }
 
 type ScanImageWithTrivy struct {
@@ -59,7 +64,7 @@ func (t *ScanImageWithTrivy) FunctionDefinition() *gollm.FunctionDefinition {
 }
 
 func (t *ScanImageWithTrivy) Run(ctx context.Context, functionArgs map[string]any) (any, error) {
-	workDir := ctx.Value("work_dir").(string)
+	workDir := workDirFromContext(ctx)
 
 	if err := parseFunctionArgs(functionArgs, t); err != nil {
 		return nil, err
@@ -69,12 +74,155 @@ func (t *ScanImageWithTrivy) Run(ctx context.Context, functionArgs map[string]an
 		return nil, fmt.Errorf("image is required")
 	}
 
-	args := []string{"trivy", "image", t.Image}
+	args := []string{"trivy", "image", "--format", "json", "--quiet", t.Image}
 	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
 	cmd.Dir = workDir
 	cmd.Env = os.Environ()
 
-	return executeCommand(cmd)
+	result, err := executeCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode != 0 {
+		return result, nil
+	}
+
+	report, err := parseTrivyReport(t.Image, []byte(result.Stdout))
+	if err != nil {
+		klog.Warningf("unable to parse trivy output, returning raw output: %v", err)
+		return result, nil
+	}
+	return report, nil
+}
+
+const ResultTypeVulnerabilityReport = "vulnerability_report"
+
+// VulnerabilityReport is the result of scanning an image with trivy.
+type VulnerabilityReport struct {
+	Image string `json:"image"`
+	// SeverityCounts is the number of vulnerabilities found at each severity, such as "CRITICAL".
+	SeverityCounts  map[string]int  `json:"severityCounts"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+	// Omitted is the number of vulnerabilities left out of Vulnerabilities to keep the result small.
+	Omitted int `json:"omitted,omitempty"`
+}
+
+func (r *VulnerabilityReport) ResultType() string {
+	return ResultTypeVulnerabilityReport
+}
+
+// Vulnerability is a single vulnerability found in an image.
+type Vulnerability struct {
+	ID               string `json:"id"`
+	Severity         string `json:"severity"`
+	Package          string `json:"package"`
+	InstalledVersion string `json:"installedVersion,omitempty"`
+	FixedVersion     string `json:"fixedVersion,omitempty"`
+	Title            string `json:"title,omitempty"`
+}
+
+// trivyReport is the subset of the `trivy image --format json` output that we use.
+type trivyReport struct {
+	Results []struct {
+		Vulnerabilities []struct {
+			VulnerabilityID  string `json:"VulnerabilityID"`
+			PkgName          string `json:"PkgName"`
+			InstalledVersion string `json:"InstalledVersion"`
+			FixedVersion     string `json:"FixedVersion"`
+			Severity         string `json:"Severity"`
+			Title            string `json:"Title"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+// severityOrder ranks trivy severities, most severe first.
+var severityOrder = map[string]int{
+	"CRITICAL": 0,
+	"HIGH":     1,
+	"MEDIUM":   2,
+	"LOW":      3,
+	"UNKNOWN":  4,
+}
+
+// maxReportedVulnerabilities limits how many vulnerabilities we send to the LLM; the counts are always complete.
+const maxReportedVulnerabilities = 50
+
+func parseTrivyReport(image string, b []byte) (*VulnerabilityReport, error) {
+	var raw trivyReport
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("parsing trivy json: %w", err)
+	}
+
+	report := &VulnerabilityReport{
+		Image:          image,
+		SeverityCounts: make(map[string]int),
+	}
+	for _, result := range raw.Results {
+		for _, v := range result.Vulnerabilities {
+			report.SeverityCounts[v.Severity]++
+			report.Vulnerabilities = append(report.Vulnerabilities, Vulnerability{
+				ID:               v.VulnerabilityID,
+				Severity:         v.Severity,
+				Package:          v.PkgName,
+				InstalledVersion: v.InstalledVersion,
+				FixedVersion:     v.FixedVersion,
+				Title:            v.Title,
+			})
+		}
+	}
+
+	rank := func(severity string) int {
+		if r, found := severityOrder[severity]; found {
+			return r
+		}
+		return len(severityOrder)
+	}
+	sort.SliceStable(report.Vulnerabilities, func(i, j int) bool {
+		return rank(report.Vulnerabilities[i].Severity) < rank(report.Vulnerabilities[j].Severity)
+	})
+	if len(report.Vulnerabilities) > maxReportedVulnerabilities {
+		report.Omitted = len(report.Vulnerabilities) - maxReportedVulnerabilities
+		report.Vulnerabilities = report.Vulnerabilities[:maxReportedVulnerabilities]
+	}
+	return report, nil
+}
+
+type vulnerabilityReportRenderer struct{}
+
+func (r *vulnerabilityReportRenderer) Schema() *gollm.Schema {
+	return schemaFor(VulnerabilityReport{})
+}
+
+func (r *vulnerabilityReportRenderer) RenderForLLM(result TypedResult) (map[string]any, error) {
+	return ToolResultToMap(result)
+}
+
+func (r *vulnerabilityReportRenderer) RenderForUI(result TypedResult) ui.Block {
+	report := result.(*VulnerabilityReport)
+
+	var counts []string
+	for _, severity := range []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "UNKNOWN"} {
+		if n := report.SeverityCounts[severity]; n != 0 {
+			counts = append(counts, fmt.Sprintf("%s: %d", severity, n))
+		}
+	}
+	title := fmt.Sprintf("vulnerabilities in %s", report.Image)
+	if len(counts) == 0 {
+		title += " (none found)"
+	} else {
+		title += " (" + strings.Join(counts, ", ") + ")"
+	}
+
+	var rows [][]string
+	for _, v := range report.Vulnerabilities {
+		rows = append(rows, []string{v.ID, v.Severity, v.Package, v.InstalledVersion, v.FixedVersion, v.Title})
+	}
+	if report.Omitted != 0 {
+		rows = append(rows, []string{fmt.Sprintf("... and %d more", report.Omitted)})
+	}
+	return ui.NewTableBlock().
+		SetTitle(title).
+		SetTable([]string{"ID", "SEVERITY", "PACKAGE", "INSTALLED", "FIXED", "TITLE"}, rows)
 }
 
 func parseFunctionArgs(functionArgs map[string]any, task any) error {
@@ -0,0 +1,218 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/kubectl-utils/pkg/expect"
+	"github.com/GoogleCloudPlatform/kubectl-ai/kubectl-utils/pkg/kube"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui"
+	"k8s.io/klog/v2"
+)
+
+func init() {
+	RegisterTool(&WaitForCondition{})
+	RegisterResultRenderer(ResultTypeConditionWait, &conditionWaitRenderer{})
+}
+
+const (
+	ResultTypeConditionWait = "condition_wait"
+
+	// defaultConditionTimeout and maxConditionTimeout bound how long wait_for_condition waits.
+	defaultConditionTimeout = time.Minute
+	maxConditionTimeout     = 10 * time.Minute
+
+	// maxConditionProgress is how many intermediate values are kept in the result.
+	maxConditionProgress = 20
+)
+
+// ConditionWait is the result of waiting for an object to satisfy a CEL expression.
+type ConditionWait struct {
+	Target    string `json:"target"`
+	Namespace string `json:"namespace,omitempty"`
+	Condition string `json:"condition"`
+	// Met is true if the condition was satisfied; otherwise we timed out.
+	Met     bool   `json:"met"`
+	Elapsed string `json:"elapsed"`
+	// Status is the last value of the parts of the condition, e.g. "self.status.readyReplicas=1".
+	Status string `json:"status,omitempty"`
+	// Progress are the values while we waited, as "<elapsed>: <status>", most recent last.
+	Progress []string `json:"progress,omitempty"`
+	Message  string   `json:"message"`
+}
+
+func (r *ConditionWait) ResultType() string {
+	return ResultTypeConditionWait
+}
+
+// WaitForCondition is the tool that waits for an object to satisfy a CEL expression, watching it.
+type WaitForCondition struct{}
+
+func (t *WaitForCondition) Name() string {
+	return "wait_for_condition"
+}
+
+func (t *WaitForCondition) Description() string {
+	return "Waits until a kubernetes object satisfies a CEL expression, or until a timeout, and reports the values seen while waiting. " +
+		"Use it to wait for a change to take effect, e.g. for a deployment to become ready, rather than running kubectl get repeatedly."
+}
+
+// IsReadOnly implements ReadOnlyTool.
+func (t *WaitForCondition) IsReadOnly() bool {
+	return true
+}
+
+func (t *WaitForCondition) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"target": {
+					Type:        gollm.TypeString,
+					Description: `The object to wait for, as <kind or resource>/<name>, e.g. "Deployment/web", "deploy/web" or "pod/web-0".`,
+				},
+				"namespace": {
+					Type:        gollm.TypeString,
+					Description: `The namespace of the object. Defaults to the current namespace.`,
+				},
+				"condition": {
+					Type: gollm.TypeString,
+					Description: `A CEL expression that is true when the object is in the state to wait for, where self is the object. Examples:
+self.status.readyReplicas == self.spec.replicas
+self.status.phase == "Running"
+self.status.succeeded >= 1
+Fields that are not set yet (such as status.readyReplicas while no replicas are ready) make the condition false, rather than an error.`,
+				},
+				"timeout_seconds": {
+					Type:        gollm.TypeInteger,
+					Description: fmt.Sprintf("How long to wait, in seconds. Defaults to %d, at most %d.", int(defaultConditionTimeout.Seconds()), int(maxConditionTimeout.Seconds())),
+				},
+			},
+			Required: []string{"target", "condition"},
+		},
+	}
+}
+
+func (t *WaitForCondition) Run(ctx context.Context, args map[string]any) (any, error) {
+	target, err := stringArgument(args, "target")
+	if err != nil {
+		return nil, err
+	}
+	conditionText, err := stringArgument(args, "condition")
+	if err != nil {
+		return nil, err
+	}
+	namespace, _ := args["namespace"].(string)
+	timeout := conditionTimeout(args)
+
+	kubeconfig := kubeconfigFromContext(ctx)
+	if kubeconfig != "" {
+		kubeconfig, err = expandShellVar(kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	client, err := kube.NewClient(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	condition, err := expect.NewCondition(ctx, conditionText)
+	if err != nil {
+		return nil, err
+	}
+	waitTarget, err := expect.ParseTarget(ctx, client, target, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ConditionWait{
+		Target:    waitTarget.String(),
+		Namespace: waitTarget.Namespace,
+		Condition: conditionText,
+	}
+	onProgress := func(progress expect.Progress) {
+		klog.V(2).Infof("waiting for %s to satisfy %q: %s", waitTarget, conditionText, progress.Status)
+		if len(result.Progress) == maxConditionProgress {
+			result.Progress = result.Progress[1:]
+		}
+		result.Progress = append(result.Progress, fmt.Sprintf("%s: %s", progress.Elapsed.Round(time.Second), progress.Status))
+	}
+
+	outcome, err := expect.Wait(ctx, client, waitTarget, condition, expect.Options{Timeout: timeout, OnProgress: onProgress})
+	if err != nil {
+		return nil, err
+	}
+	result.Met = outcome.Met
+	result.Elapsed = outcome.Elapsed.Round(time.Second).String()
+	result.Status = outcome.Status
+	if outcome.Met {
+		result.Message = fmt.Sprintf("%s satisfied the condition after %s.", result.Target, result.Elapsed)
+	} else {
+		result.Message = fmt.Sprintf("Timed out after %s: %s did not satisfy the condition; the progress shows the values while waiting.", timeout, result.Target)
+	}
+	return result, nil
+}
+
+// conditionTimeout returns how long to wait, from the timeout_seconds argument: defaultConditionTimeout if it
+// is not set (or not positive), and at most maxConditionTimeout.
+func conditionTimeout(args map[string]any) time.Duration {
+	timeout := defaultConditionTimeout
+	switch seconds := args["timeout_seconds"].(type) {
+	// Clamped before converting, so that large values don't overflow
+	case float64:
+		timeout = time.Duration(min(seconds, maxConditionTimeout.Seconds()) * float64(time.Second))
+	case int:
+		timeout = time.Duration(min(seconds, int(maxConditionTimeout.Seconds()))) * time.Second
+	}
+	if timeout <= 0 {
+		timeout = defaultConditionTimeout
+	}
+	return min(timeout, maxConditionTimeout)
+}
+
+type conditionWaitRenderer struct{}
+
+func (r *conditionWaitRenderer) Schema() *gollm.Schema {
+	return schemaFor(ConditionWait{})
+}
+
+func (r *conditionWaitRenderer) RenderForLLM(result TypedResult) (map[string]any, error) {
+	return ToolResultToMap(result)
+}
+
+func (r *conditionWaitRenderer) RenderForUI(result TypedResult) ui.Block {
+	wait := result.(*ConditionWait)
+	title := fmt.Sprintf("%s: condition met after %s", wait.Target, wait.Elapsed)
+	if !wait.Met {
+		title = fmt.Sprintf("%s: timed out after %s", wait.Target, wait.Elapsed)
+	}
+	var rows [][]string
+	for _, progress := range wait.Progress {
+		rows = append(rows, []string{progress})
+	}
+	if wait.Status != "" {
+		rows = append(rows, []string{"last: " + wait.Status})
+	}
+	return ui.NewTableBlock().
+		SetTitle(title).
+		SetTable([]string{wait.Condition}, rows)
+}
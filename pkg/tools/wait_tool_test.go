@@ -0,0 +1,222 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// configMap returns a ConfigMap named web in the default namespace, with a count.
+func configMap(count int) map[string]any {
+	return map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "web", "namespace": "default", "resourceVersion": strconv.Itoa(count + 1)},
+		"data":       map[string]any{"count": strconv.Itoa(count)},
+	}
+}
+
+// newFakeAPIServer serves the discovery, list and watch requests of wait_for_condition, for a single ConfigMap
+// named web in the default namespace, whose count is 0. Watches send updates counting up to updates, then no more events.
+func newFakeAPIServer(t *testing.T, updates int) *httptest.Server {
+	t.Helper()
+
+	responses := map[string]any{
+		"/api":  map[string]any{"kind": "APIVersions", "versions": []string{"v1"}},
+		"/apis": map[string]any{"kind": "APIGroupList", "apiVersion": "v1", "groups": []any{}},
+		"/api/v1": map[string]any{
+			"kind":         "APIResourceList",
+			"groupVersion": "v1",
+			"resources": []any{map[string]any{
+				"name":         "configmaps",
+				"singularName": "configmap",
+				"namespaced":   true,
+				"kind":         "ConfigMap",
+				"verbs":        []string{"get", "list", "watch"},
+				"shortNames":   []string{"cm"},
+			}},
+		},
+		"/api/v1/namespaces/default/configmaps": map[string]any{
+			"apiVersion": "v1",
+			"kind":       "ConfigMapList",
+			"metadata":   map[string]any{"resourceVersion": "1"},
+			"items":      []any{configMap(0)},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			w.WriteHeader(http.StatusOK)
+			encoder := json.NewEncoder(w)
+			for count := 1; count <= updates; count++ {
+				encoder.Encode(map[string]any{"type": "MODIFIED", "object": configMap(count)})
+			}
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// writeKubeconfig writes a kubeconfig for the server.
+func writeKubeconfig(t *testing.T, server string) string {
+	t.Helper()
+
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: fake
+  cluster:
+    server: %s
+contexts:
+- name: fake
+  context:
+    cluster: fake
+    user: fake
+    namespace: default
+users:
+- name: fake
+  user: {}
+current-context: fake
+`, server)
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConditionTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		seconds any
+		want    time.Duration
+	}{
+		{name: "not set", seconds: nil, want: defaultConditionTimeout},
+		{name: "from JSON", seconds: float64(30), want: 30 * time.Second},
+		{name: "fraction", seconds: 1.5, want: 1500 * time.Millisecond},
+		{name: "int", seconds: 5, want: 5 * time.Second},
+		{name: "zero", seconds: float64(0), want: defaultConditionTimeout},
+		{name: "negative", seconds: -10, want: defaultConditionTimeout},
+		{name: "too long", seconds: float64(3600), want: maxConditionTimeout},
+		{name: "too long to convert", seconds: 1e20, want: maxConditionTimeout},
+		{name: "too long int", seconds: int(^uint(0) >> 1), want: maxConditionTimeout},
+		{name: "string", seconds: "30", want: defaultConditionTimeout},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := map[string]any{"target": "cm/web", "condition": "true"}
+			if test.seconds != nil {
+				args["timeout_seconds"] = test.seconds
+			}
+			if got := conditionTimeout(args); got != test.want {
+				t.Errorf("got timeout %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWaitForCondition(t *testing.T) {
+	tests := []struct {
+		name      string
+		updates   int
+		condition string
+		wantMet   bool
+		// wantProgress are the first and last progress values, and how many there are; progress is not checked
+		// if wantProgress is 0, as the values seen before the condition is met depend on when the watch starts
+		wantFirstProgress string
+		wantLastProgress  string
+		wantProgress      int
+	}{
+		{
+			name:      "met straight away",
+			condition: `self.data.count == "0"`,
+			wantMet:   true,
+		},
+		{
+			name:      "met after updates",
+			updates:   5,
+			condition: `self.data.count == "5"`,
+			wantMet:   true,
+		},
+		{
+			// Only the most recent values are kept
+			name:              "timed out",
+			updates:           maxConditionProgress + 10,
+			condition:         `self.data.count == "done"`,
+			wantFirstProgress: "self.data.count=11",
+			wantLastProgress:  fmt.Sprintf("self.data.count=%d", maxConditionProgress+10),
+			wantProgress:      maxConditionProgress,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newFakeAPIServer(t, test.updates)
+			ctx := context.WithValue(context.Background(), "kubeconfig", writeKubeconfig(t, server.URL))
+
+			response, err := (&WaitForCondition{}).Run(ctx, map[string]any{
+				"target":          "cm/web",
+				"condition":       test.condition,
+				"timeout_seconds": float64(1),
+			})
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			wait := response.(*ConditionWait)
+			if wait.Met != test.wantMet || wait.Target != "ConfigMap/web" || wait.Namespace != "default" {
+				t.Fatalf("got result %+v, want met %t for ConfigMap/web in default", wait, test.wantMet)
+			}
+			if test.wantMet && !strings.Contains(wait.Message, "satisfied the condition") ||
+				!test.wantMet && !strings.Contains(wait.Message, "Timed out after 1s") {
+				t.Errorf("got message %q", wait.Message)
+			}
+
+			if test.updates == 0 && len(wait.Progress) != 0 {
+				t.Errorf("got progress %q, want none for a condition met straight away", wait.Progress)
+			}
+			if test.wantProgress == 0 {
+				return
+			}
+			if len(wait.Progress) != test.wantProgress {
+				t.Fatalf("got %d progress values %q, want %d", len(wait.Progress), wait.Progress, test.wantProgress)
+			}
+			if first := wait.Progress[0]; !strings.HasSuffix(first, ": "+test.wantFirstProgress) {
+				t.Errorf("got first progress %q, want %q", first, test.wantFirstProgress)
+			}
+			if last := wait.Progress[len(wait.Progress)-1]; !strings.HasSuffix(last, ": "+test.wantLastProgress) {
+				t.Errorf("got last progress %q, want %q", last, test.wantLastProgress)
+			}
+		})
+	}
+}
@@ -196,3 +196,108 @@ func (b *InputOptionBlock) Document() *Document {
 func (b *InputOptionBlock) Observable() *Observable[string] {
 	return &b.text
 }
+
+// TableBlock is used to render tabular data, such as a list of resources returned by a tool
+type TableBlock struct {
+	doc *Document
+
+	// title is an optional heading shown above the table
+	title string
+
+	columns []string
+	rows    [][]string
+}
+
+func NewTableBlock() *TableBlock {
+	return &TableBlock{}
+}
+
+func (b *TableBlock) attached(doc *Document) {
+	b.doc = doc
+}
+
+func (b *TableBlock) Document() *Document {
+	return b.doc
+}
+
+func (b *TableBlock) Title() string {
+	return b.title
+}
+
+func (b *TableBlock) Columns() []string {
+	return b.columns
+}
+
+func (b *TableBlock) Rows() [][]string {
+	return b.rows
+}
+
+func (b *TableBlock) SetTitle(title string) *TableBlock {
+	b.title = title
+	b.doc.blockChanged(b)
+	return b
+}
+
+func (b *TableBlock) SetTable(columns []string, rows [][]string) *TableBlock {
+	b.columns = columns
+	b.rows = rows
+	b.doc.blockChanged(b)
+	return b
+}
+
+// ThinkingBlock is used to render the reasoning ("thinking") of the LLM, which is kept separate from its answer.
+// It is collapsed by default, in which case only a summary is shown.
+type ThinkingBlock struct {
+	doc *Document
+
+	// text is populated with the reasoning
+	text string
+
+	// collapsed is true if only a summary of the reasoning should be shown
+	collapsed bool
+
+	// streaming is true if we are still streaming results in
+	streaming bool
+}
+
+func NewThinkingBlock() *ThinkingBlock {
+	return &ThinkingBlock{collapsed: true}
+}
+
+func (b *ThinkingBlock) attached(doc *Document) {
+	b.doc = doc
+}
+
+func (b *ThinkingBlock) Document() *Document {
+	return b.doc
+}
+
+func (b *ThinkingBlock) Text() string {
+	return b.text
+}
+
+func (b *ThinkingBlock) Collapsed() bool {
+	return b.collapsed
+}
+
+func (b *ThinkingBlock) Streaming() bool {
+	return b.streaming
+}
+
+func (b *ThinkingBlock) SetCollapsed(collapsed bool) *ThinkingBlock {
+	b.collapsed = collapsed
+	b.doc.blockChanged(b)
+	return b
+}
+
+func (b *ThinkingBlock) SetStreaming(streaming bool) *ThinkingBlock {
+	b.streaming = streaming
+	b.doc.blockChanged(b)
+	return b
+}
+
+func (b *ThinkingBlock) AppendText(text string) *ThinkingBlock {
+	b.text = b.text + text
+	b.doc.blockChanged(b)
+	return b
+}
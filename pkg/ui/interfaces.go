@@ -30,6 +30,7 @@ const (
 	ColorGreen ColorValue = "green"
 	ColorWhite            = "white"
 	ColorRed              = "red"
+	ColorGray             = "gray"
 )
 
 type StyleOption func(s *style)
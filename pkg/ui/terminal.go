@@ -24,6 +24,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
 	"github.com/charmbracelet/glamour"
@@ -134,6 +135,11 @@ func (u *TerminalUI) DocumentChanged(doc *Document, block Block) {
 	blockIndex := doc.IndexOf(block)
 
 	if blockIndex != doc.NumBlocks()-1 {
+		// A thinking block is expanded or collapsed after it was rendered; we can't rewrite the screen, so we render it again.
+		if block, ok := block.(*ThinkingBlock); ok && !block.Streaming() {
+			u.rerenderThinking(block)
+			return
+		}
 		klog.Warningf("update to blocks other than the last block is not supported in terminal mode")
 		return
 	}
@@ -157,6 +163,14 @@ func (u *TerminalUI) DocumentChanged(doc *Document, block Block) {
 	case *FunctionCallRequestBlock:
 		styleOptions = append(styleOptions, Foreground(ColorGreen))
 		text = block.Text()
+	case *TableBlock:
+		text = formatTable(block)
+	case *ThinkingBlock:
+		styleOptions = append(styleOptions, Foreground(ColorGray))
+		// The reasoning is shown once complete, so that a collapsed block can show its size
+		if !block.Streaming() {
+			text = formatThinking(block)
+		}
 	case *AgentTextBlock:
 		styleOptions = append(styleOptions, RenderMarkdown())
 		if block.Color != "" {
@@ -300,6 +314,10 @@ func (u *TerminalUI) DocumentChanged(doc *Document, block Block) {
 	}
 	u.currentBlockText = text
 
+	if printText == "" {
+		return
+	}
+
 	reset := ""
 	switch computedStyle.foreground {
 	case ColorRed:
@@ -311,6 +329,9 @@ func (u *TerminalUI) DocumentChanged(doc *Document, block Block) {
 	case ColorWhite:
 		fmt.Printf("\033[37m")
 		reset += "\033[0m"
+	case ColorGray:
+		fmt.Printf("\033[90m")
+		reset += "\033[0m"
 
 	case "":
 	default:
@@ -354,6 +375,9 @@ func (u *TerminalUI) RenderOutput(ctx context.Context, s string, styleOptions ..
 	case ColorWhite:
 		fmt.Printf("\033[37m")
 		reset += "\033[0m"
+	case ColorGray:
+		fmt.Printf("\033[90m")
+		reset += "\033[0m"
 
 	case "":
 	default:
@@ -363,6 +387,55 @@ func (u *TerminalUI) RenderOutput(ctx context.Context, s string, styleOptions ..
 	fmt.Printf("%s%s", s, reset)
 }
 
+// rerenderThinking prints a thinking block that was already rendered again, below the current output.
+func (u *TerminalUI) rerenderThinking(block *ThinkingBlock) {
+	text := formatThinking(block)
+	if text == "" {
+		return
+	}
+	if u.currentBlockText != "" {
+		fmt.Printf("\n")
+	}
+	// Output for the next block starts on a new line
+	u.currentBlock = block
+	u.currentBlockText = text
+	fmt.Printf("\033[90m%s\033[0m", text)
+}
+
+// formatThinking renders a ThinkingBlock; a collapsed block is summarized in a single line.
+func formatThinking(block *ThinkingBlock) string {
+	thinking := strings.TrimSpace(block.Text())
+	if thinking == "" {
+		return ""
+	}
+	if block.Collapsed() {
+		return fmt.Sprintf("  (thought for %d words)\n", len(strings.Fields(thinking)))
+	}
+	var sb strings.Builder
+	sb.WriteString("  Thinking:\n")
+	for _, line := range strings.Split(thinking, "\n") {
+		sb.WriteString("  │ " + line + "\n")
+	}
+	return sb.String()
+}
+
+// formatTable renders a TableBlock as aligned columns of plain text.
+func formatTable(block *TableBlock) string {
+	var sb strings.Builder
+	if title := block.Title(); title != "" {
+		fmt.Fprintf(&sb, "  %s\n", title)
+	}
+	w := tabwriter.NewWriter(&sb, 0, 0, 3, ' ', 0)
+	if columns := block.Columns(); len(columns) != 0 {
+		fmt.Fprintf(w, "  %s\n", strings.Join(columns, "\t"))
+	}
+	for _, row := range block.Rows() {
+		fmt.Fprintf(w, "  %s\n", strings.Join(row, "\t"))
+	}
+	w.Flush()
+	return sb.String()
+}
+
 func (u *TerminalUI) ClearScreen() {
 	fmt.Print("\033[H\033[2J")
 }
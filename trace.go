@@ -0,0 +1,157 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/spf13/cobra"
+)
+
+// traceOptions are the flags of the `trace` command.
+type traceOptions struct {
+	summary bool
+	kinds   []string
+	tools   []string
+	errors  bool
+}
+
+// newTraceCommand builds the `trace` command, which shows the timeline of a trace.
+func newTraceCommand(opt *Options) *cobra.Command {
+	traceOpt := &traceOptions{}
+	traceCmd := &cobra.Command{
+		Use:   "trace [path]",
+		Short: "Show the timeline of a trace",
+		Long:  "trace shows each LLM turn and tool call in a trace (as written with --trace-path), with its duration, errors and token counts. It defaults to the trace configured with --trace-path (or the tracePath setting).",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := opt.TracePath
+			if len(args) == 1 {
+				path = args[0]
+			}
+			if path == "" {
+				return fmt.Errorf("no trace given, and none is configured")
+			}
+			for _, kind := range traceOpt.kinds {
+				if kind != journal.StepLLM && kind != journal.StepTool && kind != journal.StepPlan {
+					return fmt.Errorf("unknown kind %q (supported kinds are %q, %q and %q)", kind, journal.StepLLM, journal.StepTool, journal.StepPlan)
+				}
+			}
+
+			events, err := journal.ParseEventsFromFile(path)
+			if err != nil {
+				return err
+			}
+			steps := filterSteps(journal.Timeline(events), traceOpt)
+
+			out := cmd.OutOrStdout()
+			if !traceOpt.summary {
+				if err := printTimeline(out, steps); err != nil {
+					return err
+				}
+				fmt.Fprintln(out)
+			}
+			return printSummary(out, journal.Summarize(steps))
+		},
+	}
+
+	f := traceCmd.Flags()
+	f.BoolVar(&traceOpt.summary, "summary", false, "only show the summary, not the timeline")
+	f.StringSliceVar(&traceOpt.kinds, "kind", nil, "only show steps of these kinds (llm, tool or plan)")
+	f.StringSliceVar(&traceOpt.tools, "tool", nil, "only show calls to these tools (e.g. kubectl)")
+	f.BoolVar(&traceOpt.errors, "errors", false, "only show steps that failed")
+
+	return traceCmd
+}
+
+// filterSteps returns the steps that match the filters in opt.
+func filterSteps(steps []*journal.Step, opt *traceOptions) []*journal.Step {
+	var filtered []*journal.Step
+	for _, step := range steps {
+		if len(opt.kinds) != 0 && !slices.Contains(opt.kinds, step.Kind) {
+			continue
+		}
+		if len(opt.tools) != 0 && (step.Kind != journal.StepTool || !slices.Contains(opt.tools, step.Name)) {
+			continue
+		}
+		if opt.errors && step.Error == "" {
+			continue
+		}
+		filtered = append(filtered, step)
+	}
+	return filtered
+}
+
+func printTimeline(out io.Writer, steps []*journal.Step) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tDURATION\tKIND\tTOKENS\tDETAILS")
+	var start time.Time
+	for _, step := range steps {
+		if start.IsZero() {
+			start = step.Start
+		}
+		tokens := "-"
+		if step.Tokens != nil {
+			tokens = fmt.Sprintf("%d (%d in, %d out)", step.Tokens.Total, step.Tokens.Input, step.Tokens.Output)
+		}
+		kind := step.Kind
+		if step.Name != "" {
+			kind += ":" + step.Name
+		}
+		fmt.Fprintf(w, "+%s\t%s\t%s\t%s\t%s\n", formatDuration(step.Start.Sub(start)), formatDuration(step.Duration), kind, tokens, step.Description)
+		if step.Error != "" {
+			fmt.Fprintf(w, "\t\t\t\terror: %s\n", step.Error)
+		}
+	}
+	return w.Flush()
+}
+
+func printSummary(out io.Writer, summary *journal.Summary) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	if !summary.Start.IsZero() {
+		fmt.Fprintf(w, "Started:\t%s\n", summary.Start.Format(time.RFC3339))
+	}
+	fmt.Fprintf(w, "Duration:\t%s\n", formatDuration(summary.Duration))
+	fmt.Fprintf(w, "LLM turns:\t%d (%s)\n", summary.LLMTurns, formatDuration(summary.LLMDuration))
+	if summary.Tokens.Total != 0 {
+		fmt.Fprintf(w, "Tokens:\t%d (%d in, %d out)\n", summary.Tokens.Total, summary.Tokens.Input, summary.Tokens.Output)
+	} else {
+		fmt.Fprintf(w, "Tokens:\tnot reported\n")
+	}
+	fmt.Fprintf(w, "Tool calls:\t%d (%s)\n", summary.ToolCalls, formatDuration(summary.ToolDuration))
+	for _, tool := range summary.Tools {
+		fmt.Fprintf(w, "  %s:\t%d calls, %d failed (%s)\n", tool.Name, tool.Calls, tool.Errors, formatDuration(tool.Duration))
+	}
+	if summary.Plans != 0 {
+		fmt.Fprintf(w, "Plans:\t%d\n", summary.Plans)
+	}
+	fmt.Fprintf(w, "Errors:\t%d\n", summary.Errors)
+	return w.Flush()
+}
+
+// formatDuration rounds durations for display.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	default:
+		return d.Round(10 * time.Millisecond).String()
+	}
+}